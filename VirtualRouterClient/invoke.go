@@ -0,0 +1,36 @@
+package VirtualRouterClient
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+const DefaultInvokeTimeout = rpc.DefaultInvokeTimeout
+
+// Invoke 以单个请求对象调用远端 RPC，并把返回值反序列化为 Resp
+func Invoke[Req, Resp any](provider ServiceProvider, packetId int, req Req) (Resp, error) {
+	return rpc.Invoke[Req, Resp](provider, packetId, req)
+}
+
+// InvokeTimeout 同 Invoke，可指定超时
+func InvokeTimeout[Req, Resp any](provider ServiceProvider, packetId int, timeout time.Duration, req Req) (Resp, error) {
+	return rpc.InvokeTimeout[Req, Resp](provider, packetId, timeout, req)
+}
+
+// InvokeArgs 以任意数量的参数调用远端 RPC（rpc-gen 生成的客户端桩使用）
+func InvokeArgs[Resp any](provider ServiceProvider, packetId int, timeout time.Duration, args ...any) (Resp, error) {
+	return rpc.InvokeArgs[Resp](provider, packetId, timeout, args...)
+}
+
+// InvokeArgsContext 同 InvokeArgs，并把 ctx 中的链路（traceparent）传给目标节点
+func InvokeArgsContext[Resp any](ctx context.Context, provider ServiceProvider, packetId int, timeout time.Duration, args ...any) (Resp, error) {
+	return rpc.InvokeArgsContext[Resp](ctx, provider, packetId, timeout, args...)
+}
+
+// MarshalArgs 把参数列表序列化为 ServiceProvider.Call 所需的 []json.RawMessage
+func MarshalArgs(args ...any) ([]json.RawMessage, error) {
+	return rpc.MarshalArgs(args...)
+}
@@ -2,14 +2,23 @@ package VirtualRouterClient
 
 import (
 	"encoding/json"
+	"log/slog"
 
 	"github.com/neko233-com/virtual-router-go/internal/core"
 	"github.com/neko233-com/virtual-router-go/internal/rpc"
 )
 
-// RegisterRpcStub 注册 RPC Stub（供外部服务实现注册）
+// RegisterRpcStub 注册 RPC Stub（供外部服务实现注册），packetId 重复时记录错误日志并保留已注册的 Stub；
+// 需要处理冲突时使用 TryRegisterRpcStub
 func RegisterRpcStub(meta RpcStubMetadata, handler func(args []json.RawMessage) (any, error)) {
-	rpc.ServerStubManagerInstance().RegisterStub(core.RpcStubMetadata(meta), handler)
+	if err := TryRegisterRpcStub(meta, handler); err != nil {
+		slog.Error("注册 RPC Stub 失败", "packetId", meta.PacketId, "class", meta.ClassName, "method", meta.MethodName, "error", err)
+	}
+}
+
+// TryRegisterRpcStub 注册 RPC Stub，packetId 重复时返回错误（可用 errors.Is 判断 ErrDuplicatePacketId）
+func TryRegisterRpcStub(meta RpcStubMetadata, handler func(args []json.RawMessage) (any, error)) error {
+	return rpc.ServerStubManagerInstance().RegisterStub(core.RpcStubMetadata(meta), handler)
 }
 
 // RegisterRpcFunc 使用函数签名自动完成参数反序列化和元数据注册
@@ -21,3 +30,14 @@ func RegisterRpcFunc(meta RpcFuncMeta, fn any) error {
 func EnsureStubInitialized() {
 	rpc.ServerStubManagerInstance().EnsureInitialized()
 }
+
+// RegisterRpcService 把结构体的导出方法按 packetIds（方法名 -> packetId）注册为 RPC Stub，ClassName 为结构体名；
+// packetIds 为空时使用 RpcService 接口或字段上的 packetId:"方法名=packetId" 标签
+func RegisterRpcService(instance any, packetIds map[string]int) error {
+	return rpc.ServerStubManagerInstance().RegisterService(instance, packetIds)
+}
+
+// UnregisterRpcService 运行时注销服务，下一次心跳会向 Router Center 上报新的 Stub 列表
+func UnregisterRpcService(serviceName string) error {
+	return rpc.ServerStubManagerInstance().UnregisterService(serviceName)
+}
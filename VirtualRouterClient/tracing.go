@@ -0,0 +1,35 @@
+package VirtualRouterClient
+
+import (
+	"context"
+	"io"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
+)
+
+type TracingConfig = config.TracingConfig
+
+type TracingExporter = tracing.Exporter
+
+type TracingSpanData = tracing.SpanData
+
+// SetTracingExporter 安装自定义链路追踪 Exporter，传 nil 关闭导出
+func SetTracingExporter(exp TracingExporter) {
+	tracing.SetExporter(exp)
+}
+
+// NewStdoutTracingExporter 每个 Span 输出一行 JSON 到 w
+func NewStdoutTracingExporter(w io.Writer, serviceName string) TracingExporter {
+	return tracing.NewStdoutExporter(w, serviceName)
+}
+
+// NewOTLPTracingExporter 以 OTLP/HTTP JSON 推送到 Collector
+func NewOTLPTracingExporter(endpoint, serviceName string, headers map[string]string) TracingExporter {
+	return tracing.NewOTLPHTTPExporter(endpoint, serviceName, headers)
+}
+
+// ContextWithTraceparent 把上游的 traceparent（例如 HTTP 请求头）放入 ctx，配合 InvokeArgsContext 使用
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return tracing.ContextWithTraceparent(ctx, traceparent)
+}
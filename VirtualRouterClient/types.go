@@ -17,6 +17,10 @@ type RpcParamMeta = rpc.RpcParamMeta
 
 type RpcFuncMeta = rpc.RpcFuncMeta
 
+type RpcService = rpc.RpcService
+
+type RpcServiceDescriber = rpc.RpcServiceDescriber
+
 const (
 	RouteMessageTypeHeartBeat       = core.RouteMessageTypeHeartBeat
 	RouteMessageTypeMessageData     = core.RouteMessageTypeMessageData
@@ -25,3 +29,6 @@ const (
 	RouteMessageTypeRpcResponse     = core.RouteMessageTypeRpcResponse
 	RouteMessageTypeSystemError     = core.RouteMessageTypeSystemError
 )
+
+// ErrDuplicatePacketId 注册的 packetId 已被占用
+var ErrDuplicatePacketId = rpc.ErrDuplicatePacketId
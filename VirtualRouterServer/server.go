@@ -13,6 +13,24 @@ type HttpServer = server.HttpServer
 
 type RouterServerConfig = config.RouterServerConfig
 
+type GatewayApiKey = config.GatewayApiKey
+
+type TracingConfig = config.TracingConfig
+
+type LogConfig = config.LogConfig
+
+type AdminUser = config.AdminUser
+
+type GatewayError = server.GatewayError
+
+type GatewayResult = server.GatewayResult
+
+type AuditEntry = server.AuditEntry
+
+type JWTConfig = config.JWTConfig
+
+type BuildInfo = server.BuildInfo
+
 func NewServer(cfg *config.RouterServerConfig) *server.Server {
 	return server.NewServer(cfg)
 }
@@ -29,6 +47,21 @@ func InstallProcessLogCapture(capacity int) {
 	server.InstallProcessLogCapture(capacity)
 }
 
+// ConfigureProcessLogs 按配置重新安装进程日志（格式、级别、文件轮转）
+func ConfigureProcessLogs(cfg *config.LogConfig) error {
+	return server.ConfigureProcessLogs(cfg)
+}
+
+// CurrentBuildInfo 版本号、提交与 Go 版本
+func CurrentBuildInfo() BuildInfo {
+	return server.CurrentBuildInfo()
+}
+
+// SetLogLevel 运行时修改默认或某个组件的日志级别
+func SetLogLevel(component, level string) error {
+	return server.SetLogLevel(component, level)
+}
+
 func StartServer(ctx context.Context, cfg *config.RouterServerConfig) (*server.Server, *server.HttpServer, error) {
 	srv := server.NewServer(cfg)
 	httpSrv := server.NewHttpServer(cfg, srv)
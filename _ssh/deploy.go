@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
@@ -106,7 +107,7 @@ func buildBinary(cfg *Config) (string, error) {
 		tempName += ".exe"
 	}
 
-	cmd := exec.Command("go", "build", "-o", tempName, cfg.BuildPath)
+	cmd := exec.Command("go", "build", "-trimpath", "-ldflags", buildLdflags(), "-o", tempName, cfg.BuildPath)
 	cmd.Env = append(os.Environ(),
 		"GOOS="+cfg.OS,
 		"GOARCH="+cfg.Arch,
@@ -124,6 +125,21 @@ func buildBinary(cfg *Config) (string, error) {
 	return absPath, nil
 }
 
+// buildLdflags 与 一键打包全平台.ps1 相同的版本注入参数，部署出去的二进制在管理后台显示版本与提交
+func buildLdflags() string {
+	version := "unknown"
+	if data, err := os.ReadFile("version.txt"); err == nil {
+		version = strings.TrimSpace(string(data))
+	}
+	commit := "unknown"
+	if out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output(); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		commit = strings.TrimSpace(string(out))
+	}
+	buildTime := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	pkg := "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	return fmt.Sprintf("-s -w -X %s.buildVersion=%s -X %s.buildCommit=%s -X %s.buildTime=%s", pkg, version, pkg, commit, pkg, buildTime)
+}
+
 func dialSSH(cfg *Config) (*ssh.Client, error) {
 	clientConfig := &ssh.ClientConfig{
 		User: cfg.User,
@@ -18,6 +18,13 @@ func main() {
 		slog.Error("读取服务端配置失败", "error", err)
 		os.Exit(1)
 	}
+	if err := VirtualRouterServer.ConfigureProcessLogs(cfg.Log); err != nil {
+		slog.Error("日志配置错误", "error", err)
+		os.Exit(1)
+	}
+
+	build := VirtualRouterServer.CurrentBuildInfo()
+	slog.Info("Virtual Router Center 启动", "version", build.Version, "commit", build.Commit, "go", build.GoVersion)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -38,8 +45,18 @@ func main() {
 		}
 	}()
 
+	go httpSrv.WatchConfigFile(ctx, "")
+
+	// SIGHUP 立即重新加载配置文件，SIGINT/SIGTERM 退出
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			slog.Info("收到 SIGHUP，重新加载配置文件")
+			_, _ = httpSrv.ReloadConfigFile("")
+			continue
+		}
+		break
+	}
 	cancel()
 }
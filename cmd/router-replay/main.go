@@ -0,0 +1,114 @@
+// router-replay 回放 Router Center 录制的抓包文件（格式见 internal/capture 包文档）。
+//
+// 回放到测试 Router Center（每个会话一条 TCP 连接，按原始间隔发送）:
+//
+//	go run ./cmd/router-replay -file captures/bug-1234.jsonl -target center -addr 127.0.0.1:9999
+//
+// 只回放 RpcRequest，直接打到节点 direct 模式的 StubServer，10 倍速:
+//
+//	go run ./cmd/router-replay -file captures/bug-1234.jsonl -target stub -addr 127.0.0.1:20001 -speed 10
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/neko233-com/virtual-router-go/internal/capture"
+)
+
+func main() {
+	file := flag.String("file", "", "抓包文件路径 (必填)")
+	target := flag.String("target", "center", "回放目标: center (Router Center) / stub (节点 StubServer)")
+	addr := flag.String("addr", "127.0.0.1:9999", "回放目标地址 host:port")
+	speed := flag.Float64("speed", 1, "回放倍速，1 为原始节奏，0 表示不等待")
+	routeIds := flag.String("route", "", "只回放这些会话，逗号分隔")
+	packetIds := flag.String("packet", "", "只回放这些 packetId，逗号分隔")
+	quiet := flag.Bool("quiet", false, "不打印对端返回的帧")
+	flag.Parse()
+
+	if strings.TrimSpace(*file) == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	header, records, err := capture.ReadFile(*file)
+	if err != nil {
+		slog.Error("读取抓包文件失败", "file", *file, "error", err)
+		os.Exit(1)
+	}
+	records, err = filterRecords(records, *routeIds, *packetIds)
+	if err != nil {
+		slog.Error("过滤参数错误", "error", err)
+		os.Exit(2)
+	}
+	slog.Info("抓包文件已加载", "file", *file, "version", header.Version, "frames", len(records))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	opts := capture.ReplayOptions{Speed: *speed}
+	if !*quiet {
+		opts.OnFrame = func(session string, payload []byte) {
+			fmt.Printf("[%s] <- %s\n", session, capture.DescribeFrame(payload))
+		}
+	}
+
+	var result capture.ReplayResult
+	switch *target {
+	case "center":
+		result, err = capture.ReplayToCenter(ctx, *addr, records, opts)
+	case "stub":
+		result, err = capture.ReplayToStub(ctx, *addr, records, opts)
+	default:
+		slog.Error("未知的回放目标", "target", *target)
+		os.Exit(2)
+	}
+	if err != nil {
+		slog.Error("回放中断", "error", err, "sent", result.Sent)
+		os.Exit(1)
+	}
+	slog.Info("回放完成", "sent", result.Sent, "skipped", result.Skipped, "sessions", result.Sessions)
+}
+
+func filterRecords(records []capture.Record, routeIds, packetIds string) ([]capture.Record, error) {
+	routes := splitList(routeIds)
+	var packets []int
+	for _, item := range splitList(packetIds) {
+		v, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("packetId 必须为整数: %s", item)
+		}
+		packets = append(packets, v)
+	}
+	if len(routes) == 0 && len(packets) == 0 {
+		return records, nil
+	}
+	out := make([]capture.Record, 0, len(records))
+	for _, rec := range records {
+		if len(routes) > 0 && !slices.Contains(routes, rec.Session) {
+			continue
+		}
+		if len(packets) > 0 && !slices.Contains(packets, rec.PacketId) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+// rpc-gen 根据带 packetId 注解的 Go 接口生成 RPC 服务端注册函数与强类型客户端桩。
+//
+// 用法（在接口所在文件中）:
+//
+//	//go:generate go run github.com/neko233-com/virtual-router-go/cmd/rpc-gen -type=BattleService
+//	type BattleService interface {
+//		// Attack 发起攻击
+//		// rpc:packetId=1001
+//		// rpc:param req 攻击参数
+//		Attack(req AttackReq) (AttackResp, error)
+//	}
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpcgen"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "需要生成的接口名，逗号分隔；为空时处理文件内所有带注解的接口")
+	input := flag.String("file", os.Getenv("GOFILE"), "输入的 Go 源文件，默认取 go generate 提供的 $GOFILE")
+	output := flag.String("output", "", "输出文件，默认 <输入文件名>_rpc_gen.go")
+	flag.Parse()
+
+	if *input == "" {
+		slog.Error("缺少输入文件，请通过 -file 指定或在 go:generate 中使用")
+		os.Exit(1)
+	}
+	src, err := os.ReadFile(*input)
+	if err != nil {
+		slog.Error("读取输入文件失败", "file", *input, "error", err)
+		os.Exit(1)
+	}
+
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+	out, err := rpcgen.Generate(*input, src, names)
+	if err != nil {
+		slog.Error("生成 RPC 代码失败", "file", *input, "error", err)
+		os.Exit(1)
+	}
+
+	target := *output
+	if target == "" {
+		base := strings.TrimSuffix(filepath.Base(*input), ".go")
+		target = filepath.Join(filepath.Dir(*input), base+"_rpc_gen.go")
+	}
+	if err := os.WriteFile(target, out, 0644); err != nil {
+		slog.Error("写入生成文件失败", "file", target, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("RPC 代码生成完成", "file", target)
+}
@@ -0,0 +1,154 @@
+// rpc-schema 从 Router Center 的管理 API 导出整个集群的 RPC 目录（OpenAPI / JSON Schema），
+// 供客户端团队生成 SDK。
+//
+//	go run ./cmd/rpc-schema -center http://127.0.0.1:19999 -password neko233 -format openapi -mode node -out ./rpc-schema
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type schemaDocument struct {
+	Name     string          `json:"name"`
+	Document json.RawMessage `json:"document"`
+}
+
+func main() {
+	center := flag.String("center", "http://127.0.0.1:19999", "Router Center HTTP 管理地址")
+	username := flag.String("username", os.Getenv("ROUTER_ADMIN_USERNAME"), "管理后台账号，默认 admin，也可取 $ROUTER_ADMIN_USERNAME")
+	password := flag.String("password", os.Getenv("ROUTER_ADMIN_PASSWORD"), "管理员密码，默认取 $ROUTER_ADMIN_PASSWORD")
+	totpCode := flag.String("totp", "", "账号启用两步验证时的 6 位验证码")
+	token := flag.String("token", "", "已有的管理员 JWT，提供后不再登录")
+	format := flag.String("format", "openapi", "导出格式: openapi / jsonschema")
+	mode := flag.String("mode", "merged", "merged: 合并为一份文档; node: 每个节点一份")
+	routeId := flag.String("route", "", "只导出指定 routeId")
+	outDir := flag.String("out", "rpc-schema", "输出目录")
+	flag.Parse()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	base := strings.TrimRight(*center, "/")
+
+	authToken := *token
+	if authToken == "" {
+		t, err := login(client, base, *username, *password, *totpCode)
+		if err != nil {
+			slog.Error("登录 Router Center 失败", "center", base, "error", err)
+			os.Exit(1)
+		}
+		authToken = t
+	}
+
+	query := url.Values{}
+	query.Set("format", *format)
+	query.Set("mode", *mode)
+	if *routeId != "" {
+		query.Set("routeId", *routeId)
+	}
+	docs, err := fetchSchema(client, base+"/api/rpc/schema?"+query.Encode(), authToken)
+	if err != nil {
+		slog.Error("导出 RPC Schema 失败", "error", err)
+		os.Exit(1)
+	}
+	if len(docs) == 0 {
+		slog.Warn("没有可导出的 RPC 节点")
+		return
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		slog.Error("创建输出目录失败", "dir", *outDir, "error", err)
+		os.Exit(1)
+	}
+	for _, doc := range docs {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, doc.Document, "", "  "); err != nil {
+			slog.Error("格式化文档失败", "name", doc.Name, "error", err)
+			os.Exit(1)
+		}
+		target := filepath.Join(*outDir, sanitizeFileName(doc.Name)+"."+*format+".json")
+		if err := os.WriteFile(target, pretty.Bytes(), 0644); err != nil {
+			slog.Error("写入文档失败", "file", target, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("RPC Schema 已导出", "file", target)
+	}
+}
+
+func login(client *http.Client, base, username, password, totpCode string) (string, error) {
+	if password == "" {
+		return "", errors.New("请通过 -password 或 -token 提供认证信息")
+	}
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password, "totpCode": totpCode})
+	resp, err := client.Post(base+"/api/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if !result.Success {
+		return "", errors.New(result.Message)
+	}
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return "", err
+	}
+	return data.Token, nil
+}
+
+func fetchSchema(client *http.Client, target, token string) ([]schemaDocument, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, result.Message)
+	}
+	var data struct {
+		Documents []schemaDocument `json:"documents"`
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return nil, err
+	}
+	return data.Documents, nil
+}
+
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
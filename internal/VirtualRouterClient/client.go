@@ -1,6 +1,7 @@
 package VirtualRouterClient
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
@@ -14,6 +15,7 @@ import (
 	"github.com/neko233-com/virtual-router-go/internal/config"
 	"github.com/neko233-com/virtual-router-go/internal/core"
 	"github.com/neko233-com/virtual-router-go/internal/rpc"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 type Client struct {
@@ -27,6 +29,12 @@ type Client struct {
 	isOpen           atomic.Bool
 	reconnectAttempt atomic.Bool
 	stopCh           chan struct{}
+	tracingInstalled atomic.Bool
+
+	// sentStubsHash 最近一次完整心跳上报的 Stub 摘要，为空表示下一次必须发送完整心跳；
+	// liteAccepted 当前连接的 Router Center 支持轻量心跳（旧版本会把缺少 stubs 的心跳当成清空）。均由 writeMu 保护
+	sentStubsHash string
+	liteAccepted  bool
 }
 
 func NewClient(configFile string) (*Client, error) {
@@ -70,6 +78,13 @@ func (c *Client) Start() error {
 	if !c.needConnect.CompareAndSwap(false, true) {
 		return nil
 	}
+	if c.cfg.Tracing != nil && c.cfg.Tracing.Exporter != "" {
+		if err := tracing.Install(c.cfg.Tracing, c.routeId); err != nil {
+			c.needConnect.Store(false)
+			return err
+		}
+		c.tracingInstalled.Store(true)
+	}
 
 	c.runRouterClient()
 	c.runRpcServer()
@@ -104,7 +119,12 @@ func (c *Client) tryConnect() bool {
 		return false
 	}
 	c.closeConn()
+	c.writeMu.Lock()
 	c.conn = conn
+	c.sentStubsHash = ""
+	c.liteAccepted = false
+	c.writeMu.Unlock()
+	RouteTableInstance().ResetRouteVersion()
 	return true
 }
 
@@ -128,6 +148,7 @@ func (c *Client) startHeartbeat() {
 	}()
 }
 
+// sendHeartbeat 首次连接或 Stub 列表变化时发送完整心跳，其余只携带摘要
 func (c *Client) sendHeartbeat() bool {
 	if c.conn == nil {
 		return false
@@ -139,7 +160,25 @@ func (c *Client) sendHeartbeat() bool {
 		rpcHost = c.cfg.LocalRpcHost
 		rpcPort = c.cfg.LocalRpcPort
 	}
-	info := core.RpcServerInfo{Host: rpcHost, Port: rpcPort, Stubs: rpc.ServerStubManagerInstance().GetAllStubsMetadata()}
+	stubs := rpc.ServerStubManagerInstance().GetAllStubsMetadata()
+	info := core.RpcServerInfo{
+		Host:                    rpcHost,
+		Port:                    rpcPort,
+		Stubs:                   stubs,
+		HeartBeatIntervalSecond: c.cfg.HeartBeatIntervalSecond,
+		StubsHash:               core.StubsHash(stubs),
+		RouteVersion:            RouteTableInstance().RouteVersion(),
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return false
+	}
+	if c.liteAccepted && info.StubsHash == c.sentStubsHash {
+		info.Lite = true
+		info.Stubs = nil
+	}
 	b, _ := json.Marshal(info)
 	data := string(b)
 	mt := core.RouteMessageTypeHeartBeat
@@ -153,10 +192,11 @@ func (c *Client) sendHeartbeat() bool {
 	if err != nil {
 		return false
 	}
-	c.writeMu.Lock()
-	defer c.writeMu.Unlock()
-	_, err = c.conn.Write(core.EncodeFrame(payload))
-	return err == nil
+	if _, err = c.conn.Write(core.EncodeFrame(payload)); err != nil {
+		return false
+	}
+	c.sentStubsHash = info.StubsHash
+	return true
 }
 
 func (c *Client) readLoop() {
@@ -199,16 +239,48 @@ func (c *Client) handleMessage(msg *core.RouteMessage) bool {
 	return true
 }
 
+// handleRegister 处理心跳响应：旧版本 Router Center 返回完整 []RouteNode，新版本返回 HeartbeatAck
 func (c *Client) handleRegister(msg *core.RouteMessage) {
 	if msg.Data == nil {
 		return
 	}
-	var nodes []core.RouteNode
-	if err := json.Unmarshal([]byte(*msg.Data), &nodes); err != nil {
-		slog.Warn("init route info error", "error", err)
+	data := strings.TrimSpace(*msg.Data)
+	if !strings.HasPrefix(data, "{") {
+		var nodes []core.RouteNode
+		if err := json.Unmarshal([]byte(data), &nodes); err != nil {
+			slog.Warn("init route info error", "error", err)
+			return
+		}
+		RouteTableInstance().UpsertRouteNode(nodes)
+		return
+	}
+	var ack core.HeartbeatAck
+	if err := json.Unmarshal([]byte(data), &ack); err != nil {
+		slog.Warn("heartbeat ack parse error", "error", err)
 		return
 	}
-	RouteTableInstance().UpsertRouteNode(nodes)
+	c.writeMu.Lock()
+	c.liteAccepted = true
+	if ack.ResyncStubs {
+		c.sentStubsHash = ""
+	}
+	c.writeMu.Unlock()
+	resend := ack.ResyncStubs
+	if ack.ResyncStubs {
+		slog.Info("Router Center 要求重新上报完整 Stub 列表", "routeId", c.routeId)
+	}
+	if ack.Routes != nil && !RouteTableInstance().ApplyRouteDelta(*ack.Routes) {
+		// 漏收了路由表变更：清空版本号，立即心跳拉取完整路由表
+		slog.Warn("路由表版本不连续，重新拉取完整路由表", "routeId", c.routeId,
+			"local", RouteTableInstance().RouteVersion(), "from", ack.Routes.FromVersion, "version", ack.Routes.Version)
+		RouteTableInstance().ResetRouteVersion()
+		resend = true
+	}
+	if resend {
+		if !c.sendHeartbeat() {
+			c.onConnectionLost("heartbeat failed", nil)
+		}
+	}
 }
 
 func (c *Client) handleRemoveOffline(msg *core.RouteMessage) {
@@ -280,6 +352,11 @@ func (c *Client) startBackgroundReconnect() {
 }
 
 func (c *Client) Send(toRouteId string, msgType core.RouteMessageType, obj any) error {
+	return c.SendWithTraceparent(toRouteId, msgType, obj, "")
+}
+
+// SendWithTraceparent 同 Send，traceparent 随 RouteMessage 发送，Router Center 转发时无需解析 Data
+func (c *Client) SendWithTraceparent(toRouteId string, msgType core.RouteMessageType, obj any, traceparent string) error {
 	if !c.IsConnected() {
 		return errors.New("VirtualRouterClient 未连接到 Router Center，无法发送消息")
 	}
@@ -291,6 +368,7 @@ func (c *Client) Send(toRouteId string, msgType core.RouteMessageType, obj any)
 		ToRouteId:   toRouteId,
 		MessageType: &mt,
 		Data:        &data,
+		Traceparent: traceparent,
 	}
 
 	if toRouteId == c.routeId {
@@ -325,6 +403,11 @@ func (c *Client) Shutdown() {
 	c.isOpen.Store(false)
 	close(c.stopCh)
 	c.closeConn()
+	if c.tracingInstalled.CompareAndSwap(true, false) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = tracing.Shutdown(ctx)
+	}
 }
 
 func (c *Client) onConnectionLost(reason string, err error) {
@@ -15,6 +15,7 @@ type RouteTable struct {
 	rpcMode      string
 
 	mu                 sync.RWMutex
+	version            int64
 	routeIdToNodeMap   map[string]core.RouteNode
 	routeIdToRpcClient map[string]*rpc.DirectClient
 	routeIdToRelay     map[string]*rpc.RelayClient
@@ -55,6 +56,10 @@ func (t *RouteTable) SetRpcMode(mode string) {
 func (t *RouteTable) UpsertRouteNode(nodes []core.RouteNode) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.upsertLocked(nodes)
+}
+
+func (t *RouteTable) upsertLocked(nodes []core.RouteNode) {
 	for _, node := range nodes {
 		old, ok := t.routeIdToNodeMap[node.RouterId]
 		if ok && old == node {
@@ -72,6 +77,10 @@ func (t *RouteTable) UpsertRouteNode(nodes []core.RouteNode) {
 func (t *RouteTable) RemoveRouteNode(routeIds []string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.removeLocked(routeIds)
+}
+
+func (t *RouteTable) removeLocked(routeIds []string) {
 	for _, rid := range routeIds {
 		delete(t.routeIdToNodeMap, rid)
 		if client, ok := t.routeIdToRpcClient[rid]; ok {
@@ -81,6 +90,51 @@ func (t *RouteTable) RemoveRouteNode(routeIds []string) {
 	}
 }
 
+// RouteVersion 当前持有的路由表版本，0 表示还没有收到 Router Center 的完整路由表
+func (t *RouteTable) RouteVersion() int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.version
+}
+
+// ResetRouteVersion 重新连接后清空版本号，下一次心跳会拿到完整路由表
+func (t *RouteTable) ResetRouteVersion() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.version = 0
+}
+
+// ApplyRouteDelta 应用 Router Center 下发的路由表增量；完整路由表会删除其中不存在的节点。
+// 过期或尚未拿到完整路由表时收到的增量直接忽略；FromVersion 超出本地版本说明漏收了变更，返回 false
+func (t *RouteTable) ApplyRouteDelta(delta core.RouteTableDelta) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !delta.Snapshot {
+		if t.version == 0 || delta.Version <= t.version {
+			return true
+		}
+		if delta.FromVersion > t.version {
+			return false
+		}
+	}
+	removes := delta.Removes
+	if delta.Snapshot {
+		keep := make(map[string]bool, len(delta.Upserts))
+		for _, node := range delta.Upserts {
+			keep[node.RouterId] = true
+		}
+		for rid := range t.routeIdToNodeMap {
+			if !keep[rid] {
+				removes = append(removes, rid)
+			}
+		}
+	}
+	t.upsertLocked(delta.Upserts)
+	t.removeLocked(removes)
+	t.version = delta.Version
+	return true
+}
+
 func (t *RouteTable) GetOrCreateRpcClient(routeId string) (*rpc.DirectClient, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
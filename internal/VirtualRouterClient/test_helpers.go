@@ -14,6 +14,7 @@ func ResetRouteTableForTest() {
 	t.routeId = ""
 	t.routerClient = nil
 	t.rpcMode = ""
+	t.version = 0
 
 	for _, c := range t.routeIdToRpcClient {
 		if c != nil {
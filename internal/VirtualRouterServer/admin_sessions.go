@@ -0,0 +1,157 @@
+package VirtualRouterServer
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxSessionUserAgentLength 记录的 User-Agent 长度上限
+const maxSessionUserAgentLength = 256
+
+// AdminSession 管理后台登录会话，刷新 Token 不会产生新会话
+type AdminSession struct {
+	Id         string `json:"id"`
+	Username   string `json:"username"`
+	ClientIP   string `json:"clientIp"`
+	UserAgent  string `json:"userAgent"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastSeenAt int64  `json:"lastSeenAt"`
+	// ExpiresAt 会话最新 Token 的过期时间（Unix 毫秒）
+	ExpiresAt int64 `json:"expiresAt"`
+	// Current 是否为发起查询的会话
+	Current bool `json:"current,omitempty"`
+}
+
+// adminSessionStore 内存中的活跃会话；重启后由携带 Token 的请求重新登记
+type adminSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*AdminSession
+}
+
+func newAdminSessionStore() *adminSessionStore {
+	return &adminSessionStore{sessions: make(map[string]*AdminSession)}
+}
+
+// touch 登记或更新会话的最近访问时间
+func (s *adminSessionStore) touch(claims jwt.MapClaims, r *http.Request) {
+	sid := claimSessionId(claims)
+	if sid == "" {
+		return
+	}
+	now := time.Now()
+	username, _ := claims["sub"].(string)
+	exp, _ := claims["exp"].(float64)
+	iat, _ := claims["iat"].(float64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[sid]
+	if sess == nil {
+		sess = &AdminSession{Id: sid, Username: username, CreatedAt: time.Unix(int64(iat), 0).UnixMilli()}
+		s.sessions[sid] = sess
+	}
+	sess.ClientIP = remoteIP(r)
+	if ua := r.UserAgent(); ua != "" {
+		if len(ua) > maxSessionUserAgentLength {
+			ua = ua[:maxSessionUserAgentLength]
+		}
+		sess.UserAgent = ua
+	}
+	sess.LastSeenAt = now.UnixMilli()
+	sess.ExpiresAt = max(sess.ExpiresAt, time.Unix(int64(exp), 0).UnixMilli())
+}
+
+func (s *adminSessionStore) remove(sid string) (AdminSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sid]
+	if !ok {
+		return AdminSession{}, false
+	}
+	delete(s.sessions, sid)
+	return *sess, true
+}
+
+// list 未过期的会话，最近活跃在前
+func (s *adminSessionStore) list(currentSid string) []AdminSession {
+	now := time.Now().UnixMilli()
+	s.mu.Lock()
+	items := make([]AdminSession, 0, len(s.sessions))
+	for sid, sess := range s.sessions {
+		if sess.ExpiresAt < now {
+			delete(s.sessions, sid)
+			continue
+		}
+		item := *sess
+		item.Current = sid == currentSid
+		items = append(items, item)
+	}
+	s.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool { return items[i].LastSeenAt > items[j].LastSeenAt })
+	return items
+}
+
+// ListAdminSessions 当前未过期的管理后台会话
+func (h *HttpServer) ListAdminSessions() []AdminSession {
+	return h.sessions.list("")
+}
+
+// RevokeAdminSession 强制下线：吊销会话后其所有 Token 立即失效
+func (h *HttpServer) RevokeAdminSession(sid string) bool {
+	sid = strings.TrimSpace(sid)
+	if sid == "" {
+		return false
+	}
+	_, ok := h.sessions.remove(sid)
+	RevokeSession(sid)
+	return ok
+}
+
+// revokeUserSessions 吊销账号的所有会话（keepSid 除外），用于修改或重置密码后让旧 Token 立即失效
+func (h *HttpServer) revokeUserSessions(username, keepSid string) int {
+	h.sessions.mu.Lock()
+	var sids []string
+	for sid, sess := range h.sessions.sessions {
+		if sess.Username == username && sid != keepSid {
+			sids = append(sids, sid)
+			delete(h.sessions.sessions, sid)
+		}
+	}
+	h.sessions.mu.Unlock()
+	for _, sid := range sids {
+		RevokeSession(sid)
+	}
+	return len(sids)
+}
+
+// requestSessionId 当前请求 Token 所属的会话
+func requestSessionId(r *http.Request) string {
+	claims, ok := parseClaims(extractToken(r))
+	if !ok {
+		return ""
+	}
+	return claimSessionId(claims)
+}
+
+// handleAdminSessions GET 列出会话；DELETE ?id= 强制下线
+func (h *HttpServer) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": h.sessions.list(requestSessionId(r))})
+	case http.MethodDelete:
+		sid := strings.TrimSpace(r.URL.Query().Get("id"))
+		if !h.RevokeAdminSession(sid) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "会话不存在或已过期: " + sid})
+			return
+		}
+		componentLog(LogComponentHttp).Info("管理后台会话已被强制下线", "session", sid, "operator", requestOperator(r))
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "会话已下线"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+	}
+}
@@ -0,0 +1,526 @@
+package VirtualRouterServer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 管理后台角色，权限依次递增
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// legacyAdminUsername 未配置 adminUsers 时 adminPassword 对应的内置账号
+const legacyAdminUsername = "admin"
+
+const minAdminPasswordLength = 4
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+var adminUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+var (
+	errInvalidCredentials = errors.New("账号或密码错误")
+	errAdminUserNotFound  = errors.New("账号不存在")
+)
+
+// dummyPasswordHash 账号不存在时也做一次 bcrypt 比较，避免通过耗时差异枚举账号
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("virtual-router-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// AdminUserView 对外展示的账号信息，不含密码
+type AdminUserView struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Disabled  bool   `json:"disabled"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+	// TotpEnabled 是否已绑定两步验证
+	TotpEnabled bool `json:"totpEnabled,omitempty"`
+	// Legacy 由 adminPassword 提供的内置账号
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+// AdminUserUpdate 修改账号，nil 字段保持不变
+type AdminUserUpdate struct {
+	Role     *string `json:"role"`
+	Password *string `json:"password"`
+	Disabled *bool   `json:"disabled"`
+	// ResetTotp 解除两步验证（用户丢失验证器时由 admin 重置）
+	ResetTotp bool `json:"resetTotp"`
+}
+
+func validRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// roleAtLeast role 是否拥有 min 要求的权限
+func roleAtLeast(role, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
+
+func hashAdminPassword(password string) (string, error) {
+	if len(strings.TrimSpace(password)) < minAdminPasswordLength {
+		return "", errors.New("密码长度至少 4 位")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// prepareAdminUsers 启动时把配置中的明文密码哈希化，只改内存中的配置
+func (h *HttpServer) prepareAdminUsers() {
+	if h.cfg == nil {
+		return
+	}
+	hashPlaintextAdminPasswords(h.cfg.AdminUsers, nil)
+}
+
+// hashPlaintextAdminPasswords 补齐默认角色并把明文密码换成哈希；
+// prev 中同名账号的哈希与明文一致时沿用，避免每次重载都生成新哈希
+func hashPlaintextAdminPasswords(users, prev []config.AdminUser) {
+	for i := range users {
+		u := &users[i]
+		if u.Role == "" {
+			u.Role = RoleViewer
+		}
+		if u.Password == "" {
+			continue
+		}
+		reused := false
+		for _, p := range prev {
+			if p.Username == u.Username && p.PasswordHash != "" &&
+				bcrypt.CompareHashAndPassword([]byte(p.PasswordHash), []byte(u.Password)) == nil {
+				u.PasswordHash = p.PasswordHash
+				reused = true
+				break
+			}
+		}
+		if !reused {
+			hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+			if err != nil {
+				componentLog(LogComponentHttp).Warn("管理员账号密码哈希失败", "username", u.Username, "error", err)
+				continue
+			}
+			u.PasswordHash = string(hash)
+		}
+		u.Password = ""
+		componentLog(LogComponentHttp).Warn("配置中的管理员账号使用了明文密码，下次修改账号时会改为 passwordHash", "username", u.Username)
+	}
+}
+
+// legacyAdminModeLocked 未配置任何账号时沿用 adminPassword 单账号模式
+func (h *HttpServer) legacyAdminModeLocked() bool {
+	return len(h.cfg.AdminUsers) == 0
+}
+
+func (h *HttpServer) findAdminUserLocked(username string) *config.AdminUser {
+	for i := range h.cfg.AdminUsers {
+		if h.cfg.AdminUsers[i].Username == username {
+			return &h.cfg.AdminUsers[i]
+		}
+	}
+	return nil
+}
+
+func adminUserView(u *config.AdminUser) AdminUserView {
+	return AdminUserView{Username: u.Username, Role: u.Role, Disabled: u.Disabled, CreatedAt: u.CreatedAt, TotpEnabled: u.TotpSecret != ""}
+}
+
+// authenticate 校验账号密码与两步验证码，username 为空时按内置 admin 处理（兼容只传 password 的旧客户端）
+func (h *HttpServer) authenticate(username, password, totpCode string) (AdminUserView, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		username = legacyAdminUsername
+	}
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	if h.legacyAdminModeLocked() {
+		passwordOk := legacyPasswordEqual(password, h.cfg.AdminPassword)
+		if username != legacyAdminUsername || !passwordOk {
+			return AdminUserView{}, errInvalidCredentials
+		}
+		return AdminUserView{Username: legacyAdminUsername, Role: RoleAdmin, Legacy: true}, nil
+	}
+	u := h.findAdminUserLocked(username)
+	if u == nil || u.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return AdminUserView{}, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return AdminUserView{}, errInvalidCredentials
+	}
+	if u.Disabled {
+		return AdminUserView{}, errors.New("账号已被禁用")
+	}
+	if u.TotpSecret != "" {
+		if strings.TrimSpace(totpCode) == "" {
+			return AdminUserView{}, errTotpRequired
+		}
+		if !h.totp.verify(u.Username, u.TotpSecret, totpCode, time.Now()) {
+			return AdminUserView{}, errInvalidTotp
+		}
+	}
+	return adminUserView(u), nil
+}
+
+// legacyPasswordEqual 以常量时间比较旧版明文 adminPassword，避免通过响应耗时逐字猜测
+func legacyPasswordEqual(input, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(input), []byte(expected)) == 1
+}
+
+// lookupAdminUser Token 中的账号当前是否仍然有效，以及它的角色
+func (h *HttpServer) lookupAdminUser(username string) (AdminUserView, bool) {
+	if h.cfg == nil || username == "" {
+		return AdminUserView{}, false
+	}
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	if h.legacyAdminModeLocked() {
+		if username != legacyAdminUsername {
+			return AdminUserView{}, false
+		}
+		return AdminUserView{Username: legacyAdminUsername, Role: RoleAdmin, Legacy: true}, true
+	}
+	u := h.findAdminUserLocked(username)
+	if u == nil || u.Disabled {
+		return AdminUserView{}, false
+	}
+	return adminUserView(u), true
+}
+
+// ListAdminUsers 列出账号，按用户名排序
+func (h *HttpServer) ListAdminUsers() []AdminUserView {
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	if h.legacyAdminModeLocked() {
+		return []AdminUserView{{Username: legacyAdminUsername, Role: RoleAdmin, Legacy: true}}
+	}
+	list := make([]AdminUserView, 0, len(h.cfg.AdminUsers))
+	for i := range h.cfg.AdminUsers {
+		list = append(list, adminUserView(&h.cfg.AdminUsers[i]))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// CreateAdminUser 新建账号；单账号模式下会先把 adminPassword 迁移为哈希存储的 admin 账号
+func (h *HttpServer) CreateAdminUser(username, password, role string) (AdminUserView, error) {
+	username = strings.TrimSpace(username)
+	if !adminUsernamePattern.MatchString(username) {
+		return AdminUserView{}, errors.New("用户名只能包含字母、数字与 _ . @ -，长度 1~64")
+	}
+	if !validRole(role) {
+		return AdminUserView{}, errors.New("未知的角色: " + role)
+	}
+	hash, err := hashAdminPassword(password)
+	if err != nil {
+		return AdminUserView{}, err
+	}
+
+	h.cfgMu.Lock()
+	defer h.cfgMu.Unlock()
+	users := append([]config.AdminUser(nil), h.cfg.AdminUsers...)
+	legacyPassword := h.cfg.AdminPassword
+	if h.legacyAdminModeLocked() && strings.TrimSpace(legacyPassword) != "" && username != legacyAdminUsername {
+		legacyHash, err := bcrypt.GenerateFromPassword([]byte(legacyPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return AdminUserView{}, err
+		}
+		users = append(users, config.AdminUser{Username: legacyAdminUsername, PasswordHash: string(legacyHash), Role: RoleAdmin, CreatedAt: time.Now().UnixMilli()})
+	}
+	for _, u := range users {
+		if u.Username == username {
+			return AdminUserView{}, errors.New("账号已存在: " + username)
+		}
+	}
+	created := config.AdminUser{Username: username, PasswordHash: hash, Role: role, CreatedAt: time.Now().UnixMilli()}
+	users = append(users, created)
+
+	prevUsers, prevPassword := h.cfg.AdminUsers, h.cfg.AdminPassword
+	h.cfg.AdminUsers = users
+	if !h.hasActiveAdminLocked() {
+		h.cfg.AdminUsers = prevUsers
+		return AdminUserView{}, errors.New("至少需要保留一个启用的 admin 账号")
+	}
+	// 迁移后不再在配置文件中保留明文密码
+	h.cfg.AdminPassword = ""
+	if err := h.persistConfigLocked(); err != nil {
+		h.cfg.AdminUsers, h.cfg.AdminPassword = prevUsers, prevPassword
+		return AdminUserView{}, err
+	}
+	return adminUserView(&created), nil
+}
+
+// UpdateAdminUser 修改角色、重置密码或启用/禁用账号
+func (h *HttpServer) UpdateAdminUser(username string, update AdminUserUpdate) (AdminUserView, error) {
+	if update.Role != nil && !validRole(*update.Role) {
+		return AdminUserView{}, errors.New("未知的角色: " + *update.Role)
+	}
+	var hash string
+	if update.Password != nil {
+		var err error
+		if hash, err = hashAdminPassword(*update.Password); err != nil {
+			return AdminUserView{}, err
+		}
+	}
+
+	h.cfgMu.Lock()
+	defer h.cfgMu.Unlock()
+	if h.legacyAdminModeLocked() {
+		return AdminUserView{}, errors.New("当前为单账号模式，请先创建账号")
+	}
+	u := h.findAdminUserLocked(username)
+	if u == nil {
+		return AdminUserView{}, errAdminUserNotFound
+	}
+	prev := *u
+	if update.Role != nil {
+		u.Role = *update.Role
+	}
+	if update.Disabled != nil {
+		u.Disabled = *update.Disabled
+	}
+	if hash != "" {
+		u.PasswordHash = hash
+		u.Password = ""
+	}
+	if update.ResetTotp {
+		u.TotpSecret = ""
+	}
+	if !h.hasActiveAdminLocked() {
+		*u = prev
+		return AdminUserView{}, errors.New("至少需要保留一个启用的 admin 账号")
+	}
+	if err := h.persistConfigLocked(); err != nil {
+		*u = prev
+		return AdminUserView{}, err
+	}
+	if hash != "" {
+		if n := h.revokeUserSessions(u.Username, ""); n > 0 {
+			componentLog(LogComponentHttp).Info("密码已重置，吊销该账号的会话", "username", u.Username, "sessions", n)
+		}
+	}
+	return adminUserView(u), nil
+}
+
+// DeleteAdminUser 删除账号，不能删除自己，也不能删除最后一个 admin
+func (h *HttpServer) DeleteAdminUser(username, operator string) error {
+	if username == operator {
+		return errors.New("不能删除当前登录的账号")
+	}
+	h.cfgMu.Lock()
+	defer h.cfgMu.Unlock()
+	if h.legacyAdminModeLocked() {
+		return errors.New("当前为单账号模式，没有可删除的账号")
+	}
+	idx := -1
+	for i := range h.cfg.AdminUsers {
+		if h.cfg.AdminUsers[i].Username == username {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return errAdminUserNotFound
+	}
+	prevUsers := h.cfg.AdminUsers
+	users := append([]config.AdminUser(nil), prevUsers[:idx]...)
+	h.cfg.AdminUsers = append(users, prevUsers[idx+1:]...)
+	if !h.hasActiveAdminLocked() {
+		h.cfg.AdminUsers = prevUsers
+		return errors.New("至少需要保留一个启用的 admin 账号")
+	}
+	if err := h.persistConfigLocked(); err != nil {
+		h.cfg.AdminUsers = prevUsers
+		return err
+	}
+	return nil
+}
+
+// changeOwnPassword 当前账号修改自己的密码；单账号模式下修改 adminPassword。
+// 成功后吊销该账号除 currentSid 外的所有会话
+func (h *HttpServer) changeOwnPassword(username, currentSid, oldPassword, newPassword string) error {
+	newPassword = strings.TrimSpace(newPassword)
+	if len(newPassword) < minAdminPasswordLength {
+		return errors.New("新密码长度至少 4 位")
+	}
+	h.cfgMu.Lock()
+	defer h.cfgMu.Unlock()
+	if h.legacyAdminModeLocked() {
+		if !legacyPasswordEqual(oldPassword, h.cfg.AdminPassword) {
+			return errors.New("旧密码不正确")
+		}
+		prev := h.cfg.AdminPassword
+		h.cfg.AdminPassword = newPassword
+		if err := h.persistConfigLocked(); err != nil {
+			h.cfg.AdminPassword = prev
+			return err
+		}
+		h.revokeUserSessions(legacyAdminUsername, currentSid)
+		return nil
+	}
+	u := h.findAdminUserLocked(username)
+	if u == nil {
+		return errAdminUserNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(oldPassword)) != nil {
+		return errors.New("旧密码不正确")
+	}
+	hash, err := hashAdminPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	prev := *u
+	u.PasswordHash = hash
+	u.Password = ""
+	if err := h.persistConfigLocked(); err != nil {
+		*u = prev
+		return err
+	}
+	h.revokeUserSessions(u.Username, currentSid)
+	return nil
+}
+
+func (h *HttpServer) hasActiveAdminLocked() bool {
+	for _, u := range h.cfg.AdminUsers {
+		if u.Role == RoleAdmin && !u.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// persistConfigLocked 写回配置文件：以 desired 为准，账号相关字段取运行中的值
+func (h *HttpServer) persistConfigLocked() error {
+	if err := config.WriteRouterServerConfig("", h.desiredLocked()); err != nil {
+		return errors.New("写入配置失败: " + err.Error())
+	}
+	return nil
+}
+
+// authorize 校验 Token 并要求账号角色不低于 minRole，失败时直接写出 401/403
+func (h *HttpServer) authorize(w http.ResponseWriter, r *http.Request, minRole string) bool {
+	claims, ok := parseClaims(extractToken(r))
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "未授权，请先登录"})
+		return false
+	}
+	username, _ := claims["sub"].(string)
+	user, ok := h.lookupAdminUser(username)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "账号不存在或已被禁用，请重新登录"})
+		return false
+	}
+	h.sessions.touch(claims, r)
+	if !roleAtLeast(user.Role, minRole) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"success": false, "message": "权限不足，需要 " + minRole + " 角色"})
+		return false
+	}
+	markAuditAuthenticated(r)
+	return true
+}
+
+// withRole 所有请求都要求账号角色不低于 minRole
+func (h *HttpServer) withRole(minRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		if h.authorize(w, r, minRole) {
+			next(w, r)
+		}
+	}
+}
+
+// withWriteRole 只读请求（GET/HEAD）只需 viewer，其余请求要求角色不低于 minRole
+func (h *HttpServer) withWriteRole(minRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		role := minRole
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			role = RoleViewer
+		}
+		if h.authorize(w, r, role) {
+			next(w, r)
+		}
+	}
+}
+
+// handleAdminUsers GET 列出账号；POST 新建；PUT ?username= 修改；DELETE ?username= 删除
+func (h *HttpServer) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": h.ListAdminUsers()})
+	case http.MethodPost:
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
+			return
+		}
+		user, err := h.CreateAdminUser(req.Username, req.Password, req.Role)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		componentLog(LogComponentHttp).Info("管理员账号已创建", "username", user.Username, "role", user.Role, "operator", requestOperator(r))
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "账号已创建", "data": user})
+	case http.MethodPut:
+		username := strings.TrimSpace(r.URL.Query().Get("username"))
+		var update AdminUserUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
+			return
+		}
+		user, err := h.UpdateAdminUser(username, update)
+		if errors.Is(err, errAdminUserNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "账号不存在: " + username})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		componentLog(LogComponentHttp).Info("管理员账号已修改", "username", username, "operator", requestOperator(r))
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "账号已更新", "data": user})
+	case http.MethodDelete:
+		username := strings.TrimSpace(r.URL.Query().Get("username"))
+		err := h.DeleteAdminUser(username, requestOperator(r))
+		if errors.Is(err, errAdminUserNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "账号不存在: " + username})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		componentLog(LogComponentHttp).Info("管理员账号已删除", "username", username, "operator", requestOperator(r))
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "账号已删除"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+	}
+}
@@ -0,0 +1,411 @@
+package VirtualRouterServer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuditLogLimit = 2000
+	// maxAuditParamsBytes 记录的请求体上限，超出部分截断
+	maxAuditParamsBytes = 16 * 1024
+	// maxAuditPeekBytes 为脱敏而解析的请求体上限，更大的请求体不记录内容
+	maxAuditPeekBytes = 64 * 1024
+	// maxAuditRequestBytes 经过审计的请求体上限，超出时处理函数读取请求体会失败
+	maxAuditRequestBytes = 1 << 20
+	// maxAuditResponseBytes 为提取 message 而缓存的响应体上限
+	maxAuditResponseBytes = 4 * 1024
+)
+
+// 审计动作
+const (
+	AuditActionLogin          = "auth.login"
+	AuditActionLogout         = "auth.logout"
+	AuditActionSessionRevoke  = "auth.session.revoke"
+	AuditActionTotp           = "auth.totp"
+	AuditActionPasswordUpdate = "system.adminPassword.update"
+	AuditActionLogLevelUpdate = "logs.level.update"
+	AuditActionDebugSendRpc   = "debug.sendRpc"
+	AuditActionDebugReplay    = "debug.replay"
+	AuditActionDebugTemplate  = "debug.template"
+	AuditActionCaptureControl = "capture.control"
+	AuditActionUserManage     = "system.user"
+	AuditActionJWTKeyRotate   = "system.jwtKey.rotate"
+	AuditActionConfigUpdate   = "system.config.update"
+	AuditActionConfigReload   = "system.config.reload"
+)
+
+const (
+	auditRedactedPlaceholder  = "***"
+	auditTruncatedPlaceholder = "...(truncated)"
+)
+
+// AuditEntry 一条审计记录：谁、何时、从哪里、做了什么、参数与结果
+type AuditEntry struct {
+	Id       string `json:"id"`
+	Time     int64  `json:"time"`
+	Operator string `json:"operator"`
+	ClientIP string `json:"clientIp"`
+	// ForwardedFor 请求头 X-Forwarded-For 原值，经反向代理时用于追溯真实来源
+	ForwardedFor string `json:"forwardedFor,omitempty"`
+	Action       string `json:"action"`
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	// Params 请求参数（JSON），密码等敏感字段已脱敏
+	Params  json.RawMessage `json:"params,omitempty"`
+	Status  int             `json:"status"`
+	Success bool            `json:"success"`
+	Message string          `json:"message,omitempty"`
+	CostMs  int64           `json:"costMs"`
+}
+
+// AuditQuery 审计查询条件，零值字段不参与过滤
+type AuditQuery struct {
+	Keyword  string
+	Operator string
+	Action   string
+	From     time.Time
+	To       time.Time
+	Offset   int
+	Limit    int
+}
+
+// AuditLog 只追加的审计日志：内存保留最近 limit 条供查询，filePath 非空时每条以 JSON 行追加写入文件，
+// 文件不会被改写或截断。
+type AuditLog struct {
+	mu       sync.Mutex
+	limit    int
+	filePath string
+	file     *os.File
+	entries  []AuditEntry
+}
+
+func NewAuditLog(filePath string, limit int) *AuditLog {
+	if limit <= 0 {
+		limit = defaultAuditLogLimit
+	}
+	a := &AuditLog{limit: limit, filePath: strings.TrimSpace(filePath)}
+	if a.filePath == "" {
+		return a
+	}
+	if err := a.loadTail(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		componentLog(LogComponentHttp).Warn("加载审计日志失败", "file", a.filePath, "error", err)
+	}
+	if dir := filepath.Dir(a.filePath); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	f, err := os.OpenFile(a.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		componentLog(LogComponentHttp).Warn("打开审计日志文件失败，仅保存在内存", "file", a.filePath, "error", err)
+		return a
+	}
+	a.file = f
+	return a
+}
+
+// loadTail 启动时读回文件中最近的 limit 条，供后台继续查询
+func (a *AuditLog) loadTail() error {
+	f, err := os.Open(a.filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineBytes)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Id == "" {
+			continue
+		}
+		a.entries = append(a.entries, entry)
+		if len(a.entries) > a.limit*2 {
+			a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-a.limit:]...)
+		}
+	}
+	if len(a.entries) > a.limit {
+		a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-a.limit:]...)
+	}
+	return scanner.Err()
+}
+
+// Record 追加一条审计记录，Id 与 Time 为空时自动填充
+func (a *AuditLog) Record(entry AuditEntry) AuditEntry {
+	if entry.Id == "" {
+		entry.Id = newCenterRpcUid("audit")
+	}
+	if entry.Time == 0 {
+		entry.Time = time.Now().UnixMilli()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > a.limit {
+		a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-a.limit:]...)
+	}
+	if a.file != nil {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = a.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			componentLog(LogComponentHttp).Error("写入审计日志失败", "file", a.filePath, "action", entry.Action, "error", err)
+		}
+	}
+	return entry
+}
+
+// Search 按条件倒序（最新在前）分页查询，返回命中总数与当前页
+func (a *AuditLog) Search(q AuditQuery) (int, []AuditEntry) {
+	keyword := strings.ToLower(strings.TrimSpace(q.Keyword))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	matched := make([]AuditEntry, 0)
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		entry := a.entries[i]
+		if q.Operator != "" && entry.Operator != q.Operator {
+			continue
+		}
+		if q.Action != "" && !strings.HasPrefix(entry.Action, q.Action) {
+			continue
+		}
+		if !q.From.IsZero() && entry.Time < q.From.UnixMilli() {
+			continue
+		}
+		if !q.To.IsZero() && entry.Time > q.To.UnixMilli() {
+			continue
+		}
+		if keyword != "" && !auditEntryContains(entry, keyword) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+	total := len(matched)
+	if q.Offset >= total {
+		return total, []AuditEntry{}
+	}
+	matched = matched[max(q.Offset, 0):]
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return total, matched
+}
+
+func auditEntryContains(entry AuditEntry, keyword string) bool {
+	for _, f := range []string{entry.Operator, entry.ClientIP, entry.ForwardedFor, entry.Action, entry.Path, entry.Message, string(entry.Params)} {
+		if strings.Contains(strings.ToLower(f), keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+type auditInfoKey struct{}
+
+// auditInfo 由处理函数补充的审计信息（例如登录成功后的账号）
+type auditInfo struct {
+	operator string
+	// authenticated 通过鉴权（或登录成功）后才记录请求体，未授权的请求只记录动作与结果
+	authenticated bool
+}
+
+// setAuditOperator 登录等尚无 Token 的请求由处理函数告知操作人
+func setAuditOperator(r *http.Request, operator string) {
+	if info, ok := r.Context().Value(auditInfoKey{}).(*auditInfo); ok {
+		info.operator = operator
+		info.authenticated = true
+	}
+}
+
+// markAuditAuthenticated 鉴权通过时调用
+func markAuditAuthenticated(r *http.Request) {
+	if info, ok := r.Context().Value(auditInfoKey{}).(*auditInfo); ok {
+		info.authenticated = true
+	}
+}
+
+// auditResponseWriter 记录状态码并缓存响应体开头，用于提取 message
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if remain := maxAuditResponseBytes - w.body.Len(); remain > 0 {
+		w.body.Write(p[:min(len(p), remain)])
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// withAudit 记录变更类请求（GET/HEAD/OPTIONS 不记录）；放在 withAuth 外层，未授权的尝试同样留痕，
+// 但只有通过鉴权的请求才记录参数
+func (h *HttpServer) withAudit(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.audit == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		start := time.Now()
+		body := peekAuditBody(w, r)
+		info := &auditInfo{operator: requestOperator(r)}
+		r = r.WithContext(context.WithValue(r.Context(), auditInfoKey{}, info))
+		rec := &auditResponseWriter{ResponseWriter: w}
+		next(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var resp struct {
+			Success *bool  `json:"success"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(rec.body.Bytes(), &resp)
+		var params json.RawMessage
+		if info.authenticated {
+			params = auditParams(body)
+		}
+		success := status < 400
+		if resp.Success != nil {
+			success = success && *resp.Success
+		}
+		h.audit.Record(AuditEntry{
+			Time:         start.UnixMilli(),
+			Operator:     info.operator,
+			ClientIP:     remoteIP(r),
+			ForwardedFor: strings.TrimSpace(r.Header.Get("X-Forwarded-For")),
+			Action:       action,
+			Method:       r.Method,
+			Path:         r.URL.RequestURI(),
+			Params:       params,
+			Status:       status,
+			Success:      success,
+			Message:      resp.Message,
+			CostMs:       time.Since(start).Milliseconds(),
+		})
+	}
+}
+
+// auditBody 包装限长后的请求体，已读出的开头部分重新拼回
+type auditBody struct {
+	io.Reader
+	io.Closer
+}
+
+// peekAuditBody 限制请求体大小并读出开头 maxAuditPeekBytes+1 字节，处理函数仍能读到完整请求体
+func peekAuditBody(w http.ResponseWriter, r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body := http.MaxBytesReader(w, r.Body, maxAuditRequestBytes)
+	data, _ := io.ReadAll(io.LimitReader(body, maxAuditPeekBytes+1))
+	r.Body = auditBody{Reader: io.MultiReader(bytes.NewReader(data), body), Closer: body}
+	return data
+}
+
+// auditParams 将请求体转为审计参数，JSON 对象中的敏感字段替换为 ***，超长部分截断
+func auditParams(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if len(data) > maxAuditPeekBytes {
+		// 无法完整解析，也就无法脱敏，只记录请求体过大
+		raw, _ := json.Marshal("请求体超过 " + strconv.Itoa(maxAuditPeekBytes) + " 字节" + auditTruncatedPlaceholder)
+		return raw
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		// 非 JSON 请求体只保留截断后的文本
+		text := string(data)
+		if len(text) > maxAuditParamsBytes {
+			text = text[:maxAuditParamsBytes] + auditTruncatedPlaceholder
+		}
+		raw, _ := json.Marshal(text)
+		return raw
+	}
+	raw, err := json.Marshal(redactAuditValue(decoded))
+	if err != nil {
+		return nil
+	}
+	if len(raw) > maxAuditParamsBytes {
+		raw, _ = json.Marshal(string(raw[:maxAuditParamsBytes]) + auditTruncatedPlaceholder)
+	}
+	return raw
+}
+
+func redactAuditValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if isSensitiveAuditKey(k) {
+				val[k] = auditRedactedPlaceholder
+				continue
+			}
+			val[k] = redactAuditValue(item)
+		}
+		return val
+	case []any:
+		for i, item := range val {
+			val[i] = redactAuditValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func isSensitiveAuditKey(key string) bool {
+	lower := strings.ToLower(key)
+	// 网关 API Key 的字段名就是 key
+	if lower == "key" {
+		return true
+	}
+	for _, s := range []string{"password", "secret", "token", "apikey", "totp"} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP 连接的对端 IP（不信任可伪造的转发头，转发头单独记录在 ForwardedFor）
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
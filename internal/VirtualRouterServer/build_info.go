@@ -0,0 +1,102 @@
+package VirtualRouterServer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// 打包脚本通过 -ldflags "-X" 注入，未注入时依次使用 Go 构建信息与 version.txt
+var (
+	buildVersion string
+	buildCommit  string
+	buildTime    string
+)
+
+const versionFileName = "version.txt"
+
+// BuildInfo Router Center 的版本与构建信息
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+	// Source 版本号来源: ldflags / buildinfo / version.txt / unknown
+	Source string `json:"source"`
+}
+
+var (
+	buildInfoOnce   sync.Once
+	cachedBuildInfo BuildInfo
+)
+
+// CurrentBuildInfo 当前进程的版本信息，首次调用后缓存
+func CurrentBuildInfo() BuildInfo {
+	buildInfoOnce.Do(func() {
+		cachedBuildInfo = resolveBuildInfo(versionFileDirs())
+	})
+	return cachedBuildInfo
+}
+
+func resolveBuildInfo(versionDirs []string) BuildInfo {
+	info := BuildInfo{Version: buildVersion, Commit: buildCommit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if info.Version != "" {
+		info.Source = "ldflags"
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version, info.Source = bi.Main.Version, "buildinfo"
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		for _, dir := range versionDirs {
+			if v := ReadVersionFile(filepath.Join(dir, versionFileName)); v != "" {
+				info.Version, info.Source = v, versionFileName
+				break
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version, info.Source = "unknown", "unknown"
+	}
+	return info
+}
+
+// versionFileDirs 查找 version.txt 的目录：可执行文件所在目录与工作目录
+func versionFileDirs() []string {
+	var dirs []string
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	return dirs
+}
+
+// ReadVersionFile 读取 version.txt 的版本号，兼容 Windows 写入的 UTF-8 BOM；读取失败返回空
+func ReadVersionFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+}
@@ -0,0 +1,529 @@
+package VirtualRouterServer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+// defaultConfigReloadInterval 配置文件变更检测间隔
+const defaultConfigReloadInterval = 2 * time.Second
+
+// maxConfigBodyBytes 通过接口提交的配置内容上限
+const maxConfigBodyBytes = 1 << 20
+
+// reloadableConfigKeys 热更新时立即生效的顶层配置项，其余项写入配置文件后需要重启才生效
+var reloadableConfigKeys = map[string]bool{
+	"adminPassword":            true,
+	"adminUsers":               true,
+	"gatewayApiKeys":           true,
+	"gatewayTimeoutMs":         true,
+	"log":                      true,
+	"sessionTimeoutMs":         true,
+	"heartbeatSweepIntervalMs": true,
+	"heartbeatMissLimit":       true,
+
+	"sessionWriteQueueMaxMessages": true,
+	"sessionWriteQueueMaxBytes":    true,
+	"sessionWriteTimeoutMs":        true,
+	"sessionWriteOverflowPolicy":   true,
+	"rateLimit":                    true,
+	"maxConnections":               true,
+	"maxConnectionsPerIp":          true,
+	"registrationTimeoutMs":        true,
+	"connIdleTimeoutMs":            true,
+}
+
+// ConfigChange 配置差异中的一项，敏感字段的值已脱敏
+type ConfigChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+	// Reloadable 为 false 表示需要重启才能生效
+	Reloadable bool `json:"reloadable"`
+}
+
+// ConfigReloadResult 一次配置应用的结果
+type ConfigReloadResult struct {
+	Changes []ConfigChange `json:"changes"`
+	// PendingRestart 已写入配置但运行中尚未生效的项
+	PendingRestart []ConfigChange `json:"pendingRestart"`
+}
+
+func cloneRouterServerConfig(cfg *config.RouterServerConfig) *config.RouterServerConfig {
+	out := &config.RouterServerConfig{}
+	if cfg == nil {
+		return out
+	}
+	data, err := json.Marshal(cfg)
+	if err == nil {
+		_ = json.Unmarshal(data, out)
+	}
+	return out
+}
+
+// flattenConfig 把配置展开为 path -> JSON 值，数组元素按 username/name 标识，避免顺序变化产生大量差异
+func flattenConfig(prefix string, v any, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flattenConfig(path, item, out)
+		}
+	case []any:
+		for i, item := range val {
+			flattenConfig(prefix+"["+configItemLabel(item, i)+"]", item, out)
+		}
+	default:
+		raw, _ := json.Marshal(val)
+		out[prefix] = string(raw)
+	}
+}
+
+func configItemLabel(item any, index int) string {
+	if m, ok := item.(map[string]any); ok {
+		for _, k := range []string{"username", "name"} {
+			if s, ok := m[k].(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return strconv.Itoa(index)
+}
+
+func flattenRouterServerConfig(cfg *config.RouterServerConfig) map[string]string {
+	out := make(map[string]string)
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return out
+	}
+	var tree any
+	if json.Unmarshal(data, &tree) == nil {
+		flattenConfig("", tree, out)
+	}
+	return out
+}
+
+// configTopKey 差异路径所属的顶层配置项
+func configTopKey(path string) string {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func isSensitiveConfigPath(path string) bool {
+	if strings.HasPrefix(path, "tracing.headers.") {
+		return true
+	}
+	leaf := path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		leaf = path[i+1:]
+	}
+	return isSensitiveAuditKey(leaf)
+}
+
+// diffRouterServerConfig 两份配置的逐项差异，按路径排序
+func diffRouterServerConfig(prev, next *config.RouterServerConfig) []ConfigChange {
+	before := flattenRouterServerConfig(prev)
+	after := flattenRouterServerConfig(next)
+	paths := make([]string, 0, len(before)+len(after))
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	changes := make([]ConfigChange, 0)
+	for _, p := range paths {
+		oldVal, newVal := before[p], after[p]
+		if oldVal == newVal {
+			continue
+		}
+		if isSensitiveConfigPath(p) {
+			if oldVal != "" {
+				oldVal = auditRedactedPlaceholder
+			}
+			if newVal != "" {
+				newVal = auditRedactedPlaceholder
+			}
+		}
+		changes = append(changes, ConfigChange{Path: p, Old: oldVal, New: newVal, Reloadable: reloadableConfigKeys[configTopKey(p)]})
+	}
+	return changes
+}
+
+// desiredLocked 配置文件应有的完整内容：以最近一次应用的配置为准，账号字段取运行中的值
+func (h *HttpServer) desiredLocked() *config.RouterServerConfig {
+	if h.desired == nil {
+		return cloneRouterServerConfig(h.cfg)
+	}
+	out := cloneRouterServerConfig(h.desired)
+	live := cloneRouterServerConfig(h.cfg)
+	out.AdminPassword = live.AdminPassword
+	out.AdminUsers = live.AdminUsers
+	return out
+}
+
+// pendingRestartLocked 已写入配置但需要重启才生效的差异
+func (h *HttpServer) pendingRestartLocked() []ConfigChange {
+	pending := make([]ConfigChange, 0)
+	if h.desired == nil {
+		return pending
+	}
+	for _, c := range diffRouterServerConfig(h.cfg, h.desired) {
+		if !c.Reloadable {
+			pending = append(pending, c)
+		}
+	}
+	return pending
+}
+
+// copyServerSettings 复制 Server 使用的可热更新运行参数
+func copyServerSettings(dst, src *config.RouterServerConfig) {
+	dst.GatewayTimeoutMs = src.GatewayTimeoutMs
+	dst.SessionTimeoutMs = src.SessionTimeoutMs
+	dst.HeartbeatSweepIntervalMs = src.HeartbeatSweepIntervalMs
+	dst.HeartbeatMissLimit = src.HeartbeatMissLimit
+	dst.SessionWriteQueueMaxMessages = src.SessionWriteQueueMaxMessages
+	dst.SessionWriteQueueMaxBytes = src.SessionWriteQueueMaxBytes
+	dst.SessionWriteTimeoutMs = src.SessionWriteTimeoutMs
+	dst.SessionWriteOverflowPolicy = src.SessionWriteOverflowPolicy
+	dst.RateLimit = src.RateLimit
+	dst.MaxConnections = src.MaxConnections
+	dst.MaxConnectionsPerIp = src.MaxConnectionsPerIp
+	dst.RegistrationTimeoutMs = src.RegistrationTimeoutMs
+	dst.ConnIdleTimeoutMs = src.ConnIdleTimeoutMs
+}
+
+// applySettings 热更新 Server 使用的运行参数
+func (s *Server) applySettings(next *config.RouterServerConfig) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	if s.cfg == nil {
+		return
+	}
+	copyServerSettings(s.cfg, next)
+	s.sessionManager.SetHeartbeatPolicy(heartbeatPolicyFromConfig(s.cfg))
+	s.setWriteQueuePolicy(writeQueuePolicyFromConfig(s.cfg))
+	s.limiter.setConfig(s.cfg.RateLimit)
+	s.setConnPolicy(connPolicyFromConfig(s.cfg))
+}
+
+// ApplyConfig 校验并应用新配置：可热更新的项立即生效，已有会话与连接不受影响；
+// 其余项记录为待重启。校验失败时保持原配置不变
+func (h *HttpServer) ApplyConfig(next *config.RouterServerConfig) (ConfigReloadResult, error) {
+	if next == nil {
+		return ConfigReloadResult{}, errors.New("配置不能为空")
+	}
+	if err := next.Check(); err != nil {
+		return ConfigReloadResult{}, err
+	}
+	if next.Log != nil {
+		if _, _, _, err := parseLogConfig(next.Log); err != nil {
+			return ConfigReloadResult{}, err
+		}
+	}
+	next = cloneRouterServerConfig(next)
+
+	h.cfgMu.Lock()
+	defer h.cfgMu.Unlock()
+	hashPlaintextAdminPasswords(next.AdminUsers, h.cfg.AdminUsers)
+	changes := diffRouterServerConfig(h.desiredLocked(), next)
+
+	prevLog, _ := json.Marshal(h.cfg.Log)
+	nextLog, _ := json.Marshal(next.Log)
+	if !bytes.Equal(prevLog, nextLog) {
+		if err := ConfigureProcessLogs(next.Log); err != nil {
+			return ConfigReloadResult{}, err
+		}
+	}
+	live := cloneRouterServerConfig(next)
+	h.cfg.AdminPassword = live.AdminPassword
+	h.cfg.AdminUsers = live.AdminUsers
+	h.cfg.GatewayApiKeys = live.GatewayApiKeys
+	h.cfg.Log = live.Log
+	if h.srv != nil {
+		h.srv.applySettings(next)
+	}
+	if h.srv == nil || h.srv.cfg != h.cfg {
+		copyServerSettings(h.cfg, next)
+	}
+	h.desired = next
+
+	log := componentLog(LogComponentConfig)
+	for _, c := range changes {
+		log.Info("配置项已变更", "path", c.Path, "old", c.Old, "new", c.New, "reloadable", c.Reloadable)
+	}
+	pending := h.pendingRestartLocked()
+	if len(pending) > 0 {
+		paths := make([]string, 0, len(pending))
+		for _, c := range pending {
+			paths = append(paths, c.Path)
+		}
+		log.Warn("部分配置需要重启后生效", "paths", strings.Join(paths, ","))
+	}
+	return ConfigReloadResult{Changes: changes, PendingRestart: pending}, nil
+}
+
+// ReloadConfigFile 重新读取配置文件并应用，校验失败时继续使用当前配置
+func (h *HttpServer) ReloadConfigFile(fileName string) (ConfigReloadResult, error) {
+	log := componentLog(LogComponentConfig)
+	next, err := config.LoadRouterServerConfig(fileName)
+	if err != nil {
+		log.Error("配置文件校验失败，继续使用当前配置", "error", err)
+		return ConfigReloadResult{}, err
+	}
+	result, err := h.ApplyConfig(next)
+	if err != nil {
+		log.Error("配置文件校验失败，继续使用当前配置", "error", err)
+		return ConfigReloadResult{}, err
+	}
+	if len(result.Changes) == 0 {
+		log.Debug("配置文件无变化")
+	} else {
+		log.Info("配置已重新加载", "changes", len(result.Changes), "pendingRestart", len(result.PendingRestart))
+	}
+	return result, nil
+}
+
+func configFileDigest(fileName string) string {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WatchConfigFile 定期检测配置文件内容变化并自动重载，直到 ctx 结束
+func (h *HttpServer) WatchConfigFile(ctx context.Context, fileName string) {
+	if fileName == "" {
+		fileName = config.RouterServerConfigName
+	}
+	h.cfgMu.RLock()
+	intervalMs := h.cfg.ConfigReloadIntervalMs
+	h.cfgMu.RUnlock()
+	if intervalMs < 0 {
+		componentLog(LogComponentConfig).Info("已关闭配置文件自动重载", "file", fileName)
+		return
+	}
+	interval := defaultConfigReloadInterval
+	if intervalMs > 0 {
+		interval = time.Duration(intervalMs) * time.Millisecond
+	}
+
+	last := configFileDigest(fileName)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			digest := configFileDigest(fileName)
+			if digest == "" || digest == last {
+				continue
+			}
+			last = digest
+			componentLog(LogComponentConfig).Info("检测到配置文件变化", "file", fileName)
+			_, _ = h.ReloadConfigFile(fileName)
+		}
+	}
+}
+
+// redactRouterServerConfig 供接口展示的配置，敏感字段替换为占位符
+func redactRouterServerConfig(cfg *config.RouterServerConfig) *config.RouterServerConfig {
+	out := cloneRouterServerConfig(cfg)
+	redact := func(s *string) {
+		if *s != "" {
+			*s = auditRedactedPlaceholder
+		}
+	}
+	redact(&out.AdminPassword)
+	for i := range out.AdminUsers {
+		redact(&out.AdminUsers[i].Password)
+		redact(&out.AdminUsers[i].PasswordHash)
+		redact(&out.AdminUsers[i].TotpSecret)
+	}
+	for i := range out.GatewayApiKeys {
+		redact(&out.GatewayApiKeys[i].Key)
+	}
+	if out.JWT != nil {
+		redact(&out.JWT.Secret)
+	}
+	if out.Tracing != nil {
+		for k, v := range out.Tracing.Headers {
+			redact(&v)
+			out.Tracing.Headers[k] = v
+		}
+	}
+	return out
+}
+
+// restoreRedactedConfig 把提交内容中保留的占位符还原为当前值，新增项不允许使用占位符
+func restoreRedactedConfig(next, prev *config.RouterServerConfig) error {
+	if next.AdminPassword == auditRedactedPlaceholder {
+		next.AdminPassword = prev.AdminPassword
+	}
+	findUser := func(username string) *config.AdminUser {
+		for i := range prev.AdminUsers {
+			if prev.AdminUsers[i].Username == username {
+				return &prev.AdminUsers[i]
+			}
+		}
+		return nil
+	}
+	for i := range next.AdminUsers {
+		u := &next.AdminUsers[i]
+		old := findUser(u.Username)
+		if u.Password == auditRedactedPlaceholder {
+			u.Password = ""
+		}
+		if u.PasswordHash == auditRedactedPlaceholder {
+			if old == nil {
+				return fmt.Errorf("账号 %s 是新账号，请填写 password", u.Username)
+			}
+			u.PasswordHash = old.PasswordHash
+		}
+		if u.TotpSecret == auditRedactedPlaceholder {
+			u.TotpSecret = ""
+			if old != nil {
+				u.TotpSecret = old.TotpSecret
+			}
+		}
+	}
+	for i := range next.GatewayApiKeys {
+		k := &next.GatewayApiKeys[i]
+		if k.Key != auditRedactedPlaceholder {
+			continue
+		}
+		k.Key = ""
+		for _, old := range prev.GatewayApiKeys {
+			if old.Name == k.Name {
+				k.Key = old.Key
+				break
+			}
+		}
+		if k.Key == "" {
+			return fmt.Errorf("网关 API Key %s 是新增项，请填写 key", k.Name)
+		}
+	}
+	if next.JWT != nil && next.JWT.Secret == auditRedactedPlaceholder {
+		if prev.JWT == nil || prev.JWT.Secret == "" {
+			return errors.New("请填写 jwt.secret")
+		}
+		next.JWT.Secret = prev.JWT.Secret
+	}
+	if next.Tracing != nil {
+		for name, v := range next.Tracing.Headers {
+			if v != auditRedactedPlaceholder {
+				continue
+			}
+			if prev.Tracing == nil || prev.Tracing.Headers[name] == "" {
+				return fmt.Errorf("请填写 tracing.headers.%s", name)
+			}
+			next.Tracing.Headers[name] = prev.Tracing.Headers[name]
+		}
+	}
+	return nil
+}
+
+func (h *HttpServer) configViewData() map[string]any {
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	reloadable := make([]string, 0, len(reloadableConfigKeys))
+	for k := range reloadableConfigKeys {
+		reloadable = append(reloadable, k)
+	}
+	sort.Strings(reloadable)
+	return map[string]any{
+		"config":         redactRouterServerConfig(h.desiredLocked()),
+		"pendingRestart": h.pendingRestartLocked(),
+		"reloadable":     reloadable,
+		"file":           config.RouterServerConfigName,
+	}
+}
+
+// handleSystemConfig GET 查看配置（敏感字段脱敏）与待重启项；PUT 校验并应用完整配置后写回文件
+func (h *HttpServer) handleSystemConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": h.configViewData()})
+	case http.MethodPut:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxConfigBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "读取请求失败"})
+			return
+		}
+		next := &config.RouterServerConfig{}
+		if err := json.Unmarshal(body, next); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "配置格式错误: " + err.Error()})
+			return
+		}
+		h.cfgMu.RLock()
+		current := h.desiredLocked()
+		h.cfgMu.RUnlock()
+		if err := restoreRedactedConfig(next, current); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		if err := next.Normalize(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		result, err := h.ApplyConfig(next)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		h.cfgMu.Lock()
+		err = h.persistConfigLocked()
+		h.cfgMu.Unlock()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		message := "配置已应用并写入配置文件"
+		if len(result.PendingRestart) > 0 {
+			message += "，部分配置需要重启后生效"
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": message, "data": result})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+	}
+}
+
+// handleConfigReload POST 立即重新读取配置文件
+func (h *HttpServer) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+		return
+	}
+	result, err := h.ReloadConfigFile("")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "配置已重新加载", "data": result})
+}
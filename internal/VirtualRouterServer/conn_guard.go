@@ -0,0 +1,253 @@
+package VirtualRouterServer
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+const (
+	defaultMaxConnections      = 10000
+	defaultRegistrationTimeout = 10 * time.Second
+	defaultConnIdleTimeout     = 5 * time.Minute
+
+	// maxConnectionHistory 保留的已关闭连接记录条数
+	maxConnectionHistory = 500
+)
+
+// 连接被拒绝或关闭的原因
+const (
+	ConnCloseMaxConnections  = "连接数超出上限"
+	ConnCloseMaxPerIp        = "来源 IP 连接数超出上限"
+	ConnCloseRegisterTimeout = "注册超时"
+	ConnCloseIdleTimeout     = "空闲超时"
+	ConnClosePeerClosed      = "对端关闭连接"
+	ConnCloseReadFailed      = "读取失败"
+	ConnCloseQueueFull       = "发送队列已满"
+	ConnCloseWriteFailed     = "发送失败"
+	ConnCloseSessionRemoved  = "会话被移除"
+	ConnCloseRouteIdRejected = "RouterId 被拒绝"
+)
+
+// connRejectNoticeTimeout 拒绝连接时写出 SystemError 的时限
+const connRejectNoticeTimeout = time.Second
+
+// connRejectLogInterval 拒绝连接的告警日志最短间隔，期间的拒绝只计数，避免连接风暴刷屏
+const connRejectLogInterval = 10 * time.Second
+
+// ConnPolicy Router 端口的连接限制
+type ConnPolicy struct {
+	MaxConnections      int
+	MaxConnectionsPerIp int
+	RegistrationTimeout time.Duration
+	IdleTimeout         time.Duration
+}
+
+// connPolicyFromConfig 读取配置中的连接限制，0 使用默认值，小于 0 表示不限制
+func connPolicyFromConfig(cfg *config.RouterServerConfig) ConnPolicy {
+	p := ConnPolicy{
+		MaxConnections:      defaultMaxConnections,
+		RegistrationTimeout: defaultRegistrationTimeout,
+		IdleTimeout:         defaultConnIdleTimeout,
+	}
+	if cfg == nil {
+		return p
+	}
+	switch {
+	case cfg.MaxConnections > 0:
+		p.MaxConnections = cfg.MaxConnections
+	case cfg.MaxConnections < 0:
+		p.MaxConnections = 0
+	}
+	p.MaxConnectionsPerIp = cfg.MaxConnectionsPerIp
+	p.RegistrationTimeout = durationOrDefault(cfg.RegistrationTimeoutMs, defaultRegistrationTimeout)
+	p.IdleTimeout = durationOrDefault(cfg.ConnIdleTimeoutMs, defaultConnIdleTimeout)
+	return p
+}
+
+func durationOrDefault(ms int64, def time.Duration) time.Duration {
+	switch {
+	case ms > 0:
+		return time.Duration(ms) * time.Millisecond
+	case ms < 0:
+		return 0
+	}
+	return def
+}
+
+// ConnectionRecord 一条已关闭或被拒绝的连接
+type ConnectionRecord struct {
+	RemoteAddr string `json:"remoteAddr"`
+	RouteId    string `json:"routeId,omitempty"`
+	OpenedAt   int64  `json:"openedAt"`
+	ClosedAt   int64  `json:"closedAt"`
+	Rejected   bool   `json:"rejected,omitempty"`
+	Reason     string `json:"reason"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// connTracker 按来源 IP 统计当前连接，并保留最近关闭的连接记录
+type connTracker struct {
+	mu      sync.Mutex
+	perIp   map[string]int
+	history []ConnectionRecord
+	// lastRejectLog 上次输出拒绝日志的时间，suppressedRejects 之后被合并的拒绝次数
+	lastRejectLog     time.Time
+	suppressedRejects int
+}
+
+// admit 判断是否接受新连接，接受时计入来源 IP；返回拒绝原因
+func (t *connTracker) admit(ip string, current int64, p ConnPolicy) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.MaxConnections > 0 && current >= int64(p.MaxConnections) {
+		return ConnCloseMaxConnections
+	}
+	if p.MaxConnectionsPerIp > 0 && t.perIp[ip] >= p.MaxConnectionsPerIp {
+		return ConnCloseMaxPerIp
+	}
+	if t.perIp == nil {
+		t.perIp = make(map[string]int)
+	}
+	t.perIp[ip]++
+	return ""
+}
+
+func (t *connTracker) release(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.perIp[ip] <= 1 {
+		delete(t.perIp, ip)
+		return
+	}
+	t.perIp[ip]--
+}
+
+// rejectLogDue 是否该输出拒绝日志；返回自上次日志以来被合并的拒绝次数
+func (t *connTracker) rejectLogDue(now time.Time) (due bool, suppressed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastRejectLog) < connRejectLogInterval {
+		t.suppressedRejects++
+		return false, 0
+	}
+	suppressed = t.suppressedRejects
+	t.lastRejectLog, t.suppressedRejects = now, 0
+	return true, suppressed
+}
+
+func (t *connTracker) record(r ConnectionRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.history = append(t.history, r)
+	if len(t.history) > maxConnectionHistory {
+		t.history = append([]ConnectionRecord(nil), t.history[len(t.history)-maxConnectionHistory:]...)
+	}
+}
+
+// recent 最近的连接记录，新的在前
+func (t *connTracker) recent(limit int) []ConnectionRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limit <= 0 || limit > len(t.history) {
+		limit = len(t.history)
+	}
+	out := make([]ConnectionRecord, 0, limit)
+	for i := len(t.history) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, t.history[i])
+	}
+	return out
+}
+
+func remoteIpOf(conn net.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func remoteAddrOf(conn net.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	return conn.RemoteAddr().String()
+}
+
+func (s *Server) setConnPolicy(p ConnPolicy) {
+	s.connPolicy.Store(&p)
+}
+
+// ConnPolicy 当前 Router 端口的连接限制
+func (s *Server) ConnPolicy() ConnPolicy {
+	return *s.connPolicy.Load()
+}
+
+// acceptConn 检查连接数限制，超出时回复 SystemError 后关闭并记录。
+// 先占用连接数再检查，并发接入时不会超过上限；拒绝时归还
+func (s *Server) acceptConn(conn net.Conn) {
+	s.totalConnections.Add(1)
+	ip := remoteIpOf(conn)
+	current := s.currentConnections.Add(1)
+	if reason := s.conns.admit(ip, current-1, s.ConnPolicy()); reason != "" {
+		s.currentConnections.Add(-1)
+		s.rejectedConnections.Add(1)
+		now := time.Now()
+		s.conns.record(ConnectionRecord{RemoteAddr: remoteAddrOf(conn), OpenedAt: now.UnixMilli(), ClosedAt: now.UnixMilli(), Rejected: true, Reason: reason})
+		if due, suppressed := s.conns.rejectLogDue(now); due {
+			componentLog(LogComponentRouter).Warn("拒绝新连接", "remote", remoteAddrOf(conn), "reason", reason, "suppressed", suppressed)
+		}
+		go rejectConn(conn, reason)
+		return
+	}
+	go s.handleConn(conn, ip)
+}
+
+// RejectedConnections 因连接数限制被拒绝的连接总数
+func (s *Server) RejectedConnections() uint64 {
+	return s.rejectedConnections.Load()
+}
+
+// rejectConn 尽量告知节点拒绝原因，避免节点只看到连接被重置
+func rejectConn(conn net.Conn, reason string) {
+	defer conn.Close()
+	mt := core.RouteMessageTypeSystemError
+	data := "Router Center 拒绝连接: " + reason
+	payload, err := (&core.RouteMessage{FromRouteId: "server", MessageType: &mt, Data: &data}).EncodePayload()
+	if err != nil {
+		return
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(connRejectNoticeTimeout))
+	_, _ = conn.Write(core.EncodeFrame(payload))
+}
+
+// readCloseReason 根据读取错误判断连接关闭原因，已由其他路径记录的原因优先
+func readCloseReason(queue *writeQueue, err error, registered bool) (reason, detail string) {
+	if reason, detail = queue.closeReason(); reason != "" {
+		return reason, detail
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		if registered {
+			return ConnCloseIdleTimeout, ""
+		}
+		return ConnCloseRegisterTimeout, ""
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ConnClosePeerClosed, ""
+	}
+	return ConnCloseReadFailed, err.Error()
+}
+
+// ConnectionHistory 最近关闭或被拒绝的连接，新的在前
+func (s *Server) ConnectionHistory(limit int) []ConnectionRecord {
+	return s.conns.recent(limit)
+}
@@ -0,0 +1,397 @@
+package VirtualRouterServer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+const (
+	defaultDebugHistoryLimit = 500
+	// debugCallPendingTimeout 调试请求超过该时间仍未响应视为超时
+	debugCallPendingTimeout = 30 * time.Second
+	// debugHistoryPersistDelay 变更后延迟落盘，合并短时间内的多次变更
+	debugHistoryPersistDelay = 500 * time.Millisecond
+)
+
+const (
+	DebugCallPending = "pending"
+	DebugCallSuccess = "success"
+	DebugCallError   = "error"
+	DebugCallTimeout = "timeout"
+)
+
+// DebugCall 一次 RPC 调试调用，包含请求与响应
+type DebugCall struct {
+	RpcUid        string            `json:"rpcUid"`
+	TargetRouteId string            `json:"targetRouteId"`
+	PacketId      int               `json:"packetId"`
+	Method        string            `json:"method"`
+	Params        []json.RawMessage `json:"params"`
+	Operator      string            `json:"operator"`
+	Status        string            `json:"status"`
+	// ReplayOf 重放来源的 rpcUid
+	ReplayOf string `json:"replayOf,omitempty"`
+	// Response 节点返回的原始 RpcResponse JSON
+	Response    json.RawMessage `json:"response,omitempty"`
+	ErrorMsg    string          `json:"errorMsg,omitempty"`
+	CostMs      int64           `json:"costMs"`
+	CreatedAt   int64           `json:"createdAt"`
+	CompletedAt int64           `json:"completedAt,omitempty"`
+}
+
+// DebugTemplate 针对某个 Stub 保存的命名调用模板
+type DebugTemplate struct {
+	Id            string            `json:"id"`
+	Name          string            `json:"name"`
+	TargetRouteId string            `json:"targetRouteId"`
+	PacketId      int               `json:"packetId"`
+	Params        []json.RawMessage `json:"params"`
+	Operator      string            `json:"operator"`
+	CreatedAt     int64             `json:"createdAt"`
+}
+
+// DebugHistoryQuery 历史记录查询条件，零值字段不参与过滤
+type DebugHistoryQuery struct {
+	Keyword       string
+	TargetRouteId string
+	PacketId      int
+	Offset        int
+	Limit         int
+}
+
+type debugHistoryFile struct {
+	Calls     []*DebugCall     `json:"calls"`
+	Templates []*DebugTemplate `json:"templates"`
+}
+
+// DebugHistoryStore 调试调用历史与模板，按 limit 保留最近的记录；filePath 非空时在后台延迟落盘，
+// 不阻塞记录响应的 TCP 读协程。
+type DebugHistoryStore struct {
+	mu        sync.Mutex
+	limit     int
+	filePath  string
+	calls     []*DebugCall
+	byUid     map[string]*DebugCall
+	templates []*DebugTemplate
+
+	// persistTimer 已排队的落盘任务，为 nil 表示没有未写出的变更
+	persistTimer *time.Timer
+	// writeMu 保证同一时间只有一次写文件，且按快照顺序写出
+	writeMu sync.Mutex
+}
+
+func NewDebugHistoryStore(filePath string, limit int) *DebugHistoryStore {
+	if limit <= 0 {
+		limit = defaultDebugHistoryLimit
+	}
+	store := &DebugHistoryStore{limit: limit, filePath: filePath, byUid: map[string]*DebugCall{}}
+	if filePath != "" {
+		if err := store.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			componentLog(LogComponentDebug).Warn("加载 RPC 调试历史失败", "file", filePath, "error", err)
+		}
+	}
+	return store
+}
+
+func (s *DebugHistoryStore) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+	var file debugHistoryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, call := range file.Calls {
+		if call == nil || call.RpcUid == "" {
+			continue
+		}
+		if call.Status == DebugCallPending {
+			// 进程重启前未返回的请求不可能再收到响应
+			call.Status = DebugCallTimeout
+		}
+		s.calls = append(s.calls, call)
+		s.byUid[call.RpcUid] = call
+	}
+	s.trimLocked()
+	for _, tpl := range file.Templates {
+		if tpl != nil && tpl.Id != "" {
+			s.templates = append(s.templates, tpl)
+		}
+	}
+	return nil
+}
+
+// Add 记录一次新发出的调试调用
+func (s *DebugHistoryStore) Add(call *DebugCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	s.byUid[call.RpcUid] = call
+	s.trimLocked()
+	s.schedulePersistLocked()
+}
+
+// Complete 用节点响应更新调用记录，未知 rpcUid 返回 false
+func (s *DebugHistoryStore) Complete(rpcUid string, data string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call, ok := s.byUid[rpcUid]
+	if !ok {
+		return false
+	}
+	now := time.Now().UnixMilli()
+	call.Response = json.RawMessage(data)
+	call.CompletedAt = now
+	call.CostMs = now - call.CreatedAt
+	call.Status = DebugCallSuccess
+	var resp rpc.RpcResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		call.Response = nil
+		call.Status = DebugCallError
+		call.ErrorMsg = "响应不是合法 JSON: " + data
+	} else if resp.ErrorFlag {
+		call.Status = DebugCallError
+		call.ErrorMsg = resp.ErrorMsg
+	}
+	s.schedulePersistLocked()
+	return true
+}
+
+// Fail 把调用标记为失败（例如请求没能发出去）
+func (s *DebugHistoryStore) Fail(rpcUid, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call, ok := s.byUid[rpcUid]
+	if !ok {
+		return
+	}
+	call.Status = DebugCallError
+	call.ErrorMsg = errMsg
+	call.CompletedAt = time.Now().UnixMilli()
+	s.schedulePersistLocked()
+}
+
+// Get 按 rpcUid 获取调用记录的副本
+func (s *DebugHistoryStore) Get(rpcUid string) (DebugCall, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call, ok := s.byUid[rpcUid]
+	if !ok {
+		return DebugCall{}, false
+	}
+	s.expireLocked(call, time.Now())
+	return *call, true
+}
+
+// Search 按条件倒序（最新在前）分页查询，返回命中总数与当前页
+func (s *DebugHistoryStore) Search(q DebugHistoryQuery) (int, []DebugCall) {
+	keyword := strings.ToLower(strings.TrimSpace(q.Keyword))
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matched := make([]DebugCall, 0)
+	for i := len(s.calls) - 1; i >= 0; i-- {
+		call := s.calls[i]
+		if q.TargetRouteId != "" && call.TargetRouteId != q.TargetRouteId {
+			continue
+		}
+		if q.PacketId > 0 && call.PacketId != q.PacketId {
+			continue
+		}
+		if keyword != "" && !debugCallContains(call, keyword) {
+			continue
+		}
+		s.expireLocked(call, now)
+		matched = append(matched, *call)
+	}
+	total := len(matched)
+	if q.Offset >= total {
+		return total, []DebugCall{}
+	}
+	matched = matched[max(q.Offset, 0):]
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return total, matched
+}
+
+func debugCallContains(call *DebugCall, keyword string) bool {
+	fields := []string{call.RpcUid, call.TargetRouteId, call.Method, call.Operator, call.ErrorMsg, intToString(call.PacketId), string(call.Response)}
+	for _, p := range call.Params {
+		fields = append(fields, string(p))
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DebugHistoryStore) expireLocked(call *DebugCall, now time.Time) {
+	if call.Status == DebugCallPending && now.Sub(time.UnixMilli(call.CreatedAt)) > debugCallPendingTimeout {
+		call.Status = DebugCallTimeout
+	}
+}
+
+// SaveTemplate 保存模板；Id 为空时新建，否则覆盖同 Id 的模板
+func (s *DebugHistoryStore) SaveTemplate(tpl DebugTemplate) (DebugTemplate, error) {
+	tpl.Name = strings.TrimSpace(tpl.Name)
+	if tpl.Name == "" {
+		return DebugTemplate{}, errors.New("模板名称不能为空")
+	}
+	if tpl.TargetRouteId == "" || tpl.PacketId <= 0 {
+		return DebugTemplate{}, errors.New("模板必须指定 targetRouteId 与 packetId")
+	}
+	if tpl.CreatedAt == 0 {
+		tpl.CreatedAt = time.Now().UnixMilli()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tpl.Id == "" {
+		tpl.Id = newCenterRpcUid("tpl")
+		s.templates = append(s.templates, &tpl)
+	} else {
+		replaced := false
+		for i, old := range s.templates {
+			if old.Id == tpl.Id {
+				s.templates[i] = &tpl
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			return DebugTemplate{}, errors.New("模板不存在: " + tpl.Id)
+		}
+	}
+	s.schedulePersistLocked()
+	return tpl, nil
+}
+
+// DeleteTemplate 删除模板，不存在时返回 false
+func (s *DebugHistoryStore) DeleteTemplate(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, tpl := range s.templates {
+		if tpl.Id == id {
+			s.templates = append(s.templates[:i], s.templates[i+1:]...)
+			s.schedulePersistLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// Template 按 Id 获取模板
+func (s *DebugHistoryStore) Template(id string) (DebugTemplate, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tpl := range s.templates {
+		if tpl.Id == id {
+			return *tpl, true
+		}
+	}
+	return DebugTemplate{}, false
+}
+
+// Templates 列出模板，可按节点与 packetId 过滤
+func (s *DebugHistoryStore) Templates(targetRouteId string, packetId int) []DebugTemplate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]DebugTemplate, 0, len(s.templates))
+	for _, tpl := range s.templates {
+		if targetRouteId != "" && tpl.TargetRouteId != targetRouteId {
+			continue
+		}
+		if packetId > 0 && tpl.PacketId != packetId {
+			continue
+		}
+		list = append(list, *tpl)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *DebugHistoryStore) trimLocked() {
+	if len(s.calls) <= s.limit {
+		return
+	}
+	drop := len(s.calls) - s.limit
+	for _, call := range s.calls[:drop] {
+		delete(s.byUid, call.RpcUid)
+	}
+	s.calls = append([]*DebugCall(nil), s.calls[drop:]...)
+}
+
+// schedulePersistLocked 排队一次延迟落盘，已有排队任务时合并
+func (s *DebugHistoryStore) schedulePersistLocked() {
+	if s.filePath == "" || s.persistTimer != nil {
+		return
+	}
+	s.persistTimer = time.AfterFunc(debugHistoryPersistDelay, s.persist)
+}
+
+// Flush 立即写出尚未落盘的变更，进程退出前调用
+func (s *DebugHistoryStore) Flush() {
+	s.mu.Lock()
+	if s.persistTimer == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.persistTimer.Stop()
+	s.mu.Unlock()
+	s.persist()
+}
+
+// persist 在锁内复制快照，锁外序列化并整体写入临时文件后替换，避免进程中断留下半个文件
+func (s *DebugHistoryStore) persist() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	if s.persistTimer == nil {
+		// 已被 Flush 或上一次 persist 写出
+		s.mu.Unlock()
+		return
+	}
+	s.persistTimer = nil
+	file := debugHistoryFile{Calls: make([]*DebugCall, len(s.calls)), Templates: make([]*DebugTemplate, len(s.templates))}
+	for i, call := range s.calls {
+		c := *call
+		file.Calls[i] = &c
+	}
+	for i, tpl := range s.templates {
+		t := *tpl
+		file.Templates[i] = &t
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		componentLog(LogComponentDebug).Warn("序列化 RPC 调试历史失败", "error", err)
+		return
+	}
+	if dir := filepath.Dir(s.filePath); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	tmp := s.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		componentLog(LogComponentDebug).Warn("写入 RPC 调试历史失败", "file", s.filePath, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, s.filePath); err != nil {
+		componentLog(LogComponentDebug).Warn("写入 RPC 调试历史失败", "file", s.filePath, "error", err)
+	}
+}
@@ -0,0 +1,66 @@
+package VirtualRouterServer
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// cpuSampleMinInterval 两次 CPU 采样的最小间隔，间隔过短时返回上一次的结果
+const cpuSampleMinInterval = time.Second
+
+// HostMetrics 进程与主机指标；Linux 从 /proc 读取，其他平台 Available 为 false
+type HostMetrics struct {
+	Available bool   `json:"available"`
+	OsVersion string `json:"osVersion"`
+	// ProcessCpuTime 进程累计占用的 CPU 时间（用户态 + 内核态）
+	ProcessCpuTime time.Duration `json:"-"`
+	LoadAverage    [3]float64    `json:"loadAverage"`
+	ProcessRss     int64         `json:"processRss"`
+	ProcessThreads int           `json:"processThreads"`
+
+	OpenFds int    `json:"openFds"`
+	MaxFds  uint64 `json:"maxFds"`
+	// TcpInUse/TcpTimeWait/TcpOrphan/UdpInUse 主机级 socket 统计（/proc/net/sockstat）
+	TcpInUse    int `json:"tcpInUse"`
+	TcpTimeWait int `json:"tcpTimeWait"`
+	TcpOrphan   int `json:"tcpOrphan"`
+	UdpInUse    int `json:"udpInUse"`
+
+	MemTotal     int64 `json:"memTotal"`
+	MemAvailable int64 `json:"memAvailable"`
+	MemFree      int64 `json:"memFree"`
+}
+
+// cpuSampler 根据两次采样之间的进程 CPU 时间计算使用率，结果为占全部核心的百分比
+type cpuSampler struct {
+	mu    sync.Mutex
+	at    time.Time
+	cpu   time.Duration
+	usage float64
+}
+
+func (c *cpuSampler) sample(now time.Time, cpu time.Duration, cores int) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := now.Sub(c.at)
+	if elapsed < cpuSampleMinInterval {
+		return c.usage
+	}
+	if cores < 1 {
+		cores = 1
+	}
+	if delta := cpu - c.cpu; delta >= 0 {
+		c.usage = min(100, float64(delta)*100/(float64(elapsed)*float64(cores)))
+	}
+	c.at, c.cpu = now, cpu
+	return c.usage
+}
+
+// ProcessCpuUsage 进程 CPU 使用率（占全部核心的百分比），首次调用为启动以来的平均值
+func (s *Server) ProcessCpuUsage(m HostMetrics) float64 {
+	if !m.Available {
+		return 0
+	}
+	return s.cpu.sample(time.Now(), m.ProcessCpuTime, runtime.NumCPU())
+}
@@ -0,0 +1,125 @@
+//go:build linux
+
+package VirtualRouterServer
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ReadHostMetrics 从 /proc 读取进程与主机指标，单项读取失败时保留零值
+func ReadHostMetrics() HostMetrics {
+	m := HostMetrics{Available: true}
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		m.OsVersion = strings.TrimSpace(string(data))
+	}
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err == nil {
+		m.ProcessCpuTime = time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+	}
+	m.LoadAverage = readLoadAverage("/proc/loadavg")
+	readProcStatus("/proc/self/status", &m)
+	m.OpenFds = countProcessFds("/proc/self/fd")
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err == nil {
+		m.MaxFds = rl.Cur
+	}
+	readSockstat("/proc/net/sockstat", &m)
+	readMeminfo("/proc/meminfo", &m)
+	return m
+}
+
+func readLoadAverage(path string) [3]float64 {
+	var out [3]float64
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return out
+	}
+	fields := strings.Fields(string(data))
+	for i := 0; i < len(out) && i < len(fields); i++ {
+		out[i], _ = strconv.ParseFloat(fields[i], 64)
+	}
+	return out
+}
+
+// readProcStatus 读取 VmRSS 与 Threads
+func readProcStatus(path string, m *HostMetrics) {
+	eachProcLine(path, func(key string, fields []string) {
+		switch key {
+		case "VmRSS:":
+			m.ProcessRss = parseKb(fields)
+		case "Threads:":
+			if len(fields) > 0 {
+				m.ProcessThreads, _ = strconv.Atoi(fields[0])
+			}
+		}
+	})
+}
+
+// countProcessFds 统计打开的文件句柄数，只读目录不逐个 readlink，句柄很多时也不会拖慢监控接口；
+// ReadDir 读取 /proc/self/fd 时自己打开的目录句柄也在列表里，需要减掉。socket 情况看 /proc/net/sockstat
+func countProcessFds(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return 0
+	}
+	return len(entries) - 1
+}
+
+// readSockstat 解析 "TCP: inuse 5 orphan 0 tw 2 alloc 7 mem 1" 形式的行
+func readSockstat(path string, m *HostMetrics) {
+	eachProcLine(path, func(key string, fields []string) {
+		values := make(map[string]int, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			values[fields[i]], _ = strconv.Atoi(fields[i+1])
+		}
+		switch key {
+		case "TCP:":
+			m.TcpInUse, m.TcpOrphan, m.TcpTimeWait = values["inuse"], values["orphan"], values["tw"]
+		case "UDP:":
+			m.UdpInUse = values["inuse"]
+		}
+	})
+}
+
+func readMeminfo(path string, m *HostMetrics) {
+	eachProcLine(path, func(key string, fields []string) {
+		switch key {
+		case "MemTotal:":
+			m.MemTotal = parseKb(fields)
+		case "MemAvailable:":
+			m.MemAvailable = parseKb(fields)
+		case "MemFree:":
+			m.MemFree = parseKb(fields)
+		}
+	})
+}
+
+// eachProcLine 逐行回调 "key value..." 形式的 /proc 文件
+func eachProcLine(path string, fn func(key string, fields []string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			fn(fields[0], fields[1:])
+		}
+	}
+}
+
+// parseKb 解析 "123 kB" 为字节数
+func parseKb(fields []string) int64 {
+	if len(fields) == 0 {
+		return 0
+	}
+	v, _ := strconv.ParseInt(fields[0], 10, 64)
+	return v * 1024
+}
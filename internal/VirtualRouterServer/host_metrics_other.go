@@ -0,0 +1,8 @@
+//go:build !linux
+
+package VirtualRouterServer
+
+// ReadHostMetrics 非 Linux 平台没有 /proc，只返回不可用标记
+func ReadHostMetrics() HostMetrics {
+	return HostMetrics{}
+}
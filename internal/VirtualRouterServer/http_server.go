@@ -2,73 +2,125 @@ package VirtualRouterServer
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/neko233-com/virtual-router-go/internal/capture"
 	"github.com/neko233-com/virtual-router-go/internal/config"
 	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 type HttpServer struct {
-	cfg  *config.RouterServerConfig
-	srv  *Server
-	http *http.Server
+	cfg   *config.RouterServerConfig
+	srv   *Server
+	http  *http.Server
+	audit *AuditLog
+	// cfgMu 保护 cfg 中运行时可修改的字段：账号、网关 API Key、日志配置，以及 desired
+	cfgMu sync.RWMutex
+	// desired 最近一次热更新或后台编辑得到的完整配置（含需要重启才生效的项），为 nil 表示与 cfg 一致
+	desired    *config.RouterServerConfig
+	loginGuard *loginGuard
+	sessions   *adminSessionStore
+	totp       *totpState
 }
 
 const authCookieName = "virtual-router-admin-token"
 
 func NewHttpServer(cfg *config.RouterServerConfig, srv *Server) *HttpServer {
-	return &HttpServer{cfg: cfg, srv: srv}
+	h := &HttpServer{cfg: cfg, srv: srv, loginGuard: newLoginGuard(), sessions: newAdminSessionStore(), totp: newTotpState()}
+	h.prepareAdminUsers()
+	if cfg != nil {
+		h.audit = NewAuditLog(cfg.AuditLogFile, cfg.AuditLogLimit)
+	} else {
+		h.audit = NewAuditLog("", 0)
+	}
+	return h
 }
 
 func (h *HttpServer) Start(ctx context.Context) error {
+	if err := ConfigureJWT(h.cfg.JWT); err != nil {
+		return err
+	}
+	if h.cfg.JWT != nil && h.cfg.JWT.RotateIntervalHours > 0 {
+		go runJWTRotation(ctx, time.Duration(h.cfg.JWT.RotateIntervalHours)*time.Hour)
+	}
+	h.http = &http.Server{
+		Addr:              ":" + intToString(h.cfg.HTTPMonitorPort),
+		Handler:           withCORS(h.routes()),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = h.http.Shutdown(context.Background())
+		_ = h.audit.Close()
+	}()
+
+	componentLog(LogComponentHttp).Info("HTTP Monitor 启动成功", "port", h.cfg.HTTPMonitorPort)
+	logHTTPAccessURLs("HTTP Monitor", h.cfg.HTTPMonitorPort)
+	return h.http.ListenAndServe()
+}
+
+func (h *HttpServer) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/api/auth/login", h.handleLogin)
+	mux.HandleFunc("/api/auth/login", h.withAudit(AuditActionLogin, h.handleLogin))
 	mux.HandleFunc("/api/auth/refresh", h.handleRefresh)
 	mux.HandleFunc("/api/auth/validate", h.handleValidate)
-	mux.HandleFunc("/api/auth/logout", h.handleLogout)
+	mux.HandleFunc("/api/auth/logout", h.withAudit(AuditActionLogout, h.handleLogout))
+	mux.HandleFunc("/api/auth/sessions", h.withAudit(AuditActionSessionRevoke, h.withRole(RoleAdmin, h.handleAdminSessions)))
+	mux.HandleFunc("/api/auth/totp", h.withAudit(AuditActionTotp, h.withAuth(h.handleTotp)))
 
 	mux.HandleFunc("/api/status", h.withAuth(h.handleStatus))
 	mux.HandleFunc("/api/metrics", h.withAuth(h.handleMetrics))
+	mux.HandleFunc("/api/metrics/history", h.withAuth(h.handleMetricsHistory))
 	mux.HandleFunc("/api/routers", h.withAuth(h.handleRouters))
 	mux.HandleFunc("/api/connections", h.withAuth(h.handleConnections))
 	mux.HandleFunc("/api/rpc-stats", h.withAuth(h.handleRpcStats))
 	mux.HandleFunc("/api/rpc/router-ranking", h.withAuth(h.handleRouterRPCRanking))
+	mux.HandleFunc("/api/rpc/conflicts", h.withAuth(h.handleRpcConflicts))
+	mux.HandleFunc("/api/rpc/schema", h.withAuth(h.handleRpcSchema))
 	mux.HandleFunc("/api/message-stats", h.withAuth(h.handleMessageStats))
 	mux.HandleFunc("/api/monitor-stats", h.withAuth(h.handleMonitorStats))
 	mux.HandleFunc("/api/viewers", h.withAuth(h.handleViewers))
 	mux.HandleFunc("/api/logs", h.withAuth(h.handleLogs))
 	mux.HandleFunc("/api/logs/export", h.withAuth(h.handleLogsExport))
+	mux.HandleFunc("/api/logs/levels", h.withAudit(AuditActionLogLevelUpdate, h.withWriteRole(RoleAdmin, h.handleLogLevels)))
+	mux.HandleFunc("/api/logs/search", h.withAuth(h.handleLogSearch))
+	mux.HandleFunc("/api/logs/search/export", h.withAuth(h.handleLogSearchExport))
 	mux.HandleFunc("/api/system/settings", h.withAuth(h.handleSystemSettings))
-	mux.HandleFunc("/api/system/admin-password", h.withAuth(h.handleUpdateAdminPassword))
+	mux.HandleFunc("/api/system/admin-password", h.withAudit(AuditActionPasswordUpdate, h.withAuth(h.handleUpdateAdminPassword)))
 
 	mux.HandleFunc("/api/debug/validate-route-id", h.withAuth(h.handleValidateRouteId))
 	mux.HandleFunc("/api/debug/available-routes", h.withAuth(h.handleAvailableRoutes))
-	mux.HandleFunc("/api/debug/send-rpc", h.withAuth(h.handleDebugSendRpc))
+	mux.HandleFunc("/api/debug/send-rpc", h.withAudit(AuditActionDebugSendRpc, h.withRole(RoleOperator, h.handleDebugSendRpc)))
 	mux.HandleFunc("/api/debug/rpc-result", h.withAuth(h.handleDebugRpcResult))
 	mux.HandleFunc("/api/debug/rpc-stubs", h.withAuth(h.handleDebugRpcStubs))
+	mux.HandleFunc("/api/debug/history", h.withAuth(h.handleDebugHistory))
+	mux.HandleFunc("/api/debug/history/replay", h.withAudit(AuditActionDebugReplay, h.withRole(RoleOperator, h.handleDebugReplay)))
+	mux.HandleFunc("/api/debug/templates", h.withAudit(AuditActionDebugTemplate, h.withWriteRole(RoleOperator, h.handleDebugTemplates)))
+	mux.HandleFunc("/api/capture", h.withAudit(AuditActionCaptureControl, h.withWriteRole(RoleOperator, h.handleCapture)))
+	mux.HandleFunc("/api/audit", h.withRole(RoleAdmin, h.handleAudit))
+	mux.HandleFunc("/api/system/config", h.withAudit(AuditActionConfigUpdate, h.withRole(RoleAdmin, h.handleSystemConfig)))
+	mux.HandleFunc("/api/system/config/reload", h.withAudit(AuditActionConfigReload, h.withRole(RoleAdmin, h.handleConfigReload)))
+	mux.HandleFunc("/api/system/jwt-keys", h.withAudit(AuditActionJWTKeyRotate, h.withRole(RoleAdmin, h.handleJWTKeys)))
+	mux.HandleFunc("/api/users", h.withAudit(AuditActionUserManage, h.withRole(RoleAdmin, h.handleAdminUsers)))
+	mux.HandleFunc("/api/tap/stream", h.withAuth(h.handleTapStream))
+	mux.HandleFunc("/rpc/", h.withGatewayAuth(h.handleRpcGateway))
 	mux.Handle("/", monitorStaticHandler())
-
-	h.http = &http.Server{
-		Addr:              ":" + intToString(h.cfg.HTTPMonitorPort),
-		Handler:           withCORS(mux),
-		ReadHeaderTimeout: 5 * time.Second,
-	}
-
-	go func() {
-		<-ctx.Done()
-		_ = h.http.Shutdown(context.Background())
-	}()
-
-	slog.Info("HTTP Monitor 启动成功", "port", h.cfg.HTTPMonitorPort)
-	logHTTPAccessURLs("HTTP Monitor", h.cfg.HTTPMonitorPort)
-	return h.http.ListenAndServe()
+	return mux
 }
 
 func (h *HttpServer) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -77,34 +129,65 @@ func (h *HttpServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
+		Username string `json:"username"`
 		Password string `json:"password"`
+		TotpCode string `json:"totpCode"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&req)
 
-	if h.cfg.AdminPassword == "" {
-		writeJSON(w, http.StatusForbidden, map[string]any{"success": false, "message": "服务端未配置 adminPassword，禁止登录"})
-		return
-	}
-	if req.Password == h.cfg.AdminPassword {
-		token, _ := GenerateToken("admin")
-		h.setAuthCookie(w, token)
-		writeJSON(w, http.StatusOK, map[string]any{
-			"success": true,
-			"message": "登录成功",
-			"data": map[string]any{
-				"token":     token,
-				"expiresIn": 24 * 60 * 60,
-				"tokenType": "Bearer",
-				"user": map[string]any{
-					"id":   "admin",
-					"name": "Administrator",
-					"role": "admin",
-				},
-			},
-		})
+	if !h.loginConfigured() {
+		writeJSON(w, http.StatusForbidden, map[string]any{"success": false, "message": "服务端未配置 adminPassword 或 adminUsers，禁止登录"})
+		return
+	}
+	ip := remoteIP(r)
+	if wait := h.loginGuard.lockedFor(ip, req.Username); wait > 0 {
+		writeLoginLocked(w, wait)
+		return
+	}
+	user, err := h.authenticate(req.Username, req.Password, req.TotpCode)
+	if errors.Is(err, errTotpRequired) {
+		// 密码正确但未填验证码，不计入失败次数
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": err.Error(), "data": map[string]any{"totpRequired": true}})
+		return
+	}
+	if err != nil {
+		if wait := h.loginGuard.fail(ip, req.Username); wait > 0 {
+			componentLog(LogComponentHttp).Warn("登录失败次数过多，已临时锁定", "ip", ip, "username", req.Username, "lock", wait)
+		}
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "密码错误"})
+	h.loginGuard.succeed(ip, req.Username)
+	setAuditOperator(r, user.Username)
+	token, _ := GenerateToken(user.Username)
+	if claims, ok := parseClaims(token); ok {
+		h.sessions.touch(claims, r)
+	}
+	h.setAuthCookie(w, token)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "登录成功",
+		"data": map[string]any{
+			"token":     token,
+			"expiresIn": 24 * 60 * 60,
+			"tokenType": "Bearer",
+			"user": map[string]any{
+				"id":   user.Username,
+				"name": user.Username,
+				"role": user.Role,
+			},
+		},
+	})
+}
+
+// loginConfigured 单账号模式需要配置 adminPassword，多账号模式至少有一个账号
+func (h *HttpServer) loginConfigured() bool {
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	if h.legacyAdminModeLocked() {
+		return strings.TrimSpace(h.cfg.AdminPassword) != ""
+	}
+	return true
 }
 
 func (h *HttpServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
@@ -113,11 +196,18 @@ func (h *HttpServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "缺少 Token"})
 		return
 	}
+	if _, ok := h.lookupAdminUser(requestOperator(r)); !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "账号不存在或已被禁用，请重新登录"})
+		return
+	}
 	newToken, ok := RefreshToken(token)
 	if !ok {
 		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "Token 无效，请重新登录"})
 		return
 	}
+	if claims, ok := parseClaims(newToken); ok {
+		h.sessions.touch(claims, r)
+	}
 	h.setAuthCookie(w, newToken)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
@@ -136,38 +226,68 @@ func (h *HttpServer) handleValidate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "valid": false, "message": "缺少 Token"})
 		return
 	}
-	valid := ValidateToken(token)
+	user, exists := h.lookupAdminUser(requestOperator(r))
+	valid := ValidateToken(token) && exists
+	data := map[string]any{
+		"remainingSeconds": GetTokenRemainingSeconds(token),
+		"shouldRefresh":    ShouldRefreshToken(token),
+	}
+	if valid {
+		data["user"] = map[string]any{"id": user.Username, "name": user.Username, "role": user.Role}
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"valid":   valid,
-		"data": map[string]any{
-			"remainingSeconds": GetTokenRemainingSeconds(token),
-			"shouldRefresh":    ShouldRefreshToken(token),
-		},
+		"data":    data,
 	})
 }
 
 func (h *HttpServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if sid := requestSessionId(r); sid != "" {
+		h.RevokeAdminSession(sid)
+	}
 	h.clearAuthCookie(w)
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "登出成功"})
 }
 
+// writeLoginLocked 登录被临时锁定时返回 429 与 Retry-After
+func writeLoginLocked(w http.ResponseWriter, wait time.Duration) {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	writeJSON(w, http.StatusTooManyRequests, map[string]any{
+		"success": false,
+		"message": "登录失败次数过多，请 " + strconv.FormatInt(seconds, 10) + " 秒后再试",
+	})
+}
+
 func (h *HttpServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 	_, _, _, _, uptime := h.srv.Stats()
+	build := CurrentBuildInfo()
+	host := ReadHostMetrics()
+	osVersion := host.OsVersion
+	if osVersion == "" {
+		osVersion = "-"
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"data": map[string]any{
 			"serverInfo": map[string]any{
 				"name":      "Virtual Router Center",
-				"version":   "0.0.10",
+				"version":   build.Version,
+				"commit":    build.Commit,
+				"buildTime": build.BuildTime,
+				"modified":  build.Modified,
 				"uptime":    uptime,
 				"startTime": time.Now().Add(-time.Duration(uptime) * time.Millisecond).UnixMilli(),
+				"pid":       os.Getpid(),
 			},
 			"system": map[string]any{
-				"osName":      runtime.GOOS,
-				"osVersion":   "-",
-				"javaVersion": "-",
-				"processors":  runtime.NumCPU(),
+				"osName":     runtime.GOOS,
+				"osVersion":  osVersion,
+				"arch":       runtime.GOARCH,
+				"goVersion":  build.GoVersion,
+				"processors": runtime.NumCPU(),
+				"gomaxprocs": runtime.GOMAXPROCS(0),
 			},
 			"router": map[string]any{
 				"port":        h.cfg.RouterServerPort,
@@ -186,13 +306,21 @@ func (h *HttpServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	if max > 0 {
 		usagePercent = int((used * 100) / max)
 	}
+	host := ReadHostMetrics()
+	hostMemUsed := host.MemTotal - host.MemAvailable
+	hostMemPercent := 0
+	if host.MemTotal > 0 {
+		hostMemPercent = int(hostMemUsed * 100 / host.MemTotal)
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"data": map[string]any{
+			"available": host.Available,
 			"cpu": map[string]any{
-				"usage":       0,
-				"loadAverage": 0,
-				"cores":       runtime.NumCPU(),
+				"usage":        math.Round(h.srv.ProcessCpuUsage(host)*10) / 10,
+				"loadAverage":  host.LoadAverage[0],
+				"loadAverages": host.LoadAverage,
+				"cores":        runtime.NumCPU(),
 			},
 			"memory": map[string]any{
 				"used":         used,
@@ -200,10 +328,30 @@ func (h *HttpServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 				"total":        max,
 				"free":         max - used,
 				"usagePercent": usagePercent,
+				"rss":          host.ProcessRss,
 			},
-			"thread": map[string]any{
+			"host": map[string]any{
+				"memTotal":        host.MemTotal,
+				"memAvailable":    host.MemAvailable,
+				"memFree":         host.MemFree,
+				"memUsed":         hostMemUsed,
+				"memUsagePercent": hostMemPercent,
+			},
+			"fd": map[string]any{
+				"open": host.OpenFds,
+				"max":  host.MaxFds,
+			},
+			"sockets": map[string]any{
+				"tcpInUse":    host.TcpInUse,
+				"tcpTimeWait": host.TcpTimeWait,
+				"tcpOrphan":   host.TcpOrphan,
+				"udpInUse":    host.UdpInUse,
+			},
+			"goroutine": map[string]any{
 				"count": runtime.NumGoroutine(),
-				"peak":  runtime.NumGoroutine(),
+			},
+			"thread": map[string]any{
+				"count": host.ProcessThreads,
 			},
 			"gc": []any{
 				map[string]any{
@@ -216,6 +364,37 @@ func (h *HttpServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleMetricsHistory 历史指标：metrics 逗号分隔，resolution 为 second / minute，window 为秒数，routeId 查询节点的 RPC 调用数
+func (h *HttpServer) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := MetricHistoryQuery{
+		Resolution: strings.TrimSpace(query.Get("resolution")),
+		RouteId:    strings.TrimSpace(query.Get("routeId")),
+	}
+	switch q.Resolution {
+	case "":
+		q.Resolution = MetricResolutionSecond
+	case MetricResolutionSecond, MetricResolutionMinute:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "resolution 仅支持 second / minute"})
+		return
+	}
+	if window, _ := strconv.ParseInt(query.Get("window"), 10, 64); window > 0 {
+		q.Window = time.Duration(window) * time.Second
+	}
+	for _, name := range strings.Split(query.Get("metrics"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !isHistoryMetric(name) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "未知指标: " + name})
+			return
+		}
+		q.Metrics = append(q.Metrics, name)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": h.srv.MetricsHistory(q)})
+}
+
 func (h *HttpServer) handleRouters(w http.ResponseWriter, r *http.Request) {
 	nodes := h.srv.SessionManager().GetAllSessionSnapshots()
 	keyword := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("keyword")))
@@ -244,6 +423,11 @@ func (h *HttpServer) handleRouters(w http.ResponseWriter, r *http.Request) {
 			countryLabel = "未知"
 		}
 
+		status := "ONLINE"
+		if n.Suspect {
+			status = "SUSPECT"
+		}
+
 		routers = append(routers, map[string]any{
 			"routeId":       n.RouterId,
 			"rpcHost":       n.HostForRpc,
@@ -262,9 +446,11 @@ func (h *HttpServer) handleRouters(w http.ResponseWriter, r *http.Request) {
 			"as":            geo.AS,
 			"stubCount":     n.StubCount,
 			"rpcMode":       rpcMode,
-			"status":        "ONLINE",
+			"status":        status,
 			"connected":     true,
 			"lastHeartbeat": n.LastHeartbeatMs,
+			"heartbeatMs":   n.HeartbeatIntervalMs,
+			"writeQueue":    n.WriteQueue,
 			"uptime":        0,
 		})
 	}
@@ -304,18 +490,76 @@ func (h *HttpServer) handleRouterRPCRanking(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-func (h *HttpServer) handleConnections(w http.ResponseWriter, r *http.Request) {
-	totalConn, _, _, totalRequests, _ := h.srv.Stats()
+func (h *HttpServer) handleRpcConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts := h.srv.SessionManager().StubConflicts()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"list":  conflicts,
+			"total": len(conflicts),
+		},
+	})
+}
+
+func (h *HttpServer) handleRpcSchema(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = RpcSchemaFormatOpenAPI
+	}
+	if format != RpcSchemaFormatOpenAPI && format != RpcSchemaFormatJsonSchema {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "format 仅支持 openapi / jsonschema"})
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("mode")))
+	if mode == "" {
+		mode = RpcSchemaModeMerged
+	}
+	if mode != RpcSchemaModeMerged && mode != RpcSchemaModeNode {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "mode 仅支持 merged / node"})
+		return
+	}
+	routeId := strings.TrimSpace(r.URL.Query().Get("routeId"))
+	if routeId != "" && h.srv.SessionManager().GetSession(routeId) == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "路由节点不存在: " + routeId})
+		return
+	}
+
+	docs := h.srv.ExportRpcSchema(format, mode, routeId)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"data": map[string]any{
-			"connectionHistory": []any{},
-			"totalConnections":  totalConn,
-			"totalRequests":     totalRequests,
+			"format":    format,
+			"mode":      mode,
+			"documents": docs,
 		},
 	})
 }
 
+func (h *HttpServer) handleConnections(w http.ResponseWriter, r *http.Request) {
+	totalConn, currentConn, _, totalRequests, _ := h.srv.Stats()
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	data := map[string]any{
+		"connectionHistory":   h.srv.ConnectionHistory(limit),
+		"totalConnections":    totalConn,
+		"currentConnections":  currentConn,
+		"rejectedConnections": h.srv.RejectedConnections(),
+		"totalRequests":       totalRequests,
+		"timelineNodes":       h.srv.SessionManager().SessionEventSummaries(),
+	}
+	// 指定 routeId 时返回该节点的时间线
+	if routeId := strings.TrimSpace(r.URL.Query().Get("routeId")); routeId != "" {
+		data["routeId"] = routeId
+		data["timeline"] = h.srv.SessionManager().SessionEvents(routeId, limit)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"data":    data,
+	})
+}
+
 func (h *HttpServer) handleRpcStats(w http.ResponseWriter, r *http.Request) {
 	_, _, totalBytes, totalRequests, _ := h.srv.Stats()
 	writeJSON(w, http.StatusOK, map[string]any{
@@ -369,10 +613,68 @@ func (h *HttpServer) handleViewers(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// logQuery /api/logs 与 /api/logs/export 的过滤条件
+type logQuery struct {
+	keyword   string
+	level     string
+	component string
+	// attrs 需要全部命中的结构化属性，来自 attr=key:value（可重复）与 routeId= 简写
+	attrs map[string]string
+}
+
+func parseLogQuery(r *http.Request) logQuery {
+	query := r.URL.Query()
+	q := logQuery{
+		keyword:   strings.TrimSpace(query.Get("keyword")),
+		level:     normalizeLogLevel(query.Get("level")),
+		component: strings.TrimSpace(query.Get("component")),
+		attrs:     map[string]string{},
+	}
+	for _, raw := range query["attr"] {
+		key, value, ok := strings.Cut(raw, ":")
+		if !ok {
+			key, value, ok = strings.Cut(raw, "=")
+		}
+		if key = strings.TrimSpace(key); ok && key != "" {
+			q.attrs[key] = strings.TrimSpace(value)
+		}
+	}
+	if routeId := strings.TrimSpace(query.Get("routeId")); routeId != "" {
+		q.attrs["routeId"] = routeId
+	}
+	return q
+}
+
+func (q logQuery) match(rec LogRecord) bool {
+	if q.keyword != "" && !strings.Contains(strings.ToLower(rec.Line), strings.ToLower(q.keyword)) {
+		return false
+	}
+	if !matchRecordLevel(rec, q.level) {
+		return false
+	}
+	if q.component != "" && rec.Component != q.component {
+		return false
+	}
+	for k, v := range q.attrs {
+		if got, ok := rec.Attrs[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (q logQuery) filter(records []LogRecord) []LogRecord {
+	out := make([]LogRecord, 0, len(records))
+	for _, rec := range records {
+		if q.match(rec) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
 func (h *HttpServer) handleLogs(w http.ResponseWriter, r *http.Request) {
 	limit := 200
-	keyword := strings.TrimSpace(r.URL.Query().Get("keyword"))
-	level := normalizeLogLevel(r.URL.Query().Get("level"))
 	if value := r.URL.Query().Get("limit"); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
 			if parsed > 1000 {
@@ -382,44 +684,30 @@ func (h *HttpServer) handleLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	lines := GetRecentProcessLogs(1000)
-	if keyword != "" {
-		filtered := make([]string, 0, len(lines))
-		lowerKeyword := strings.ToLower(keyword)
-		for _, line := range lines {
-			if strings.Contains(strings.ToLower(line), lowerKeyword) {
-				filtered = append(filtered, line)
-			}
-		}
-		lines = filtered
-	}
-	if level != "all" {
-		filtered := make([]string, 0, len(lines))
-		for _, line := range lines {
-			if matchLogLevel(line, level) {
-				filtered = append(filtered, line)
-			}
-		}
-		lines = filtered
+	q := parseLogQuery(r)
+	records := q.filter(GetRecentProcessLogRecords(1000))
+	if len(records) > limit {
+		records = records[len(records)-limit:]
 	}
-	if len(lines) > limit {
-		lines = lines[len(lines)-limit:]
+	lines := make([]string, 0, len(records))
+	for _, rec := range records {
+		lines = append(lines, rec.Line)
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"success": true,
 		"data": map[string]any{
-			"lines":   lines,
-			"count":   len(lines),
-			"keyword": keyword,
-			"level":   level,
+			"lines":     lines,
+			"records":   records,
+			"count":     len(lines),
+			"keyword":   q.keyword,
+			"level":     q.level,
+			"component": q.component,
 		},
 	})
 }
 
 func (h *HttpServer) handleLogsExport(w http.ResponseWriter, r *http.Request) {
 	limit := 1000
-	keyword := strings.TrimSpace(r.URL.Query().Get("keyword"))
-	level := normalizeLogLevel(r.URL.Query().Get("level"))
 	if value := r.URL.Query().Get("limit"); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
 			if parsed > 5000 {
@@ -429,25 +717,10 @@ func (h *HttpServer) handleLogsExport(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	lines := GetRecentProcessLogs(limit)
-	if keyword != "" {
-		filtered := make([]string, 0, len(lines))
-		lowerKeyword := strings.ToLower(keyword)
-		for _, line := range lines {
-			if strings.Contains(strings.ToLower(line), lowerKeyword) {
-				filtered = append(filtered, line)
-			}
-		}
-		lines = filtered
-	}
-	if level != "all" {
-		filtered := make([]string, 0, len(lines))
-		for _, line := range lines {
-			if matchLogLevel(line, level) {
-				filtered = append(filtered, line)
-			}
-		}
-		lines = filtered
+	records := parseLogQuery(r).filter(GetRecentProcessLogRecords(limit))
+	lines := make([]string, 0, len(records))
+	for _, rec := range records {
+		lines = append(lines, rec.Line)
 	}
 
 	fileName := "router-logs-" + time.Now().Format("20060102-150405") + ".txt"
@@ -456,25 +729,176 @@ func (h *HttpServer) handleLogsExport(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(strings.Join(lines, "\n")))
 }
 
+func parseLogSearchQuery(r *http.Request) (LogSearchQuery, error) {
+	query := r.URL.Query()
+	from, err := parseLogTimeParam(query.Get("from"))
+	if err != nil {
+		return LogSearchQuery{}, err
+	}
+	to, err := parseLogTimeParam(query.Get("to"))
+	if err != nil {
+		return LogSearchQuery{}, err
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return LogSearchQuery{}, errors.New("结束时间不能早于开始时间")
+	}
+	q := LogSearchQuery{
+		From:      from,
+		To:        to,
+		Keyword:   strings.TrimSpace(query.Get("keyword")),
+		Level:     normalizeLogLevel(query.Get("level")),
+		Component: strings.TrimSpace(query.Get("component")),
+	}
+	q.Offset, _ = strconv.Atoi(query.Get("offset"))
+	q.Limit, _ = strconv.Atoi(query.Get("limit"))
+	return q, nil
+}
+
+// handleLogSearch 在当前与已轮转（含 .gz）的日志文件中检索，结果最新在前分页返回
+func (h *HttpServer) handleLogSearch(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLogSearchQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	result, err := SearchLogFiles(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": result})
+}
+
+// handleLogSearchExport 按检索条件从旧到新流式导出，不受内存缓冲与分页限制
+func (h *HttpServer) handleLogSearchExport(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLogSearchQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	fileName := "router-logs-" + time.Now().Format("20060102-150405") + ".txt"
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	// 响应头已发出，中途出错只能记录日志
+	if count, err := ExportLogFiles(w, q, flush); err != nil {
+		componentLog(LogComponentHttp).Warn("导出历史日志中断", "lines", count, "error", err)
+	}
+}
+
+// handleAudit 查询审计日志，支持 keyword/operator/action/from/to 过滤与 offset/limit 分页
+func (h *HttpServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := parseLogTimeParam(query.Get("from"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	to, err := parseLogTimeParam(query.Get("to"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	q := AuditQuery{
+		Keyword:  strings.TrimSpace(query.Get("keyword")),
+		Operator: strings.TrimSpace(query.Get("operator")),
+		Action:   strings.TrimSpace(query.Get("action")),
+		From:     from,
+		To:       to,
+		Limit:    50,
+	}
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		q.Offset = v
+	}
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		q.Limit = min(v, 500)
+	}
+	total, items := h.audit.Search(q)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"total":  total,
+			"offset": q.Offset,
+			"limit":  q.Limit,
+			"items":  items,
+		},
+	})
+}
+
+// handleLogLevels GET 查看当前日志级别；POST {"component":"gateway","level":"debug"} 运行时修改
+func (h *HttpServer) handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Component string `json:"component"`
+			Level     string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求体格式错误"})
+			return
+		}
+		if err := SetLogLevel(req.Component, req.Level); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		componentLog(LogComponentHttp).Info("日志级别已修改", "component", req.Component, "level", req.Level, "operator", requestOperator(r))
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": LogLevels()})
+}
+
 func normalizeLogLevel(raw string) string {
 	v := strings.ToLower(strings.TrimSpace(raw))
 	switch v {
-	case "info", "warn", "error", "all":
+	case "debug", "info", "warn", "error", "all":
 		return v
 	default:
 		return "all"
 	}
 }
 
+// matchRecordLevel 结构化记录按真实级别过滤，原始文本行退回到关键字匹配
+func matchRecordLevel(rec LogRecord, level string) bool {
+	if level == "all" || level == "" {
+		return true
+	}
+	recLevel, err := parseLogLevel(rec.Level)
+	if rec.Level == "" || err != nil {
+		return matchLogLevel(rec.Line, level)
+	}
+	switch level {
+	case "debug":
+		return recLevel < slog.LevelInfo
+	case "info":
+		return recLevel >= slog.LevelInfo && recLevel < slog.LevelWarn
+	case "warn":
+		return recLevel >= slog.LevelWarn && recLevel < slog.LevelError
+	case "error":
+		return recLevel >= slog.LevelError
+	default:
+		return true
+	}
+}
+
 func matchLogLevel(line, level string) bool {
 	lower := strings.ToLower(line)
 	switch level {
+	case "debug":
+		return strings.Contains(lower, "debug")
 	case "error":
 		return strings.Contains(lower, "error") || strings.Contains(lower, "fatal")
 	case "warn":
 		return strings.Contains(lower, "warn")
 	case "info":
-		if strings.Contains(lower, "error") || strings.Contains(lower, "fatal") || strings.Contains(lower, "warn") {
+		if strings.Contains(lower, "error") || strings.Contains(lower, "fatal") || strings.Contains(lower, "warn") || strings.Contains(lower, "debug") {
 			return false
 		}
 		return true
@@ -489,8 +913,10 @@ func (h *HttpServer) handleSystemSettings(w http.ResponseWriter, r *http.Request
 		"data": map[string]any{
 			"routerServerPort":        h.cfg.RouterServerPort,
 			"httpMonitorPort":         h.cfg.HTTPMonitorPort,
-			"adminPasswordConfigured": strings.TrimSpace(h.cfg.AdminPassword) != "",
-			"logBufferCapacity":       800,
+			"adminPasswordConfigured": h.loginConfigured(),
+			"adminUserCount":          len(h.ListAdminUsers()),
+			"logBufferCapacity":       globalLogs.getCapacity(),
+			"logFormat":               LogLevels().Format,
 		},
 	})
 }
@@ -509,18 +935,8 @@ func (h *HttpServer) handleUpdateAdminPassword(w http.ResponseWriter, r *http.Re
 		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
 		return
 	}
-	if req.OldPassword != h.cfg.AdminPassword {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "旧密码不正确"})
-		return
-	}
-	if len(strings.TrimSpace(req.NewPassword)) < 4 {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "新密码长度至少 4 位"})
-		return
-	}
-
-	h.cfg.AdminPassword = strings.TrimSpace(req.NewPassword)
-	if err := config.WriteRouterServerConfig("", h.cfg); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "message": "更新成功但写入配置失败: " + err.Error()})
+	if err := h.changeOwnPassword(requestOperator(r), requestSessionId(r), req.OldPassword, req.NewPassword); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
 		return
 	}
 
@@ -530,6 +946,23 @@ func (h *HttpServer) handleUpdateAdminPassword(w http.ResponseWriter, r *http.Re
 	})
 }
 
+// handleJWTKeys GET 查看签名密钥，POST 立即轮换
+func (h *HttpServer) handleJWTKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": JWTKeys()})
+	case http.MethodPost:
+		key, err := RotateJWTKey()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "签名密钥已轮换，旧密钥签发的 Token 在宽限期内仍然有效", "data": key})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+	}
+}
+
 func (h *HttpServer) handleValidateRouteId(w http.ResponseWriter, r *http.Request) {
 	routeId := r.URL.Query().Get("routeId")
 	if strings.TrimSpace(routeId) == "" {
@@ -567,69 +1000,277 @@ func (h *HttpServer) handleDebugSendRpc(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var req struct {
-		TargetRouteId string `json:"targetRouteId"`
-		PacketId      int    `json:"packetId"`
-		Params        []any  `json:"params"`
+		TargetRouteId string            `json:"targetRouteId"`
+		PacketId      int               `json:"packetId"`
+		Params        []json.RawMessage `json:"params"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&req)
 
-	if req.TargetRouteId == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "路由节点不存在"})
+	call, err := h.srv.SendDebugRpc(req.TargetRouteId, req.PacketId, req.Params, requestOperator(r), "")
+	if err != nil {
+		gwErr := asGatewayError(err)
+		writeJSON(w, gwErr.HTTPStatus, map[string]any{"success": false, "message": gwErr.Message})
 		return
 	}
-	session := h.srv.SessionManager().GetSession(req.TargetRouteId)
-	if session == nil {
-		writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "路由节点不存在: " + req.TargetRouteId})
+	writeDebugCallSent(w, call, "✅ RPC 调试请求已发送")
+}
+
+func writeDebugCallSent(w http.ResponseWriter, call *DebugCall, message string) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": message,
+		"data": map[string]any{
+			"targetRouteId": call.TargetRouteId,
+			"packetId":      call.PacketId,
+			"method":        call.Method,
+			"paramsCount":   len(call.Params),
+			"requestId":     call.RpcUid,
+			"replayOf":      call.ReplayOf,
+			"status":        "sent",
+			"note":          "RPC 请求已通过 Router Center 转发到目标节点，等待响应（异步调用）",
+		},
+	})
+}
+
+// handleDebugHistory 查询调试历史，支持 keyword/routeId/packetId 过滤与 offset/limit 分页
+func (h *HttpServer) handleDebugHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := DebugHistoryQuery{
+		Keyword:       strings.TrimSpace(query.Get("keyword")),
+		TargetRouteId: strings.TrimSpace(query.Get("routeId")),
+		Limit:         50,
+	}
+	if v, err := strconv.Atoi(query.Get("packetId")); err == nil {
+		q.PacketId = v
+	}
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		q.Offset = v
+	}
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		q.Limit = min(v, 500)
+	}
+	if id := strings.TrimSpace(query.Get("requestId")); id != "" {
+		call, ok := h.srv.DebugHistory().Get(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "调试记录不存在: " + id})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": call})
 		return
 	}
-	if req.PacketId <= 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "Packet ID 必须大于 0"})
+	total, items := h.srv.DebugHistory().Search(q)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"total":  total,
+			"offset": q.Offset,
+			"limit":  q.Limit,
+			"items":  items,
+		},
+	})
+}
+
+// handleDebugReplay 按历史记录的目标与参数重新发送一次，params 非空时覆盖原参数
+func (h *HttpServer) handleDebugReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
 		return
 	}
+	var req struct {
+		RequestId     string            `json:"requestId"`
+		TemplateId    string            `json:"templateId"`
+		TargetRouteId string            `json:"targetRouteId"`
+		Params        []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
+		return
+	}
+
+	var targetRouteId, replayOf string
+	var packetId int
+	var params []json.RawMessage
+	switch {
+	case req.RequestId != "":
+		call, ok := h.srv.DebugHistory().Get(req.RequestId)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "调试记录不存在: " + req.RequestId})
+			return
+		}
+		targetRouteId, packetId, params, replayOf = call.TargetRouteId, call.PacketId, call.Params, call.RpcUid
+	case req.TemplateId != "":
+		tpl, ok := h.srv.DebugHistory().Template(req.TemplateId)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "模板不存在: " + req.TemplateId})
+			return
+		}
+		targetRouteId, packetId, params = tpl.TargetRouteId, tpl.PacketId, tpl.Params
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "requestId 与 templateId 至少提供一个"})
+		return
+	}
+	// 同一个方法可以重放到另一个节点
+	if req.TargetRouteId != "" {
+		targetRouteId = req.TargetRouteId
+	}
+	if req.Params != nil {
+		params = req.Params
+	}
 
-	stub := findStub(session.RpcServerInfo.Stubs, req.PacketId)
-	if stub == nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "目标节点未注册 Packet ID = " + intToString(req.PacketId)})
+	call, err := h.srv.SendDebugRpc(targetRouteId, packetId, params, requestOperator(r), replayOf)
+	if err != nil {
+		gwErr := asGatewayError(err)
+		writeJSON(w, gwErr.HTTPStatus, map[string]any{"success": false, "message": gwErr.Message})
 		return
 	}
+	writeDebugCallSent(w, call, "✅ RPC 调试请求已重放")
+}
+
+// handleDebugTemplates GET 列出模板（可按 routeId/packetId 过滤），POST 新建或覆盖，DELETE ?id= 删除
+func (h *HttpServer) handleDebugTemplates(w http.ResponseWriter, r *http.Request) {
+	store := h.srv.DebugHistory()
+	switch r.Method {
+	case http.MethodGet:
+		packetId, _ := strconv.Atoi(r.URL.Query().Get("packetId"))
+		list := store.Templates(strings.TrimSpace(r.URL.Query().Get("routeId")), packetId)
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": list})
+	case http.MethodPost:
+		var tpl DebugTemplate
+		if err := json.NewDecoder(r.Body).Decode(&tpl); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
+			return
+		}
+		tpl.Operator = requestOperator(r)
+		tpl.CreatedAt = 0
+		saved, err := store.SaveTemplate(tpl)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "模板已保存", "data": saved})
+	case http.MethodDelete:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if !store.DeleteTemplate(id) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "模板不存在: " + id})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "模板已删除"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+	}
+}
 
-	requestId := intToString(int(time.Now().UnixMilli()))
-	argsJson := make([]string, 0, len(req.Params))
-	for _, p := range req.Params {
-		b, _ := json.Marshal(p)
-		argsJson = append(argsJson, string(b))
+// handleCapture GET 查看抓包状态；POST {"action":"start","file":"x","filter":{...},"maxBytes":0,"maxFrames":0,"maxDurationMs":0} 开始
+// （上限为 0 使用默认值），{"action":"stop"} 停止
+func (h *HttpServer) handleCapture(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": h.srv.CaptureStatus()})
+	case http.MethodPost:
+		var req struct {
+			Action string         `json:"action"`
+			File   string         `json:"file"`
+			Filter capture.Filter `json:"filter"`
+			capture.Limits
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
+			return
+		}
+		var status CaptureStatus
+		var err error
+		switch req.Action {
+		case "start":
+			status, err = h.srv.StartCapture(req.File, req.Filter, req.Limits)
+		case "stop":
+			status, err = h.srv.StopCapture()
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "action 仅支持 start / stop"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": status})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
 	}
+}
 
-	rpcReq := map[string]any{
-		"rpcUid":             requestId,
-		"packetId":           req.PacketId,
-		"methodArgsJsonList": argsJson,
-		"fromDebug":          true,
+// handleTapStream 以 SSE 推送实时消息摘要。
+// 过滤参数: routeId / type / packetId（均可逗号分隔多个），sample=N 表示每 N 条推 1 条。
+func (h *HttpServer) handleTapStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "message": "当前连接不支持流式响应"})
+		return
+	}
+	query := r.URL.Query()
+	filter := capture.Filter{
+		RouteIds:     splitQueryList(query.Get("routeId")),
+		MessageTypes: splitQueryList(query.Get("type")),
 	}
-	dataBytes, _ := json.Marshal(rpcReq)
-	dataStr := string(dataBytes)
-	mt := core.RouteMessageTypeRpcRequest
-	msg := &core.RouteMessage{
-		FromRouteId: "debug-admin",
-		ToRouteId:   req.TargetRouteId,
-		MessageType: &mt,
-		Data:        &dataStr,
+	for _, item := range splitQueryList(query.Get("packetId")) {
+		packetId, err := strconv.Atoi(item)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "packetId 必须为整数: " + item})
+			return
+		}
+		filter.PacketIds = append(filter.PacketIds, packetId)
 	}
-	_ = session.WriteRouteMessage(msg)
+	if err := filter.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	sample, _ := strconv.Atoi(query.Get("sample"))
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"success": true,
-		"message": "✅ RPC 调试请求已发送",
-		"data": map[string]any{
-			"targetRouteId": req.TargetRouteId,
-			"packetId":      req.PacketId,
-			"method":        stub.ClassName + "." + stub.MethodName,
-			"paramsCount":   len(req.Params),
-			"requestId":     requestId,
-			"status":        "sent",
-			"note":          "RPC 请求已通过 Router Center 转发到目标节点，等待响应（异步调用）",
-		},
-	})
+	tap := h.srv.MessageTap()
+	sub := tap.Subscribe(filter, sample)
+	defer tap.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("retry: 3000\n\n"))
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	var reportedDropped uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-sub.C:
+			data, _ := json.Marshal(event)
+			if _, err := w.Write([]byte("event: message\ndata: " + string(data) + "\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			// 顺带告知客户端因消费过慢被丢弃的条数
+			if dropped := sub.Dropped(); dropped != reportedDropped {
+				reportedDropped = dropped
+				_, _ = w.Write([]byte("event: dropped\ndata: " + strconv.FormatUint(dropped, 10) + "\n\n"))
+			} else {
+				_, _ = w.Write([]byte(": keep-alive\n\n"))
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func splitQueryList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
 
 func (h *HttpServer) handleDebugRpcResult(w http.ResponseWriter, r *http.Request) {
@@ -667,8 +1308,9 @@ func (h *HttpServer) handleDebugRpcStubs(w http.ResponseWriter, r *http.Request)
 		writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "message": "路由节点不存在"})
 		return
 	}
-	stubs := make([]any, 0, len(session.RpcServerInfo.Stubs))
-	for _, s := range session.RpcServerInfo.Stubs {
+	stubList := session.GetRpcServerInfo().Stubs
+	stubs := make([]any, 0, len(stubList))
+	for _, s := range stubList {
 		desc := s.Description
 		if desc == "" {
 			desc = "[" + intToString(s.PacketId) + "] " + afterLast(s.ClassName, ".") + "." + s.MethodName
@@ -687,23 +1329,149 @@ func (h *HttpServer) handleDebugRpcStubs(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": stubs})
 }
 
+// withAuth 要求已登录，任意角色都可访问
 func (h *HttpServer) withAuth(next http.HandlerFunc) http.HandlerFunc {
+	return h.withRole(RoleViewer, next)
+}
+
+// withGatewayAuth 网关鉴权：管理员 Token 可调用任意节点；API Key 只能调用 allowRoutes 内的节点
+func (h *HttpServer) withGatewayAuth(next func(w http.ResponseWriter, r *http.Request, key *config.GatewayApiKey)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api/auth/") {
-			next(w, r)
-			return
-		}
-		if r.Method == http.MethodOptions {
-			next(w, r)
+		if apiKey := extractApiKey(r); apiKey != "" {
+			key := h.findGatewayApiKey(apiKey)
+			if key == nil {
+				writeGatewayError(w, newGatewayError(GatewayErrUnauthorized, http.StatusUnauthorized, "API Key 无效"))
+				return
+			}
+			next(w, r, key)
 			return
 		}
 		token := extractToken(r)
 		if token == "" || !ValidateToken(token) {
-			writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "message": "未授权，请先登录"})
+			writeGatewayError(w, newGatewayError(GatewayErrUnauthorized, http.StatusUnauthorized, "缺少有效的 API Key 或管理员 Token"))
+			return
+		}
+		user, ok := h.lookupAdminUser(requestOperator(r))
+		if !ok {
+			writeGatewayError(w, newGatewayError(GatewayErrUnauthorized, http.StatusUnauthorized, "账号不存在或已被禁用"))
 			return
 		}
-		next(w, r)
+		if !roleAtLeast(user.Role, RoleOperator) {
+			writeGatewayError(w, newGatewayError(GatewayErrForbidden, http.StatusForbidden, "通过网关调用 RPC 需要 operator 角色"))
+			return
+		}
+		next(w, r, nil)
+	}
+}
+
+// findGatewayApiKey 返回匹配 Key 的副本，配置热更新替换列表后不受影响
+func (h *HttpServer) findGatewayApiKey(apiKey string) *config.GatewayApiKey {
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	for i := range h.cfg.GatewayApiKeys {
+		key := h.cfg.GatewayApiKeys[i]
+		if key.Key != "" && subtle.ConstantTimeCompare([]byte(key.Key), []byte(apiKey)) == 1 {
+			return &key
+		}
+	}
+	return nil
+}
+
+func extractApiKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-Api-Key")); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "ApiKey ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "ApiKey "))
+	}
+	return ""
+}
+
+// handleRpcGateway POST /rpc/{routeId}/{packetId}，同步等待节点返回。
+// 请求体为 {"params":[...],"timeoutMs":3000}，也可以直接传参数数组。
+func (h *HttpServer) handleRpcGateway(w http.ResponseWriter, r *http.Request, key *config.GatewayApiKey) {
+	if r.Method != http.MethodPost {
+		writeGatewayError(w, newGatewayError(GatewayErrBadRequest, http.StatusMethodNotAllowed, "method not allowed"))
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/rpc/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		writeGatewayError(w, newGatewayError(GatewayErrBadRequest, http.StatusNotFound, "请求路径应为 /rpc/{routeId}/{packetId}"))
+		return
+	}
+	routeId := parts[0]
+	packetId, err := strconv.Atoi(parts[1])
+	if err != nil || packetId <= 0 {
+		writeGatewayError(w, newGatewayError(GatewayErrBadRequest, http.StatusBadRequest, "packetId 必须为正整数: "+parts[1]))
+		return
+	}
+	if key != nil && !key.AllowRoute(routeId) {
+		componentLog(LogComponentGateway).Warn("HTTP 网关拒绝越权调用", "apiKey", key.Name, "routeId", routeId, "packetId", packetId)
+		writeGatewayError(w, newGatewayError(GatewayErrForbidden, http.StatusForbidden, "API Key 无权调用节点: "+routeId))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxGatewayBodyBytes)
+	params, timeout, err := decodeGatewayBody(r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeGatewayError(w, newGatewayError(GatewayErrTooLarge, http.StatusRequestEntityTooLarge, "请求体超过上限 "+strconv.FormatInt(tooLarge.Limit, 10)+" 字节"))
+		return
+	}
+	if err != nil {
+		writeGatewayError(w, newGatewayError(GatewayErrBadRequest, http.StatusBadRequest, "请求体解析失败: "+err.Error()))
+		return
+	}
+	argsJson := make([]string, 0, len(params))
+	for _, p := range params {
+		argsJson = append(argsJson, string(p))
+	}
+
+	ctx := tracing.ContextWithTraceparent(r.Context(), r.Header.Get(tracing.TraceparentHeader))
+	result, err := h.srv.InvokeRpc(ctx, routeId, packetId, argsJson, timeout)
+	if err != nil {
+		writeGatewayError(w, asGatewayError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "data": result})
+}
+
+func decodeGatewayBody(r *http.Request) ([]json.RawMessage, time.Duration, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	trimmed := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(trimmed, "[") {
+		var params []json.RawMessage
+		err := json.Unmarshal(raw, &params)
+		return params, 0, err
+	}
+	var body struct {
+		Params    []json.RawMessage `json:"params"`
+		TimeoutMs int64             `json:"timeoutMs"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, 0, err
+	}
+	return body.Params, time.Duration(body.TimeoutMs) * time.Millisecond, nil
+}
+
+func writeGatewayError(w http.ResponseWriter, err *GatewayError) {
+	writeJSON(w, err.HTTPStatus, map[string]any{"success": false, "message": err.Message, "error": err})
+}
+
+// requestOperator 当前请求对应的管理员账号，取自 Token 的 sub
+func requestOperator(r *http.Request) string {
+	claims, ok := parseClaims(extractToken(r))
+	if !ok {
+		return ""
 	}
+	sub, _ := claims["sub"].(string)
+	return sub
 }
 
 func extractToken(r *http.Request) string {
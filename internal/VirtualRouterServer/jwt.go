@@ -1,31 +1,361 @@
 package VirtualRouterServer
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/neko233-com/virtual-router-go/internal/config"
 )
 
-const jwtSecret = "VirtualRouter-Center-Server-JWT-Secret-Key-Please-Change-In-Production-2024"
-
 const tokenExpire = 24 * time.Hour
 const refreshThreshold = 2 * time.Hour
 
+const (
+	defaultJWTKeyFile = "jwt-keys.json"
+	// defaultJWTGracePeriod 轮换后旧密钥的校验宽限期，与 Token 有效期一致，保证已签发的 Token 自然过期
+	defaultJWTGracePeriod = tokenExpire
+	jwtSecretBytes        = 32
+	// jwtRotationCheckInterval 自动轮换的检查周期
+	jwtRotationCheckInterval = 10 * time.Minute
+)
+
+// jwtKey 一把签名密钥；RetiredAt 非 0 表示已被轮换，只在宽限期内用于校验
+type jwtKey struct {
+	Kid       string `json:"kid"`
+	Secret    []byte `json:"secret"`
+	CreatedAt int64  `json:"createdAt"`
+	RetiredAt int64  `json:"retiredAt,omitempty"`
+}
+
+// jwtKeyFileData 密钥文件内容，同时保存未过期的吊销记录，重启后已登出的会话依然无效
+type jwtKeyFileData struct {
+	Keys []jwtKey `json:"keys"`
+	// Revoked 会话 sid -> 吊销记录过期时间（Unix 秒），届时该会话签发的 Token 都已过期
+	Revoked map[string]int64 `json:"revoked,omitempty"`
+}
+
+// JWTKeyInfo 签名密钥概要，不含密钥本身
+type JWTKeyInfo struct {
+	Kid       string `json:"kid"`
+	CreatedAt int64  `json:"createdAt"`
+	RetiredAt int64  `json:"retiredAt,omitempty"`
+	// ExpiresAt 旧密钥停止校验的时间（Unix 毫秒），当前密钥为 0
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+	Active    bool  `json:"active"`
+}
+
+// jwtKeyring 签名密钥环：最后一把为当前签名密钥，其余为宽限期内的旧密钥
+type jwtKeyring struct {
+	mu    sync.RWMutex
+	keys  []jwtKey
+	grace time.Duration
+	// file 为空表示不落盘（固定 secret 或未调用 ConfigureJWT）
+	file string
+	// static 使用配置中的固定 secret，不支持轮换
+	static  bool
+	revoked map[string]int64
+}
+
+// jwtKeys 未调用 ConfigureJWT 时使用进程内随机密钥，重启后 Token 全部失效
+var jwtKeys atomic.Pointer[jwtKeyring]
+
+func init() {
+	ring := &jwtKeyring{grace: defaultJWTGracePeriod, revoked: make(map[string]int64)}
+	key, err := newJWTKey(time.Now())
+	if err != nil {
+		panic(err)
+	}
+	ring.keys = []jwtKey{key}
+	jwtKeys.Store(ring)
+}
+
+// ConfigureJWT 按配置加载签名密钥：配置了 secret 时直接使用，否则从密钥文件读取，首次启动自动生成
+func ConfigureJWT(cfg *config.JWTConfig) error {
+	if cfg == nil {
+		cfg = &config.JWTConfig{}
+	}
+	ring := &jwtKeyring{grace: defaultJWTGracePeriod, revoked: make(map[string]int64)}
+	if cfg.GracePeriodHours > 0 {
+		ring.grace = time.Duration(cfg.GracePeriodHours) * time.Hour
+	}
+	now := time.Now()
+	if secret := strings.TrimSpace(cfg.Secret); secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		ring.static = true
+		ring.keys = []jwtKey{{Kid: "cfg-" + hex.EncodeToString(sum[:4]), Secret: []byte(secret), CreatedAt: now.UnixMilli()}}
+		jwtKeys.Store(ring)
+		return nil
+	}
+	ring.file = strings.TrimSpace(cfg.KeyFile)
+	if ring.file == "" {
+		ring.file = defaultJWTKeyFile
+	}
+	if err := ring.load(now); err != nil {
+		return err
+	}
+	jwtKeys.Store(ring)
+	return nil
+}
+
+func newJWTKey(now time.Time) (jwtKey, error) {
+	secret := make([]byte, jwtSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return jwtKey{}, err
+	}
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return jwtKey{}, err
+	}
+	return jwtKey{Kid: hex.EncodeToString(id), Secret: secret, CreatedAt: now.UnixMilli()}, nil
+}
+
+// load 读取密钥文件，不存在或没有可用的当前密钥时生成新密钥并写回
+func (k *jwtKeyring) load(now time.Time) error {
+	data, err := os.ReadFile(k.file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("读取 JWT 密钥文件失败: %w", err)
+	}
+	if err == nil {
+		var stored jwtKeyFileData
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("解析 JWT 密钥文件失败 %s: %w", k.file, err)
+		}
+		k.keys = stored.Keys
+		if stored.Revoked != nil {
+			k.revoked = stored.Revoked
+		}
+	}
+	k.pruneLocked(now)
+	if len(k.keys) == 0 || k.keys[len(k.keys)-1].RetiredAt != 0 || len(k.keys[len(k.keys)-1].Secret) == 0 {
+		key, err := newJWTKey(now)
+		if err != nil {
+			return err
+		}
+		k.keys = append(k.keys, key)
+		componentLog(LogComponentHttp).Info("已生成新的 JWT 签名密钥", "kid", key.Kid, "file", k.file)
+	}
+	return k.saveLocked()
+}
+
+// saveLocked 先写临时文件再替换，权限 0600
+func (k *jwtKeyring) saveLocked() error {
+	if k.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(jwtKeyFileData{Keys: k.keys, Revoked: k.revoked}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(k.file); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	tmp := k.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入 JWT 密钥文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, k.file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("写入 JWT 密钥文件失败: %w", err)
+	}
+	return nil
+}
+
+// pruneLocked 移除宽限期已过的旧密钥与已过期 Token 的吊销记录
+func (k *jwtKeyring) pruneLocked(now time.Time) {
+	kept := k.keys[:0]
+	for _, key := range k.keys {
+		if key.RetiredAt != 0 && now.Sub(time.UnixMilli(key.RetiredAt)) > k.grace {
+			continue
+		}
+		kept = append(kept, key)
+	}
+	k.keys = kept
+	for sid, exp := range k.revoked {
+		if now.Unix() > exp {
+			delete(k.revoked, sid)
+		}
+	}
+}
+
+func (k *jwtKeyring) activeKey() jwtKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[len(k.keys)-1]
+}
+
+// rotate 生成新的当前密钥，旧密钥进入宽限期
+func (k *jwtKeyring) rotate(now time.Time) (JWTKeyInfo, error) {
+	if k.static {
+		return JWTKeyInfo{}, errors.New("已配置固定 jwt.secret，不支持轮换")
+	}
+	key, err := newJWTKey(now)
+	if err != nil {
+		return JWTKeyInfo{}, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// 在副本上退役旧密钥，落盘失败时保持原密钥可用
+	prevKeys := k.keys
+	prev := prevKeys[len(prevKeys)-1]
+	next := append(append(make([]jwtKey, 0, len(prevKeys)+1), prevKeys...), key)
+	next[len(prevKeys)-1].RetiredAt = now.UnixMilli()
+	k.keys = next
+	k.pruneLocked(now)
+	if err := k.saveLocked(); err != nil {
+		k.keys = prevKeys
+		return JWTKeyInfo{}, err
+	}
+	componentLog(LogComponentHttp).Info("JWT 签名密钥已轮换", "kid", key.Kid, "previousKid", prev.Kid, "grace", k.grace)
+	return JWTKeyInfo{Kid: key.Kid, CreatedAt: key.CreatedAt, Active: true}, nil
+}
+
+func (k *jwtKeyring) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("缺少 kid")
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.Kid != kid {
+			continue
+		}
+		if key.RetiredAt != 0 && time.Since(time.UnixMilli(key.RetiredAt)) > k.grace {
+			return nil, errors.New("签名密钥已过期")
+		}
+		return key.Secret, nil
+	}
+	return nil, errors.New("未知的签名密钥")
+}
+
+func (k *jwtKeyring) isRevoked(sid string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.revoked[sid]
+	return ok
+}
+
+// RotateJWTKey 立即轮换签名密钥，已签发的 Token 在宽限期内仍然有效
+func RotateJWTKey() (JWTKeyInfo, error) {
+	return jwtKeys.Load().rotate(time.Now())
+}
+
+// JWTKeys 当前密钥与宽限期内的旧密钥，最新在前
+func JWTKeys() []JWTKeyInfo {
+	k := jwtKeys.Load()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	items := make([]JWTKeyInfo, 0, len(k.keys))
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		key := k.keys[i]
+		info := JWTKeyInfo{Kid: key.Kid, CreatedAt: key.CreatedAt, RetiredAt: key.RetiredAt, Active: i == len(k.keys)-1}
+		if key.RetiredAt != 0 {
+			info.ExpiresAt = time.UnixMilli(key.RetiredAt).Add(k.grace).UnixMilli()
+		}
+		items = append(items, info)
+	}
+	return items
+}
+
+// runJWTRotation 当前密钥使用超过 interval 后自动轮换，ctx 结束时退出
+func runJWTRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(min(interval, jwtRotationCheckInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			k := jwtKeys.Load()
+			if k.static || now.Sub(time.UnixMilli(k.activeKey().CreatedAt)) < interval {
+				continue
+			}
+			if _, err := k.rotate(now); err != nil {
+				componentLog(LogComponentHttp).Error("JWT 签名密钥自动轮换失败", "error", err)
+			}
+		}
+	}
+}
+
+func randomTokenId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// GenerateToken 为新会话签发 Token
 func GenerateToken(userId string) (string, error) {
+	sid, err := randomTokenId()
+	if err != nil {
+		return "", err
+	}
+	return generateSessionToken(userId, sid)
+}
+
+// generateSessionToken 签发属于会话 sid 的 Token，刷新 Token 时沿用原会话
+func generateSessionToken(userId, sid string) (string, error) {
 	now := time.Now()
+	jti, err := randomTokenId()
+	if err != nil {
+		return "", err
+	}
+	key := jwtKeys.Load().activeKey()
 	claims := jwt.MapClaims{
 		"sub": userId,
+		"sid": sid,
+		"jti": jti,
 		"iat": now.Unix(),
 		"exp": now.Add(tokenExpire).Unix(),
 	}
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtSecret))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.Secret)
 }
 
 func ValidateToken(tokenStr string) bool {
-	_, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
-		return []byte(jwtSecret), nil
-	})
-	return err == nil
+	_, ok := parseClaims(tokenStr)
+	return ok
+}
+
+// RevokeToken 吊销 Token 所属的会话（登出），刷新得到的 Token 一并失效
+func RevokeToken(tokenStr string) bool {
+	claims, ok := parseClaims(tokenStr)
+	if !ok {
+		return false
+	}
+	RevokeSession(claimSessionId(claims))
+	return true
+}
+
+// RevokeSession 吊销会话，该会话已签发的 Token 都会被拒绝
+func RevokeSession(sid string) {
+	if sid == "" {
+		return
+	}
+	now := time.Now()
+	k := jwtKeys.Load()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// 会话内最晚签发的 Token 也会在 tokenExpire 后过期，届时吊销记录可以清理
+	k.revoked[sid] = now.Add(tokenExpire).Unix()
+	k.pruneLocked(now)
+	if err := k.saveLocked(); err != nil {
+		componentLog(LogComponentHttp).Warn("保存 Token 吊销记录失败，仅在内存生效", "error", err)
+	}
 }
 
 func RefreshToken(tokenStr string) (string, bool) {
@@ -37,7 +367,7 @@ func RefreshToken(tokenStr string) (string, bool) {
 	if userId == "" {
 		return "", false
 	}
-	newToken, err := GenerateToken(userId)
+	newToken, err := generateSessionToken(userId, claimSessionId(claims))
 	if err != nil {
 		return "", false
 	}
@@ -73,13 +403,28 @@ func GetTokenRemainingSeconds(tokenStr string) int64 {
 	return int64(remaining.Seconds())
 }
 
+// parseClaims 校验签名（按 kid 选择密钥）、有效期与吊销状态
 func parseClaims(tokenStr string) (jwt.MapClaims, bool) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
-		return []byte(jwtSecret), nil
-	})
+	k := jwtKeys.Load()
+	token, err := jwt.Parse(tokenStr, k.keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil || !token.Valid {
 		return nil, false
 	}
 	claims, ok := token.Claims.(jwt.MapClaims)
-	return claims, ok
+	if !ok {
+		return nil, false
+	}
+	if sid := claimSessionId(claims); sid == "" || k.isRevoked(sid) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// claimSessionId Token 所属会话；没有 sid 的 Token 自成一个会话
+func claimSessionId(claims jwt.MapClaims) string {
+	if sid, _ := claims["sid"].(string); sid != "" {
+		return sid
+	}
+	jti, _ := claims["jti"].(string)
+	return jti
 }
@@ -1,6 +1,7 @@
 package VirtualRouterServer
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,15 +12,19 @@ import (
 	"time"
 )
 
+// logCapture 进程日志的内存环形缓冲，供管理后台查看
 type logCapture struct {
 	mu       sync.Mutex
 	capacity int
-	lines    []string
+	records  []LogRecord
 }
 
+// defaultLogBufferCapacity 内存日志缓冲默认条数，与 LogConfig.BufferCapacity 的默认值一致
+const defaultLogBufferCapacity = 800
+
 var (
 	logCaptureOnce sync.Once
-	globalLogs     = &logCapture{capacity: 500, lines: make([]string, 0, 500)}
+	globalLogs     = &logCapture{capacity: defaultLogBufferCapacity, records: make([]LogRecord, 0, defaultLogBufferCapacity)}
 	logRotateCfg   = logRotationConfig{
 		Dir:      "logs",
 		BaseName: "router-center.log",
@@ -33,6 +38,8 @@ type logRotationConfig struct {
 	BaseName string
 	MaxBytes int64
 	MaxFiles int
+	// Compress 轮转出去的文件是否压缩为 .gz
+	Compress bool
 }
 
 type rotatingFileWriter struct {
@@ -43,11 +50,15 @@ type rotatingFileWriter struct {
 	maxFiles   int
 	file       *os.File
 	currentLen int64
+	compress   bool
+	// compressing 后台压缩中的轮转文件，下次轮转与关闭前需要等它完成
+	compressing sync.WaitGroup
 }
 
 func (w *rotatingFileWriter) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	w.compressing.Wait()
 	if w.file == nil {
 		return nil
 	}
@@ -57,59 +68,73 @@ func (w *rotatingFileWriter) Close() error {
 	return err
 }
 
+// InstallProcessLogCapture 以默认配置安装进程日志，已通过 ConfigureProcessLogs 配置过时只调整缓冲容量
 func InstallProcessLogCapture(capacity int) {
-	if capacity > 0 {
-		globalLogs.capacity = capacity
-	}
-
 	logCaptureOnce.Do(func() {
-		origin := os.Stdout
-		writers := []io.Writer{origin, globalLogs}
-
-		var rotateWriter io.Writer
-		if rotateWriter, err := newRotatingFileWriter(logRotateCfg); err == nil {
-			writers = append(writers, rotateWriter)
-		} else {
-			slog.Warn("日志文件轮转初始化失败，仅输出到控制台和内存", "error", err)
-		}
-
-		handler := slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: slog.LevelInfo})
-		logger := slog.New(handler)
-		slog.SetDefault(logger)
-
-		if rotateWriter != nil {
-			slog.Info("日志文件轮转已启用", "dir", logRotateCfg.Dir, "maxBytes", logRotateCfg.MaxBytes, "maxFiles", logRotateCfg.MaxFiles)
-		}
+		_ = ConfigureProcessLogs(nil)
 	})
+
+	if capacity > 0 {
+		globalLogs.setCapacity(capacity)
+	}
 }
 
 func GetRecentProcessLogs(limit int) []string {
 	return globalLogs.getRecent(limit)
 }
 
-func (c *logCapture) Write(p []byte) (n int, err error) {
-	text := string(p)
-	segments := strings.Split(text, "\n")
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// GetRecentProcessLogRecords 最近的结构化日志记录（旧 → 新）
+func GetRecentProcessLogRecords(limit int) []LogRecord {
+	return globalLogs.recentRecords(limit)
+}
 
-	for _, seg := range segments {
+// Write 直接写入的原始文本按行记录，没有级别与属性
+func (c *logCapture) Write(p []byte) (n int, err error) {
+	now := time.Now()
+	for _, seg := range strings.Split(string(p), "\n") {
 		line := strings.TrimSpace(seg)
 		if line == "" {
 			continue
 		}
-		c.lines = append(c.lines, time.Now().Format("2006-01-02 15:04:05")+" "+line)
-		if len(c.lines) > c.capacity {
-			over := len(c.lines) - c.capacity
-			c.lines = c.lines[over:]
-		}
+		c.add(LogRecord{Time: now.UnixMilli(), Line: now.Format("2006-01-02 15:04:05") + " " + line})
 	}
-
 	return len(p), nil
 }
 
+func (c *logCapture) add(rec LogRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, rec)
+	if over := len(c.records) - c.capacity; over > 0 {
+		c.records = c.records[over:]
+	}
+}
+
+func (c *logCapture) setCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = capacity
+	if over := len(c.records) - c.capacity; over > 0 {
+		c.records = c.records[over:]
+	}
+}
+
+func (c *logCapture) getCapacity() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capacity
+}
+
 func (c *logCapture) getRecent(limit int) []string {
+	records := c.recentRecords(limit)
+	out := make([]string, 0, len(records))
+	for _, rec := range records {
+		out = append(out, rec.Line)
+	}
+	return out
+}
+
+func (c *logCapture) recentRecords(limit int) []LogRecord {
 	if limit <= 0 {
 		limit = 100
 	}
@@ -117,16 +142,12 @@ func (c *logCapture) getRecent(limit int) []string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	total := len(c.lines)
-	if total == 0 {
-		return []string{}
-	}
+	total := len(c.records)
 	if limit > total {
 		limit = total
 	}
-	start := total - limit
-	out := make([]string, 0, limit)
-	out = append(out, c.lines[start:]...)
+	out := make([]LogRecord, 0, limit)
+	out = append(out, c.records[total-limit:]...)
 	return out
 }
 
@@ -153,6 +174,7 @@ func newRotatingFileWriter(cfg logRotationConfig) (*rotatingFileWriter, error) {
 		baseName: cfg.BaseName,
 		maxBytes: cfg.MaxBytes,
 		maxFiles: cfg.MaxFiles,
+		compress: cfg.Compress,
 	}
 	if err := w.openCurrent(); err != nil {
 		return nil, err
@@ -160,6 +182,10 @@ func newRotatingFileWriter(cfg logRotationConfig) (*rotatingFileWriter, error) {
 	return w, nil
 }
 
+func (w *rotatingFileWriter) config() logRotationConfig {
+	return logRotationConfig{Dir: w.dir, BaseName: w.baseName, MaxBytes: w.maxBytes, MaxFiles: w.maxFiles, Compress: w.compress}
+}
+
 func (w *rotatingFileWriter) Write(p []byte) (int, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -202,22 +228,71 @@ func (w *rotatingFileWriter) rotate() error {
 		_ = w.file.Close()
 		w.file = nil
 	}
+	// 上一次的压缩还没结束时不能移动 .1
+	w.compressing.Wait()
 
 	basePath := filepath.Join(w.dir, w.baseName)
 	oldest := fmt.Sprintf("%s.%d", basePath, w.maxFiles-1)
 	_ = os.Remove(oldest)
+	_ = os.Remove(oldest + ".gz")
 
 	for i := w.maxFiles - 2; i >= 1; i-- {
-		src := fmt.Sprintf("%s.%d", basePath, i)
-		dst := fmt.Sprintf("%s.%d", basePath, i+1)
-		if _, err := os.Stat(src); err == nil {
-			_ = os.Rename(src, dst)
+		for _, suffix := range []string{"", ".gz"} {
+			src := fmt.Sprintf("%s.%d%s", basePath, i, suffix)
+			dst := fmt.Sprintf("%s.%d%s", basePath, i+1, suffix)
+			if _, err := os.Stat(src); err == nil {
+				_ = os.Rename(src, dst)
+			}
 		}
 	}
 
 	if _, err := os.Stat(basePath); err == nil {
 		_ = os.Rename(basePath, basePath+".1")
+		if w.compress {
+			w.compressing.Add(1)
+			go func() {
+				defer w.compressing.Done()
+				if err := gzipLogFile(basePath + ".1"); err != nil {
+					// 写日志会回到本 writer 并等待 w.mu，不能在 Done 之前同步执行
+					go slog.Warn("压缩轮转日志失败，保留未压缩文件", "file", basePath+".1", "error", err)
+				}
+			}()
+		}
 	}
 
 	return w.openCurrent()
 }
+
+// gzipLogFile 把 path 压缩为 path.gz 后删除原文件，先写临时文件避免留下半个 .gz
+func gzipLogFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,383 @@
+package VirtualRouterServer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+// 日志组件名，通过 componentLog 打到日志的 component 属性上，可单独调整级别
+const (
+	LogComponentRouter  = "router"
+	LogComponentSession = "session"
+	LogComponentGateway = "gateway"
+	LogComponentDebug   = "debug"
+	LogComponentCapture = "capture"
+	LogComponentHttp    = "http"
+	LogComponentConfig  = "config"
+)
+
+var knownLogComponents = []string{LogComponentRouter, LogComponentSession, LogComponentGateway, LogComponentDebug, LogComponentCapture, LogComponentHttp, LogComponentConfig}
+
+// componentLoggerCache 基于某个 slog.Default 构建的各组件 logger
+type componentLoggerCache struct {
+	base    *slog.Logger
+	loggers sync.Map // component -> *slog.Logger
+}
+
+var componentLoggers atomic.Pointer[componentLoggerCache]
+
+// componentLog 带 component 属性的 logger，每个组件缓存一个；slog.Default 被替换后重新构建，保证重新配置后立即生效
+func componentLog(component string) *slog.Logger {
+	base := slog.Default()
+	cache := componentLoggers.Load()
+	if cache == nil || cache.base != base {
+		cache = &componentLoggerCache{base: base}
+		componentLoggers.Store(cache)
+	}
+	if l, ok := cache.loggers.Load(component); ok {
+		return l.(*slog.Logger)
+	}
+	l, _ := cache.loggers.LoadOrStore(component, base.With("component", component))
+	return l.(*slog.Logger)
+}
+
+// LogRecord 内存日志缓冲中的一条记录；直接写入的原始文本只有 Line
+type LogRecord struct {
+	Time      int64             `json:"time"`
+	Level     string            `json:"level,omitempty"`
+	Component string            `json:"component,omitempty"`
+	Msg       string            `json:"msg,omitempty"`
+	Attrs     map[string]string `json:"attrs,omitempty"`
+	Line      string            `json:"line"`
+}
+
+// LogLevelSnapshot 当前生效的日志级别
+type LogLevelSnapshot struct {
+	Default    string            `json:"default"`
+	Components map[string]string `json:"components"`
+	Known      []string          `json:"known"`
+	Format     string            `json:"format"`
+}
+
+type logLevelRegistry struct {
+	mu         sync.RWMutex
+	def        slog.Level
+	components map[string]slog.Level
+	format     string
+
+	// configDef/configComponents 上一次由配置设置的级别，重新加载时只应用与之不同的项，
+	// 保留通过 SetLogLevel 在运行时做的调整
+	configDef        slog.Level
+	configComponents map[string]slog.Level
+}
+
+var logLevels = &logLevelRegistry{def: slog.LevelInfo, components: map[string]slog.Level{}, format: "text", configComponents: map[string]slog.Level{}}
+
+// applyConfig 应用配置中的级别：只有相对上一次配置发生变化的默认级别与组件级别才会覆盖当前值
+func (r *logLevelRegistry) applyConfig(def slog.Level, components map[string]slog.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if def != r.configDef {
+		r.def = def
+	}
+	for name, level := range components {
+		if prev, ok := r.configComponents[name]; !ok || prev != level {
+			r.components[name] = level
+		}
+	}
+	for name := range r.configComponents {
+		if _, ok := components[name]; !ok {
+			delete(r.components, name)
+		}
+	}
+	r.configDef = def
+	r.configComponents = components
+}
+
+func (r *logLevelRegistry) levelOf(component string) slog.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if level, ok := r.components[component]; ok && component != "" {
+		return level
+	}
+	return r.def
+}
+
+func (r *logLevelRegistry) snapshot() LogLevelSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	components := make(map[string]string, len(r.components))
+	for k, v := range r.components {
+		components[k] = v.String()
+	}
+	return LogLevelSnapshot{Default: r.def.String(), Components: components, Known: append([]string(nil), knownLogComponents...), Format: r.format}
+}
+
+func parseLogLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, errors.New("未知的日志级别: " + raw)
+	}
+}
+
+// SetLogLevel 运行时调整日志级别；component 为空或 default 时调整默认级别，
+// level 为空时删除该组件的单独设置。
+func SetLogLevel(component, level string) error {
+	component = strings.TrimSpace(component)
+	if component == "" || component == "default" {
+		parsed, err := parseLogLevel(level)
+		if err != nil {
+			return err
+		}
+		logLevels.mu.Lock()
+		logLevels.def = parsed
+		logLevels.mu.Unlock()
+		return nil
+	}
+	if strings.TrimSpace(level) == "" {
+		logLevels.mu.Lock()
+		delete(logLevels.components, component)
+		logLevels.mu.Unlock()
+		return nil
+	}
+	parsed, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	logLevels.mu.Lock()
+	logLevels.components[component] = parsed
+	logLevels.mu.Unlock()
+	return nil
+}
+
+// LogLevels 当前默认级别与各组件级别
+func LogLevels() LogLevelSnapshot {
+	return logLevels.snapshot()
+}
+
+var (
+	logSetupMu   sync.Mutex
+	logFileClose io.Closer
+)
+
+// parseLogConfig 校验日志格式与级别配置
+func parseLogConfig(cfg *config.LogConfig) (string, slog.Level, map[string]slog.Level, error) {
+	format := strings.ToLower(strings.TrimSpace(cfg.Format))
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		return "", 0, nil, errors.New("未知的日志格式: " + cfg.Format)
+	}
+	def, err := parseLogLevel(cfg.Level)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	components := make(map[string]slog.Level, len(cfg.ComponentLevels))
+	for name, raw := range cfg.ComponentLevels {
+		level, err := parseLogLevel(raw)
+		if err != nil {
+			return "", 0, nil, errors.New("组件 " + name + ": " + err.Error())
+		}
+		components[name] = level
+	}
+	return format, def, components, nil
+}
+
+// ConfigureProcessLogs 按配置（重新）安装进程日志：输出格式、默认/组件级别与日志文件轮转。
+// cfg 为 nil 时使用默认值（text、info、logs/router-center.log 20MB×5，缓冲 800 条）。
+// 重新配置时只覆盖配置中发生变化的级别，运行时通过 SetLogLevel 做的其他调整保留。
+func ConfigureProcessLogs(cfg *config.LogConfig) error {
+	if cfg == nil {
+		cfg = &config.LogConfig{}
+	}
+	format, def, components, err := parseLogConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	rotation := logRotationConfig{Dir: cfg.Dir, BaseName: cfg.FileName, MaxBytes: cfg.MaxFileSizeMB * 1024 * 1024, MaxFiles: cfg.MaxFiles, Compress: cfg.CompressRotated}
+
+	logSetupMu.Lock()
+	defer logSetupMu.Unlock()
+
+	capacity := cfg.BufferCapacity
+	if capacity <= 0 {
+		capacity = defaultLogBufferCapacity
+	}
+	globalLogs.setCapacity(capacity)
+	writers := []io.Writer{os.Stdout}
+	rotateWriter, rotateErr := newRotatingFileWriter(rotation)
+	if rotateErr == nil {
+		writers = append(writers, rotateWriter)
+		logRotateCfg = rotateWriter.config()
+	}
+	out := io.MultiWriter(writers...)
+	// 级别过滤在 captureHandler 中按组件完成，底层 handler 不再过滤
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var base slog.Handler
+	if format == "json" {
+		base = slog.NewJSONHandler(out, opts)
+	} else {
+		base = slog.NewTextHandler(out, opts)
+	}
+
+	logLevels.applyConfig(def, components)
+	logLevels.mu.Lock()
+	logLevels.format = format
+	logLevels.mu.Unlock()
+
+	slog.SetDefault(slog.New(&captureHandler{out: base, sink: globalLogs}))
+	if logFileClose != nil {
+		_ = logFileClose.Close()
+		logFileClose = nil
+	}
+	if rotateWriter != nil {
+		logFileClose = rotateWriter
+	}
+	// 之后再调用 InstallProcessLogCapture 不会覆盖这里的配置
+	logCaptureOnce.Do(func() {})
+
+	if rotateErr != nil {
+		slog.Warn("日志文件轮转初始化失败，仅输出到控制台和内存", "error", rotateErr)
+	} else {
+		slog.Info("日志文件轮转已启用", "dir", logRotateCfg.Dir, "maxBytes", logRotateCfg.MaxBytes, "maxFiles", logRotateCfg.MaxFiles, "format", format)
+	}
+	return nil
+}
+
+// captureHandler 把记录交给底层 text/json handler 输出，同时以结构化形式写入内存缓冲
+type captureHandler struct {
+	out       slog.Handler
+	sink      *logCapture
+	component string
+	attrs     map[string]string
+	group     string
+}
+
+func (h *captureHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevels.levelOf(h.component)
+}
+
+func (h *captureHandler) Handle(ctx context.Context, r slog.Record) error {
+	err := h.out.Handle(ctx, r)
+	rec := LogRecord{
+		Time:      r.Time.UnixMilli(),
+		Level:     r.Level.String(),
+		Component: h.component,
+		Msg:       r.Message,
+	}
+	attrs := make(map[string]string, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		attrs[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		flattenLogAttr(attrs, h.group, a)
+		return true
+	})
+	if rec.Component == "" {
+		if c, ok := attrs["component"]; ok {
+			rec.Component = c
+			delete(attrs, "component")
+		}
+	}
+	if len(attrs) > 0 {
+		rec.Attrs = attrs
+	}
+	rec.Line = formatLogRecordLine(rec)
+	h.sink.add(rec)
+	return err
+}
+
+func (h *captureHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	next := h.clone()
+	next.out = h.out.WithAttrs(as)
+	for _, a := range as {
+		if h.group == "" && a.Key == "component" {
+			next.component = a.Value.String()
+			continue
+		}
+		flattenLogAttr(next.attrs, h.group, a)
+	}
+	return next
+}
+
+func (h *captureHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := h.clone()
+	next.out = h.out.WithGroup(name)
+	next.group = h.group + name + "."
+	return next
+}
+
+func (h *captureHandler) clone() *captureHandler {
+	attrs := make(map[string]string, len(h.attrs))
+	for k, v := range h.attrs {
+		attrs[k] = v
+	}
+	return &captureHandler{out: h.out, sink: h.sink, component: h.component, attrs: attrs, group: h.group}
+}
+
+func flattenLogAttr(dst map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, sub := range a.Value.Group() {
+			flattenLogAttr(dst, prefix, sub)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	dst[prefix+a.Key] = a.Value.String()
+}
+
+// formatLogRecordLine 生成 UI 展示与导出用的单行文本，属性按 key 排序保证稳定
+func formatLogRecordLine(rec LogRecord) string {
+	var b strings.Builder
+	b.WriteString(time.UnixMilli(rec.Time).Format("2006-01-02 15:04:05"))
+	b.WriteString(" ")
+	b.WriteString(rec.Level)
+	if rec.Component != "" {
+		b.WriteString(" [" + rec.Component + "]")
+	}
+	b.WriteString(" ")
+	b.WriteString(rec.Msg)
+	keys := make([]string, 0, len(rec.Attrs))
+	for k := range rec.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := rec.Attrs[k]
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(" " + k + "=" + v)
+	}
+	return b.String()
+}
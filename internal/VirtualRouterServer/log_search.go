@@ -0,0 +1,324 @@
+package VirtualRouterServer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogSearchLimit = 100
+	maxLogSearchLimit     = 1000
+	// maxLogSearchWindow offset+limit 的上限，检索时只需在内存中保留这么多条
+	maxLogSearchWindow = 100000
+	maxLogLineBytes    = 1024 * 1024
+)
+
+// errStopLogScan 已越过检索结束时间，后续行与文件都更新，不需要再读
+var errStopLogScan = errors.New("stop log scan")
+
+// LogSearchQuery 历史日志检索条件，零值字段不参与过滤
+type LogSearchQuery struct {
+	From      time.Time
+	To        time.Time
+	Keyword   string
+	Level     string
+	Component string
+	// Offset/Limit 按时间倒序（最新在前）分页
+	Offset int
+	Limit  int
+}
+
+// LogFileLine 日志文件中的一行
+type LogFileLine struct {
+	Time      int64  `json:"time"`
+	Level     string `json:"level,omitempty"`
+	Component string `json:"component,omitempty"`
+	File      string `json:"file"`
+	Line      string `json:"line"`
+	// Truncated 原始行超过 maxLogLineBytes，Line 只保留开头部分
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// LogSearchResult 检索结果，Items 最新在前
+type LogSearchResult struct {
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+	Files  []string      `json:"files"`
+	Items  []LogFileLine `json:"items"`
+}
+
+func currentLogRotation() logRotationConfig {
+	logSetupMu.Lock()
+	defer logSetupMu.Unlock()
+	return logRotateCfg
+}
+
+// listLogFiles 当前与已轮转的日志文件，按从旧到新排序；同一序号同时存在 .gz 与未压缩文件时取 .gz
+func listLogFiles(cfg logRotationConfig) []string {
+	basePath := filepath.Join(cfg.Dir, cfg.BaseName)
+	files := make([]string, 0, cfg.MaxFiles)
+	for i := cfg.MaxFiles - 1; i >= 1; i-- {
+		for _, candidate := range []string{fmt.Sprintf("%s.%d.gz", basePath, i), fmt.Sprintf("%s.%d", basePath, i)} {
+			if _, err := os.Stat(candidate); err == nil {
+				files = append(files, candidate)
+				break
+			}
+		}
+	}
+	if _, err := os.Stat(basePath); err == nil {
+		files = append(files, basePath)
+	}
+	return files
+}
+
+// SearchLogFiles 在当前与已轮转的日志文件中按时间范围、关键字、级别检索
+func SearchLogFiles(q LogSearchQuery) (LogSearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultLogSearchLimit
+	}
+	q.Limit = min(q.Limit, maxLogSearchLimit)
+	q.Offset = max(q.Offset, 0)
+	if q.Offset+q.Limit > maxLogSearchWindow {
+		return LogSearchResult{}, fmt.Errorf("offset+limit 不能超过 %d，请缩小时间范围", maxLogSearchWindow)
+	}
+
+	files := listLogFiles(currentLogRotation())
+	result := LogSearchResult{Offset: q.Offset, Limit: q.Limit, Files: displayLogFiles(files), Items: []LogFileLine{}}
+	// 从旧到新扫描，只保留最新的 offset+limit 条
+	window := q.Offset + q.Limit
+	ring := make([]LogFileLine, 0, min(window, 1024))
+	start := 0
+	err := scanLogFiles(files, q, func(line LogFileLine) error {
+		result.Total++
+		if len(ring) < window {
+			ring = append(ring, line)
+		} else {
+			ring[start] = line
+			start = (start + 1) % window
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	ordered := append(ring[start:len(ring):len(ring)], ring[:start]...)
+	for i := len(ordered) - 1 - q.Offset; i >= 0 && len(result.Items) < q.Limit; i-- {
+		result.Items = append(result.Items, ordered[i])
+	}
+	return result, nil
+}
+
+// ExportLogFiles 把命中的行按从旧到新写入 w，不做分页，返回写出的行数
+func ExportLogFiles(w io.Writer, q LogSearchQuery, flush func()) (int, error) {
+	files := listLogFiles(currentLogRotation())
+	count := 0
+	err := scanLogFiles(files, q, func(line LogFileLine) error {
+		if _, err := io.WriteString(w, line.Line+"\n"); err != nil {
+			return err
+		}
+		count++
+		if flush != nil && count%500 == 0 {
+			flush()
+		}
+		return nil
+	})
+	if flush != nil {
+		flush()
+	}
+	return count, err
+}
+
+// scanLogFiles 依次扫描文件并回调命中的行；整个文件都早于 From 时直接跳过
+func scanLogFiles(files []string, q LogSearchQuery, fn func(LogFileLine) error) error {
+	keyword := strings.ToLower(strings.TrimSpace(q.Keyword))
+	level := normalizeLogLevel(q.Level)
+	for _, path := range files {
+		if !q.From.IsZero() {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(q.From) {
+				continue
+			}
+		}
+		err := scanLogFile(path, func(line LogFileLine) error {
+			if !q.To.IsZero() && line.Time > q.To.UnixMilli() {
+				return errStopLogScan
+			}
+			if !q.From.IsZero() && line.Time < q.From.UnixMilli() {
+				return nil
+			}
+			if keyword != "" && !strings.Contains(strings.ToLower(line.Line), keyword) {
+				return nil
+			}
+			if q.Component != "" && line.Component != q.Component {
+				return nil
+			}
+			if !matchRecordLevel(LogRecord{Level: line.Level, Line: line.Line}, level) {
+				return nil
+			}
+			return fn(line)
+		})
+		if errors.Is(err, errStopLogScan) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanLogFile(path string, fn func(LogFileLine) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// 检索过程中文件被轮转或压缩
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("读取压缩日志失败 %s: %w", filepath.Base(path), err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	name := filepath.Base(path)
+	var lastTime int64
+	for {
+		raw, truncated, err := readLogLine(br)
+		switch {
+		case errors.Is(err, io.EOF) && len(raw) == 0, errors.Is(err, io.ErrUnexpectedEOF):
+			// 压缩文件可能仍在写入，读到的不完整部分直接忽略
+			return nil
+		case err != nil && !errors.Is(err, io.EOF):
+			return fmt.Errorf("读取日志失败 %s: %w", name, err)
+		}
+		text := strings.TrimSpace(string(raw))
+		if text == "" {
+			continue
+		}
+		line := parseLogFileLine(text)
+		line.File = name
+		line.Truncated = truncated
+		// 无法解析时间的行（例如 panic 堆栈）沿用上一行的时间
+		if line.Time == 0 {
+			line.Time = lastTime
+		} else {
+			lastTime = line.Time
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+// readLogLine 读取一行（不含换行符），超过 maxLogLineBytes 的部分被丢弃并标记截断，
+// 避免单个超长行中止整个文件的检索；文件末尾没有换行符的最后一行与 io.EOF 一起返回
+func readLogLine(br *bufio.Reader) (line []byte, truncated bool, err error) {
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+		if room := maxLogLineBytes - len(line); len(chunk) > room {
+			line = append(line, chunk[:room]...)
+			truncated = true
+		} else {
+			line = append(line, chunk...)
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, truncated, err
+		}
+	}
+}
+
+// parseLogFileLine 解析 slog text/json handler 输出的一行，取出时间、级别与组件
+func parseLogFileLine(text string) LogFileLine {
+	line := LogFileLine{Line: text}
+	if strings.HasPrefix(text, "{") {
+		var head struct {
+			Time      time.Time `json:"time"`
+			Level     string    `json:"level"`
+			Component string    `json:"component"`
+		}
+		if err := json.Unmarshal([]byte(text), &head); err == nil {
+			line.Level = head.Level
+			line.Component = head.Component
+			if !head.Time.IsZero() {
+				line.Time = head.Time.UnixMilli()
+			}
+		}
+		return line
+	}
+	if v := textLogField(text, "time"); v != "" {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			line.Time = t.UnixMilli()
+		}
+	}
+	line.Level = textLogField(text, "level")
+	line.Component = textLogField(text, "component")
+	return line
+}
+
+// textLogField 取 text handler 输出中 key=value 的 value（不处理带引号的值，time/level/component 不会带引号）
+func textLogField(text, key string) string {
+	prefix := key + "="
+	idx := 0
+	for {
+		pos := strings.Index(text[idx:], prefix)
+		if pos < 0 {
+			return ""
+		}
+		pos += idx
+		if pos == 0 || text[pos-1] == ' ' {
+			rest := text[pos+len(prefix):]
+			if end := strings.IndexByte(rest, ' '); end >= 0 {
+				rest = rest[:end]
+			}
+			return strings.Trim(rest, "\"")
+		}
+		idx = pos + len(prefix)
+	}
+}
+
+func displayLogFiles(files []string) []string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	return names
+}
+
+// parseLogTimeParam 支持 Unix 毫秒、RFC3339 与浏览器 datetime-local（本地时间）
+func parseLogTimeParam(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("时间格式错误: " + raw)
+}
@@ -0,0 +1,126 @@
+package VirtualRouterServer
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loginAccountMaxFailures 同一 IP 对同一账号连续失败次数达到后开始锁定
+	loginAccountMaxFailures = 5
+	// loginIPMaxFailures 同一 IP 的阈值更高，避免误伤共用出口的用户
+	loginIPMaxFailures = 20
+	// loginLockBase 首次锁定时长，之后每多失败一次翻倍
+	loginLockBase = 30 * time.Second
+	loginLockMax  = 15 * time.Minute
+	// loginFailureWindow 超过该时长没有新的失败则清零
+	loginFailureWindow = 15 * time.Minute
+	// loginGuardMaxEntries 记录数上限，超过后淘汰最久未失败的条目
+	loginGuardMaxEntries = 10000
+)
+
+type loginAttempt struct {
+	key         string
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// loginGuard 按 IP 以及 IP+账号统计登录失败次数，超过阈值后按指数退避锁定。
+// 账号维度同样按 IP 区分，其他 IP 的失败不会锁住正常用户；记录按 LRU 淘汰，总数有上限
+type loginGuard struct {
+	mu       sync.Mutex
+	attempts map[string]*list.Element
+	// order 按最近失败时间排列，队首最新
+	order *list.List
+	now   func() time.Time
+}
+
+func newLoginGuard() *loginGuard {
+	return &loginGuard{attempts: make(map[string]*list.Element), order: list.New(), now: time.Now}
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+func loginAccountKey(ip, username string) string {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" {
+		username = legacyAdminUsername
+	}
+	return "user:" + ip + "|" + username
+}
+
+// lockedFor IP 或账号任一处于锁定中时返回剩余时长
+func (g *loginGuard) lockedFor(ip, username string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	var wait time.Duration
+	for _, key := range []string{loginIPKey(ip), loginAccountKey(ip, username)} {
+		if e := g.attempts[key]; e != nil {
+			if a := e.Value.(*loginAttempt); a.lockedUntil.After(now) {
+				wait = max(wait, a.lockedUntil.Sub(now))
+			}
+		}
+	}
+	return wait
+}
+
+// fail 记录一次失败，返回因此产生的锁定时长（未锁定为 0）
+func (g *loginGuard) fail(ip, username string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	var wait time.Duration
+	for _, item := range []struct {
+		key       string
+		threshold int
+	}{{loginIPKey(ip), loginIPMaxFailures}, {loginAccountKey(ip, username), loginAccountMaxFailures}} {
+		a := g.touchLocked(item.key)
+		if now.Sub(a.lastFailure) > loginFailureWindow {
+			a.failures = 0
+		}
+		a.failures++
+		a.lastFailure = now
+		if a.failures < item.threshold {
+			continue
+		}
+		lock := loginLockBase << min(a.failures-item.threshold, 10)
+		lock = min(lock, loginLockMax)
+		a.lockedUntil = now.Add(lock)
+		wait = max(wait, lock)
+	}
+	return wait
+}
+
+// succeed 登录成功后清除该 IP 与账号的失败记录
+func (g *loginGuard) succeed(ip, username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range []string{loginIPKey(ip), loginAccountKey(ip, username)} {
+		if e := g.attempts[key]; e != nil {
+			g.order.Remove(e)
+			delete(g.attempts, key)
+		}
+	}
+}
+
+// touchLocked 取出 key 的记录并移到队首，新建记录超过上限时淘汰最久未失败的条目
+func (g *loginGuard) touchLocked(key string) *loginAttempt {
+	if e := g.attempts[key]; e != nil {
+		g.order.MoveToFront(e)
+		return e.Value.(*loginAttempt)
+	}
+	a := &loginAttempt{key: key}
+	g.attempts[key] = g.order.PushFront(a)
+	for g.order.Len() > loginGuardMaxEntries {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.attempts, oldest.Value.(*loginAttempt).key)
+	}
+	return a
+}
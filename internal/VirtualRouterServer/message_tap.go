@@ -0,0 +1,128 @@
+package VirtualRouterServer
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/capture"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+const tapSubscriberBuffer = 256
+
+// TapEvent 实时流量中的一条消息摘要，不包含消息体
+type TapEvent struct {
+	Ts       int64  `json:"ts"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	RpcUid   string `json:"rpcUid,omitempty"`
+	PacketId int    `json:"packetId,omitempty"`
+}
+
+// TapSubscription 一个实时流量订阅者；消费过慢时丢弃事件而不是阻塞转发
+type TapSubscription struct {
+	C       <-chan TapEvent
+	ch      chan TapEvent
+	filter  capture.Filter
+	sample  uint64
+	seen    atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// Dropped 因缓冲区满而丢弃的事件数
+func (s *TapSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// MessageTap 把经过 Router Center 的消息摘要广播给订阅者
+type MessageTap struct {
+	mu     sync.RWMutex
+	subs   map[*TapSubscription]struct{}
+	active atomic.Int32
+}
+
+func NewMessageTap() *MessageTap {
+	return &MessageTap{subs: map[*TapSubscription]struct{}{}}
+}
+
+// Subscribe 订阅满足 filter 的消息，sample>1 时每 sample 条只推送 1 条
+func (t *MessageTap) Subscribe(filter capture.Filter, sample int) *TapSubscription {
+	if sample < 1 {
+		sample = 1
+	}
+	ch := make(chan TapEvent, tapSubscriberBuffer)
+	sub := &TapSubscription{C: ch, ch: ch, filter: filter, sample: uint64(sample)}
+	t.mu.Lock()
+	t.subs[sub] = struct{}{}
+	t.active.Store(int32(len(t.subs)))
+	t.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe 取消订阅，之后不会再向该订阅者推送
+func (t *MessageTap) Unsubscribe(sub *TapSubscription) {
+	t.mu.Lock()
+	delete(t.subs, sub)
+	t.active.Store(int32(len(t.subs)))
+	t.mu.Unlock()
+}
+
+// Subscribers 当前订阅者数量
+func (t *MessageTap) Subscribers() int {
+	return int(t.active.Load())
+}
+
+// Publish 推送一条消息摘要；没有订阅者时直接返回，不做任何解析
+func (t *MessageTap) Publish(msg *core.RouteMessage) {
+	if t.active.Load() == 0 || msg == nil || msg.MessageType == nil {
+		return
+	}
+	var event *TapEvent
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for sub := range t.subs {
+		if !sub.filter.Matches(msg.FromRouteId, msg) {
+			continue
+		}
+		if n := sub.seen.Add(1); (n-1)%sub.sample != 0 {
+			continue
+		}
+		if event == nil {
+			e := newTapEvent(msg)
+			event = &e
+		}
+		select {
+		case sub.ch <- *event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+func newTapEvent(msg *core.RouteMessage) TapEvent {
+	event := TapEvent{
+		Ts:   time.Now().UnixMilli(),
+		From: msg.FromRouteId,
+		To:   msg.ToRouteId,
+		Type: msg.MessageType.String(),
+	}
+	if msg.Data == nil {
+		return event
+	}
+	event.Size = len(*msg.Data)
+	if *msg.MessageType == core.RouteMessageTypeRpcRequest || *msg.MessageType == core.RouteMessageTypeRpcResponse {
+		var head struct {
+			RpcUid   any `json:"rpcUid"`
+			PacketId int `json:"packetId"`
+		}
+		if err := json.Unmarshal([]byte(*msg.Data), &head); err == nil {
+			event.RpcUid = toString(head.RpcUid)
+			event.PacketId = head.PacketId
+		}
+	}
+	return event
+}
@@ -0,0 +1,340 @@
+package VirtualRouterServer
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 历史指标名称
+const (
+	MetricRequests    = "requests"
+	MetricBytes       = "bytes"
+	MetricRpc         = "rpc"
+	MetricErrors      = "errors"
+	MetricConnections = "connections"
+	MetricRouters     = "routers"
+)
+
+// 历史指标的精度
+const (
+	MetricResolutionSecond = "second"
+	MetricResolutionMinute = "minute"
+)
+
+const (
+	// 全局指标：1 秒精度保留 1 小时，1 分钟精度保留 24 小时
+	metricSecondSlots = 3600
+	metricMinuteSlots = 1440
+	// routerMetricSecondSlots 节点 RPC 的秒级精度只保留最近一分钟，够计算每分钟调用数即可，避免节点多时占用过多内存
+	routerMetricSecondSlots = 60
+
+	// defaultSecondHistoryWindow 秒级精度未指定窗口时返回最近 10 分钟
+	defaultSecondHistoryWindow = 10 * time.Minute
+
+	// metricGaugeSampleInterval 连接数、在线节点数的采样间隔
+	metricGaugeSampleInterval = time.Second
+)
+
+// metricCounters 计数类指标，按时间累加
+var metricCounters = []string{MetricRequests, MetricBytes, MetricRpc, MetricErrors}
+
+// metricGauges 状态类指标，定时采样，同一时间段取最大值
+var metricGauges = []string{MetricConnections, MetricRouters}
+
+func isHistoryMetric(name string) bool {
+	return slices.Contains(metricCounters, name) || slices.Contains(metricGauges, name)
+}
+
+// MetricPoint 时间段起点（毫秒）与该时间段的值
+type MetricPoint struct {
+	T int64 `json:"t"`
+	V int64 `json:"v"`
+}
+
+// metricRing 固定长度的环形时间桶，写入时清空跳过的桶，不随请求量分配内存
+type metricRing struct {
+	step  int64
+	slots []int64
+	// head 最新时间桶的编号（毫秒时间 / step），0 表示尚无数据
+	head int64
+}
+
+func newMetricRing(step time.Duration, size int) metricRing {
+	return metricRing{step: step.Milliseconds(), slots: make([]int64, size)}
+}
+
+func (r *metricRing) advance(bucket int64) {
+	if bucket <= r.head {
+		return
+	}
+	n := int64(len(r.slots))
+	if r.head == 0 || bucket-r.head >= n {
+		clear(r.slots)
+	} else {
+		for b := r.head + 1; b <= bucket; b++ {
+			r.slots[b%n] = 0
+		}
+	}
+	r.head = bucket
+}
+
+// slot 返回 nowMs 所在时间桶，早于保留范围时返回 nil
+func (r *metricRing) slot(nowMs int64) *int64 {
+	bucket := nowMs / r.step
+	r.advance(bucket)
+	if r.head-bucket >= int64(len(r.slots)) {
+		return nil
+	}
+	return &r.slots[bucket%int64(len(r.slots))]
+}
+
+func (r *metricRing) add(nowMs, delta int64) {
+	if p := r.slot(nowMs); p != nil {
+		*p += delta
+	}
+}
+
+func (r *metricRing) observe(nowMs, v int64) {
+	if p := r.slot(nowMs); p != nil {
+		*p = max(*p, v)
+	}
+}
+
+// points 从 fromMs 到 nowMs 的各时间桶，超出保留范围的部分截掉
+func (r *metricRing) points(fromMs, nowMs int64) []MetricPoint {
+	r.advance(nowMs / r.step)
+	n := int64(len(r.slots))
+	first := max(fromMs/r.step, r.head-n+1)
+	out := make([]MetricPoint, 0, max(0, r.head-first+1))
+	for b := first; b <= r.head; b++ {
+		out = append(out, MetricPoint{T: b * r.step, V: r.slots[b%n]})
+	}
+	return out
+}
+
+// sum 最近 window 内的累计值
+func (r *metricRing) sum(window time.Duration, nowMs int64) int64 {
+	var total int64
+	for _, p := range r.points(nowMs-window.Milliseconds()+1, nowMs) {
+		total += p.V
+	}
+	return total
+}
+
+// timeSeries 同一指标的秒级与分钟级两组时间桶
+type timeSeries struct {
+	second metricRing
+	minute metricRing
+}
+
+func newTimeSeries(secondSlots int) *timeSeries {
+	return &timeSeries{
+		second: newMetricRing(time.Second, secondSlots),
+		minute: newMetricRing(time.Minute, metricMinuteSlots),
+	}
+}
+
+func (ts *timeSeries) add(nowMs, delta int64) {
+	ts.second.add(nowMs, delta)
+	ts.minute.add(nowMs, delta)
+}
+
+func (ts *timeSeries) observe(nowMs, v int64) {
+	ts.second.observe(nowMs, v)
+	ts.minute.observe(nowMs, v)
+}
+
+func (ts *timeSeries) ring(resolution string) *metricRing {
+	if resolution == MetricResolutionMinute {
+		return &ts.minute
+	}
+	return &ts.second
+}
+
+// metricsHistory 进程内的指标时间序列
+type metricsHistory struct {
+	mu      sync.Mutex
+	series  map[string]*timeSeries
+	routers map[string]*timeSeries
+}
+
+func newMetricsHistory() *metricsHistory {
+	h := &metricsHistory{series: make(map[string]*timeSeries), routers: make(map[string]*timeSeries)}
+	for _, name := range metricCounters {
+		h.series[name] = newTimeSeries(metricSecondSlots)
+	}
+	for _, name := range metricGauges {
+		h.series[name] = newTimeSeries(metricSecondSlots)
+	}
+	return h
+}
+
+// recordRequest 一帧入站消息
+func (h *metricsHistory) recordRequest(nowMs int64, size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.series[MetricRequests].add(nowMs, 1)
+	h.series[MetricBytes].add(nowMs, int64(size))
+}
+
+func (h *metricsHistory) add(name string, nowMs, delta int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ts := h.series[name]; ts != nil {
+		ts.add(nowMs, delta)
+	}
+}
+
+// recordRpc 一次 RPC 调用，同时计入 routeIds 中的调用方与被调用方；调用方只传入已注册的节点
+func (h *metricsHistory) recordRpc(nowMs int64, routeIds ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.series[MetricRpc].add(nowMs, 1)
+	for _, routeId := range routeIds {
+		h.routerSeriesLocked(routeId).add(nowMs, 1)
+	}
+}
+
+// removeRouters 节点会话移除后删除其 RPC 时间序列
+func (h *metricsHistory) removeRouters(routeIds []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, routeId := range routeIds {
+		delete(h.routers, routeId)
+	}
+}
+
+func (h *metricsHistory) routerSeriesLocked(routeId string) *timeSeries {
+	ts, ok := h.routers[routeId]
+	if !ok {
+		ts = newTimeSeries(routerMetricSecondSlots)
+		h.routers[routeId] = ts
+	}
+	return ts
+}
+
+func (h *metricsHistory) observeGauges(nowMs, connections, routers int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.series[MetricConnections].observe(nowMs, connections)
+	h.series[MetricRouters].observe(nowMs, routers)
+}
+
+// lastMinute 指标最近一分钟的累计值
+func (h *metricsHistory) lastMinute(name string, nowMs int64) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ts := h.series[name]; ts != nil {
+		return ts.second.sum(time.Minute, nowMs)
+	}
+	return 0
+}
+
+// routerLastMinute 节点最近一分钟的 RPC 调用数
+func (h *metricsHistory) routerLastMinute(routeId string, nowMs int64) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ts := h.routers[routeId]; ts != nil {
+		return ts.second.sum(time.Minute, nowMs)
+	}
+	return 0
+}
+
+func (h *metricsHistory) points(name, resolution string, fromMs, nowMs int64) ([]MetricPoint, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ts := h.series[name]
+	if ts == nil {
+		return nil, false
+	}
+	return ts.ring(resolution).points(fromMs, nowMs), true
+}
+
+// routerPoints 节点的 RPC 调用历史；节点的秒级精度只保留最近一分钟
+func (h *metricsHistory) routerPoints(routeId, resolution string, fromMs, nowMs int64) []MetricPoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ts := h.routers[routeId]
+	if ts == nil {
+		return []MetricPoint{}
+	}
+	return ts.ring(resolution).points(fromMs, nowMs)
+}
+
+// MetricHistoryQuery /api/metrics/history 的查询参数
+type MetricHistoryQuery struct {
+	Metrics    []string
+	Resolution string
+	Window     time.Duration
+	RouteId    string
+}
+
+// MetricHistory 查询结果，Series 按指标名分组
+type MetricHistory struct {
+	Resolution string                   `json:"resolution"`
+	StepMs     int64                    `json:"stepMs"`
+	From       int64                    `json:"from"`
+	To         int64                    `json:"to"`
+	RouteId    string                   `json:"routeId,omitempty"`
+	Series     map[string][]MetricPoint `json:"series"`
+}
+
+// MetricsHistory 按精度与时间窗口查询历史指标；指定 RouteId 时只返回该节点的 RPC 调用数
+func (s *Server) MetricsHistory(q MetricHistoryQuery) MetricHistory {
+	now := time.Now()
+	if q.Resolution != MetricResolutionMinute {
+		q.Resolution = MetricResolutionSecond
+	}
+	step, limit, def := time.Second, time.Duration(metricSecondSlots)*time.Second, defaultSecondHistoryWindow
+	if q.Resolution == MetricResolutionMinute {
+		step, limit = time.Minute, time.Duration(metricMinuteSlots)*time.Minute
+		def = limit
+	}
+	if q.Window <= 0 {
+		q.Window = def
+	}
+	q.Window = min(q.Window, limit)
+	nowMs := now.UnixMilli()
+	fromMs := nowMs - q.Window.Milliseconds() + step.Milliseconds()
+	out := MetricHistory{Resolution: q.Resolution, StepMs: step.Milliseconds(), From: fromMs - fromMs%step.Milliseconds(), To: nowMs, RouteId: q.RouteId, Series: make(map[string][]MetricPoint)}
+	if q.RouteId != "" {
+		out.Series[MetricRpc] = s.metrics.routerPoints(q.RouteId, q.Resolution, fromMs, nowMs)
+		return out
+	}
+	names := q.Metrics
+	if len(names) == 0 {
+		names = append(append([]string(nil), metricCounters...), metricGauges...)
+	}
+	for _, name := range names {
+		if points, ok := s.metrics.points(name, q.Resolution, fromMs, nowMs); ok {
+			out.Series[name] = points
+		}
+	}
+	return out
+}
+
+// sampleGauges 记录当前连接数与在线节点数
+func (s *Server) sampleGauges(now time.Time) {
+	s.metrics.observeGauges(now.UnixMilli(), s.currentConnections.Load(), int64(len(s.sessionManager.ListSessions())))
+}
+
+// runMetricsSampler 定时采样状态类指标，直到 ctx 结束
+func (s *Server) runMetricsSampler(ctx context.Context) {
+	ticker := time.NewTicker(metricGaugeSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sampleGauges(now)
+		}
+	}
+}
+
+// recordError 记录一次转发失败或被拒绝的消息
+func (s *Server) recordError() {
+	s.metrics.add(MetricErrors, time.Now().UnixMilli(), 1)
+}
@@ -0,0 +1,261 @@
+package VirtualRouterServer
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+// RateLimitedErrorCode 被限流时返回给节点的错误码，位于错误信息开头
+const RateLimitedErrorCode = "RATE_LIMITED"
+
+// 触发的限流项
+const (
+	RateLimitMessages = "messages"
+	RateLimitBytes    = "bytes"
+	RateLimitRpc      = "rpc"
+	RateLimitPacket   = "packet"
+)
+
+// rateLimitNoticeInterval 同一节点同一限流项的告警日志与 SystemError 的最小间隔
+const rateLimitNoticeInterval = time.Second
+
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, burstSeconds float64, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burstSeconds <= 0 {
+		burstSeconds = 1
+	}
+	capacity := max(rate*burstSeconds, 1)
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// has 是否有 n 个令牌；超过容量的单次请求在桶满时放行，之后按欠额补足
+func (b *tokenBucket) has(n float64) bool {
+	return b.tokens >= min(n, b.capacity)
+}
+
+type nodeRateLimiter struct {
+	// routeId 规则所属的已注册节点，未注册的连接使用默认规则
+	routeId  string
+	rule     config.RateLimitRule
+	messages *tokenBucket
+	bytes    *tokenBucket
+	rpc      *tokenBucket
+	packets  map[int]*tokenBucket
+
+	// lastNotice 各限流项最近一次告警的时间
+	lastNotice map[string]time.Time
+}
+
+func newNodeRateLimiter(routeId string, rule config.RateLimitRule, now time.Time) *nodeRateLimiter {
+	return &nodeRateLimiter{
+		routeId:  routeId,
+		rule:     rule,
+		messages: newTokenBucket(rule.MessagesPerSecond, rule.BurstSeconds, now),
+		bytes:    newTokenBucket(rule.BytesPerSecond, rule.BurstSeconds, now),
+		rpc:      newTokenBucket(rule.RpcPerSecond, rule.BurstSeconds, now),
+		packets:  make(map[int]*tokenBucket),
+
+		lastNotice: make(map[string]time.Time),
+	}
+}
+
+// packetBucket 只为配置了规则的 packetId 创建令牌桶；packetId 由节点填写，没有规则的不缓存，避免表无限增长
+func (n *nodeRateLimiter) packetBucket(packetId int, now time.Time) *tokenBucket {
+	if b, ok := n.packets[packetId]; ok {
+		return b
+	}
+	rate, ok := n.rule.PacketRpcPerSecond[packetId]
+	if !ok || rate <= 0 {
+		return nil
+	}
+	b := newTokenBucket(rate, n.rule.BurstSeconds, now)
+	n.packets[packetId] = b
+	return b
+}
+
+// rateLimiter 按连接分配的令牌桶：消息中的 FromRouteId 由节点填写，不能作为限流的依据；
+// 连接关闭时删除，限流配置变更后按新规则重建
+type rateLimiter struct {
+	cfg atomic.Pointer[config.RateLimitConfig]
+
+	mu    sync.Mutex
+	nodes map[net.Conn]*nodeRateLimiter
+}
+
+// setConfig 更新限流配置；与当前配置相同时保留已有令牌桶
+func (l *rateLimiter) setConfig(cfg *config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.nodes != nil && reflect.DeepEqual(l.cfg.Load(), cfg) {
+		return
+	}
+	l.cfg.Store(cfg)
+	l.nodes = make(map[net.Conn]*nodeRateLimiter)
+}
+
+// release 删除连接的令牌桶
+func (l *rateLimiter) release(conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.nodes, conn)
+}
+
+// allow 判断连接发出的消息是否放行，routeId 为连接已注册的节点（未注册为空，使用默认规则）；
+// 返回触发的限流项以及是否需要通知（按间隔节流）
+func (l *rateLimiter) allow(conn net.Conn, routeId string, msg *core.RouteMessage, size int, now time.Time) (kind string, notice bool) {
+	cfg := l.cfg.Load()
+	if cfg == nil || msg.MessageType == nil {
+		return "", false
+	}
+	mt := *msg.MessageType
+	if mt != core.RouteMessageTypeMessageData && mt != core.RouteMessageTypeRpcRequest {
+		return "", false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	node, ok := l.nodes[conn]
+	if !ok || node.routeId != routeId {
+		node = newNodeRateLimiter(routeId, ruleFor(cfg, routeId), now)
+		l.nodes[conn] = node
+	}
+	type charge struct {
+		bucket *tokenBucket
+		n      float64
+		kind   string
+	}
+	charges := []charge{{node.messages, 1, RateLimitMessages}, {node.bytes, float64(size), RateLimitBytes}}
+	if mt == core.RouteMessageTypeRpcRequest {
+		charges = append(charges, charge{node.rpc, 1, RateLimitRpc})
+		if len(node.rule.PacketRpcPerSecond) > 0 && msg.Data != nil {
+			charges = append(charges, charge{node.packetBucket(extractPacketId(*msg.Data), now), 1, RateLimitPacket})
+		}
+	}
+	// 先检查所有桶再扣减，被拒绝的消息不消耗其他桶的令牌
+	for _, c := range charges {
+		if c.bucket == nil {
+			continue
+		}
+		c.bucket.refill(now)
+		if !c.bucket.has(c.n) {
+			notice = now.Sub(node.lastNotice[c.kind]) >= rateLimitNoticeInterval
+			if notice {
+				node.lastNotice[c.kind] = now
+			}
+			return c.kind, notice
+		}
+	}
+	for _, c := range charges {
+		if c.bucket != nil {
+			c.bucket.tokens -= c.n
+		}
+	}
+	return "", false
+}
+
+// ruleFor 已注册节点使用其覆盖规则，未注册的连接只使用默认规则
+func ruleFor(cfg *config.RateLimitConfig, routeId string) config.RateLimitRule {
+	if routeId == "" {
+		return cfg.Default
+	}
+	return cfg.RuleFor(routeId)
+}
+
+func extractPacketId(data string) int {
+	var req struct {
+		PacketId int `json:"packetId"`
+	}
+	_ = json.Unmarshal([]byte(data), &req)
+	return req.PacketId
+}
+
+func rateLimitedMessage(routeId, kind string) string {
+	return RateLimitedErrorCode + ": 节点 " + routeId + " 超出限流 (" + kind + ")"
+}
+
+// rejectRateLimited 拒绝被限流的消息：RPC 请求立即以错误响应返回给调用方，普通消息按间隔回复 SystemError；
+// 只有已注册节点（registeredId 非空）计入限流统计
+func (s *Server) rejectRateLimited(msg *core.RouteMessage, conn net.Conn, queue *writeQueue, routeId, registeredId, kind string, notice bool) {
+	s.recordError()
+	if registeredId != "" {
+		s.recordRateLimited(registeredId, kind)
+	}
+	if notice {
+		componentLog(LogComponentRouter).Warn("节点超出限流，拒绝消息", "routeId", routeId, "limit", kind, "type", msg.MessageType.String(), "to", msg.ToRouteId)
+	}
+	session := s.newConnSession(routeId, conn, core.RpcServerInfo{}, queue)
+	errMsg := rateLimitedMessage(routeId, kind)
+	if *msg.MessageType == core.RouteMessageTypeRpcRequest {
+		var req rpc.RpcRequest
+		if msg.Data != nil {
+			_ = json.Unmarshal([]byte(*msg.Data), &req)
+		}
+		if req.RpcUid == "" && msg.Data != nil {
+			req.RpcUid = extractRpcUid(*msg.Data)
+		}
+		data, _ := json.Marshal(rpc.RpcResponse{
+			RpcUid:      req.RpcUid,
+			ErrorFlag:   true,
+			ErrorMsg:    errMsg,
+			StartTimeMs: req.StartTimeMs,
+			PacketId:    req.PacketId,
+		})
+		dataStr := string(data)
+		mt := core.RouteMessageTypeRpcResponse
+		_ = session.WriteRouteMessage(&core.RouteMessage{FromRouteId: msg.ToRouteId, ToRouteId: routeId, MessageType: &mt, Data: &dataStr})
+		return
+	}
+	if !notice {
+		return
+	}
+	mt := core.RouteMessageTypeSystemError
+	_ = session.WriteRouteMessage(&core.RouteMessage{FromRouteId: "server", ToRouteId: routeId, MessageType: &mt, Data: &errMsg})
+}
+
+func (s *Server) recordRateLimited(routeId, kind string) {
+	s.rpcStatsMu.Lock()
+	defer s.rpcStatsMu.Unlock()
+	item := s.ensureRouterRPCStats(routeId)
+	item.RateLimited++
+	if item.RateLimitedByKind == nil {
+		item.RateLimitedByKind = make(map[string]uint64)
+	}
+	item.RateLimitedByKind[kind]++
+}
+
+// RateLimitHits 节点各限流项被触发的次数
+func (s *Server) RateLimitHits(routeId string) map[string]uint64 {
+	s.rpcStatsMu.RLock()
+	defer s.rpcStatsMu.RUnlock()
+	out := make(map[string]uint64)
+	if item, ok := s.rpcStatsByRouter[routeId]; ok {
+		for k, v := range item.RateLimitedByKind {
+			out[k] = v
+		}
+	}
+	return out
+}
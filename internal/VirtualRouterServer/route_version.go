@@ -0,0 +1,87 @@
+package VirtualRouterServer
+
+import (
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// maxRouteChangeLog 保留的路由表变更条数，节点落后更多时下发完整路由表
+const maxRouteChangeLog = 1024
+
+type routeChange struct {
+	version int64
+	node    core.RouteNode
+	removed bool
+}
+
+// routeChangeLog 路由表版本号与最近的变更记录，由 RouterSessionManager.mu 保护
+type routeChangeLog struct {
+	version int64
+	changes []routeChange
+}
+
+// record 记录一次节点加入、RPC 地址变更或移除，返回新版本号
+func (l *routeChangeLog) record(node core.RouteNode, removed bool) int64 {
+	l.version++
+	l.changes = append(l.changes, routeChange{version: l.version, node: node, removed: removed})
+	if len(l.changes) > maxRouteChangeLog {
+		l.changes = append([]routeChange(nil), l.changes[len(l.changes)-maxRouteChangeLog:]...)
+	}
+	return l.version
+}
+
+// since 持有 from 版本的节点需要的增量，同一节点的多次变更只保留最后一次；记录不足时返回 false
+func (l *routeChangeLog) since(from int64) (core.RouteTableDelta, bool) {
+	delta := core.RouteTableDelta{FromVersion: from, Version: l.version}
+	if from <= 0 || from > l.version {
+		return delta, false
+	}
+	if from == l.version {
+		return delta, true
+	}
+	if len(l.changes) == 0 || l.changes[0].version > from+1 {
+		return delta, false
+	}
+	latest := make(map[string]routeChange)
+	order := make([]string, 0)
+	for _, c := range l.changes {
+		if c.version <= from {
+			continue
+		}
+		if _, ok := latest[c.node.RouterId]; !ok {
+			order = append(order, c.node.RouterId)
+		}
+		latest[c.node.RouterId] = c
+	}
+	for _, routeId := range order {
+		c := latest[routeId]
+		if c.removed {
+			delta.Removes = append(delta.Removes, routeId)
+		} else {
+			delta.Upserts = append(delta.Upserts, c.node)
+		}
+	}
+	return delta, true
+}
+
+func routeNodeOf(routeId string, info core.RpcServerInfo) core.RouteNode {
+	return core.RouteNode{RouterId: routeId, HostForRpc: info.Host, PortForRpc: info.Port}
+}
+
+// RouteVersion 当前路由表版本号，每次节点加入、RPC 地址变更或移除时递增
+func (m *RouterSessionManager) RouteVersion() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.routes.version
+}
+
+// RouteDeltaSince 持有 from 版本的节点需要的路由表变更，无法增量时返回完整路由表
+func (m *RouterSessionManager) RouteDeltaSince(from int64) core.RouteTableDelta {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	delta, ok := m.routes.since(from)
+	if !ok {
+		delta.Snapshot = true
+		delta.Upserts = m.routeNodesLocked()
+	}
+	return delta
+}
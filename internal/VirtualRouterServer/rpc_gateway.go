@@ -0,0 +1,207 @@
+package VirtualRouterServer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
+)
+
+// GatewayRouteId HTTP 网关发出 RPC 时使用的来源 routeId，节点的响应会回到这里
+const GatewayRouteId = "http-gateway"
+
+// debugRouteId 管理后台调试 RPC 的来源 routeId
+const debugRouteId = "debug-admin"
+
+const (
+	defaultGatewayTimeout = 10 * time.Second
+	maxGatewayTimeout     = 2 * time.Minute
+	// maxGatewayBodyBytes 网关请求体上限，参数最终要装进一帧发给节点，超过帧上限的请求直接拒绝
+	maxGatewayBodyBytes = core.MaxFrameSize
+)
+
+// 网关错误码
+const (
+	GatewayErrBadRequest   = "BAD_REQUEST"
+	GatewayErrTooLarge     = "PAYLOAD_TOO_LARGE"
+	GatewayErrUnauthorized = "UNAUTHORIZED"
+	GatewayErrForbidden    = "FORBIDDEN"
+	GatewayErrRouteOffline = "ROUTE_NOT_FOUND"
+	GatewayErrNoStub       = "PACKET_NOT_FOUND"
+	GatewayErrSendFailed   = "SEND_FAILED"
+	GatewayErrTimeout      = "TIMEOUT"
+	GatewayErrRemote       = "REMOTE_ERROR"
+	GatewayErrCanceled     = "CANCELED"
+)
+
+// GatewayError 网关调用失败的结构化错误
+type GatewayError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// HTTPStatus 该错误对应的 HTTP 状态码
+	HTTPStatus int `json:"-"`
+}
+
+func (e *GatewayError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newGatewayError(code string, status int, message string) *GatewayError {
+	return &GatewayError{Code: code, Message: message, HTTPStatus: status}
+}
+
+// GatewayResult 网关调用成功的结果
+type GatewayResult struct {
+	RpcUid   string `json:"rpcUid"`
+	RouteId  string `json:"routeId"`
+	PacketId int    `json:"packetId"`
+	Method   string `json:"method"`
+	// Result 节点返回值，能解析为 JSON 时为解析后的值，否则为原始字符串
+	Result any   `json:"result"`
+	CostMs int64 `json:"costMs"`
+	// TraceId 本次调用的链路 id，可在追踪后端检索整条调用链
+	TraceId string `json:"traceId"`
+}
+
+var centerRpcUidCounter atomic.Uint64
+
+// newCenterRpcUid 由 Router Center 发起的 RPC 使用的唯一 rpcUid
+func newCenterRpcUid(prefix string) string {
+	return prefix + "-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(centerRpcUidCounter.Add(1), 36)
+}
+
+// InvokeRpc 通过 Router Center 同步调用节点 RPC，阻塞直到节点响应、超时或 ctx 取消。
+// argsJson 为每个参数的 JSON；timeout<=0 时使用配置的 gatewayTimeoutMs。
+// ctx 中带有链路（例如 HTTP 请求头 traceparent）时，节点上的执行会接在该链路下。
+func (s *Server) InvokeRpc(ctx context.Context, routeId string, packetId int, argsJson []string, timeout time.Duration) (result *GatewayResult, err error) {
+	ctx, span := tracing.Start(ctx, "Server.InvokeRpc", tracing.SpanKindServer,
+		tracing.String("route.to", routeId),
+		tracing.Int("rpc.packet_id", packetId))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	if routeId == "" || packetId <= 0 {
+		return nil, newGatewayError(GatewayErrBadRequest, http.StatusBadRequest, "routeId 不能为空且 packetId 必须大于 0")
+	}
+	session := s.sessionManager.GetSession(routeId)
+	if session == nil {
+		return nil, newGatewayError(GatewayErrRouteOffline, http.StatusNotFound, "路由节点不存在: "+routeId)
+	}
+	if session.IsSuspect() {
+		return nil, newGatewayError(GatewayErrRouteOffline, http.StatusServiceUnavailable, "路由节点疑似下线: "+routeId)
+	}
+	stub := findStub(session.GetRpcServerInfo().Stubs, packetId)
+	if stub == nil {
+		return nil, newGatewayError(GatewayErrNoStub, http.StatusNotFound, "目标节点未注册 Packet ID = "+intToString(packetId))
+	}
+	if timeout <= 0 {
+		timeout = s.gatewayTimeout()
+	}
+	if timeout > maxGatewayTimeout {
+		timeout = maxGatewayTimeout
+	}
+
+	rpcUid := newCenterRpcUid("gw")
+	waitCh := make(chan rpc.RpcResponse, 1)
+	s.gatewayPending.Store(rpcUid, waitCh)
+	defer s.gatewayPending.Delete(rpcUid)
+
+	start := time.Now()
+	req := rpc.RpcRequest{
+		FromRouteId:        GatewayRouteId,
+		ToRouteId:          routeId,
+		RpcUid:             rpcUid,
+		StartTimeMs:        start.UnixMilli(),
+		PacketId:           packetId,
+		MethodArgsJsonList: argsJson,
+	}
+	span.SetAttributes(tracing.String("rpc.uid", rpcUid), tracing.String("rpc.method", stub.ClassName+"."+stub.MethodName))
+	dataBytes, _ := json.Marshal(req)
+	dataStr := string(dataBytes)
+	mt := core.RouteMessageTypeRpcRequest
+	if err := session.WriteRouteMessage(&core.RouteMessage{
+		FromRouteId: GatewayRouteId,
+		ToRouteId:   routeId,
+		MessageType: &mt,
+		Data:        &dataStr,
+		Traceparent: span.Traceparent(),
+	}); err != nil {
+		return nil, newGatewayError(GatewayErrSendFailed, http.StatusBadGateway, "发送 RPC 请求失败: "+err.Error())
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-waitCh:
+		if resp.ErrorFlag {
+			return nil, newGatewayError(GatewayErrRemote, http.StatusBadGateway, resp.ErrorMsg)
+		}
+		var value any
+		if err := json.Unmarshal([]byte(resp.ResultValueStr), &value); err != nil {
+			value = resp.ResultValueStr
+		}
+		return &GatewayResult{
+			RpcUid:   rpcUid,
+			RouteId:  routeId,
+			PacketId: packetId,
+			Method:   stub.ClassName + "." + stub.MethodName,
+			Result:   value,
+			CostMs:   time.Since(start).Milliseconds(),
+			TraceId:  span.SpanContext().TraceId.String(),
+		}, nil
+	case <-timer.C:
+		componentLog(LogComponentGateway).Warn("HTTP 网关 RPC 超时", "routeId", routeId, "packetId", packetId, "rpcUid", rpcUid, "timeout", timeout)
+		return nil, newGatewayError(GatewayErrTimeout, http.StatusGatewayTimeout, "等待节点响应超时: "+timeout.String())
+	case <-ctx.Done():
+		return nil, newGatewayError(GatewayErrCanceled, 499, "调用已取消: "+ctx.Err().Error())
+	}
+}
+
+func (s *Server) gatewayTimeout() time.Duration {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	if s.cfg != nil && s.cfg.GatewayTimeoutMs > 0 {
+		return time.Duration(s.cfg.GatewayTimeoutMs) * time.Millisecond
+	}
+	return defaultGatewayTimeout
+}
+
+// completeGatewayRpc 把节点响应交给等待中的网关调用，调用已超时则丢弃
+func (s *Server) completeGatewayRpc(data string) {
+	var resp rpc.RpcResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		componentLog(LogComponentGateway).Warn("HTTP 网关 RPC 响应解析失败", "error", err)
+		return
+	}
+	v, ok := s.gatewayPending.Load(resp.RpcUid)
+	if !ok {
+		componentLog(LogComponentGateway).Debug("HTTP 网关 RPC 响应已过期", "rpcUid", resp.RpcUid)
+		return
+	}
+	select {
+	case v.(chan rpc.RpcResponse) <- resp:
+	default:
+	}
+}
+
+// isReservedRouteId Router Center 自己发起 RPC 时占用的 routeId，节点不能使用
+func isReservedRouteId(routeId string) bool {
+	return routeId == GatewayRouteId || routeId == debugRouteId
+}
+
+// asGatewayError 把任意错误转换为网关结构化错误
+func asGatewayError(err error) *GatewayError {
+	var gwErr *GatewayError
+	if errors.As(err, &gwErr) {
+		return gwErr
+	}
+	return newGatewayError(GatewayErrSendFailed, http.StatusInternalServerError, err.Error())
+}
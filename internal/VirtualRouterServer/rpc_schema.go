@@ -0,0 +1,287 @@
+package VirtualRouterServer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+const (
+	RpcSchemaFormatOpenAPI    = "openapi"
+	RpcSchemaFormatJsonSchema = "jsonschema"
+
+	RpcSchemaModeMerged = "merged"
+	RpcSchemaModeNode   = "node"
+)
+
+// RpcSchemaDocument 一份导出的 RPC 目录文档，Name 用作导出文件名
+type RpcSchemaDocument struct {
+	Name     string         `json:"name"`
+	Document map[string]any `json:"document"`
+}
+
+type nodeStubs struct {
+	RouteId string
+	Stubs   []core.RpcStubMetadata
+}
+
+// mergedStub 合并模式下同一 packetId 的 Stub，记录提供它的节点
+type mergedStub struct {
+	Stub     core.RpcStubMetadata
+	RouteIds []string
+	Conflict bool
+}
+
+// ExportRpcSchema 把集群内节点的 RpcStubMetadata 导出为 OpenAPI 或 JSON Schema 文档。
+// mode=merged 时合并为一份文档；mode=node 时每个节点一份。routeId 非空时只导出该节点。
+func (s *Server) ExportRpcSchema(format, mode, routeId string) []RpcSchemaDocument {
+	nodes := s.collectNodeStubs(routeId)
+	if mode == RpcSchemaModeNode {
+		docs := make([]RpcSchemaDocument, 0, len(nodes))
+		for _, n := range nodes {
+			merged := mergeStubs([]nodeStubs{n})
+			docs = append(docs, RpcSchemaDocument{Name: n.RouteId, Document: buildSchemaDocument(format, n.RouteId, merged)})
+		}
+		return docs
+	}
+	return []RpcSchemaDocument{{Name: "cluster", Document: buildSchemaDocument(format, "", mergeStubs(nodes))}}
+}
+
+func (s *Server) collectNodeStubs(routeId string) []nodeStubs {
+	var nodes []nodeStubs
+	if routeId != "" {
+		if session := s.sessionManager.GetSession(routeId); session != nil {
+			nodes = append(nodes, nodeStubs{RouteId: routeId, Stubs: session.GetRpcServerInfo().Stubs})
+		}
+		return nodes
+	}
+	for _, session := range s.sessionManager.ListSessions() {
+		nodes = append(nodes, nodeStubs{RouteId: session.RouterId, Stubs: session.GetRpcServerInfo().Stubs})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].RouteId < nodes[j].RouteId })
+	return nodes
+}
+
+func mergeStubs(nodes []nodeStubs) []*mergedStub {
+	byPacketId := map[int]*mergedStub{}
+	for _, n := range nodes {
+		for _, stub := range n.Stubs {
+			item, ok := byPacketId[stub.PacketId]
+			if !ok {
+				byPacketId[stub.PacketId] = &mergedStub{Stub: stub, RouteIds: []string{n.RouteId}}
+				continue
+			}
+			item.RouteIds = append(item.RouteIds, n.RouteId)
+			if stubSignatureKey(item.Stub) != stubSignatureKey(stub) {
+				item.Conflict = true
+			}
+		}
+	}
+	list := make([]*mergedStub, 0, len(byPacketId))
+	for _, item := range byPacketId {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Stub.PacketId < list[j].Stub.PacketId })
+	return list
+}
+
+func buildSchemaDocument(format, routeId string, stubs []*mergedStub) map[string]any {
+	if format == RpcSchemaFormatJsonSchema {
+		return buildJsonSchemaDocument(routeId, stubs)
+	}
+	return buildOpenAPIDocument(routeId, stubs)
+}
+
+func buildJsonSchemaDocument(routeId string, stubs []*mergedStub) map[string]any {
+	title := "Virtual Router RPC catalog"
+	id := "virtual-router://cluster/rpc"
+	if routeId != "" {
+		title = routeId + " RPC catalog"
+		id = "virtual-router://" + routeId + "/rpc"
+	}
+	defs := map[string]any{}
+	for _, item := range stubs {
+		stub := item.Stub
+		def := map[string]any{
+			"title":        stubTitle(stub),
+			"x-packetId":   stub.PacketId,
+			"x-className":  stub.ClassName,
+			"x-methodName": stub.MethodName,
+			"x-routeIds":   item.RouteIds,
+			"type":         "object",
+			"properties": map[string]any{
+				"params": paramsSchema(stub),
+				"result": resultSchema(stub),
+			},
+			"required": []string{"params"},
+		}
+		if stub.Description != "" {
+			def["description"] = stub.Description
+		}
+		if item.Conflict {
+			def["x-conflict"] = true
+		}
+		defs[intToString(stub.PacketId)] = def
+	}
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     id,
+		"title":   title,
+		"$defs":   defs,
+	}
+}
+
+func buildOpenAPIDocument(routeId string, stubs []*mergedStub) map[string]any {
+	title := "Virtual Router RPC Gateway"
+	if routeId != "" {
+		title = routeId + " RPC"
+	}
+	paths := map[string]any{}
+	for _, item := range stubs {
+		stub := item.Stub
+		operation := map[string]any{
+			"operationId": operationId(stub),
+			"summary":     stubTitle(stub),
+			"tags":        []string{fallbackString(afterLast(stub.ClassName, "."), "rpc")},
+			"x-packetId":  stub.PacketId,
+			"requestBody": map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": map[string]any{
+							"type":       "object",
+							"properties": map[string]any{"params": paramsSchema(stub)},
+							"required":   []string{"params"},
+						},
+					},
+				},
+			},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "RPC 调用成功",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": resultSchema(stub)},
+					},
+				},
+			},
+		}
+		if stub.Description != "" {
+			operation["description"] = stub.Description
+		}
+		if item.Conflict {
+			operation["x-conflict"] = true
+		}
+
+		path := "/rpc/{routeId}/" + intToString(stub.PacketId)
+		if routeId != "" {
+			path = "/rpc/" + routeId + "/" + intToString(stub.PacketId)
+		} else {
+			operation["parameters"] = []any{
+				map[string]any{
+					"name":     "routeId",
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]any{"type": "string", "enum": item.RouteIds},
+				},
+			}
+		}
+		paths[path] = map[string]any{"post": operation}
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   title,
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+}
+
+func paramsSchema(stub core.RpcStubMetadata) map[string]any {
+	items := make([]any, 0, len(stub.ParameterTypes))
+	for i, typeStr := range stub.ParameterTypes {
+		var schema map[string]any
+		if i < len(stub.ParameterSchemaJson) {
+			_ = json.Unmarshal([]byte(stub.ParameterSchemaJson[i]), &schema)
+		}
+		if schema == nil {
+			schema = schemaForTypeName(typeStr)
+		}
+		if i < len(stub.ParameterNames) && stub.ParameterNames[i] != "" {
+			schema["title"] = stub.ParameterNames[i]
+		}
+		if i < len(stub.ParameterDescriptions) && stub.ParameterDescriptions[i] != "" {
+			schema["description"] = stub.ParameterDescriptions[i]
+		}
+		if i < len(stub.ParameterExampleJson) {
+			var example any
+			if err := json.Unmarshal([]byte(stub.ParameterExampleJson[i]), &example); err == nil {
+				schema["examples"] = []any{example}
+			}
+		}
+		items = append(items, schema)
+	}
+	return map[string]any{
+		"type":        "array",
+		"prefixItems": items,
+		"minItems":    len(items),
+		"maxItems":    len(items),
+	}
+}
+
+func resultSchema(stub core.RpcStubMetadata) map[string]any {
+	var schema map[string]any
+	if stub.ReturnSchemaJson != "" {
+		_ = json.Unmarshal([]byte(stub.ReturnSchemaJson), &schema)
+	}
+	if schema == nil {
+		if stub.ReturnType != "" {
+			return schemaForTypeName(stub.ReturnType)
+		}
+		return map[string]any{}
+	}
+	return schema
+}
+
+// schemaForTypeName 节点没有上报 Schema 时（例如 Kotlin 节点），按类型名做最基本的推断
+func schemaForTypeName(typeStr string) map[string]any {
+	name := strings.TrimPrefix(typeStr, "*")
+	switch strings.ToLower(afterLast(name, ".")) {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "integer", "long", "short", "byte":
+		return map[string]any{"type": "integer"}
+	case "float32", "float64", "float", "double":
+		return map[string]any{"type": "number"}
+	case "bool", "boolean":
+		return map[string]any{"type": "boolean"}
+	case "string":
+		return map[string]any{"type": "string"}
+	}
+	if strings.HasPrefix(name, "[]") || strings.HasPrefix(name, "List<") {
+		return map[string]any{"type": "array"}
+	}
+	if strings.HasPrefix(name, "map[") || strings.HasPrefix(name, "Map<") {
+		return map[string]any{"type": "object"}
+	}
+	return map[string]any{"description": typeStr}
+}
+
+func stubTitle(stub core.RpcStubMetadata) string {
+	return "[" + intToString(stub.PacketId) + "] " + afterLast(stub.ClassName, ".") + "." + stub.MethodName
+}
+
+func operationId(stub core.RpcStubMetadata) string {
+	cls := afterLast(stub.ClassName, ".")
+	if cls == "" {
+		return stub.MethodName + "_" + intToString(stub.PacketId)
+	}
+	return cls + "_" + stub.MethodName + "_" + intToString(stub.PacketId)
+}
+
+func fallbackString(s, def string) string {
+	if strings.TrimSpace(s) == "" {
+		return def
+	}
+	return s
+}
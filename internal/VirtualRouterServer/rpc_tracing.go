@@ -0,0 +1,48 @@
+package VirtualRouterServer
+
+import (
+	"context"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
+)
+
+const tracingShutdownTimeout = 5 * time.Second
+
+// startForwardSpan 为带 traceparent 的 RpcRequest 创建转发 Span，并把 RouteMessage.Traceparent 替换为该 Span，
+// 使目标节点的执行挂在 Router Center 之下；未开启链路或消息没有链路时返回 nil，Data 始终原样转发。
+func (s *Server) startForwardSpan(msg *core.RouteMessage) *tracing.Span {
+	if msg.Traceparent == "" || msg.MessageType == nil || *msg.MessageType != core.RouteMessageTypeRpcRequest || !tracing.Enabled() {
+		return nil
+	}
+	parent, err := tracing.ParseTraceparent(msg.Traceparent)
+	if err != nil {
+		return nil
+	}
+	_, span := tracing.Start(tracing.ContextWithRemote(context.Background(), parent), "Server.forwardToTarget", tracing.SpanKindServer,
+		tracing.String("route.from", msg.FromRouteId),
+		tracing.String("route.to", msg.ToRouteId))
+	msg.Traceparent = span.Traceparent()
+	return span
+}
+
+func (s *Server) installTracing() error {
+	if s.cfg == nil || s.cfg.Tracing == nil || s.cfg.Tracing.Exporter == "" {
+		return nil
+	}
+	if err := tracing.Install(s.cfg.Tracing, "virtual-router-center"); err != nil {
+		return err
+	}
+	s.tracingInstalled.Store(true)
+	return nil
+}
+
+func (s *Server) shutdownTracing() {
+	if !s.tracingInstalled.CompareAndSwap(true, false) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	_ = tracing.Shutdown(ctx)
+}
@@ -3,8 +3,9 @@ package VirtualRouterServer
 import (
 	"context"
 	"encoding/json"
-	"log/slog"
+	"errors"
 	"net"
+	"net/http"
 	"regexp"
 	"sort"
 	"strconv"
@@ -13,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/neko233-com/virtual-router-go/internal/capture"
 	"github.com/neko233-com/virtual-router-go/internal/config"
 	"github.com/neko233-com/virtual-router-go/internal/core"
 )
@@ -26,21 +28,46 @@ type Server struct {
 	totalRequests      atomic.Uint64
 	totalConnections   atomic.Uint64
 	currentConnections atomic.Int64
-	debugResults       sync.Map
-	shutdownCh         chan struct{}
+	// rejectedConnections 因连接数限制被拒绝的连接数
+	rejectedConnections atomic.Uint64
+	debugHistory        *DebugHistoryStore
+	gatewayPending      sync.Map
+
+	tap *MessageTap
+
+	captureMu        sync.Mutex
+	capture          atomic.Pointer[capture.Writer]
+	captureStartedAt int64
+	// lastCapture 上一次因达到上限自动停止的抓包
+	lastCapture *CaptureStatus
+
+	tracingInstalled atomic.Bool
+	shutdownCh       chan struct{}
+
+	// settingsMu 保护 cfg 中可热更新的运行参数（网关超时等）
+	settingsMu sync.RWMutex
+	// writePolicy 节点连接发送队列的限制，热更新后对已有连接立即生效
+	writePolicy atomic.Pointer[WriteQueuePolicy]
+	limiter     rateLimiter
+	connPolicy  atomic.Pointer[ConnPolicy]
+	conns       connTracker
 
 	rpcStatsMu       sync.RWMutex
 	rpcStatsByRouter map[string]*routerRPCStats
 
-	requestStatsMu sync.Mutex
-	requestHits    []int64
+	// metrics 请求、流量、RPC、错误、连接数的时间序列
+	metrics *metricsHistory
+
+	cpu cpuSampler
 }
 
 type routerRPCStats struct {
-	RouterID       string
-	IncomingTotal  uint64
-	OutgoingTotal  uint64
-	LastMinuteHits []int64
+	RouterID      string
+	IncomingTotal uint64
+	OutgoingTotal uint64
+	// RateLimited 节点发出的消息被限流拒绝的次数，按限流项细分
+	RateLimited       uint64
+	RateLimitedByKind map[string]uint64
 }
 
 type RouterRPCSnapshot struct {
@@ -49,17 +76,39 @@ type RouterRPCSnapshot struct {
 	OutgoingTotal uint64 `json:"outgoingTotal"`
 	Total         uint64 `json:"total"`
 	PerMinute     int    `json:"perMinute"`
+	RateLimited   uint64 `json:"rateLimited"`
+
+	RateLimitedByKind map[string]uint64 `json:"rateLimitedByKind,omitempty"`
 }
 
 func NewServer(cfg *config.RouterServerConfig) *Server {
-	return &Server{
+	sessionManager := NewRouterSessionManager()
+	sessionManager.SetHeartbeatPolicy(heartbeatPolicyFromConfig(cfg))
+	s := &Server{
 		cfg:              cfg,
-		sessionManager:   NewRouterSessionManager(),
+		sessionManager:   sessionManager,
+		tap:              NewMessageTap(),
+		debugHistory:     NewDebugHistoryStore(cfg.DebugHistoryFile, cfg.DebugHistoryLimit),
 		startTime:        time.Now(),
 		shutdownCh:       make(chan struct{}),
 		rpcStatsByRouter: make(map[string]*routerRPCStats),
-		requestHits:      make([]int64, 0, 256),
+		metrics:          newMetricsHistory(),
+		cpu:              cpuSampler{at: time.Now()},
 	}
+	sessionManager.onRemoved = s.metrics.removeRouters
+	s.setWriteQueuePolicy(writeQueuePolicyFromConfig(cfg))
+	s.limiter.setConfig(cfg.RateLimit)
+	s.setConnPolicy(connPolicyFromConfig(cfg))
+	return s
+}
+
+func (s *Server) setWriteQueuePolicy(p WriteQueuePolicy) {
+	s.writePolicy.Store(&p)
+}
+
+// WriteQueuePolicy 当前节点连接发送队列的限制
+func (s *Server) WriteQueuePolicy() WriteQueuePolicy {
+	return *s.writePolicy.Load()
 }
 
 func (s *Server) Start(ctx context.Context) error {
@@ -68,13 +117,19 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 	s.listener = ln
-	slog.Info("Router Server 启动成功", "port", s.cfg.RouterServerPort)
+	if err := s.installTracing(); err != nil {
+		_ = ln.Close()
+		return err
+	}
+	componentLog(LogComponentRouter).Info("Router Server 启动成功", "port", s.cfg.RouterServerPort)
 	logTCPAccessAddresses("Router Server", s.cfg.RouterServerPort)
 
 	go func() {
 		<-ctx.Done()
 		_ = s.Shutdown()
 	}()
+	go s.sessionManager.RunHeartbeatSweep(ctx)
+	go s.runMetricsSampler(ctx)
 
 	for {
 		conn, err := ln.Accept()
@@ -83,13 +138,11 @@ func (s *Server) Start(ctx context.Context) error {
 			case <-s.shutdownCh:
 				return nil
 			default:
-				slog.Warn("accept error", "error", err)
+				componentLog(LogComponentRouter).Warn("accept error", "error", err)
 				continue
 			}
 		}
-		s.totalConnections.Add(1)
-		s.currentConnections.Add(1)
-		go s.handleConn(conn)
+		s.acceptConn(conn)
 	}
 }
 
@@ -103,53 +156,104 @@ func (s *Server) Shutdown() error {
 	if s.listener != nil {
 		_ = s.listener.Close()
 	}
+	s.sessionManager.Stop()
+	if s.capture.Load() != nil {
+		_, _ = s.StopCapture()
+	}
+	s.shutdownTracing()
+	s.debugHistory.Flush()
 	return nil
 }
 
-func (s *Server) handleConn(conn net.Conn) {
+// handleConn 读取节点消息；未注册的连接受注册时限约束，注册后每条消息刷新空闲时限
+func (s *Server) handleConn(conn net.Conn, ip string) {
+	openedAt := time.Now().UnixMilli()
+	queue := newWriteQueue(conn, s.WriteQueuePolicy)
+	go queue.run()
 	defer func() {
 		s.currentConnections.Add(-1)
-		_ = conn.Close()
+		s.conns.release(ip)
+		s.limiter.release(conn)
+		queue.close()
 	}()
 
-	var routeId string
-	writeMu := &sync.Mutex{}
+	// routeId 最近一条消息的 FromRouteId，registeredId 该连接注册成功的节点
+	var routeId, registeredId string
+	if timeout := s.ConnPolicy().RegistrationTimeout; timeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	}
 
 	for {
 		payload, err := core.ReadFrame(conn)
 		if err != nil {
-			if routeId != "" {
-				s.sessionManager.RemoveSession(routeId)
+			registered := registeredId != ""
+			reason, detail := readCloseReason(queue, err, registered)
+			recordId := routeId
+			if registered {
+				recordId = registeredId
+			}
+			if reason == ConnCloseRegisterTimeout || reason == ConnCloseIdleTimeout {
+				componentLog(LogComponentRouter).Warn("连接超时，断开", "remote", remoteAddrOf(conn), "routeId", recordId, "reason", reason)
+			}
+			s.conns.record(ConnectionRecord{RemoteAddr: remoteAddrOf(conn), RouteId: recordId, OpenedAt: openedAt, ClosedAt: time.Now().UnixMilli(), Reason: reason, Detail: detail})
+			// 未完成注册的连接只有连接记录，不写入节点时间线
+			if registered {
+				s.sessionManager.RemoveSessionOfConn(registeredId, conn, reason, detail)
 			}
 			return
 		}
+		if registeredId != "" {
+			s.refreshIdleDeadline(conn)
+		}
 		s.totalRequests.Add(1)
-		s.recordRequestHit()
 		s.totalBytes.Add(uint64(len(payload)))
+		s.recordRequestHit(len(payload))
 
 		msg, err := core.DecodeRouteMessagePayload(payload)
 		if err != nil {
-			slog.Warn("decode route message error", "error", err)
+			componentLog(LogComponentRouter).Warn("decode route message error", "error", err)
 			continue
 		}
 
 		if msg.MessageType == nil {
-			slog.Warn("msgType is nil", "remote", conn.RemoteAddr().String(), "from", msg.FromRouteId, "to", msg.ToRouteId)
+			componentLog(LogComponentRouter).Warn("msgType is nil", "remote", conn.RemoteAddr().String(), "from", msg.FromRouteId, "to", msg.ToRouteId)
 			continue
 		}
 
-		if msg.FromRouteId != "" {
+		if msg.FromRouteId != "" && msg.FromRouteId != routeId {
 			routeId = msg.FromRouteId
+			queue.setRouteId(routeId)
+		}
+		if kind, notice := s.limiter.allow(conn, registeredId, msg, len(payload), time.Now()); kind != "" {
+			s.rejectRateLimited(msg, conn, queue, routeId, registeredId, kind, notice)
+			continue
 		}
 
-		s.handleRouteMessage(msg, conn, writeMu)
+		s.handleRouteMessage(msg, conn, queue)
+		if registeredId == "" && routeId != "" {
+			if session := s.sessionManager.GetSession(routeId); session != nil && session.Conn == conn {
+				registeredId = routeId
+				s.refreshIdleDeadline(conn)
+			}
+		}
+	}
+}
+
+// refreshIdleDeadline 已注册连接的读取时限，未配置空闲超时时清除注册时限
+func (s *Server) refreshIdleDeadline(conn net.Conn) {
+	var deadline time.Time
+	if idle := s.ConnPolicy().IdleTimeout; idle > 0 {
+		deadline = time.Now().Add(idle)
 	}
+	_ = conn.SetReadDeadline(deadline)
 }
 
-func (s *Server) handleRouteMessage(msg *core.RouteMessage, conn net.Conn, writeMu *sync.Mutex) {
+func (s *Server) handleRouteMessage(msg *core.RouteMessage, conn net.Conn, queue *writeQueue) {
+	s.captureInbound(msg)
+	s.tap.Publish(msg)
 	switch *msg.MessageType {
 	case core.RouteMessageTypeHeartBeat:
-		s.handleHeartBeat(msg, conn, writeMu)
+		s.handleHeartBeat(msg, conn, queue)
 	case core.RouteMessageTypeMessageData:
 		s.forwardToTarget(msg)
 	case core.RouteMessageTypeRpcRequest:
@@ -166,20 +270,32 @@ func (s *Server) handleRouteMessage(msg *core.RouteMessage, conn net.Conn, write
 	}
 }
 
-func (s *Server) handleHeartBeat(msg *core.RouteMessage, conn net.Conn, writeMu *sync.Mutex) {
+func (s *Server) handleHeartBeat(msg *core.RouteMessage, conn net.Conn, queue *writeQueue) {
 	if msg.Data == nil {
 		return
 	}
 	var rpcInfo core.RpcServerInfo
 	if err := json.Unmarshal([]byte(*msg.Data), &rpcInfo); err != nil {
-		slog.Warn("heartbeat parse error", "error", err)
+		componentLog(LogComponentRouter).Warn("heartbeat parse error", "error", err)
+		return
+	}
+	routeVersion := rpcInfo.RouteVersion
+	rpcInfo.RouteVersion = 0
+	if rpcInfo.Lite {
+		s.handleLiteHeartBeat(msg, conn, queue, rpcInfo, routeVersion)
 		return
 	}
 
-	newSession := NewRouterSession(msg.FromRouteId, conn, rpcInfo, writeMu)
+	newSession := s.newConnSession(msg.FromRouteId, conn, rpcInfo, queue)
 	newSession.RefreshHeartbeat()
 
-	session, err := s.sessionManager.UpsertSession(msg.FromRouteId, newSession)
+	var session *RouterSession
+	var err error
+	if isReservedRouteId(msg.FromRouteId) {
+		err = errors.New("RouterId 为 Router Center 保留值，禁止使用: " + msg.FromRouteId)
+	} else {
+		session, err = s.sessionManager.UpsertSession(msg.FromRouteId, newSession)
+	}
 	if err != nil {
 		// RouterId 冲突
 		errorMsg := err.Error()
@@ -191,11 +307,17 @@ func (s *Server) handleHeartBeat(msg *core.RouteMessage, conn net.Conn, writeMu
 			Data:        &errorMsg,
 		}
 		_ = newSession.WriteRouteMessage(resp)
-		_ = conn.Close()
+		newSession.CloseAfterFlush(ConnCloseRouteIdRejected, errorMsg)
+		s.sessionManager.RecordSessionEvent(SessionEvent{RouteId: msg.FromRouteId, Type: SessionEventRejected, RemoteAddr: remoteAddrOf(conn), Detail: errorMsg})
 		return
 	}
 
-	// 返回路由表
+	// 支持轻量心跳的节点只下发路由表增量，旧版本节点返回完整路由表
+	if rpcInfo.StubsHash != "" {
+		delta := s.sessionManager.RouteDeltaSince(routeVersion)
+		s.writeHeartbeatAck(session, msg.FromRouteId, core.HeartbeatAck{Routes: &delta})
+		return
+	}
 	routeList := s.sessionManager.GetAllRouteNodeList()
 	jsonBytes, _ := json.Marshal(routeList)
 	jsonStr := string(jsonBytes)
@@ -208,20 +330,83 @@ func (s *Server) handleHeartBeat(msg *core.RouteMessage, conn net.Conn, writeMu
 	_ = session.WriteRouteMessage(respMsg)
 }
 
+// handleLiteHeartBeat 轻量心跳只刷新会话；Stub 摘要或 RPC 地址与登记的不一致时要求节点发送完整心跳
+func (s *Server) handleLiteHeartBeat(msg *core.RouteMessage, conn net.Conn, queue *writeQueue, rpcInfo core.RpcServerInfo, routeVersion int64) {
+	session := s.sessionManager.GetSession(msg.FromRouteId)
+	if session == nil || session.Conn != conn {
+		s.writeHeartbeatAck(s.newConnSession(msg.FromRouteId, conn, rpcInfo, queue), msg.FromRouteId, core.HeartbeatAck{ResyncStubs: true})
+		return
+	}
+	s.sessionManager.refreshHeartbeat(msg.FromRouteId, session)
+	stored := session.GetRpcServerInfo()
+	ack := core.HeartbeatAck{}
+	if stored.StubsHash != rpcInfo.StubsHash || routeNodeOf(msg.FromRouteId, stored) != routeNodeOf(msg.FromRouteId, rpcInfo) {
+		componentLog(LogComponentSession).Info("节点 Stub 摘要或 RPC 地址变化，要求重新上报", "routeId", msg.FromRouteId, "stubsHash", rpcInfo.StubsHash)
+		ack.ResyncStubs = true
+	}
+	if delta := s.sessionManager.RouteDeltaSince(routeVersion); !delta.Empty() {
+		ack.Routes = &delta
+	}
+	if !ack.ResyncStubs && ack.Routes == nil {
+		return
+	}
+	s.writeHeartbeatAck(session, msg.FromRouteId, ack)
+}
+
+// newConnSession 为连接创建会话，queue 为空时（测试直接调用）同步写出
+func (s *Server) newConnSession(routeId string, conn net.Conn, info core.RpcServerInfo, queue *writeQueue) *RouterSession {
+	if queue == nil {
+		return NewRouterSession(routeId, conn, info, &sync.Mutex{})
+	}
+	return newQueuedRouterSession(routeId, conn, info, queue)
+}
+
+func (s *Server) writeHeartbeatAck(session *RouterSession, routeId string, ack core.HeartbeatAck) {
+	data, _ := json.Marshal(ack)
+	dataStr := string(data)
+	mt := core.RouteMessageTypeHeartBeat
+	_ = session.WriteRouteMessage(&core.RouteMessage{
+		FromRouteId: routeId,
+		ToRouteId:   routeId,
+		MessageType: &mt,
+		Data:        &dataStr,
+	})
+}
+
 func (s *Server) forwardToTarget(msg *core.RouteMessage) {
 	if msg.ToRouteId == "" {
 		return
 	}
+	span := s.startForwardSpan(msg)
+	defer span.End()
 	target := s.sessionManager.GetSession(msg.ToRouteId)
 	if target == nil {
-		slog.Warn("route message target offline", "from", msg.FromRouteId, "to", msg.ToRouteId, "type", msg.MessageType.String())
+		span.RecordError(errors.New("目标节点不在线: " + msg.ToRouteId))
+		componentLog(LogComponentRouter).Warn("route message target offline", "from", msg.FromRouteId, "to", msg.ToRouteId, "type", msg.MessageType.String())
+		s.recordError()
+		return
+	}
+	if target.IsSuspect() {
+		// 疑似下线的节点已移出路由表，连接很可能已失效，不再写入
+		span.RecordError(errors.New("目标节点疑似下线: " + msg.ToRouteId))
+		componentLog(LogComponentRouter).Warn("route message target suspect", "from", msg.FromRouteId, "to", msg.ToRouteId, "type", msg.MessageType.String())
+		s.recordError()
 		return
 	}
-	_ = target.WriteRouteMessage(msg)
+	if err := target.WriteRouteMessage(msg); err != nil {
+		span.RecordError(err)
+		s.recordError()
+	}
 }
 
 func (s *Server) handleRpcResponse(msg *core.RouteMessage) {
-	if msg.ToRouteId == "debug-admin" {
+	if msg.ToRouteId == GatewayRouteId {
+		if msg.Data != nil {
+			s.completeGatewayRpc(*msg.Data)
+		}
+		return
+	}
+	if msg.ToRouteId == debugRouteId {
 		if msg.Data == nil {
 			return
 		}
@@ -229,11 +414,9 @@ func (s *Server) handleRpcResponse(msg *core.RouteMessage) {
 		if rpcUid == "" {
 			return
 		}
-		s.debugResults.Store(rpcUid, *msg.Data)
-		go func(uid string) {
-			time.Sleep(5 * time.Minute)
-			s.debugResults.Delete(uid)
-		}(rpcUid)
+		if !s.debugHistory.Complete(rpcUid, *msg.Data) {
+			componentLog(LogComponentRouter).Debug("RPC 调试响应已不在历史记录中", "rpcUid", rpcUid)
+		}
 		return
 	}
 
@@ -241,10 +424,81 @@ func (s *Server) handleRpcResponse(msg *core.RouteMessage) {
 }
 
 func (s *Server) GetDebugResult(rpcUid string) (string, bool) {
-	if v, ok := s.debugResults.Load(rpcUid); ok {
-		return v.(string), true
+	call, ok := s.debugHistory.Get(rpcUid)
+	if !ok || call.Response == nil {
+		return "", false
+	}
+	return string(call.Response), true
+}
+
+func (s *Server) MessageTap() *MessageTap {
+	return s.tap
+}
+
+func (s *Server) DebugHistory() *DebugHistoryStore {
+	return s.debugHistory
+}
+
+// SendDebugRpc 以 debug-admin 身份向节点发送 RPC 并记录到调试历史，响应异步回填
+func (s *Server) SendDebugRpc(targetRouteId string, packetId int, params []json.RawMessage, operator, replayOf string) (*DebugCall, error) {
+	if targetRouteId == "" {
+		return nil, newGatewayError(GatewayErrBadRequest, http.StatusBadRequest, "路由节点不存在")
+	}
+	session := s.sessionManager.GetSession(targetRouteId)
+	if session == nil {
+		return nil, newGatewayError(GatewayErrRouteOffline, http.StatusNotFound, "路由节点不存在: "+targetRouteId)
+	}
+	if session.IsSuspect() {
+		return nil, newGatewayError(GatewayErrRouteOffline, http.StatusServiceUnavailable, "路由节点疑似下线: "+targetRouteId)
+	}
+	if packetId <= 0 {
+		return nil, newGatewayError(GatewayErrBadRequest, http.StatusBadRequest, "Packet ID 必须大于 0")
+	}
+	stub := findStub(session.GetRpcServerInfo().Stubs, packetId)
+	if stub == nil {
+		return nil, newGatewayError(GatewayErrNoStub, http.StatusBadRequest, "目标节点未注册 Packet ID = "+intToString(packetId))
 	}
-	return "", false
+	if params == nil {
+		params = []json.RawMessage{}
+	}
+
+	call := &DebugCall{
+		RpcUid:        newCenterRpcUid("debug"),
+		TargetRouteId: targetRouteId,
+		PacketId:      packetId,
+		Method:        stub.ClassName + "." + stub.MethodName,
+		Params:        params,
+		Operator:      operator,
+		Status:        DebugCallPending,
+		ReplayOf:      replayOf,
+		CreatedAt:     time.Now().UnixMilli(),
+	}
+	argsJson := make([]string, 0, len(params))
+	for _, p := range params {
+		argsJson = append(argsJson, string(p))
+	}
+	rpcReq := map[string]any{
+		"rpcUid":             call.RpcUid,
+		"packetId":           packetId,
+		"startTimeMs":        call.CreatedAt,
+		"methodArgsJsonList": argsJson,
+		"fromDebug":          true,
+	}
+	dataBytes, _ := json.Marshal(rpcReq)
+	dataStr := string(dataBytes)
+	mt := core.RouteMessageTypeRpcRequest
+	// 先记录再发送，避免响应先于记录到达
+	s.debugHistory.Add(call)
+	if err := session.WriteRouteMessage(&core.RouteMessage{
+		FromRouteId: debugRouteId,
+		ToRouteId:   targetRouteId,
+		MessageType: &mt,
+		Data:        &dataStr,
+	}); err != nil {
+		s.debugHistory.Fail(call.RpcUid, "发送失败: "+err.Error())
+		return nil, newGatewayError(GatewayErrSendFailed, http.StatusBadGateway, "RPC 调试请求发送失败: "+err.Error())
+	}
+	return call, nil
 }
 
 func (s *Server) SessionManager() *RouterSessionManager {
@@ -275,13 +529,19 @@ func (s *Server) RouterRPCStats(keyword string, limit int) []RouterRPCSnapshot {
 			continue
 		}
 
-		item.LastMinuteHits = pruneLastMinute(item.LastMinuteHits, now)
 		snapshot := RouterRPCSnapshot{
 			RouterID:      item.RouterID,
 			IncomingTotal: item.IncomingTotal,
 			OutgoingTotal: item.OutgoingTotal,
 			Total:         item.IncomingTotal + item.OutgoingTotal,
-			PerMinute:     len(item.LastMinuteHits),
+			PerMinute:     int(s.metrics.routerLastMinute(item.RouterID, now)),
+			RateLimited:   item.RateLimited,
+		}
+		if len(item.RateLimitedByKind) > 0 {
+			snapshot.RateLimitedByKind = make(map[string]uint64, len(item.RateLimitedByKind))
+			for k, v := range item.RateLimitedByKind {
+				snapshot.RateLimitedByKind[k] = v
+			}
 		}
 		result = append(result, snapshot)
 	}
@@ -302,23 +562,27 @@ func (s *Server) RouterRPCStats(keyword string, limit int) []RouterRPCSnapshot {
 	return result
 }
 
+// recordRouterRPC 记录一次 RPC；FromRouteId/ToRouteId 由节点填写，只有已注册的节点计入节点统计
 func (s *Server) recordRouterRPC(fromRouteID, toRouteID string) {
-	now := time.Now().UnixMilli()
+	fromRegistered := fromRouteID != "" && s.sessionManager.GetSession(fromRouteID) != nil
+	toRegistered := toRouteID != "" && s.sessionManager.GetSession(toRouteID) != nil
+	routers := make([]string, 0, 2)
+	if fromRegistered {
+		routers = append(routers, fromRouteID)
+	}
+	if toRegistered {
+		routers = append(routers, toRouteID)
+	}
+	s.metrics.recordRpc(time.Now().UnixMilli(), routers...)
 
 	s.rpcStatsMu.Lock()
 	defer s.rpcStatsMu.Unlock()
 
-	if fromRouteID != "" {
-		outgoing := s.ensureRouterRPCStats(fromRouteID)
-		outgoing.OutgoingTotal++
-		outgoing.LastMinuteHits = append(outgoing.LastMinuteHits, now)
-		outgoing.LastMinuteHits = pruneLastMinute(outgoing.LastMinuteHits, now)
+	if fromRegistered {
+		s.ensureRouterRPCStats(fromRouteID).OutgoingTotal++
 	}
-	if toRouteID != "" {
-		incoming := s.ensureRouterRPCStats(toRouteID)
-		incoming.IncomingTotal++
-		incoming.LastMinuteHits = append(incoming.LastMinuteHits, now)
-		incoming.LastMinuteHits = pruneLastMinute(incoming.LastMinuteHits, now)
+	if toRegistered {
+		s.ensureRouterRPCStats(toRouteID).IncomingTotal++
 	}
 }
 
@@ -326,43 +590,17 @@ func (s *Server) ensureRouterRPCStats(routerID string) *routerRPCStats {
 	if item, ok := s.rpcStatsByRouter[routerID]; ok {
 		return item
 	}
-	item := &routerRPCStats{RouterID: routerID, LastMinuteHits: make([]int64, 0, 32)}
+	item := &routerRPCStats{RouterID: routerID}
 	s.rpcStatsByRouter[routerID] = item
 	return item
 }
 
-func pruneLastMinute(list []int64, nowMs int64) []int64 {
-	if len(list) == 0 {
-		return list
-	}
-	cutoff := nowMs - 60*1000
-	idx := 0
-	for idx < len(list) && list[idx] < cutoff {
-		idx++
-	}
-	if idx == 0 {
-		return list
-	}
-	if idx >= len(list) {
-		return list[:0]
-	}
-	return list[idx:]
-}
-
-func (s *Server) recordRequestHit() {
-	now := time.Now().UnixMilli()
-	s.requestStatsMu.Lock()
-	defer s.requestStatsMu.Unlock()
-	s.requestHits = append(s.requestHits, now)
-	s.requestHits = pruneLastMinute(s.requestHits, now)
+func (s *Server) recordRequestHit(size int) {
+	s.metrics.recordRequest(time.Now().UnixMilli(), size)
 }
 
 func (s *Server) RequestsPerMinute() int {
-	now := time.Now().UnixMilli()
-	s.requestStatsMu.Lock()
-	defer s.requestStatsMu.Unlock()
-	s.requestHits = pruneLastMinute(s.requestHits, now)
-	return len(s.requestHits)
+	return int(s.metrics.lastMinute(MetricRequests, time.Now().UnixMilli()))
 }
 
 var rpcUidRegex = regexp.MustCompile(`"rpcUid"\s*:\s*"([^"]+)"`)
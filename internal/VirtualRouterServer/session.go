@@ -12,25 +12,71 @@ import (
 type RouterSession struct {
 	RouterId      string
 	Conn          net.Conn
-	RpcServerInfo core.RpcServerInfo
+	rpcInfoMu     sync.RWMutex
+	rpcServerInfo core.RpcServerInfo
 	lastHeartbeat atomic.Int64
 	closed        atomic.Bool
+	suspect       atomic.Bool
 	writeMu       *sync.Mutex
+	// queue 不为空时消息经连接的发送队列异步写出，否则在调用方 goroutine 同步写出
+	queue *writeQueue
 }
 
 func NewRouterSession(routeId string, conn net.Conn, info core.RpcServerInfo, writeMu *sync.Mutex) *RouterSession {
 	s := &RouterSession{
 		RouterId:      routeId,
 		Conn:          conn,
-		RpcServerInfo: info,
+		rpcServerInfo: info,
 		writeMu:       writeMu,
 	}
 	s.RefreshHeartbeat()
 	return s
 }
 
-func (s *RouterSession) RefreshHeartbeat() {
+// newQueuedRouterSession 创建经连接发送队列写出的会话
+func newQueuedRouterSession(routeId string, conn net.Conn, info core.RpcServerInfo, queue *writeQueue) *RouterSession {
+	s := NewRouterSession(routeId, conn, info, &sync.Mutex{})
+	s.queue = queue
+	return s
+}
+
+// GetRpcServerInfo 返回节点最近一次心跳上报的 RPC 信息
+func (s *RouterSession) GetRpcServerInfo() core.RpcServerInfo {
+	s.rpcInfoMu.RLock()
+	defer s.rpcInfoMu.RUnlock()
+	return s.rpcServerInfo
+}
+
+// UpdateRpcServerInfo 用心跳中的最新 RPC 信息覆盖（例如节点运行时注销了服务）
+func (s *RouterSession) UpdateRpcServerInfo(info core.RpcServerInfo) {
+	s.rpcInfoMu.Lock()
+	defer s.rpcInfoMu.Unlock()
+	s.rpcServerInfo = info
+}
+
+// RefreshHeartbeat 刷新心跳时间，返回是否从疑似下线状态恢复
+func (s *RouterSession) RefreshHeartbeat() bool {
 	s.lastHeartbeat.Store(time.Now().UnixMilli())
+	if s.suspect.Swap(false) {
+		componentLog(LogComponentSession).Info("节点心跳恢复", "routeId", s.RouterId)
+		return true
+	}
+	return false
+}
+
+// HeartbeatInterval 节点上报的心跳间隔，0 表示未上报
+func (s *RouterSession) HeartbeatInterval() time.Duration {
+	return time.Duration(s.GetRpcServerInfo().HeartBeatIntervalSecond) * time.Second
+}
+
+// IsSuspect 是否已错过心跳、处于疑似下线状态；疑似下线的节点不在路由表中，也不再转发消息给它
+func (s *RouterSession) IsSuspect() bool {
+	return s.suspect.Load()
+}
+
+// markSuspect 标记疑似下线，返回是否为新进入该状态
+func (s *RouterSession) markSuspect() bool {
+	return !s.suspect.Swap(true)
 }
 
 func (s *RouterSession) LastHeartbeatMs() int64 {
@@ -50,21 +96,45 @@ func (s *RouterSession) WriteRouteMessage(msg *core.RouteMessage) error {
 	if err != nil {
 		return err
 	}
-	frame := core.EncodeFrame(payload)
-	s.writeMu.Lock()
-	defer s.writeMu.Unlock()
-	_, err = s.Conn.Write(frame)
-	return err
+	return s.WritePayload(payload)
 }
 
 func (s *RouterSession) WritePayload(payload []byte) error {
 	frame := core.EncodeFrame(payload)
+	if s.queue != nil {
+		return s.queue.enqueue(frame)
+	}
 	s.writeMu.Lock()
 	defer s.writeMu.Unlock()
 	_, err := s.Conn.Write(frame)
 	return err
 }
 
+// CloseAfterFlush 已入队的消息写出后再关闭连接，用于发送错误响应后断开
+func (s *RouterSession) CloseAfterFlush(reason, detail string) {
+	if s.queue != nil {
+		s.queue.setCloseReason(reason, detail)
+		s.queue.closeAfterFlush()
+		return
+	}
+	_ = s.Conn.Close()
+}
+
+// setCloseReason 记录 Router Center 主动断开连接的原因，用于连接历史
+func (s *RouterSession) setCloseReason(reason, detail string) {
+	if s.queue != nil {
+		s.queue.setCloseReason(reason, detail)
+	}
+}
+
+// WriteQueueStats 发送队列状态，同步写出的会话返回零值
+func (s *RouterSession) WriteQueueStats() WriteQueueStats {
+	if s.queue == nil {
+		return WriteQueueStats{}
+	}
+	return s.queue.Stats()
+}
+
 func (s *RouterSession) RemoteAddrStr() string {
 	if s.Conn == nil {
 		return ""
@@ -0,0 +1,153 @@
+package VirtualRouterServer
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 节点时间线事件类型
+const (
+	SessionEventConnect         = "connect"
+	SessionEventRegister        = "register"
+	SessionEventStubsChanged    = "stubs_changed"
+	SessionEventEndpointChanged = "endpoint_changed"
+	SessionEventRejected        = "rejected"
+	SessionEventRemoved         = "removed"
+)
+
+// ConnCloseHeartbeatTimeout 心跳超时移除会话时记录的原因
+const ConnCloseHeartbeatTimeout = "心跳超时"
+
+const (
+	// maxSessionEventsPerRoute 每个 routeId 保留的事件条数
+	maxSessionEventsPerRoute = 100
+	// maxSessionEventRoutes 保留时间线的 routeId 数，超出时淘汰最久没有事件的节点；
+	// 只有注册过的节点（以及与其冲突的注册请求）才有时间线，节点无法通过更换 FromRouteId 刷掉其他节点的记录
+	maxSessionEventRoutes = 1000
+)
+
+// SessionEvent 节点时间线上的一条事件
+type SessionEvent struct {
+	RouteId    string `json:"routeId"`
+	Type       string `json:"type"`
+	At         int64  `json:"at"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// SessionEventSummary 节点时间线概要，用于选择要查看的节点
+type SessionEventSummary struct {
+	RouteId  string       `json:"routeId"`
+	Count    int          `json:"count"`
+	Last     SessionEvent `json:"last"`
+	IsOnline bool         `json:"online"`
+}
+
+// sessionEventLog 按 routeId 保存的有界事件历史，order 按最近一次记录排序，淘汰时取队首
+type sessionEventLog struct {
+	mu      sync.Mutex
+	byRoute map[string]*list.Element
+	order   *list.List
+}
+
+// routeEvents order 中的元素
+type routeEvents struct {
+	routeId string
+	events  []SessionEvent
+}
+
+func (l *sessionEventLog) record(ev SessionEvent) {
+	if ev.RouteId == "" {
+		return
+	}
+	if ev.At == 0 {
+		ev.At = time.Now().UnixMilli()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.byRoute == nil {
+		l.byRoute = make(map[string]*list.Element)
+		l.order = list.New()
+	}
+	elem, ok := l.byRoute[ev.RouteId]
+	if ok {
+		l.order.MoveToBack(elem)
+	} else {
+		if l.order.Len() >= maxSessionEventRoutes {
+			oldest := l.order.Front()
+			delete(l.byRoute, oldest.Value.(*routeEvents).routeId)
+			l.order.Remove(oldest)
+		}
+		elem = l.order.PushBack(&routeEvents{routeId: ev.RouteId})
+		l.byRoute[ev.RouteId] = elem
+	}
+	item := elem.Value.(*routeEvents)
+	item.events = append(item.events, ev)
+	if len(item.events) > maxSessionEventsPerRoute {
+		item.events = append([]SessionEvent(nil), item.events[len(item.events)-maxSessionEventsPerRoute:]...)
+	}
+}
+
+// events 节点的事件，新的在前
+func (l *sessionEventLog) events(routeId string, limit int) []SessionEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []SessionEvent
+	if elem, ok := l.byRoute[routeId]; ok {
+		events = elem.Value.(*routeEvents).events
+	}
+	if limit <= 0 || limit > len(events) {
+		limit = len(events)
+	}
+	out := make([]SessionEvent, 0, limit)
+	for i := len(events) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, events[i])
+	}
+	return out
+}
+
+func (l *sessionEventLog) summaries() []SessionEventSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]SessionEventSummary, 0, len(l.byRoute))
+	for _, elem := range l.byRoute {
+		item := elem.Value.(*routeEvents)
+		out = append(out, SessionEventSummary{RouteId: item.routeId, Count: len(item.events), Last: item.events[len(item.events)-1]})
+	}
+	return out
+}
+
+// joinReason 时间线中的关闭原因与细节
+func joinReason(reason, detail string) string {
+	if detail == "" {
+		return reason
+	}
+	return reason + ": " + detail
+}
+
+// RecordSessionEvent 向节点时间线追加一条事件
+func (m *RouterSessionManager) RecordSessionEvent(ev SessionEvent) {
+	m.events.record(ev)
+}
+
+// SessionEvents 节点时间线，新的在前
+func (m *RouterSessionManager) SessionEvents(routeId string, limit int) []SessionEvent {
+	return m.events.events(routeId, limit)
+}
+
+// SessionEventSummaries 有时间线的节点，最近有事件的在前
+func (m *RouterSessionManager) SessionEventSummaries() []SessionEventSummary {
+	list := m.events.summaries()
+	for i := range list {
+		list[i].IsOnline = m.GetSession(list[i].RouteId) != nil
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Last.At == list[j].Last.At {
+			return list[i].RouteId < list[j].RouteId
+		}
+		return list[i].Last.At > list[j].Last.At
+	})
+	return list
+}
@@ -1,23 +1,88 @@
 package VirtualRouterServer
 
 import (
+	"context"
 	"errors"
-	"log/slog"
+	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/neko233-com/virtual-router-go/internal/config"
 	"github.com/neko233-com/virtual-router-go/internal/core"
 )
 
-const sessionTimeout = 30 * time.Second
+// 心跳超时策略默认值
+const (
+	defaultSessionTimeout         = 30 * time.Second
+	defaultHeartbeatSweepInterval = time.Second
+	defaultHeartbeatMissLimit     = 3
+)
+
+// HeartbeatPolicy 会话心跳超时策略：节点按上报的心跳间隔计算超时，
+// 连续错过 MissLimit-1 次心跳标记为疑似下线（移出路由表），错过 MissLimit 次移除会话
+type HeartbeatPolicy struct {
+	// SessionTimeout 节点未上报心跳间隔时使用的超时，也是节点上报间隔计算出的超时上限
+	SessionTimeout time.Duration
+	// SweepInterval 检查心跳的间隔
+	SweepInterval time.Duration
+	MissLimit     int
+}
+
+// heartbeatPolicyFromConfig 读取配置中的心跳策略，未配置的项使用默认值
+func heartbeatPolicyFromConfig(cfg *config.RouterServerConfig) HeartbeatPolicy {
+	p := HeartbeatPolicy{
+		SessionTimeout: defaultSessionTimeout,
+		SweepInterval:  defaultHeartbeatSweepInterval,
+		MissLimit:      defaultHeartbeatMissLimit,
+	}
+	if cfg == nil {
+		return p
+	}
+	if cfg.SessionTimeoutMs > 0 {
+		p.SessionTimeout = time.Duration(cfg.SessionTimeoutMs) * time.Millisecond
+	}
+	if cfg.HeartbeatSweepIntervalMs > 0 {
+		p.SweepInterval = time.Duration(cfg.HeartbeatSweepIntervalMs) * time.Millisecond
+	}
+	if cfg.HeartbeatMissLimit > 0 {
+		p.MissLimit = cfg.HeartbeatMissLimit
+	}
+	return p
+}
+
+// deadlines 按节点上报的心跳间隔计算疑似下线与移除的时限；上报的间隔由节点填写，
+// 计算出的超时不超过 SessionTimeout，避免失联节点长期占用路由
+func (p HeartbeatPolicy) deadlines(reported time.Duration) (suspectAfter, timeout time.Duration) {
+	limit := max(p.MissLimit, 1)
+	interval := reported
+	if ceiling := p.SessionTimeout / time.Duration(limit); interval <= 0 || interval > ceiling {
+		interval = ceiling
+	}
+	return interval * time.Duration(max(limit-1, 1)), interval * time.Duration(limit)
+}
 
 // RouterSessionManager 管理所有路由会话
 
 type RouterSessionManager struct {
 	mu       sync.RWMutex
 	sessions map[string]*RouterSession
+	stubs    stubIndex
+	routes   routeChangeLog
+	events   sessionEventLog
+	// pushQueue 待推送的路由表增量，按版本顺序由单个 goroutine 发送，不阻塞会话变更
+	pushMu    sync.Mutex
+	pushQueue []routePush
+	pushing   bool
+
+	policy   atomic.Pointer[HeartbeatPolicy]
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	// onRemoved 会话移除后在锁外回调，用于清理按节点保存的统计
+	onRemoved func(routeIds []string)
 }
 
 type RouterSessionSnapshot struct {
@@ -29,40 +94,120 @@ type RouterSessionSnapshot struct {
 	RemoteIP        string
 	RemotePort      int
 	StubCount       int
+	// HeartbeatIntervalMs 节点上报的心跳间隔，0 表示未上报
+	HeartbeatIntervalMs int64
+	// Suspect 已错过心跳、即将被移除
+	Suspect bool
+	// WriteQueue 发送队列积压与丢弃情况
+	WriteQueue WriteQueueStats
 }
 
+// NewRouterSessionManager 创建会话管理器，心跳检查由 RunHeartbeatSweep 驱动
 func NewRouterSessionManager() *RouterSessionManager {
 	sm := &RouterSessionManager{
 		sessions: make(map[string]*RouterSession),
+		stubs:    stubIndex{},
+		stopCh:   make(chan struct{}),
 	}
-	go sm.heartbeatLoop()
+	sm.SetHeartbeatPolicy(heartbeatPolicyFromConfig(nil))
 	return sm
 }
 
-func (m *RouterSessionManager) heartbeatLoop() {
+// SetHeartbeatPolicy 更新心跳超时策略，下一轮检查生效
+func (m *RouterSessionManager) SetHeartbeatPolicy(p HeartbeatPolicy) {
+	m.policy.Store(&p)
+}
+
+func (m *RouterSessionManager) HeartbeatPolicy() HeartbeatPolicy {
+	return *m.policy.Load()
+}
+
+// RunHeartbeatSweep 定期检查会话心跳，直到 ctx 结束或调用 Stop
+func (m *RouterSessionManager) RunHeartbeatSweep(ctx context.Context) {
+	timer := time.NewTimer(m.HeartbeatPolicy().SweepInterval)
+	defer timer.Stop()
 	for {
-		time.Sleep(time.Minute)
-		m.checkHeartbeat()
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopCh:
+			return
+		case <-timer.C:
+			m.checkHeartbeat(time.Now())
+			timer.Reset(m.HeartbeatPolicy().SweepInterval)
+		}
 	}
 }
 
-func (m *RouterSessionManager) checkHeartbeat() {
-	cutoff := time.Now().Add(-sessionTimeout).UnixMilli()
-	var offline []string
+// Stop 停止心跳检查循环，可重复调用
+func (m *RouterSessionManager) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+func (m *RouterSessionManager) checkHeartbeat(now time.Time) {
+	policy := m.HeartbeatPolicy()
+	var offline, suspects []string
 
 	m.mu.RLock()
 	for routeId, session := range m.sessions {
-		if session.LastHeartbeatMs() < cutoff {
+		suspectAfter, timeout := policy.deadlines(session.HeartbeatInterval())
+		elapsed := now.Sub(time.UnixMilli(session.LastHeartbeatMs()))
+		switch {
+		case elapsed >= timeout:
 			offline = append(offline, routeId)
+			componentLog(LogComponentSession).Warn("节点心跳超时，移除会话", "routeId", routeId, "elapsed", elapsed.Round(time.Millisecond), "timeout", timeout)
+		case elapsed >= suspectAfter:
+			if session.markSuspect() {
+				suspects = append(suspects, routeId)
+				componentLog(LogComponentSession).Warn("节点错过心跳，标记为疑似下线", "routeId", routeId, "elapsed", elapsed.Round(time.Millisecond), "timeout", timeout)
+			}
 		}
 	}
 	m.mu.RUnlock()
 
+	if len(suspects) > 0 {
+		m.withdrawSuspects(suspects)
+	}
 	if len(offline) > 0 {
-		m.RemoveSessions(offline)
+		m.removeSessions(offline, ConnCloseHeartbeatTimeout, "")
 	}
 }
 
+// withdrawSuspects 把疑似下线的节点移出路由表并通知其他节点，会话保留到心跳恢复或超时移除
+func (m *RouterSessionManager) withdrawSuspects(routeIds []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var withdrawn []string
+	var fromVersion, version int64
+	for _, routeId := range routeIds {
+		session, ok := m.sessions[routeId]
+		if !ok || !session.IsSuspect() {
+			continue
+		}
+		version = m.routes.record(core.RouteNode{RouterId: routeId}, true)
+		if fromVersion == 0 {
+			fromVersion = version - 1
+		}
+		withdrawn = append(withdrawn, routeId)
+	}
+	if len(withdrawn) > 0 {
+		m.enqueuePush(core.RouteTableDelta{FromVersion: fromVersion, Version: version, Removes: withdrawn}, "")
+	}
+}
+
+// refreshHeartbeat 刷新会话心跳，疑似下线的节点恢复时重新加入路由表
+func (m *RouterSessionManager) refreshHeartbeat(routeId string, session *RouterSession) {
+	if !session.RefreshHeartbeat() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions[routeId] == session {
+		m.recordUpsertLocked(routeNodeOf(routeId, session.GetRpcServerInfo()))
+	}
+}
+
+// UpsertSession 登记会话；节点加入或 RPC 地址变化时递增路由表版本并推送给其他节点
 func (m *RouterSessionManager) UpsertSession(routeId string, session *RouterSession) (*RouterSession, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -70,22 +215,91 @@ func (m *RouterSessionManager) UpsertSession(routeId string, session *RouterSess
 	if old, ok := m.sessions[routeId]; ok {
 		if old.IsActive() {
 			if old.RemoteAddrStr() == session.RemoteAddrStr() {
-				old.RefreshHeartbeat()
+				recovered := old.RefreshHeartbeat()
+				oldInfo := old.GetRpcServerInfo()
+				newInfo := session.GetRpcServerInfo()
+				old.UpdateRpcServerInfo(newInfo)
+				if m.stubs.replace(routeId, oldInfo.Stubs, newInfo.Stubs) {
+					m.warnStubConflictsLocked(routeId, newInfo.Stubs)
+					m.events.record(SessionEvent{RouteId: routeId, Type: SessionEventStubsChanged, RemoteAddr: old.RemoteAddrStr(),
+						Detail: fmt.Sprintf("stubs %d -> %d", len(oldInfo.Stubs), len(newInfo.Stubs))})
+				}
+				node := routeNodeOf(routeId, newInfo)
+				if node != routeNodeOf(routeId, oldInfo) {
+					m.recordUpsertLocked(node)
+					m.events.record(SessionEvent{RouteId: routeId, Type: SessionEventEndpointChanged, RemoteAddr: old.RemoteAddrStr(),
+						Detail: rpcEndpointOf(oldInfo) + " -> " + rpcEndpointOf(newInfo)})
+				} else if recovered {
+					// 疑似下线的节点恢复心跳，重新加入路由表
+					m.recordUpsertLocked(node)
+				}
 				return old, nil
 			}
 			return nil, errors.New("RouterId '" + routeId + "' 已经存在! 请修改您的 routerId 配置.")
 		}
+		m.stubs.remove(routeId, old.GetRpcServerInfo().Stubs)
 	}
 	m.sessions[routeId] = session
+	newInfo := session.GetRpcServerInfo()
+	m.stubs.replace(routeId, nil, newInfo.Stubs)
+	m.warnStubConflictsLocked(routeId, newInfo.Stubs)
+	m.recordUpsertLocked(routeNodeOf(routeId, newInfo))
+	// 连接建立事件在注册成功时按会话记录一次，未注册连接的 FromRouteId 由节点随意填写，不进入时间线
+	m.events.record(SessionEvent{RouteId: routeId, Type: SessionEventConnect, RemoteAddr: session.RemoteAddrStr()})
+	m.events.record(SessionEvent{RouteId: routeId, Type: SessionEventRegister, RemoteAddr: session.RemoteAddrStr(),
+		Detail: fmt.Sprintf("rpc %s, stubs %d", rpcEndpointOf(newInfo), len(newInfo.Stubs))})
 	return session, nil
 }
 
+// rpcEndpointOf 时间线中展示的 RPC 地址
+func rpcEndpointOf(info core.RpcServerInfo) string {
+	if info.Host == "" && info.Port == 0 {
+		return "-"
+	}
+	return net.JoinHostPort(info.Host, strconv.Itoa(info.Port))
+}
+
+func (m *RouterSessionManager) recordUpsertLocked(node core.RouteNode) {
+	version := m.routes.record(node, false)
+	m.enqueuePush(core.RouteTableDelta{FromVersion: version - 1, Version: version, Upserts: []core.RouteNode{node}}, node.RouterId)
+}
+
+func (m *RouterSessionManager) warnStubConflictsLocked(routeId string, stubs []core.RpcStubMetadata) {
+	for _, c := range m.stubs.conflictsOf(stubs) {
+		signatures := make([]string, 0, len(c.Signatures))
+		for _, sig := range c.Signatures {
+			signatures = append(signatures, sig.Signature+"@"+strings.Join(sig.RouterIds, "|"))
+		}
+		componentLog(LogComponentSession).Warn("RPC packetId 签名冲突，请检查各节点协议版本是否一致", "routeId", routeId, "packetId", c.PacketId, "signatures", signatures)
+	}
+}
+
+// StubConflicts 返回全集群的 packetId 签名冲突报告
+func (m *RouterSessionManager) StubConflicts() []StubConflict {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stubs.allConflicts()
+}
+
 func (m *RouterSessionManager) RemoveSession(routeId string) {
 	m.RemoveSessions([]string{routeId})
 }
 
+// RemoveSessionOfConn 只在会话仍属于 conn 时移除，避免被拒绝或已过期的旧连接关闭时误删其他连接的会话；
+// reason/detail 记录到节点时间线
+func (m *RouterSessionManager) RemoveSessionOfConn(routeId string, conn net.Conn, reason, detail string) {
+	if session := m.GetSession(routeId); session != nil && session.Conn == conn {
+		m.removeSessions([]string{routeId}, reason, detail)
+	}
+}
+
 func (m *RouterSessionManager) RemoveSessions(routeIds []string) {
+	m.removeSessions(routeIds, ConnCloseSessionRemoved, "")
+}
+
+func (m *RouterSessionManager) removeSessions(routeIds []string, reason, detail string) {
 	var removed []string
+	var fromVersion, version int64
 	m.mu.Lock()
 	for _, routeId := range routeIds {
 		session, ok := m.sessions[routeId]
@@ -93,50 +307,127 @@ func (m *RouterSessionManager) RemoveSessions(routeIds []string) {
 			continue
 		}
 		delete(m.sessions, routeId)
+		m.stubs.remove(routeId, session.GetRpcServerInfo().Stubs)
+		version = m.routes.record(core.RouteNode{RouterId: routeId}, true)
+		if fromVersion == 0 {
+			fromVersion = version - 1
+		}
 		removed = append(removed, routeId)
 		session.MarkClosed()
+		session.setCloseReason(reason, detail)
 		_ = session.Conn.Close()
-		slog.Info("client 的 routeSession 被移除了", "routeId", routeId, "remote", session.RemoteAddrStr())
+		m.events.record(SessionEvent{RouteId: routeId, Type: SessionEventRemoved, RemoteAddr: session.RemoteAddrStr(), Detail: joinReason(reason, detail)})
+		componentLog(LogComponentSession).Info("client 的 routeSession 被移除了", "routeId", routeId, "remote", session.RemoteAddrStr())
+	}
+	if len(removed) > 0 {
+		m.enqueuePush(core.RouteTableDelta{FromVersion: fromVersion, Version: version, Removes: removed}, "")
 	}
 	m.mu.Unlock()
+	if len(removed) > 0 && m.onRemoved != nil {
+		m.onRemoved(removed)
+	}
+}
 
-	if len(removed) == 0 {
-		return
+type routePush struct {
+	delta   core.RouteTableDelta
+	exclude string
+}
+
+// enqueuePush 在 mu 内调用，保证入队顺序与版本顺序一致
+func (m *RouterSessionManager) enqueuePush(delta core.RouteTableDelta, exclude string) {
+	m.pushMu.Lock()
+	defer m.pushMu.Unlock()
+	m.pushQueue = append(m.pushQueue, routePush{delta: delta, exclude: exclude})
+	if !m.pushing {
+		m.pushing = true
+		go m.drainPushes()
 	}
+}
 
-	// 通知剩余活着的节点
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	dataBytes, _ := jsonMarshal(removed)
-	dataStr := string(dataBytes)
-	msgType := core.RouteMessageTypeRemoveRouteNode
-	for _, alive := range m.sessions {
+// drainPushes 依次发送排队的增量，队列清空后退出
+func (m *RouterSessionManager) drainPushes() {
+	for {
+		m.pushMu.Lock()
+		if len(m.pushQueue) == 0 {
+			m.pushing = false
+			m.pushMu.Unlock()
+			return
+		}
+		p := m.pushQueue[0]
+		m.pushQueue = m.pushQueue[1:]
+		m.pushMu.Unlock()
+		m.pushRouteDelta(p.delta, p.exclude)
+	}
+}
+
+// pushRouteDelta 把路由表变更推送给 exclude 以外的在线节点：支持增量的节点收到带版本的 HeartbeatAck；
+// 旧版本节点在加入/变更时收到只含变更节点的路由列表，在移除时收到 RemoveRouteNode
+func (m *RouterSessionManager) pushRouteDelta(delta core.RouteTableDelta, exclude string) {
+	ackBytes, _ := jsonMarshal(core.HeartbeatAck{Routes: &delta})
+	ackData := string(ackBytes)
+	var legacyData string
+	legacyType := core.RouteMessageTypeHeartBeat
+	if len(delta.Removes) > 0 {
+		legacyBytes, _ := jsonMarshal(delta.Removes)
+		legacyData = string(legacyBytes)
+		legacyType = core.RouteMessageTypeRemoveRouteNode
+	} else {
+		legacyBytes, _ := jsonMarshal(delta.Upserts)
+		legacyData = string(legacyBytes)
+	}
+	ackType := core.RouteMessageTypeHeartBeat
+
+	// 在锁外写入，避免慢节点阻塞会话变更
+	for _, alive := range m.ListSessions() {
+		if alive.RouterId == exclude {
+			continue
+		}
 		msg := &core.RouteMessage{
 			FromRouteId: alive.RouterId,
 			ToRouteId:   alive.RouterId,
-			MessageType: &msgType,
-			Data:        &dataStr,
+			MessageType: &legacyType,
+			Data:        &legacyData,
+		}
+		if alive.GetRpcServerInfo().StubsHash != "" {
+			msg.MessageType = &ackType
+			msg.Data = &ackData
 		}
 		_ = alive.WriteRouteMessage(msg)
 	}
 }
 
+// GetSession 按 routeId 获取会话，包括疑似下线的会话；转发消息前需检查 IsSuspect
 func (m *RouterSessionManager) GetSession(routeId string) *RouterSession {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.sessions[routeId]
 }
 
+// ListSessions 返回当前所有会话的快照切片
+func (m *RouterSessionManager) ListSessions() []*RouterSession {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]*RouterSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		list = append(list, s)
+	}
+	return list
+}
+
 func (m *RouterSessionManager) GetAllRouteNodeList() []core.RouteNode {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return m.routeNodesLocked()
+}
+
+// routeNodesLocked 路由表中的节点，不含疑似下线的节点
+func (m *RouterSessionManager) routeNodesLocked() []core.RouteNode {
 	list := make([]core.RouteNode, 0, len(m.sessions))
-	for _, s := range m.sessions {
-		list = append(list, core.RouteNode{
-			RouterId:   s.RouterId,
-			HostForRpc: s.RpcServerInfo.Host,
-			PortForRpc: s.RpcServerInfo.Port,
-		})
+	for routeId, s := range m.sessions {
+		if s.IsSuspect() {
+			continue
+		}
+		list = append(list, routeNodeOf(routeId, s.GetRpcServerInfo()))
 	}
 	return list
 }
@@ -155,15 +446,20 @@ func (m *RouterSessionManager) GetAllSessionSnapshots() []RouterSessionSnapshot
 				remotePort = parsed
 			}
 		}
+		info := s.GetRpcServerInfo()
 		list = append(list, RouterSessionSnapshot{
 			RouterId:        s.RouterId,
-			HostForRpc:      s.RpcServerInfo.Host,
-			PortForRpc:      s.RpcServerInfo.Port,
+			HostForRpc:      info.Host,
+			PortForRpc:      info.Port,
 			LastHeartbeatMs: s.LastHeartbeatMs(),
 			RemoteAddr:      remoteAddr,
 			RemoteIP:        remoteIP,
 			RemotePort:      remotePort,
-			StubCount:       len(s.RpcServerInfo.Stubs),
+			StubCount:       len(info.Stubs),
+
+			HeartbeatIntervalMs: s.HeartbeatInterval().Milliseconds(),
+			Suspect:             s.IsSuspect(),
+			WriteQueue:          s.WriteQueueStats(),
 		})
 	}
 	return list
@@ -174,7 +470,7 @@ func (m *RouterSessionManager) RefreshSession(routeId string) {
 	s := m.sessions[routeId]
 	m.mu.RUnlock()
 	if s != nil {
-		s.RefreshHeartbeat()
+		m.refreshHeartbeat(routeId, s)
 	}
 }
 
@@ -1,14 +1,21 @@
 const state = {
   token: "",
+  user: null,
   activeTab: "home",
   stubs: [],
+  debugHistory: [],
+  debugTemplates: [],
+  logSearchOffset: 0,
+  tap: {
+    source: null,
+    events: [],
+    received: 0,
+  },
   history: {
     labels: [],
-    totalRequests: [],
-    totalConnections: [],
-    onlineRouters: [],
     memoryUsagePercent: [],
   },
+  metricsHistory: null,
   charts: {
     request: null,
     memory: null,
@@ -30,6 +37,12 @@ const tabs = document.getElementById("tabs");
 
 const stats = document.getElementById("stats");
 const routersBody = document.getElementById("routersBody");
+const connHistoryBody = document.getElementById("connHistoryBody");
+const historyRangeInput = document.getElementById("historyRange");
+const timelineRouteIdInput = document.getElementById("timelineRouteId");
+const timelineRouteOptions = document.getElementById("timelineRouteOptions");
+const loadTimelineBtn = document.getElementById("loadTimelineBtn");
+const timelineBody = document.getElementById("timelineBody");
 const rpcRankBody = document.getElementById("rpcRankBody");
 const settingsInfo = document.getElementById("settingsInfo");
 const routerKeywordInput = document.getElementById("routerKeyword");
@@ -39,10 +52,60 @@ const searchRpcTrafficBtn = document.getElementById("searchRpcTrafficBtn");
 const logLimitInput = document.getElementById("logLimit");
 const logKeywordInput = document.getElementById("logKeyword");
 const logLevelInput = document.getElementById("logLevel");
+const logComponentInput = document.getElementById("logComponent");
+const logRouteIdInput = document.getElementById("logRouteId");
+const logLevelsBody = document.getElementById("logLevelsBody");
+const logFormatInfo = document.getElementById("logFormatInfo");
 const logAutoRefreshInput = document.getElementById("logAutoRefresh");
 const loadLogsBtn = document.getElementById("loadLogsBtn");
 const exportLogsBtn = document.getElementById("exportLogsBtn");
 const logOutput = document.getElementById("logOutput");
+const logSearchFromInput = document.getElementById("logSearchFrom");
+const logSearchToInput = document.getElementById("logSearchTo");
+const logSearchLimitInput = document.getElementById("logSearchLimit");
+const logSearchBtn = document.getElementById("logSearchBtn");
+const logSearchPrevBtn = document.getElementById("logSearchPrevBtn");
+const logSearchNextBtn = document.getElementById("logSearchNextBtn");
+const logSearchExportBtn = document.getElementById("logSearchExportBtn");
+const logSearchInfo = document.getElementById("logSearchInfo");
+const logSearchOutput = document.getElementById("logSearchOutput");
+const auditKeywordInput = document.getElementById("auditKeyword");
+const auditActionInput = document.getElementById("auditAction");
+const loadAuditBtn = document.getElementById("loadAuditBtn");
+const auditMsg = document.getElementById("auditMsg");
+const auditBody = document.getElementById("auditBody");
+const currentUserInfo = document.getElementById("currentUser");
+const auditTab = document.getElementById("auditTab");
+const usersCard = document.getElementById("usersCard");
+const newUsernameInput = document.getElementById("newUsername");
+const newUserPasswordInput = document.getElementById("newUserPassword");
+const newUserRoleInput = document.getElementById("newUserRole");
+const createUserBtn = document.getElementById("createUserBtn");
+const usersMsg = document.getElementById("usersMsg");
+const usersBody = document.getElementById("usersBody");
+const sessionsCard = document.getElementById("sessionsCard");
+const loadSessionsBtn = document.getElementById("loadSessionsBtn");
+const sessionsMsg = document.getElementById("sessionsMsg");
+const sessionsBody = document.getElementById("sessionsBody");
+const totpStatus = document.getElementById("totpStatus");
+const totpSetupBtn = document.getElementById("totpSetupBtn");
+const totpSetupBox = document.getElementById("totpSetupBox");
+const totpSecretInfo = document.getElementById("totpSecretInfo");
+const totpCodeInput = document.getElementById("totpCodeInput");
+const totpEnableBtn = document.getElementById("totpEnableBtn");
+const totpDisableBtn = document.getElementById("totpDisableBtn");
+const totpMsg = document.getElementById("totpMsg");
+const configCard = document.getElementById("configCard");
+const configText = document.getElementById("configText");
+const loadConfigBtn = document.getElementById("loadConfigBtn");
+const saveConfigBtn = document.getElementById("saveConfigBtn");
+const reloadConfigFileBtn = document.getElementById("reloadConfigFileBtn");
+const configMsg = document.getElementById("configMsg");
+const configPending = document.getElementById("configPending");
+const jwtKeysCard = document.getElementById("jwtKeysCard");
+const rotateJwtKeyBtn = document.getElementById("rotateJwtKeyBtn");
+const jwtKeysMsg = document.getElementById("jwtKeysMsg");
+const jwtKeysBody = document.getElementById("jwtKeysBody");
 const oldPasswordInput = document.getElementById("oldPassword");
 const newPasswordInput = document.getElementById("newPassword");
 const confirmPasswordInput = document.getElementById("confirmPassword");
@@ -61,13 +124,51 @@ const openRpcDebugBtn = document.getElementById("openRpcDebugBtn");
 const closeRpcDebugBtn = document.getElementById("closeRpcDebugBtn");
 const rpcDebugBackdrop = document.getElementById("rpcDebugBackdrop");
 const rpcDebugModal = document.getElementById("rpcDebugModal");
+const templateSelect = document.getElementById("templateSelect");
+const applyTemplateBtn = document.getElementById("applyTemplateBtn");
+const deleteTemplateBtn = document.getElementById("deleteTemplateBtn");
+const templateNameInput = document.getElementById("templateName");
+const saveTemplateBtn = document.getElementById("saveTemplateBtn");
+const historyKeywordInput = document.getElementById("historyKeyword");
+const searchHistoryBtn = document.getElementById("searchHistoryBtn");
+const historyMsg = document.getElementById("historyMsg");
+const historyBody = document.getElementById("historyBody");
+const tapRouteIdInput = document.getElementById("tapRouteId");
+const tapTypeInput = document.getElementById("tapType");
+const tapPacketIdInput = document.getElementById("tapPacketId");
+const tapSampleInput = document.getElementById("tapSample");
+const tapToggleBtn = document.getElementById("tapToggleBtn");
+const tapClearBtn = document.getElementById("tapClearBtn");
+const tapMsg = document.getElementById("tapMsg");
+const tapBody = document.getElementById("tapBody");
 
 refreshBtn.addEventListener("click", () => loadAll());
 logoutBtn.addEventListener("click", logout);
 tabs.addEventListener("click", onTabClick);
 loadLogsBtn.addEventListener("click", () => loadLogs());
 exportLogsBtn.addEventListener("click", exportLogs);
+logSearchBtn.addEventListener("click", () => searchLogFiles(0));
+logSearchPrevBtn.addEventListener("click", () => searchLogFiles(state.logSearchOffset - logSearchPageSize()));
+logSearchNextBtn.addEventListener("click", () => searchLogFiles(state.logSearchOffset + logSearchPageSize()));
+logSearchExportBtn.addEventListener("click", exportLogSearch);
+loadAuditBtn.addEventListener("click", () => loadAudit());
+createUserBtn.addEventListener("click", createUser);
+rotateJwtKeyBtn.addEventListener("click", rotateJwtKey);
+loadConfigBtn.addEventListener("click", loadConfig);
+saveConfigBtn.addEventListener("click", saveConfig);
+reloadConfigFileBtn.addEventListener("click", reloadConfigFile);
+loadSessionsBtn.addEventListener("click", loadSessions);
+sessionsBody.addEventListener("click", onSessionAction);
+totpSetupBtn.addEventListener("click", setupTotp);
+totpEnableBtn.addEventListener("click", () => submitTotp("enable"));
+totpDisableBtn.addEventListener("click", () => submitTotp("disable"));
+usersBody.addEventListener("click", onUserAction);
+usersBody.addEventListener("change", onUserRoleChange);
 searchRoutersBtn.addEventListener("click", () => loadRoutersAndRanking());
+loadTimelineBtn.addEventListener("click", () => loadTimeline());
+historyRangeInput.addEventListener("change", () => loadMetricsHistory());
+routersBody.addEventListener("click", onRouteIdClick);
+connHistoryBody.addEventListener("click", onRouteIdClick);
 searchRpcTrafficBtn.addEventListener("click", () => loadRoutersAndRanking());
 
 loadStubsBtn.addEventListener("click", loadStubs);
@@ -77,6 +178,13 @@ updatePasswordBtn.addEventListener("click", updateAdminPassword);
 openRpcDebugBtn.addEventListener("click", openRpcDebugModal);
 closeRpcDebugBtn.addEventListener("click", closeRpcDebugModal);
 rpcDebugBackdrop.addEventListener("click", closeRpcDebugModal);
+searchHistoryBtn.addEventListener("click", () => loadDebugHistory());
+historyBody.addEventListener("click", onHistoryAction);
+applyTemplateBtn.addEventListener("click", applyTemplate);
+deleteTemplateBtn.addEventListener("click", deleteTemplate);
+saveTemplateBtn.addEventListener("click", saveTemplate);
+tapToggleBtn.addEventListener("click", toggleTap);
+tapClearBtn.addEventListener("click", clearTap);
 
 window.addEventListener("resize", resizeCharts);
 
@@ -91,10 +199,21 @@ function enableAuthorizedUI() {
   stubSelect.disabled = false;
   loadLogsBtn.disabled = false;
   exportLogsBtn.disabled = false;
+  logSearchBtn.disabled = false;
+  logSearchExportBtn.disabled = false;
+  loadAuditBtn.disabled = false;
   updatePasswordBtn.disabled = false;
   searchRoutersBtn.disabled = false;
+  loadTimelineBtn.disabled = false;
   searchRpcTrafficBtn.disabled = false;
   openRpcDebugBtn.disabled = false;
+  templateSelect.disabled = false;
+  applyTemplateBtn.disabled = false;
+  deleteTemplateBtn.disabled = false;
+  saveTemplateBtn.disabled = false;
+  searchHistoryBtn.disabled = false;
+  tapToggleBtn.disabled = false;
+  tapClearBtn.disabled = false;
 }
 
 async function restoreAuthState() {
@@ -113,6 +232,7 @@ async function restoreAuthState() {
   }
 
   enableAuthorizedUI();
+  await loadCurrentUser();
   setMessage("已恢复登录状态");
   await loadAll(true);
   startAutoRefresh();
@@ -208,6 +328,19 @@ function onTabClick(event) {
 
   if (tab === "logs" && state.token) {
     loadLogs();
+    loadLogLevels();
+  }
+  if (tab === "audit" && state.token) {
+    loadAudit();
+  }
+  if (tab === "settings" && state.token) {
+    loadTotpStatus();
+  }
+  if (tab === "settings" && hasRole("admin")) {
+    loadUsers();
+    loadSessions();
+    loadJwtKeys();
+    loadConfig();
   }
   if (tab === "home") {
     resizeCharts();
@@ -224,16 +357,17 @@ async function loadAll(isSilent = false) {
   }
 
   try {
-    const [statusRes, metricsRes, connRes, routersRes, monitorRes, viewersRes, rpcStatsRes, rpcRankRes, systemSettingsRes] = await Promise.all([
+    const [statusRes, metricsRes, connRes, routersRes, monitorRes, viewersRes, rpcStatsRes, rpcRankRes, systemSettingsRes, historyRes] = await Promise.all([
       apiGet("/api/status"),
       apiGet("/api/metrics"),
-      apiGet("/api/connections"),
+      apiGet(connectionsUrl()),
       apiGet(`/api/routers?keyword=${encodeURIComponent((routerKeywordInput.value || "").trim())}`),
       apiGet("/api/monitor-stats"),
       apiGet("/api/viewers"),
       apiGet("/api/rpc-stats"),
       apiGet(`/api/rpc/router-ranking?limit=50&keyword=${encodeURIComponent((rpcTrafficKeywordInput.value || "").trim())}`),
       apiGet("/api/system/settings"),
+      apiGet(metricsHistoryUrl()),
     ]);
 
     const serverInfo = statusRes.data?.serverInfo || {};
@@ -241,6 +375,9 @@ async function loadAll(isSilent = false) {
     const systemInfo = statusRes.data?.system || {};
     const memory = metricsRes.data?.memory || {};
     const cpu = metricsRes.data?.cpu || {};
+    const hostMem = metricsRes.data?.host || {};
+    const fd = metricsRes.data?.fd || {};
+    const sockets = metricsRes.data?.sockets || {};
     const connData = connRes.data || {};
     const monitor = monitorRes.data || {};
     const viewers = viewersRes.data || {};
@@ -254,11 +391,17 @@ async function loadAll(isSilent = false) {
       ["运行时长", formatDuration(serverInfo.uptime || 0)],
       ["总请求数", connData.totalRequests || 0],
       ["历史连接数", connData.totalConnections || 0],
+      ["拒绝连接数", connData.rejectedConnections || 0],
       ["在线路由", routersData.online || routers.length || 0],
       ["活跃查看者", viewers.activeViewers || 0],
       ["累计流量", formatBytesToMB(rpcTotal.bytes || 0)],
     ]);
     renderRouters(routers);
+    renderConnectionHistory(connData.connectionHistory || []);
+    renderTimelineOptions(connData.timelineNodes || []);
+    if (connData.routeId) {
+      renderTimeline(connData.timeline || []);
+    }
     renderRPCRankTable(rpcRanking);
     renderRPCRankChart(rpcRanking);
     renderCountryDistributionChart(routers);
@@ -266,22 +409,27 @@ async function loadAll(isSilent = false) {
     renderStubTopChart(routers);
     syncRouteOptions(routers);
     renderSettings({
-      osName: systemInfo.osName || "-",
+      version: [serverInfo.version || "-", serverInfo.commit ? String(serverInfo.commit).slice(0, 12) : ""].filter(Boolean).join(" @ "),
+      goVersion: systemInfo.goVersion || "-",
+      osName: [systemInfo.osName || "-", systemInfo.arch || "", systemInfo.osVersion && systemInfo.osVersion !== "-" ? systemInfo.osVersion : ""].filter(Boolean).join(" / "),
       cpuCores: systemInfo.processors || 0,
       routerPort: routerInfo.port || 0,
       monitorPort: routerInfo.monitorPort || 0,
       cpuUsage: cpu.usage || 0,
+      loadAverage: (cpu.loadAverages || []).map((v) => Number(v).toFixed(2)).join(" / ") || "-",
       memoryUsed: formatBytesToMB(memory.used || 0),
       memoryMax: formatBytesToMB(memory.max || 0),
+      processRss: formatBytesToMB(memory.rss || 0),
+      hostMemory: hostMem.memTotal ? `${formatBytesToMB(hostMem.memUsed || 0)} / ${formatBytesToMB(hostMem.memTotal)} (${hostMem.memUsagePercent || 0}%)` : "-",
+      fds: fd.max ? `${fd.open || 0} / ${fd.max}` : "-",
+      sockets: `TCP ${sockets.tcpInUse || 0}，TIME_WAIT ${sockets.tcpTimeWait || 0}`,
       requestsLastMinute: monitor.requestsLastMinute || 0,
       adminPasswordConfigured: systemSettings.adminPasswordConfigured ? "是" : "否",
       logBufferCapacity: systemSettings.logBufferCapacity || 0,
     });
 
+    state.metricsHistory = historyRes.data || null;
     updateHistory({
-      totalRequests: monitor.requestsLastMinute || 0,
-      totalConnections: connData.totalConnections || 0,
-      onlineRouters: routersData.online || routers.length || 0,
       memoryUsed: memory.used || 0,
       memoryMax: memory.max || 0,
       memoryUsagePercent: memory.usagePercent || 0,
@@ -303,15 +451,30 @@ async function loadRoutersAndRanking() {
   await loadAll(true);
 }
 
+function logQueryString(limit) {
+  const params = new URLSearchParams({
+    limit: String(limit),
+    keyword: (logKeywordInput.value || "").trim(),
+    level: (logLevelInput.value || "all").trim(),
+  });
+  const component = (logComponentInput.value || "").trim();
+  const routeId = (logRouteIdInput.value || "").trim();
+  if (component) {
+    params.set("component", component);
+  }
+  if (routeId) {
+    params.set("routeId", routeId);
+  }
+  return params.toString();
+}
+
 async function loadLogs() {
   if (!state.token) {
     return;
   }
   const limit = Math.min(1000, Math.max(10, Number(logLimitInput.value || 200)));
-  const keyword = (logKeywordInput.value || "").trim();
-  const level = (logLevelInput.value || "all").trim();
   try {
-    const data = await apiGet(`/api/logs?limit=${limit}&keyword=${encodeURIComponent(keyword)}&level=${encodeURIComponent(level)}`);
+    const data = await apiGet(`/api/logs?${logQueryString(limit)}`);
     const lines = data?.data?.lines || [];
     logOutput.textContent = lines.length ? lines.join("\n") : "暂无日志";
   } catch (error) {
@@ -324,30 +487,169 @@ async function exportLogs() {
     return;
   }
   const limit = Math.min(5000, Math.max(10, Number(logLimitInput.value || 200)));
-  const keyword = (logKeywordInput.value || "").trim();
-  const level = (logLevelInput.value || "all").trim();
-  const url = `/api/logs/export?limit=${limit}&keyword=${encodeURIComponent(keyword)}&level=${encodeURIComponent(level)}`;
+  const url = `/api/logs/export?${logQueryString(limit)}`;
+  try {
+    await downloadText(url, "router-logs.txt");
+  } catch (error) {
+    logOutput.textContent = `导出失败: ${error.message || error}`;
+  }
+}
+
+async function downloadText(url, defaultName) {
+  const resp = await fetch(url, {
+    headers: { Authorization: `Bearer ${state.token}` },
+  });
+  if (!resp.ok) {
+    throw new Error(`导出失败: HTTP ${resp.status}`);
+  }
+
+  const blob = await resp.blob();
+  const fileName = parseFileName(resp.headers.get("Content-Disposition")) || defaultName;
+  const objectUrl = URL.createObjectURL(blob);
+  const link = document.createElement("a");
+  link.href = objectUrl;
+  link.download = fileName;
+  document.body.appendChild(link);
+  link.click();
+  document.body.removeChild(link);
+  URL.revokeObjectURL(objectUrl);
+}
 
+function logSearchPageSize() {
+  return Math.min(1000, Math.max(10, Number(logSearchLimitInput.value || 100)));
+}
+
+function logSearchQueryString(offset) {
+  const params = new URLSearchParams({
+    keyword: (logKeywordInput.value || "").trim(),
+    level: (logLevelInput.value || "all").trim(),
+    offset: String(Math.max(0, offset)),
+    limit: String(logSearchPageSize()),
+  });
+  const component = (logComponentInput.value || "").trim();
+  if (component) {
+    params.set("component", component);
+  }
+  if (logSearchFromInput.value) {
+    params.set("from", logSearchFromInput.value);
+  }
+  if (logSearchToInput.value) {
+    params.set("to", logSearchToInput.value);
+  }
+  return params.toString();
+}
+
+async function searchLogFiles(offset) {
+  if (!state.token) {
+    return;
+  }
   try {
-    const resp = await fetch(url, {
-      headers: { Authorization: `Bearer ${state.token}` },
-    });
-    if (!resp.ok) {
-      throw new Error(`导出失败: HTTP ${resp.status}`);
-    }
+    const data = await apiGet(`/api/logs/search?${logSearchQueryString(offset)}`);
+    const result = data?.data || {};
+    const items = result.items || [];
+    state.logSearchOffset = result.offset || 0;
+    logSearchOutput.textContent = items.length ? items.map((item) => `[${item.file}] ${item.line}`).join("\n") : "暂无结果";
+    const end = state.logSearchOffset + items.length;
+    logSearchInfo.textContent = `共 ${result.total || 0} 条，当前 ${items.length ? state.logSearchOffset + 1 : 0}-${end}，文件: ${(result.files || []).join(", ") || "无"}`;
+    logSearchPrevBtn.disabled = state.logSearchOffset <= 0;
+    logSearchNextBtn.disabled = end >= (result.total || 0);
+  } catch (error) {
+    logSearchOutput.textContent = `检索失败: ${error.message || error}`;
+  }
+}
+
+async function exportLogSearch() {
+  if (!state.token) {
+    return;
+  }
+  try {
+    await downloadText(`/api/logs/search/export?${logSearchQueryString(0)}`, "router-logs.txt");
+  } catch (error) {
+    logSearchOutput.textContent = `导出失败: ${error.message || error}`;
+  }
+}
 
-    const blob = await resp.blob();
-    const fileName = parseFileName(resp.headers.get("Content-Disposition")) || "router-logs.txt";
-    const objectUrl = URL.createObjectURL(blob);
-    const link = document.createElement("a");
-    link.href = objectUrl;
-    link.download = fileName;
-    document.body.appendChild(link);
-    link.click();
-    document.body.removeChild(link);
-    URL.revokeObjectURL(objectUrl);
+async function loadAudit() {
+  if (!state.token) {
+    return;
+  }
+  const params = new URLSearchParams({
+    limit: "200",
+    keyword: (auditKeywordInput.value || "").trim(),
+    action: (auditActionInput.value || "").trim(),
+  });
+  try {
+    const data = await apiGet(`/api/audit?${params.toString()}`);
+    const items = Array.isArray(data.data?.items) ? data.data.items : [];
+    renderAudit(items);
+    auditMsg.textContent = `共 ${data.data?.total || 0} 条，显示最近 ${items.length} 条`;
   } catch (error) {
-    logOutput.textContent = `导出失败: ${error.message || error}`;
+    auditMsg.textContent = `审计日志加载失败: ${error.message || error}`;
+  }
+}
+
+function renderAudit(items) {
+  if (!items.length) {
+    auditBody.innerHTML = `<tr><td colspan="6">暂无审计记录</td></tr>`;
+    return;
+  }
+  auditBody.innerHTML = items
+    .map((item) => {
+      const ip = item.forwardedFor ? `${item.clientIp} (XFF: ${item.forwardedFor})` : item.clientIp;
+      const params = item.params ? JSON.stringify(item.params) : "";
+      const result = `${item.success ? "成功" : "失败"} ${item.status || ""}${item.message ? " · " + item.message : ""}`;
+      return `<tr>
+        <td>${escapeHtml(formatDateTime(item.time))}</td>
+        <td>${escapeHtml(String(item.operator || "-"))}</td>
+        <td>${escapeHtml(String(ip || "-"))}</td>
+        <td>${escapeHtml(`${item.action} (${item.method} ${item.path})`)}</td>
+        <td title="${escapeHtml(params)}">${escapeHtml(params.length > 80 ? params.slice(0, 80) + "…" : params || "-")}</td>
+        <td>${escapeHtml(result)}</td>
+      </tr>`;
+    })
+    .join("");
+}
+
+const LOG_LEVEL_OPTIONS = ["DEBUG", "INFO", "WARN", "ERROR"];
+
+async function loadLogLevels() {
+  try {
+    const data = await apiGet("/api/logs/levels");
+    renderLogLevels(data?.data || {});
+  } catch (error) {
+    logFormatInfo.textContent = `日志级别加载失败: ${error.message || error}`;
+  }
+}
+
+function renderLogLevels(levels) {
+  const known = levels.known || [];
+  const components = levels.components || {};
+  logFormatInfo.textContent = `输出格式: ${levels.format || "text"}，修改立即生效，进程重启后恢复为配置文件中的级别`;
+
+  const selected = logComponentInput.value;
+  logComponentInput.innerHTML = `<option value="">全部</option>` + known.map((name) => `<option value="${escapeHtml(name)}">${escapeHtml(name)}</option>`).join("");
+  logComponentInput.value = selected;
+
+  const rows = [["default", levels.default || "INFO", false]].concat(known.map((name) => [name, components[name] || "", true]));
+  logLevelsBody.innerHTML = rows
+    .map(([name, level, inherit]) => {
+      const options = (inherit ? [`<option value="">继承默认</option>`] : [])
+        .concat(LOG_LEVEL_OPTIONS.map((item) => `<option value="${item}" ${item === level ? "selected" : ""}>${item}</option>`))
+        .join("");
+      return `<tr><td>${escapeHtml(name)}</td><td><select data-log-component="${escapeHtml(name)}">${options}</select></td></tr>`;
+    })
+    .join("");
+  logLevelsBody.querySelectorAll("select[data-log-component]").forEach((select) => {
+    select.addEventListener("change", () => updateLogLevel(select.dataset.logComponent, select.value));
+  });
+}
+
+async function updateLogLevel(component, level) {
+  try {
+    const data = await apiPost("/api/logs/levels", { component, level });
+    renderLogLevels(data?.data || {});
+  } catch (error) {
+    logFormatInfo.textContent = `修改日志级别失败: ${error.message || error}`;
   }
 }
 
@@ -394,8 +696,12 @@ function redirectToLogin(message = "") {
 }
 
 async function apiPost(path, body) {
+  return apiRequest("POST", path, body);
+}
+
+async function apiRequest(method, path, body) {
   const resp = await fetch(path, {
-    method: "POST",
+    method,
     headers: {
       "Content-Type": "application/json",
       Authorization: `Bearer ${state.token}`,
@@ -417,13 +723,20 @@ function renderStats(items) {
 
 function renderSettings(info) {
   const pairs = [
+    ["版本", info.version],
+    ["Go 版本", info.goVersion],
     ["操作系统", info.osName],
     ["CPU 核心", info.cpuCores],
     ["Router 端口", info.routerPort],
     ["Monitor 端口", info.monitorPort],
     ["CPU 使用率", `${info.cpuUsage}%`],
+    ["系统负载 (1/5/15)", info.loadAverage],
     ["内存已用", info.memoryUsed],
     ["内存总量", info.memoryMax],
+    ["进程 RSS", info.processRss],
+    ["主机内存", info.hostMemory],
+    ["文件句柄", info.fds],
+    ["Socket", info.sockets],
     ["最近一分钟请求", info.requestsLastMinute],
     ["管理员密码已配置", info.adminPasswordConfigured],
     ["日志缓冲区容量", info.logBufferCapacity],
@@ -435,7 +748,7 @@ function renderSettings(info) {
 
 function renderRouters(list) {
   if (!list.length) {
-    routersBody.innerHTML = `<tr><td colspan="10">暂无在线路由节点</td></tr>`;
+    routersBody.innerHTML = `<tr><td colspan="11">暂无在线路由节点</td></tr>`;
     return;
   }
   routersBody.innerHTML = list
@@ -449,13 +762,83 @@ function renderRouters(list) {
       const mode = r.rpcMode || "-";
       const addr = r.address || "-";
       const stubCount = Number(r.stubCount || 0);
+      const queue = r.writeQueue || {};
+      const queueText = `${Number(queue.depth || 0)} / ${Number(queue.highWater || 0)}` + (queue.dropped ? ` (丢弃 ${queue.dropped})` : "");
       const status = r.status || (r.connected ? "ONLINE" : "OFFLINE");
-      const statusClass = String(status).toUpperCase() === "ONLINE" ? "status-badge status-online" : "status-badge status-offline";
-      return `<tr><td>${escapeHtml(routeId)}</td><td>${escapeHtml(lastHeartbeat)}</td><td>${escapeHtml(remote)}</td><td>${escapeHtml(country)}</td><td>${escapeHtml(location)}</td><td>${escapeHtml(provider)}</td><td>${escapeHtml(mode)}</td><td>${escapeHtml(addr)}</td><td>${escapeHtml(String(stubCount))}</td><td><span class="${statusClass}">${escapeHtml(status)}</span></td></tr>`;
+      const statusKey = String(status).toUpperCase();
+      const statusClass = statusKey === "ONLINE" ? "status-badge status-online" : statusKey === "SUSPECT" ? "status-badge status-suspect" : "status-badge status-offline";
+      return `<tr><td class="route-link" data-route-id="${escapeHtml(routeId)}">${escapeHtml(routeId)}</td><td>${escapeHtml(lastHeartbeat)}</td><td>${escapeHtml(remote)}</td><td>${escapeHtml(country)}</td><td>${escapeHtml(location)}</td><td>${escapeHtml(provider)}</td><td>${escapeHtml(mode)}</td><td>${escapeHtml(addr)}</td><td>${escapeHtml(String(stubCount))}</td><td>${escapeHtml(queueText)}</td><td><span class="${statusClass}">${escapeHtml(status)}</span></td></tr>`;
     })
     .join("");
 }
 
+function renderConnectionHistory(list) {
+  if (!list.length) {
+    connHistoryBody.innerHTML = `<tr><td colspan="5">暂无记录</td></tr>`;
+    return;
+  }
+  connHistoryBody.innerHTML = list
+    .map((c) => {
+      const reason = c.rejected ? `拒绝: ${c.reason}` : c.reason;
+      const detail = c.detail ? ` (${c.detail})` : "";
+      const routeCell = c.routeId ? `<td class="route-link" data-route-id="${escapeHtml(c.routeId)}">${escapeHtml(c.routeId)}</td>` : "<td>-</td>";
+      return `<tr><td>${escapeHtml(formatDateTime(c.closedAt || 0))}</td>${routeCell}<td>${escapeHtml(c.remoteAddr || "-")}</td><td>${escapeHtml(formatDuration((c.closedAt || 0) - (c.openedAt || 0)))}</td><td>${escapeHtml(reason + detail)}</td></tr>`;
+    })
+    .join("");
+}
+
+const SESSION_EVENT_LABELS = {
+  connect: "建立连接",
+  register: "注册",
+  stubs_changed: "Stub 变更",
+  endpoint_changed: "RPC 地址变更",
+  rejected: "拒绝注册",
+  removed: "移除会话",
+};
+
+function connectionsUrl() {
+  const routeId = (timelineRouteIdInput.value || "").trim();
+  return routeId ? `/api/connections?routeId=${encodeURIComponent(routeId)}` : "/api/connections";
+}
+
+async function loadTimeline() {
+  if (!(timelineRouteIdInput.value || "").trim()) {
+    timelineBody.innerHTML = `<tr><td colspan="4">请先选择 routeId</td></tr>`;
+    return;
+  }
+  try {
+    const res = await apiGet(connectionsUrl());
+    renderTimeline(res.data?.timeline || []);
+  } catch (error) {
+    timelineBody.innerHTML = `<tr><td colspan="4">${escapeHtml(`加载失败: ${error.message || error}`)}</td></tr>`;
+  }
+}
+
+function onRouteIdClick(event) {
+  const cell = event.target.closest("td[data-route-id]");
+  if (!cell) {
+    return;
+  }
+  timelineRouteIdInput.value = cell.dataset.routeId;
+  loadTimeline();
+}
+
+function renderTimelineOptions(nodes) {
+  timelineRouteOptions.innerHTML = nodes
+    .map((n) => `<option value="${escapeHtml(n.routeId)}">${escapeHtml(`${n.online ? "在线" : "离线"} · ${n.count} 条`)}</option>`)
+    .join("");
+}
+
+function renderTimeline(list) {
+  if (!list.length) {
+    timelineBody.innerHTML = `<tr><td colspan="4">暂无事件</td></tr>`;
+    return;
+  }
+  timelineBody.innerHTML = list
+    .map((e) => `<tr><td>${escapeHtml(formatDateTime(e.at || 0))}</td><td>${escapeHtml(SESSION_EVENT_LABELS[e.type] || e.type)}</td><td>${escapeHtml(e.remoteAddr || "-")}</td><td>${escapeHtml(e.detail || "-")}</td></tr>`)
+    .join("");
+}
+
 function renderCountryDistributionChart(routers) {
   if (!state.charts.countryDist) {
     return;
@@ -527,11 +910,14 @@ function renderStubTopChart(routers) {
 
 function renderRPCRankTable(list) {
   if (!list.length) {
-    rpcRankBody.innerHTML = `<tr><td colspan="6">暂无 RPC 统计数据</td></tr>`;
+    rpcRankBody.innerHTML = `<tr><td colspan="7">暂无 RPC 统计数据</td></tr>`;
     return;
   }
   rpcRankBody.innerHTML = list
     .map((item, idx) => {
+      const limitDetail = Object.entries(item.rateLimitedByKind || {})
+        .map(([k, v]) => `${k}: ${v}`)
+        .join(", ");
       return `<tr>
         <td>${idx + 1}</td>
         <td>${escapeHtml(item.routerId || "-")}</td>
@@ -539,6 +925,7 @@ function renderRPCRankTable(list) {
         <td>${escapeHtml(String(item.total || 0))}</td>
         <td>${escapeHtml(String(item.incomingTotal || 0))}</td>
         <td>${escapeHtml(String(item.outgoingTotal || 0))}</td>
+        <td title="${escapeHtml(limitDetail)}">${escapeHtml(String(item.rateLimited || 0))}</td>
       </tr>`;
     })
     .join("");
@@ -566,6 +953,8 @@ function renderRPCRankChart(list) {
 
 function openRpcDebugModal() {
   rpcDebugModal.classList.remove("hidden");
+  loadDebugHistory();
+  loadTemplates();
 }
 
 function closeRpcDebugModal() {
@@ -633,11 +1022,10 @@ async function sendRpc() {
     return;
   }
 
-  const raw = paramText.value || "";
   const payload = {
     targetRouteId,
     packetId,
-    params: raw.length ? [raw] : [],
+    params: currentDebugParams(),
   };
 
   setRpcMessage("发送 RPC 中...");
@@ -655,6 +1043,250 @@ async function sendRpc() {
     setRpcMessage(`发送失败: ${error.message || error}`);
     rpcResult.textContent = String(error.message || error);
   }
+  loadDebugHistory();
+}
+
+const TAP_MAX_ROWS = 300;
+
+function toggleTap() {
+  if (state.tap.source) {
+    stopTap("已停止监听");
+    return;
+  }
+  const params = new URLSearchParams();
+  const routeId = (tapRouteIdInput.value || "").trim();
+  const type = tapTypeInput.value || "";
+  const packetId = (tapPacketIdInput.value || "").trim();
+  const sample = Math.max(1, Number(tapSampleInput.value) || 1);
+  if (routeId) params.set("routeId", routeId);
+  if (type) params.set("type", type);
+  if (packetId) params.set("packetId", packetId);
+  params.set("sample", String(sample));
+
+  // EventSource 无法自定义请求头，依赖登录时写入的鉴权 Cookie
+  const source = new EventSource(`/api/tap/stream?${params.toString()}`);
+  state.tap.source = source;
+  state.tap.received = 0;
+  tapToggleBtn.textContent = "停止监听";
+  tapMsg.textContent = "监听中...";
+
+  source.addEventListener("message", (event) => {
+    try {
+      state.tap.events.unshift(JSON.parse(event.data));
+    } catch (_) {
+      return;
+    }
+    if (state.tap.events.length > TAP_MAX_ROWS) {
+      state.tap.events.length = TAP_MAX_ROWS;
+    }
+    state.tap.received += 1;
+    renderTap();
+  });
+  source.addEventListener("dropped", (event) => {
+    tapMsg.textContent = `监听中，已收到 ${state.tap.received} 条；浏览器消费过慢，服务端累计丢弃 ${event.data} 条`;
+  });
+  source.onerror = () => {
+    if (source.readyState === EventSource.CLOSED) {
+      stopTap("连接已断开");
+    } else {
+      tapMsg.textContent = "连接中断，正在重连...";
+    }
+  };
+}
+
+function stopTap(message) {
+  if (state.tap.source) {
+    state.tap.source.close();
+    state.tap.source = null;
+  }
+  tapToggleBtn.textContent = "开始监听";
+  tapMsg.textContent = message || "";
+}
+
+function clearTap() {
+  state.tap.events = [];
+  renderTap();
+}
+
+function renderTap() {
+  if (!state.tap.events.length) {
+    tapBody.innerHTML = `<tr><td colspan="7">暂无数据</td></tr>`;
+    return;
+  }
+  tapBody.innerHTML = state.tap.events
+    .map((item) => `<tr>
+        <td>${escapeHtml(formatDateTime(item.ts))}</td>
+        <td>${escapeHtml(String(item.from || "-"))}</td>
+        <td>${escapeHtml(String(item.to || "-"))}</td>
+        <td>${escapeHtml(String(item.type || "-"))}</td>
+        <td>${escapeHtml(String(item.size || 0))}</td>
+        <td>${escapeHtml(String(item.packetId || "-"))}</td>
+        <td>${escapeHtml(String(item.rpcUid || "-"))}</td>
+      </tr>`)
+    .join("");
+  if (state.tap.source) {
+    tapMsg.textContent = `监听中，已收到 ${state.tap.received} 条`;
+  }
+}
+
+function currentDebugParams() {
+  const raw = paramText.value || "";
+  return raw.length ? [raw] : [];
+}
+
+// 调试窗口的参数框按单个 string 参数传递，回填时做相反的转换
+function fillDebugParams(params) {
+  const list = Array.isArray(params) ? params : [];
+  if (list.length === 1 && typeof list[0] === "string") {
+    paramText.value = list[0];
+    return;
+  }
+  paramText.value = list.length ? JSON.stringify(list) : "";
+}
+
+async function loadDebugHistory() {
+  const keyword = (historyKeywordInput.value || "").trim();
+  try {
+    const data = await apiGet(`/api/debug/history?limit=50&keyword=${encodeURIComponent(keyword)}`);
+    const items = Array.isArray(data.data?.items) ? data.data.items : [];
+    state.debugHistory = items;
+    renderDebugHistory(items);
+    historyMsg.textContent = `共 ${data.data?.total || 0} 条，显示最近 ${items.length} 条`;
+  } catch (error) {
+    historyMsg.textContent = `加载历史失败: ${error.message || error}`;
+  }
+}
+
+function renderDebugHistory(items) {
+  if (!items.length) {
+    historyBody.innerHTML = `<tr><td colspan="7">暂无调试记录</td></tr>`;
+    return;
+  }
+  historyBody.innerHTML = items
+    .map((item) => {
+      const uid = escapeHtml(String(item.rpcUid || ""));
+      const status = String(item.status || "-");
+      const cost = item.completedAt ? `${item.costMs || 0} ms` : "-";
+      return `<tr>
+        <td>${escapeHtml(formatDateTime(item.createdAt))}</td>
+        <td>${escapeHtml(String(item.targetRouteId || "-"))}</td>
+        <td>[${escapeHtml(String(item.packetId || 0))}] ${escapeHtml(String(item.method || ""))}</td>
+        <td title="${escapeHtml(String(item.errorMsg || ""))}">${escapeHtml(status)}</td>
+        <td>${escapeHtml(cost)}</td>
+        <td>${escapeHtml(String(item.operator || "-"))}</td>
+        <td>
+          <button data-action="view" data-id="${uid}">查看</button>
+          <button data-action="fill" data-id="${uid}">填充</button>
+          <button data-action="replay" data-id="${uid}">重放</button>
+        </td>
+      </tr>`;
+    })
+    .join("");
+}
+
+async function onHistoryAction(event) {
+  const button = event.target.closest("button[data-action]");
+  if (!button) {
+    return;
+  }
+  const id = button.dataset.id || "";
+  const item = (state.debugHistory || []).find((x) => x.rpcUid === id);
+  if (!item) {
+    return;
+  }
+  if (button.dataset.action === "view") {
+    rpcResult.textContent = JSON.stringify(item, null, 2);
+    return;
+  }
+  if (button.dataset.action === "fill") {
+    targetRouteIdInput.value = item.targetRouteId || "";
+    packetIdInput.value = String(item.packetId || "");
+    fillDebugParams(item.params);
+    setRpcMessage(`已填充 requestId=${id} 的请求`);
+    return;
+  }
+  setRpcMessage("重放中...");
+  rpcResult.textContent = "等待结果...";
+  try {
+    const resp = await apiPost("/api/debug/history/replay", { requestId: id });
+    const requestId = resp?.data?.requestId || "";
+    setRpcMessage(`已重放，requestId=${requestId}，正在查询结果...`);
+    await pollRpcResult(requestId, 25, 1000);
+  } catch (error) {
+    setRpcMessage(`重放失败: ${error.message || error}`);
+  }
+  loadDebugHistory();
+}
+
+async function loadTemplates() {
+  try {
+    const data = await apiGet("/api/debug/templates");
+    const list = Array.isArray(data.data) ? data.data : [];
+    state.debugTemplates = list;
+    if (!list.length) {
+      templateSelect.innerHTML = `<option value="">暂无模板</option>`;
+      return;
+    }
+    templateSelect.innerHTML = list
+      .map((tpl) => `<option value="${escapeHtml(String(tpl.id))}">${escapeHtml(String(tpl.name))} (${escapeHtml(String(tpl.targetRouteId))} / ${escapeHtml(String(tpl.packetId))})</option>`)
+      .join("");
+  } catch (error) {
+    setRpcMessage(`加载模板失败: ${error.message || error}`);
+  }
+}
+
+function applyTemplate() {
+  const tpl = (state.debugTemplates || []).find((x) => x.id === templateSelect.value);
+  if (!tpl) {
+    setRpcMessage("请先选择模板");
+    return;
+  }
+  targetRouteIdInput.value = tpl.targetRouteId || "";
+  packetIdInput.value = String(tpl.packetId || "");
+  fillDebugParams(tpl.params);
+  setRpcMessage(`已填充模板: ${tpl.name}`);
+}
+
+async function saveTemplate() {
+  const name = (templateNameInput.value || "").trim();
+  if (!name) {
+    setRpcMessage("模板名称不能为空");
+    return;
+  }
+  try {
+    await apiPost("/api/debug/templates", {
+      name,
+      targetRouteId: (targetRouteIdInput.value || "").trim(),
+      packetId: Number(packetIdInput.value) || 0,
+      params: currentDebugParams(),
+    });
+    templateNameInput.value = "";
+    setRpcMessage(`模板已保存: ${name}`);
+    await loadTemplates();
+  } catch (error) {
+    setRpcMessage(`保存模板失败: ${error.message || error}`);
+  }
+}
+
+async function deleteTemplate() {
+  const id = templateSelect.value;
+  if (!id) {
+    return;
+  }
+  try {
+    const resp = await fetch(`/api/debug/templates?id=${encodeURIComponent(id)}`, {
+      method: "DELETE",
+      headers: { Authorization: `Bearer ${state.token}` },
+    });
+    const data = await resp.json();
+    if (!resp.ok || data.success === false) {
+      throw new Error(data.message || "删除失败");
+    }
+    setRpcMessage("模板已删除");
+    await loadTemplates();
+  } catch (error) {
+    setRpcMessage(`删除模板失败: ${error.message || error}`);
+  }
 }
 
 async function pollRpcResult(requestId, maxTimes, intervalMs) {
@@ -682,15 +1314,45 @@ function updateHistory(values) {
   const max = 30;
 
   pushHistory(state.history.labels, label, max);
-  pushHistory(state.history.totalRequests, values.totalRequests, max);
-  pushHistory(state.history.totalConnections, values.totalConnections, max);
-  pushHistory(state.history.onlineRouters, values.onlineRouters, max);
   pushHistory(state.history.memoryUsagePercent, values.memoryUsagePercent, max);
 
   state._memoryUsed = values.memoryUsed;
   state._memoryMax = values.memoryMax;
 }
 
+function metricsHistoryUrl() {
+  const [resolution, windowSeconds] = (historyRangeInput.value || "second:600").split(":");
+  return `/api/metrics/history?metrics=requests,errors,connections,routers&resolution=${resolution}&window=${windowSeconds}`;
+}
+
+async function loadMetricsHistory() {
+  try {
+    const res = await apiGet(metricsHistoryUrl());
+    state.metricsHistory = res.data || null;
+    refreshCharts();
+  } catch (error) {
+    setMessage(`加载趋势失败: ${error.message || error}`);
+  }
+}
+
+// metricsHistorySeries 服务端历史指标转换为图表的横轴与数据
+function metricsHistorySeries(names) {
+  const history = state.metricsHistory || {};
+  const series = history.series || {};
+  const base = series[names[0]] || [];
+  const withSeconds = history.resolution !== "minute";
+  const labels = base.map((p) => {
+    const t = new Date(p.t);
+    const hm = `${pad2(t.getHours())}:${pad2(t.getMinutes())}`;
+    return withSeconds ? `${hm}:${pad2(t.getSeconds())}` : hm;
+  });
+  const values = {};
+  names.forEach((name) => {
+    values[name] = (series[name] || []).map((p) => p.v);
+  });
+  return { labels, values };
+}
+
 function pushHistory(arr, value, max) {
   arr.push(value);
   if (arr.length > max) {
@@ -717,16 +1379,26 @@ function refreshCharts() {
     return;
   }
 
+  const traffic = metricsHistorySeries(["requests", "errors"]);
   state.charts.request.setOption({
     tooltip: { trigger: "axis" },
-    xAxis: { type: "category", data: state.history.labels },
+    legend: { data: ["请求数", "错误数"] },
+    xAxis: { type: "category", data: traffic.labels },
     yAxis: { type: "value" },
     series: [
       {
         type: "line",
-        name: "每分钟请求数",
+        name: "请求数",
+        smooth: true,
+        showSymbol: false,
+        data: traffic.values.requests,
+      },
+      {
+        type: "line",
+        name: "错误数",
         smooth: true,
-        data: state.history.totalRequests,
+        showSymbol: false,
+        data: traffic.values.errors,
       },
     ],
   });
@@ -748,23 +1420,26 @@ function refreshCharts() {
     ],
   });
 
+  const online = metricsHistorySeries(["connections", "routers"]);
   state.charts.online.setOption({
     tooltip: { trigger: "axis" },
-    legend: { data: ["历史连接", "在线路由"] },
-    xAxis: { type: "category", data: state.history.labels },
+    legend: { data: ["当前连接", "在线路由"] },
+    xAxis: { type: "category", data: online.labels },
     yAxis: { type: "value" },
     series: [
       {
         type: "line",
-        name: "历史连接",
+        name: "当前连接",
         smooth: true,
-        data: state.history.totalConnections,
+        showSymbol: false,
+        data: online.values.connections,
       },
       {
         type: "line",
         name: "在线路由",
         smooth: true,
-        data: state.history.onlineRouters,
+        showSymbol: false,
+        data: online.values.routers,
       },
     ],
   });
@@ -859,6 +1534,301 @@ async function updateAdminPassword() {
   }
 }
 
+const ROLE_RANK = { viewer: 1, operator: 2, admin: 3 };
+
+function hasRole(role) {
+  return (ROLE_RANK[state.user?.role] || 0) >= ROLE_RANK[role];
+}
+
+async function loadCurrentUser() {
+  try {
+    const data = await apiGet("/api/auth/validate");
+    state.user = data?.data?.user || null;
+  } catch (_) {
+    state.user = null;
+  }
+  applyRoleUI();
+}
+
+// applyRoleUI 按角色隐藏或禁用无权限的操作，服务端仍会再次校验
+function applyRoleUI() {
+  const user = state.user;
+  currentUserInfo.textContent = user ? `当前账号: ${user.name} (${user.role})` : "";
+  const canOperate = hasRole("operator");
+  sendRpcBtn.disabled = !canOperate;
+  saveTemplateBtn.disabled = !canOperate;
+  deleteTemplateBtn.disabled = !canOperate;
+  auditTab.classList.toggle("hidden", !hasRole("admin"));
+  usersCard.classList.toggle("hidden", !hasRole("admin"));
+  jwtKeysCard.classList.toggle("hidden", !hasRole("admin"));
+  configCard.classList.toggle("hidden", !hasRole("admin"));
+  sessionsCard.classList.toggle("hidden", !hasRole("admin"));
+}
+
+async function loadUsers() {
+  try {
+    const data = await apiGet("/api/users");
+    renderUsers(Array.isArray(data.data) ? data.data : []);
+  } catch (error) {
+    usersMsg.textContent = `账号加载失败: ${error.message || error}`;
+  }
+}
+
+function renderUsers(users) {
+  usersBody.innerHTML = users
+    .map((u) => {
+      const name = escapeHtml(String(u.username || ""));
+      const roleOptions = ["viewer", "operator", "admin"]
+        .map((role) => `<option value="${role}" ${role === u.role ? "selected" : ""}>${role}</option>`)
+        .join("");
+      const legacy = u.legacy ? "（adminPassword 内置账号）" : "";
+      return `<tr>
+        <td>${name}${escapeHtml(legacy)}</td>
+        <td><select data-username="${name}" ${u.legacy ? "disabled" : ""}>${roleOptions}</select></td>
+        <td>${u.disabled ? "已禁用" : "启用"}</td>
+        <td>${u.totpEnabled ? "已启用" : "-"}</td>
+        <td>${escapeHtml(u.createdAt ? formatDateTime(u.createdAt) : "-")}</td>
+        <td>${
+          u.legacy
+            ? "-"
+            : `<button data-action="toggle" data-username="${name}" data-disabled="${u.disabled ? "1" : "0"}">${u.disabled ? "启用" : "禁用"}</button>
+          <button data-action="reset" data-username="${name}">重置密码</button>
+          ${u.totpEnabled ? `<button data-action="resetTotp" data-username="${name}">重置两步验证</button>` : ""}
+          <button data-action="delete" data-username="${name}" class="danger">删除</button>`
+        }</td>
+      </tr>`;
+    })
+    .join("");
+}
+
+async function createUser() {
+  const username = (newUsernameInput.value || "").trim();
+  const password = newUserPasswordInput.value || "";
+  if (!username || password.length < 4) {
+    usersMsg.textContent = "请填写用户名，密码长度至少 4 位";
+    return;
+  }
+  try {
+    await apiPost("/api/users", { username, password, role: newUserRoleInput.value });
+    usersMsg.textContent = `账号 ${username} 已创建`;
+    newUsernameInput.value = "";
+    newUserPasswordInput.value = "";
+    await loadUsers();
+  } catch (error) {
+    usersMsg.textContent = `创建失败: ${error.message || error}`;
+  }
+}
+
+async function updateUser(username, update) {
+  try {
+    await apiRequest("PUT", `/api/users?username=${encodeURIComponent(username)}`, update);
+    usersMsg.textContent = `账号 ${username} 已更新`;
+  } catch (error) {
+    usersMsg.textContent = `更新失败: ${error.message || error}`;
+  }
+  await loadUsers();
+}
+
+async function onUserRoleChange(event) {
+  const select = event.target.closest("select[data-username]");
+  if (select) {
+    await updateUser(select.dataset.username, { role: select.value });
+  }
+}
+
+async function onUserAction(event) {
+  const button = event.target.closest("button[data-action]");
+  if (!button) {
+    return;
+  }
+  const username = button.dataset.username || "";
+  if (button.dataset.action === "toggle") {
+    await updateUser(username, { disabled: button.dataset.disabled !== "1" });
+  } else if (button.dataset.action === "reset") {
+    const password = window.prompt(`为 ${username} 设置新密码（至少 4 位）`) || "";
+    if (password) {
+      await updateUser(username, { password });
+    }
+  } else if (button.dataset.action === "resetTotp") {
+    if (window.confirm(`确定解除 ${username} 的两步验证？`)) {
+      await updateUser(username, { resetTotp: true });
+    }
+  } else if (button.dataset.action === "delete") {
+    if (!window.confirm(`确定删除账号 ${username}？`)) {
+      return;
+    }
+    try {
+      await apiRequest("DELETE", `/api/users?username=${encodeURIComponent(username)}`);
+      usersMsg.textContent = `账号 ${username} 已删除`;
+    } catch (error) {
+      usersMsg.textContent = `删除失败: ${error.message || error}`;
+    }
+    await loadUsers();
+  }
+}
+
+async function loadSessions() {
+  try {
+    const data = await apiGet("/api/auth/sessions");
+    const sessions = Array.isArray(data.data) ? data.data : [];
+    sessionsBody.innerHTML = sessions
+      .map(
+        (s) => `<tr>
+        <td>${escapeHtml(String(s.username || ""))}${s.current ? "（当前）" : ""}</td>
+        <td>${escapeHtml(String(s.clientIp || "-"))}</td>
+        <td>${escapeHtml(String(s.userAgent || "-"))}</td>
+        <td>${escapeHtml(formatDateTime(s.createdAt))}</td>
+        <td>${escapeHtml(formatDateTime(s.lastSeenAt))}</td>
+        <td><button data-session="${escapeHtml(String(s.id || ""))}" class="danger">强制下线</button></td>
+      </tr>`
+      )
+      .join("");
+    sessionsMsg.textContent = `共 ${sessions.length} 个会话`;
+  } catch (error) {
+    sessionsMsg.textContent = `会话加载失败: ${error.message || error}`;
+  }
+}
+
+async function onSessionAction(event) {
+  const button = event.target.closest("button[data-session]");
+  if (!button || !window.confirm("确定强制下线该会话？")) {
+    return;
+  }
+  try {
+    await apiRequest("DELETE", `/api/auth/sessions?id=${encodeURIComponent(button.dataset.session)}`);
+    sessionsMsg.textContent = "会话已下线";
+  } catch (error) {
+    sessionsMsg.textContent = `下线失败: ${error.message || error}`;
+  }
+  await loadSessions();
+}
+
+async function loadTotpStatus() {
+  try {
+    const data = await apiGet("/api/auth/totp");
+    const status = data.data || {};
+    totpStatus.textContent = !status.supported
+      ? "单账号模式不支持两步验证，请先在账号管理中创建账号"
+      : status.enabled
+        ? "已启用：登录时需要输入验证器 App 中的 6 位验证码"
+        : "未启用";
+    totpSetupBtn.disabled = !status.supported;
+    totpSetupBtn.classList.toggle("hidden", !!status.enabled);
+    totpDisableBtn.classList.toggle("hidden", !status.enabled);
+    totpEnableBtn.classList.add("hidden");
+    totpSetupBox.classList.add("hidden");
+  } catch (error) {
+    totpStatus.textContent = `状态加载失败: ${error.message || error}`;
+  }
+}
+
+async function setupTotp() {
+  try {
+    const data = await apiPost("/api/auth/totp", { action: "setup" });
+    totpSecretInfo.textContent = `密钥: ${data.data.secret}\n${data.data.otpauthUrl}`;
+    totpSetupBox.classList.remove("hidden");
+    totpEnableBtn.classList.remove("hidden");
+    totpMsg.textContent = data.message || "";
+  } catch (error) {
+    totpMsg.textContent = `生成失败: ${error.message || error}`;
+  }
+}
+
+async function submitTotp(action) {
+  const code = (totpCodeInput.value || "").trim();
+  if (!/^\d{6}$/.test(code)) {
+    totpMsg.textContent = "请输入 6 位验证码";
+    return;
+  }
+  try {
+    const data = await apiPost("/api/auth/totp", { action, code });
+    totpMsg.textContent = data.message || "";
+    totpCodeInput.value = "";
+    await loadTotpStatus();
+  } catch (error) {
+    totpMsg.textContent = `操作失败: ${error.message || error}`;
+  }
+}
+
+async function loadJwtKeys() {
+  try {
+    const data = await apiGet("/api/system/jwt-keys");
+    const keys = Array.isArray(data.data) ? data.data : [];
+    jwtKeysBody.innerHTML = keys
+      .map(
+        (k) => `<tr>
+        <td>${escapeHtml(String(k.kid || ""))}</td>
+        <td>${k.active ? "当前签名" : "宽限期"}</td>
+        <td>${escapeHtml(formatDateTime(k.createdAt))}</td>
+        <td>${escapeHtml(k.active ? "-" : formatDateTime(k.expiresAt))}</td>
+      </tr>`
+      )
+      .join("");
+  } catch (error) {
+    jwtKeysMsg.textContent = `密钥加载失败: ${error.message || error}`;
+  }
+}
+
+async function rotateJwtKey() {
+  if (!window.confirm("确定轮换 Token 签名密钥？")) {
+    return;
+  }
+  try {
+    const data = await apiPost("/api/system/jwt-keys", {});
+    jwtKeysMsg.textContent = data.message || "签名密钥已轮换";
+  } catch (error) {
+    jwtKeysMsg.textContent = `轮换失败: ${error.message || error}`;
+  }
+  await loadJwtKeys();
+}
+
+function renderConfigPending(pending) {
+  const items = Array.isArray(pending) ? pending : [];
+  configPending.innerHTML = items.length
+    ? `需要重启后生效：${items.map((c) => escapeHtml(`${c.path}: ${c.old || "-"} → ${c.new || "-"}`)).join("<br>")}`
+    : "";
+}
+
+async function loadConfig() {
+  try {
+    const data = await apiGet("/api/system/config");
+    const info = data.data || {};
+    configText.value = JSON.stringify(info.config || {}, null, 2);
+    renderConfigPending(info.pendingRestart);
+  } catch (error) {
+    configMsg.textContent = `配置加载失败: ${error.message || error}`;
+  }
+}
+
+async function saveConfig() {
+  let body;
+  try {
+    body = JSON.parse(configText.value);
+  } catch (error) {
+    configMsg.textContent = `JSON 格式错误: ${error.message || error}`;
+    return;
+  }
+  try {
+    const data = await apiRequest("PUT", "/api/system/config", body);
+    const changes = (data.data && data.data.changes) || [];
+    configMsg.textContent = `${data.message || "配置已应用"}（${changes.length} 项变更）`;
+    await loadConfig();
+  } catch (error) {
+    configMsg.textContent = `保存失败: ${error.message || error}`;
+  }
+}
+
+async function reloadConfigFile() {
+  try {
+    const data = await apiPost("/api/system/config/reload", {});
+    const changes = (data.data && data.data.changes) || [];
+    configMsg.textContent = `${data.message || "配置已重新加载"}（${changes.length} 项变更）`;
+    await loadConfig();
+  } catch (error) {
+    configMsg.textContent = `重新加载失败: ${error.message || error}`;
+  }
+}
+
 function setSettingsMessage(message) {
   settingsMsg.textContent = message;
 }
@@ -0,0 +1,126 @@
+package VirtualRouterServer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// StubSignature 同一 packetId 下的一种方法签名，以及声明该签名的节点
+type StubSignature struct {
+	Signature      string   `json:"signature"`
+	ClassName      string   `json:"className"`
+	MethodName     string   `json:"methodName"`
+	ParameterTypes []string `json:"parameterTypes"`
+	RouterIds      []string `json:"routerIds"`
+}
+
+// StubConflict 不同节点对同一 packetId 声明了不同的方法签名
+type StubConflict struct {
+	PacketId   int             `json:"packetId"`
+	Signatures []StubSignature `json:"signatures"`
+}
+
+// stubIndex packetId -> routeId -> 元数据，跨会话比较签名用
+type stubIndex map[int]map[string]core.RpcStubMetadata
+
+// stubSignatureKey 判定冲突只看方法名与参数类型，ClassName 不同不算冲突
+func stubSignatureKey(meta core.RpcStubMetadata) string {
+	return meta.MethodName + "(" + strings.Join(meta.ParameterTypes, ",") + ")"
+}
+
+// replace 用新的 Stub 列表替换某节点的索引，返回签名集合是否发生变化
+func (idx stubIndex) replace(routeId string, oldStubs, newStubs []core.RpcStubMetadata) bool {
+	changed := len(oldStubs) != len(newStubs)
+	if !changed {
+		oldKeys := make(map[int]string, len(oldStubs))
+		for _, s := range oldStubs {
+			oldKeys[s.PacketId] = stubSignatureKey(s)
+		}
+		for _, s := range newStubs {
+			if key, ok := oldKeys[s.PacketId]; !ok || key != stubSignatureKey(s) {
+				changed = true
+				break
+			}
+		}
+	}
+	idx.remove(routeId, oldStubs)
+	for _, s := range newStubs {
+		byRoute, ok := idx[s.PacketId]
+		if !ok {
+			byRoute = map[string]core.RpcStubMetadata{}
+			idx[s.PacketId] = byRoute
+		}
+		byRoute[routeId] = s
+	}
+	return changed
+}
+
+func (idx stubIndex) remove(routeId string, stubs []core.RpcStubMetadata) {
+	for _, s := range stubs {
+		byRoute, ok := idx[s.PacketId]
+		if !ok {
+			continue
+		}
+		delete(byRoute, routeId)
+		if len(byRoute) == 0 {
+			delete(idx, s.PacketId)
+		}
+	}
+}
+
+func (idx stubIndex) conflictOf(packetId int) (StubConflict, bool) {
+	byRoute := idx[packetId]
+	if len(byRoute) < 2 {
+		return StubConflict{}, false
+	}
+	grouped := map[string]*StubSignature{}
+	for routeId, meta := range byRoute {
+		key := stubSignatureKey(meta)
+		sig, ok := grouped[key]
+		if !ok {
+			sig = &StubSignature{
+				Signature:      key,
+				ClassName:      meta.ClassName,
+				MethodName:     meta.MethodName,
+				ParameterTypes: meta.ParameterTypes,
+			}
+			grouped[key] = sig
+		}
+		sig.RouterIds = append(sig.RouterIds, routeId)
+	}
+	if len(grouped) < 2 {
+		return StubConflict{}, false
+	}
+	conflict := StubConflict{PacketId: packetId, Signatures: make([]StubSignature, 0, len(grouped))}
+	for _, sig := range grouped {
+		sort.Strings(sig.RouterIds)
+		conflict.Signatures = append(conflict.Signatures, *sig)
+	}
+	sort.Slice(conflict.Signatures, func(i, j int) bool {
+		return conflict.Signatures[i].Signature < conflict.Signatures[j].Signature
+	})
+	return conflict, true
+}
+
+func (idx stubIndex) conflictsOf(stubs []core.RpcStubMetadata) []StubConflict {
+	var list []StubConflict
+	for _, s := range stubs {
+		if c, ok := idx.conflictOf(s.PacketId); ok {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+func (idx stubIndex) allConflicts() []StubConflict {
+	list := make([]StubConflict, 0)
+	for packetId := range idx {
+		if c, ok := idx.conflictOf(packetId); ok {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].PacketId < list[j].PacketId })
+	return list
+}
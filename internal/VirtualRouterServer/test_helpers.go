@@ -2,8 +2,12 @@ package VirtualRouterServer
 
 import (
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/neko233-com/virtual-router-go/internal/config"
 	"github.com/neko233-com/virtual-router-go/internal/core"
 )
 
@@ -13,18 +17,60 @@ func (s *Server) ForwardToTargetForTest(msg *core.RouteMessage) {
 	s.forwardToTarget(msg)
 }
 
+func (s *Server) HandleRpcResponseForTest(msg *core.RouteMessage) {
+	s.handleRpcResponse(msg)
+}
+
+func (s *Server) HandleRouteMessageForTest(msg *core.RouteMessage) {
+	s.handleRouteMessage(msg, nil, nil)
+}
+
+// RateLimitPacketBucketsForTest 所有连接缓存的 packetId 令牌桶数
+func (s *Server) RateLimitPacketBucketsForTest() int {
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+	total := 0
+	for _, node := range s.limiter.nodes {
+		total += len(node.packets)
+	}
+	return total
+}
+
+// ServeConnForTest 像 Accept 到新连接一样处理 conn，用 net.Pipe 模拟节点
+func (s *Server) ServeConnForTest(conn net.Conn) {
+	s.acceptConn(conn)
+}
+
 func (s *Server) RecordRouterRPCForTest(fromRouteID, toRouteID string) {
 	s.recordRouterRPC(fromRouteID, toRouteID)
 }
 
+// SetRouterLastMinuteHitsForTest 清空节点的 RPC 时间序列后按给定时间（毫秒）重新记录
 func (s *Server) SetRouterLastMinuteHitsForTest(routerID string, hits []int64) {
 	s.rpcStatsMu.Lock()
-	defer s.rpcStatsMu.Unlock()
-	st, ok := s.rpcStatsByRouter[routerID]
-	if !ok {
-		st = s.ensureRouterRPCStats(routerID)
+	s.ensureRouterRPCStats(routerID)
+	s.rpcStatsMu.Unlock()
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	ts := newTimeSeries(routerMetricSecondSlots)
+	for _, at := range hits {
+		ts.add(at, 1)
 	}
-	st.LastMinuteHits = hits
+	s.metrics.routers[routerID] = ts
+}
+
+// AddMetricAtForTest 在指定时间记录计数类指标
+func (s *Server) AddMetricAtForTest(name string, at time.Time, delta int64) {
+	s.metrics.add(name, at.UnixMilli(), delta)
+}
+
+// SampleGaugesForTest 立即采样连接数与在线节点数
+func (s *Server) SampleGaugesForTest() {
+	s.sampleGauges(time.Now())
+}
+
+func (h *HttpServer) HandleMetricsHistoryForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleMetricsHistory(w, r)
 }
 
 func (h *HttpServer) HandleLogsForTest(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +85,55 @@ func (h *HttpServer) HandleRouterRPCRankingForTest(w http.ResponseWriter, r *htt
 	h.handleRouterRPCRanking(w, r)
 }
 
+func (h *HttpServer) HandleStatusForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleStatus(w, r)
+}
+
+func (h *HttpServer) HandleMetricsForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleMetrics(w, r)
+}
+
+// ResolveBuildInfoForTest 在指定目录中查找 version.txt，不使用缓存
+func ResolveBuildInfoForTest(versionDirs []string) BuildInfo {
+	return resolveBuildInfo(versionDirs)
+}
+
+func (h *HttpServer) HandleConnectionsForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleConnections(w, r)
+}
+
+func (h *HttpServer) HandleRpcConflictsForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleRpcConflicts(w, r)
+}
+
+func (h *HttpServer) HandleRpcSchemaForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleRpcSchema(w, r)
+}
+
+func (h *HttpServer) HandleRpcGatewayForTest(w http.ResponseWriter, r *http.Request) {
+	h.withGatewayAuth(h.handleRpcGateway)(w, r)
+}
+
+func (h *HttpServer) HandleDebugSendRpcForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleDebugSendRpc(w, r)
+}
+
+func (h *HttpServer) HandleDebugHistoryForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleDebugHistory(w, r)
+}
+
+func (h *HttpServer) HandleDebugReplayForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleDebugReplay(w, r)
+}
+
+func (h *HttpServer) HandleDebugTemplatesForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleDebugTemplates(w, r)
+}
+
+func (h *HttpServer) HandleTapStreamForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleTapStream(w, r)
+}
+
 func (h *HttpServer) HandleUpdateAdminPasswordForTest(w http.ResponseWriter, r *http.Request) {
 	h.handleUpdateAdminPassword(w, r)
 }
@@ -47,6 +142,16 @@ func MatchLogLevelForTest(line, level string) bool {
 	return matchLogLevel(line, level)
 }
 
+// MatchRecordLevelForTest 按结构化记录的级别过滤
+func MatchRecordLevelForTest(rec LogRecord, level string) bool {
+	return matchRecordLevel(rec, level)
+}
+
+// ComponentLogForTest 组件 logger，用于确认缓存与重新配置后的行为
+func ComponentLogForTest(component string) *slog.Logger {
+	return componentLog(component)
+}
+
 func ResolveIPCountryForTest(ip string) string {
 	return resolveIPCountry(ip)
 }
@@ -59,11 +164,23 @@ func AuthCookieNameForTest() string {
 	return authCookieName
 }
 
+func ProcessLogCapacityForTest() int {
+	return globalLogs.getCapacity()
+}
+
 func SetProcessLogsForTest(lines []string, capacity int) (restore func()) {
+	records := make([]LogRecord, 0, len(lines))
+	for _, line := range lines {
+		records = append(records, LogRecord{Line: line})
+	}
+	return SetProcessLogRecordsForTest(records, capacity)
+}
+
+func SetProcessLogRecordsForTest(records []LogRecord, capacity int) (restore func()) {
 	globalLogs.mu.Lock()
-	backupLines := append([]string(nil), globalLogs.lines...)
+	backupRecords := append([]LogRecord(nil), globalLogs.records...)
 	backupCapacity := globalLogs.capacity
-	globalLogs.lines = append([]string(nil), lines...)
+	globalLogs.records = append([]LogRecord(nil), records...)
 	if capacity > 0 {
 		globalLogs.capacity = capacity
 	}
@@ -71,7 +188,7 @@ func SetProcessLogsForTest(lines []string, capacity int) (restore func()) {
 
 	return func() {
 		globalLogs.mu.Lock()
-		globalLogs.lines = backupLines
+		globalLogs.records = backupRecords
 		globalLogs.capacity = backupCapacity
 		globalLogs.mu.Unlock()
 	}
@@ -85,7 +202,7 @@ func NewLogCaptureTestHelper(capacity int) *LogCaptureTestHelper {
 	if capacity <= 0 {
 		capacity = 1
 	}
-	return &LogCaptureTestHelper{capture: &logCapture{capacity: capacity, lines: make([]string, 0, capacity)}}
+	return &LogCaptureTestHelper{capture: &logCapture{capacity: capacity, records: make([]LogRecord, 0, capacity)}}
 }
 
 func (h *LogCaptureTestHelper) Write(p []byte) (int, error) {
@@ -99,3 +216,127 @@ func (h *LogCaptureTestHelper) GetRecent(limit int) []string {
 func NewRotatingFileWriterForTest(dir, baseName string, maxBytes int64, maxFiles int) (io.WriteCloser, error) {
 	return newRotatingFileWriter(logRotationConfig{Dir: dir, BaseName: baseName, MaxBytes: maxBytes, MaxFiles: maxFiles})
 }
+
+// NewCompressedRotatingFileWriterForTest 轮转后 gzip 压缩旧文件，Close 会等待压缩完成
+func NewCompressedRotatingFileWriterForTest(dir, baseName string, maxBytes int64, maxFiles int) (io.WriteCloser, error) {
+	return newRotatingFileWriter(logRotationConfig{Dir: dir, BaseName: baseName, MaxBytes: maxBytes, MaxFiles: maxFiles, Compress: true})
+}
+
+// UseProcessLogConfigForTest 按 cfg 重新安装进程日志，restore 恢复原来的 slog 默认 logger 与级别
+func UseProcessLogConfigForTest(cfg *config.LogConfig) (restore func(), err error) {
+	prevLogger := slog.Default()
+	prevLevels := logLevels.snapshot()
+	logLevels.mu.RLock()
+	prevConfigDef, prevConfigComponents := logLevels.configDef, logLevels.configComponents
+	logLevels.mu.RUnlock()
+	prevRotation := currentLogRotation()
+	if err := ConfigureProcessLogs(cfg); err != nil {
+		return func() {}, err
+	}
+	return func() {
+		logSetupMu.Lock()
+		if logFileClose != nil {
+			_ = logFileClose.Close()
+			logFileClose = nil
+		}
+		logRotateCfg = prevRotation
+		logSetupMu.Unlock()
+		slog.SetDefault(prevLogger)
+		_ = SetLogLevel("", prevLevels.Default)
+		logLevels.mu.Lock()
+		logLevels.components = map[string]slog.Level{}
+		logLevels.format = prevLevels.Format
+		logLevels.configDef = prevConfigDef
+		logLevels.configComponents = prevConfigComponents
+		logLevels.mu.Unlock()
+	}, nil
+}
+
+func (h *HttpServer) HandleLogLevelsForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleLogLevels(w, r)
+}
+
+func (h *HttpServer) HandleLogSearchForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleLogSearch(w, r)
+}
+
+func (h *HttpServer) HandleLogSearchExportForTest(w http.ResponseWriter, r *http.Request) {
+	h.handleLogSearchExport(w, r)
+}
+
+// HandlerForTest 完整的管理后台路由（含鉴权与审计中间件）
+func (h *HttpServer) HandlerForTest() http.Handler {
+	return withCORS(h.routes())
+}
+
+func (h *HttpServer) AuditLogForTest() *AuditLog {
+	return h.audit
+}
+
+// UseJWTConfigForTest 按 cfg 加载签名密钥，restore 恢复原来的密钥环
+func UseJWTConfigForTest(cfg *config.JWTConfig) (restore func(), err error) {
+	prev := jwtKeys.Load()
+	if err := ConfigureJWT(cfg); err != nil {
+		return func() {}, err
+	}
+	return func() { jwtKeys.Store(prev) }, nil
+}
+
+// AgeRetiredJWTKeysForTest 将已轮换密钥的轮换时间提前 d，模拟宽限期流逝
+func AgeRetiredJWTKeysForTest(d time.Duration) {
+	k := jwtKeys.Load()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i := range k.keys {
+		if k.keys[i].RetiredAt != 0 {
+			k.keys[i].RetiredAt -= d.Milliseconds()
+		}
+	}
+}
+
+// SetLoginClockForTest 替换登录限流使用的时钟，模拟锁定到期
+func (h *HttpServer) SetLoginClockForTest(now func() time.Time) {
+	h.loginGuard.mu.Lock()
+	defer h.loginGuard.mu.Unlock()
+	h.loginGuard.now = now
+}
+
+// LoginFailForTest 直接记录一次登录失败，跳过密码校验
+func (h *HttpServer) LoginFailForTest(ip, username string) {
+	h.loginGuard.fail(ip, username)
+}
+
+// LoginGuardEntriesForTest 登录限流当前保存的记录数
+func (h *HttpServer) LoginGuardEntriesForTest() int {
+	h.loginGuard.mu.Lock()
+	defer h.loginGuard.mu.Unlock()
+	return len(h.loginGuard.attempts)
+}
+
+// TotpCodeForTest 计算 secret 在 at 时刻的两步验证码
+func TotpCodeForTest(secret string, at time.Time) string {
+	key, err := decodeTotpSecret(secret)
+	if err != nil {
+		return ""
+	}
+	return totpCodeAt(key, at.Unix()/totpPeriod)
+}
+
+// CheckHeartbeatForTest 以 now 为当前时间执行一轮心跳检查
+func (m *RouterSessionManager) CheckHeartbeatForTest(now time.Time) {
+	m.checkHeartbeat(now)
+}
+
+// WriteQueueForTest 直接驱动节点发送队列，用于模拟写出与关闭并发
+type WriteQueueForTest struct {
+	q *writeQueue
+}
+
+func NewWriteQueueForTest(conn net.Conn) *WriteQueueForTest {
+	return &WriteQueueForTest{q: newWriteQueue(conn, func() WriteQueuePolicy { return writeQueuePolicyFromConfig(nil) })}
+}
+
+func (w *WriteQueueForTest) Enqueue(frame []byte) error { return w.q.enqueue(frame) }
+func (w *WriteQueueForTest) Run()                       { w.q.run() }
+func (w *WriteQueueForTest) Close()                     { w.q.close() }
+func (w *WriteQueueForTest) Stats() WriteQueueStats     { return w.q.Stats() }
@@ -0,0 +1,230 @@
+package VirtualRouterServer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 两步验证（RFC 6238 TOTP）：HMAC-SHA1、30 秒步长、6 位数字，兼容常见验证器 App
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkewSteps 允许前后各偏差一个步长，容忍客户端时钟误差
+	totpSkewSteps   = 1
+	totpSecretBytes = 20
+	totpIssuer      = "VirtualRouter"
+)
+
+var (
+	errTotpRequired = errors.New("请输入两步验证码")
+	errInvalidTotp  = errors.New("两步验证码错误")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func newTotpSecret() (string, error) {
+	buf := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+func decodeTotpSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	return totpEncoding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+func totpCodeAt(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// totpURL 供验证器 App 扫码添加的 otpauth 链接
+func totpURL(username, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + username)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpState 待确认的绑定密钥与每个账号最近一次使用的步长（同一验证码不能重复使用）
+type totpState struct {
+	mu       sync.Mutex
+	pending  map[string]string
+	lastStep map[string]int64
+}
+
+func newTotpState() *totpState {
+	return &totpState{pending: make(map[string]string), lastStep: make(map[string]int64)}
+}
+
+// verify 校验验证码，成功后记录步长防止重放
+func (t *totpState) verify(username, secret, code string, now time.Time) bool {
+	code = strings.TrimSpace(code)
+	key, err := decodeTotpSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return false
+	}
+	current := now.Unix() / totpPeriod
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for step := current - totpSkewSteps; step <= current+totpSkewSteps; step++ {
+		if step <= t.lastStep[username] {
+			continue
+		}
+		if hmac.Equal([]byte(totpCodeAt(key, step)), []byte(code)) {
+			t.lastStep[username] = step
+			return true
+		}
+	}
+	return false
+}
+
+func (t *totpState) setPending(username, secret string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[username] = secret
+}
+
+func (t *totpState) takePending(username string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	secret := t.pending[username]
+	delete(t.pending, username)
+	return secret
+}
+
+// adminUserTotpSecret 账号已绑定的 TOTP 密钥，单账号模式不支持两步验证
+func (h *HttpServer) adminUserTotpSecret(username string) (string, error) {
+	h.cfgMu.RLock()
+	defer h.cfgMu.RUnlock()
+	if h.legacyAdminModeLocked() {
+		return "", errors.New("当前为单账号模式，请先创建账号后再启用两步验证")
+	}
+	u := h.findAdminUserLocked(username)
+	if u == nil {
+		return "", errAdminUserNotFound
+	}
+	return u.TotpSecret, nil
+}
+
+// setAdminUserTotpSecret 绑定或解除两步验证并写回配置，secret 为空表示解除
+func (h *HttpServer) setAdminUserTotpSecret(username, secret string) error {
+	h.cfgMu.Lock()
+	defer h.cfgMu.Unlock()
+	u := h.findAdminUserLocked(username)
+	if u == nil {
+		return errAdminUserNotFound
+	}
+	prev := u.TotpSecret
+	u.TotpSecret = secret
+	if err := h.persistConfigLocked(); err != nil {
+		u.TotpSecret = prev
+		return err
+	}
+	return nil
+}
+
+// handleTotp 当前账号管理自己的两步验证：GET 查看状态；POST action=setup 生成密钥，
+// action=enable 用验证码确认绑定，action=disable 用验证码解除
+func (h *HttpServer) handleTotp(w http.ResponseWriter, r *http.Request) {
+	username := requestOperator(r)
+	switch r.Method {
+	case http.MethodGet:
+		secret, err := h.adminUserTotpSecret(username)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"success": true,
+			"data":    map[string]any{"enabled": secret != "", "supported": err == nil},
+		})
+	case http.MethodPost:
+		var req struct {
+			Action string `json:"action"`
+			Code   string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请求格式错误"})
+			return
+		}
+		current, err := h.adminUserTotpSecret(username)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": err.Error()})
+			return
+		}
+		switch req.Action {
+		case "setup":
+			if current != "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "已启用两步验证，请先解除"})
+				return
+			}
+			secret, err := newTotpSecret()
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "message": err.Error()})
+				return
+			}
+			h.totp.setPending(username, secret)
+			writeJSON(w, http.StatusOK, map[string]any{
+				"success": true,
+				"message": "请在验证器 App 中添加后输入验证码确认",
+				"data":    map[string]any{"secret": secret, "otpauthUrl": totpURL(username, secret)},
+			})
+		case "enable":
+			pending := h.totp.takePending(username)
+			if pending == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "请先生成两步验证密钥"})
+				return
+			}
+			if !h.totp.verify(username, pending, req.Code, time.Now()) {
+				// 验证失败保留待确认密钥，允许重新输入
+				h.totp.setPending(username, pending)
+				writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": errInvalidTotp.Error()})
+				return
+			}
+			if err := h.setAdminUserTotpSecret(username, pending); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "message": err.Error()})
+				return
+			}
+			componentLog(LogComponentHttp).Info("管理员账号已启用两步验证", "username", username)
+			writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "两步验证已启用"})
+		case "disable":
+			if current == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "未启用两步验证"})
+				return
+			}
+			if !h.totp.verify(username, current, req.Code, time.Now()) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": errInvalidTotp.Error()})
+				return
+			}
+			if err := h.setAdminUserTotpSecret(username, ""); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"success": false, "message": err.Error()})
+				return
+			}
+			componentLog(LogComponentHttp).Info("管理员账号已解除两步验证", "username", username)
+			writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "两步验证已解除"})
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "message": "未知的 action: " + req.Action})
+		}
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"success": false, "message": "method not allowed"})
+	}
+}
@@ -0,0 +1,141 @@
+package VirtualRouterServer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/capture"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+const defaultCaptureDir = "captures"
+
+// CaptureStatus 当前抓包状态；没有运行中的抓包时返回上一次因达到上限自动停止的抓包
+type CaptureStatus struct {
+	Running   bool           `json:"running"`
+	File      string         `json:"file,omitempty"`
+	Filter    capture.Filter `json:"filter"`
+	Limits    capture.Limits `json:"limits"`
+	Frames    int64          `json:"frames"`
+	Bytes     int64          `json:"bytes"`
+	StartedAt int64          `json:"startedAt,omitempty"`
+	// StopReason 达到的上限（maxBytes/maxFrames/maxDuration），手动停止时为空
+	StopReason string `json:"stopReason,omitempty"`
+}
+
+// StartCapture 开始把满足过滤条件的入站帧写入 captureDir 下的 name 文件。
+// name 只取文件名部分，为空时按时间生成；同一时间只允许一个抓包任务。
+// 写入字节数、帧数或时长达到 limits（未设置的项使用默认值）时自动停止。
+func (s *Server) StartCapture(name string, filter capture.Filter, limits capture.Limits) (CaptureStatus, error) {
+	s.captureMu.Lock()
+	defer s.captureMu.Unlock()
+	if s.capture.Load() != nil {
+		return CaptureStatus{}, errors.New("已有抓包任务在运行，请先停止")
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = "capture-" + time.Now().Format("20060102-150405")
+	}
+	if !strings.HasSuffix(name, ".jsonl") {
+		name += ".jsonl"
+	}
+	dir := s.captureDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return CaptureStatus{}, err
+	}
+	w, err := capture.Create(filepath.Join(dir, name), filter, limits)
+	if err != nil {
+		return CaptureStatus{}, err
+	}
+	s.captureStartedAt = time.Now().UnixMilli()
+	s.capture.Store(w)
+	s.lastCapture = nil
+	go s.watchCapture(w)
+	componentLog(LogComponentCapture).Info("开始流量抓包", "file", w.Path(), "routeIds", filter.RouteIds, "messageTypes", filter.MessageTypes, "packetIds", filter.PacketIds, "limits", w.Limits())
+	return s.captureStatusLocked(), nil
+}
+
+// watchCapture 等待抓包达到上限或时长到期后自动停止；手动停止时直接退出
+func (s *Server) watchCapture(w *capture.Writer) {
+	timer := time.NewTimer(time.Duration(w.Limits().MaxDurationMs) * time.Millisecond)
+	defer timer.Stop()
+	var reason string
+	select {
+	case <-w.Done():
+		reason = w.StopReason()
+	case <-timer.C:
+		reason = capture.StopReasonMaxDuration
+	}
+	if reason == "" {
+		return
+	}
+	s.captureMu.Lock()
+	defer s.captureMu.Unlock()
+	if s.capture.Load() != w {
+		return
+	}
+	err := w.Stop(reason)
+	status := s.captureStatusOf(w)
+	status.StopReason = w.StopReason()
+	s.capture.Store(nil)
+	s.lastCapture = &status
+	componentLog(LogComponentCapture).Warn("流量抓包达到上限，已自动停止", "file", w.Path(), "reason", status.StopReason, "frames", status.Frames, "bytes", status.Bytes, "error", err)
+}
+
+// StopCapture 停止抓包并返回最终状态
+func (s *Server) StopCapture() (CaptureStatus, error) {
+	s.captureMu.Lock()
+	defer s.captureMu.Unlock()
+	w := s.capture.Load()
+	if w == nil {
+		return CaptureStatus{}, errors.New("当前没有抓包任务")
+	}
+	status := s.captureStatusLocked()
+	status.Running = false
+	s.capture.Store(nil)
+	if err := w.Close(); err != nil {
+		return status, err
+	}
+	componentLog(LogComponentCapture).Info("流量抓包已停止", "file", w.Path(), "frames", status.Frames, "bytes", status.Bytes)
+	return status, nil
+}
+
+func (s *Server) CaptureStatus() CaptureStatus {
+	s.captureMu.Lock()
+	defer s.captureMu.Unlock()
+	return s.captureStatusLocked()
+}
+
+func (s *Server) captureStatusLocked() CaptureStatus {
+	w := s.capture.Load()
+	if w == nil {
+		if s.lastCapture != nil {
+			return *s.lastCapture
+		}
+		return CaptureStatus{}
+	}
+	status := s.captureStatusOf(w)
+	status.Running = true
+	return status
+}
+
+func (s *Server) captureStatusOf(w *capture.Writer) CaptureStatus {
+	return CaptureStatus{File: w.Path(), Filter: w.Filter(), Limits: w.Limits(), Frames: w.Count(), Bytes: w.Bytes(), StartedAt: s.captureStartedAt}
+}
+
+func (s *Server) captureDir() string {
+	if s.cfg != nil && strings.TrimSpace(s.cfg.CaptureDir) != "" {
+		return s.cfg.CaptureDir
+	}
+	return defaultCaptureDir
+}
+
+// captureInbound 记录节点发给 Router Center 的帧，未开启抓包时只有一次原子读
+func (s *Server) captureInbound(msg *core.RouteMessage) {
+	if w := s.capture.Load(); w != nil {
+		w.Record(capture.DirectionIn, msg.FromRouteId, msg)
+	}
+}
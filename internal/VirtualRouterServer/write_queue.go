@@ -0,0 +1,269 @@
+package VirtualRouterServer
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+const (
+	// WriteOverflowDrop 发送队列满时丢弃新消息，连接保持
+	WriteOverflowDrop = "drop"
+	// WriteOverflowDisconnect 发送队列满时断开节点连接，由节点重连后重新登记
+	WriteOverflowDisconnect = "disconnect"
+
+	defaultWriteQueueMaxMessages = 10000
+	defaultWriteQueueMaxBytes    = 64 << 20
+	defaultWriteTimeout          = 10 * time.Second
+)
+
+var (
+	ErrWriteQueueFull   = errors.New("节点发送队列已满")
+	ErrWriteQueueClosed = errors.New("节点连接已关闭")
+)
+
+// WriteQueuePolicy 每个节点连接的发送队列限制
+type WriteQueuePolicy struct {
+	MaxMessages  int
+	MaxBytes     int64
+	WriteTimeout time.Duration
+	Overflow     string
+}
+
+// writeQueuePolicyFromConfig 读取配置中的发送队列限制，未配置的项使用默认值
+func writeQueuePolicyFromConfig(cfg *config.RouterServerConfig) WriteQueuePolicy {
+	p := WriteQueuePolicy{
+		MaxMessages:  defaultWriteQueueMaxMessages,
+		MaxBytes:     defaultWriteQueueMaxBytes,
+		WriteTimeout: defaultWriteTimeout,
+		Overflow:     WriteOverflowDrop,
+	}
+	if cfg == nil {
+		return p
+	}
+	if cfg.SessionWriteQueueMaxMessages > 0 {
+		p.MaxMessages = cfg.SessionWriteQueueMaxMessages
+	}
+	if cfg.SessionWriteQueueMaxBytes > 0 {
+		p.MaxBytes = cfg.SessionWriteQueueMaxBytes
+	}
+	if cfg.SessionWriteTimeoutMs > 0 {
+		p.WriteTimeout = time.Duration(cfg.SessionWriteTimeoutMs) * time.Millisecond
+	}
+	if cfg.SessionWriteOverflowPolicy == WriteOverflowDisconnect {
+		p.Overflow = WriteOverflowDisconnect
+	}
+	return p
+}
+
+// WriteQueueStats 发送队列状态，Depth/Bytes 包含正在写出的消息
+type WriteQueueStats struct {
+	Depth     int    `json:"depth"`
+	Bytes     int64  `json:"bytes"`
+	HighWater int    `json:"highWater"`
+	Sent      uint64 `json:"sent"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// writeQueue 节点连接的出站队列：转发方只负责入队，由独立的 goroutine 按顺序写出，
+// 慢节点不会阻塞其他节点的读取 goroutine
+type writeQueue struct {
+	conn    net.Conn
+	policy  func() WriteQueuePolicy
+	routeId atomic.Pointer[string]
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	frames    [][]byte
+	bytes     int64
+	highWater int
+	full      bool
+	draining  bool
+	closed    bool
+	// reason/detail 由 Router Center 主动关闭连接的原因，先记录的优先
+	reason string
+	detail string
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+}
+
+func newWriteQueue(conn net.Conn, policy func() WriteQueuePolicy) *writeQueue {
+	q := &writeQueue{conn: conn, policy: policy}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *writeQueue) setRouteId(routeId string) {
+	q.routeId.Store(&routeId)
+}
+
+func (q *writeQueue) routeIdForLog() string {
+	if p := q.routeId.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+func (q *writeQueue) remoteAddr() string {
+	if q.conn == nil || q.conn.RemoteAddr() == nil {
+		return ""
+	}
+	return q.conn.RemoteAddr().String()
+}
+
+// enqueue 追加一帧；超出限制时按策略丢弃或断开连接
+func (q *writeQueue) enqueue(frame []byte) error {
+	p := q.policy()
+	q.mu.Lock()
+	if q.closed || q.draining {
+		q.mu.Unlock()
+		return ErrWriteQueueClosed
+	}
+	size := int64(len(frame))
+	overflow := (p.MaxMessages > 0 && len(q.frames) >= p.MaxMessages) ||
+		(p.MaxBytes > 0 && len(q.frames) > 0 && q.bytes+size > p.MaxBytes)
+	if overflow {
+		q.dropped.Add(1)
+		depth, bytes := len(q.frames), q.bytes
+		if p.Overflow == WriteOverflowDisconnect {
+			q.mu.Unlock()
+			componentLog(LogComponentSession).Warn("节点发送队列已满，断开连接", "routeId", q.routeIdForLog(), "remote", q.remoteAddr(), "depth", depth, "bytes", bytes)
+			q.closeWith(ConnCloseQueueFull, "")
+			return ErrWriteQueueFull
+		}
+		first := !q.full
+		q.full = true
+		q.mu.Unlock()
+		if first {
+			componentLog(LogComponentSession).Warn("节点发送队列已满，开始丢弃消息", "routeId", q.routeIdForLog(), "remote", q.remoteAddr(), "depth", depth, "bytes", bytes)
+		}
+		return ErrWriteQueueFull
+	}
+	q.frames = append(q.frames, frame)
+	q.bytes += size
+	q.highWater = max(q.highWater, len(q.frames))
+	q.cond.Signal()
+	q.mu.Unlock()
+	return nil
+}
+
+// run 写出队列中的消息，写超时或失败时关闭连接，由读取 goroutine 负责移除会话
+func (q *writeQueue) run() {
+	for {
+		q.mu.Lock()
+		for len(q.frames) == 0 && !q.closed && !q.draining {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		if len(q.frames) == 0 {
+			q.mu.Unlock()
+			q.close()
+			return
+		}
+		frame := q.frames[0]
+		q.mu.Unlock()
+
+		if timeout := q.policy().WriteTimeout; timeout > 0 {
+			_ = q.conn.SetWriteDeadline(time.Now().Add(timeout))
+		}
+		if _, err := q.conn.Write(frame); err != nil {
+			if !q.isClosed() {
+				componentLog(LogComponentSession).Warn("节点发送失败，断开连接", "routeId", q.routeIdForLog(), "remote", q.remoteAddr(), "error", err, "pending", q.Stats().Depth)
+			}
+			q.closeWith(ConnCloseWriteFailed, err.Error())
+			return
+		}
+		q.sent.Add(1)
+
+		q.mu.Lock()
+		// 写出期间连接可能已被关闭，close 已清空队列与字节数
+		if q.closed || len(q.frames) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		q.frames[0] = nil
+		q.frames = q.frames[1:]
+		q.bytes -= int64(len(frame))
+		if len(q.frames) == 0 {
+			q.frames = nil
+		}
+		recovered := q.full && len(q.frames) == 0
+		if recovered {
+			q.full = false
+		}
+		q.mu.Unlock()
+		if recovered {
+			componentLog(LogComponentSession).Info("节点发送队列已清空", "routeId", q.routeIdForLog(), "dropped", q.dropped.Load())
+		}
+	}
+}
+
+// closeAfterFlush 不再接受新消息，已入队的消息写出后关闭连接
+func (q *writeQueue) closeAfterFlush() {
+	q.mu.Lock()
+	q.draining = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// setCloseReason 记录主动关闭连接的原因，已有原因时保持不变
+func (q *writeQueue) setCloseReason(reason, detail string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.reason == "" {
+		q.reason, q.detail = reason, detail
+	}
+}
+
+func (q *writeQueue) closeReason() (string, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.reason, q.detail
+}
+
+// closeWith 记录原因后关闭
+func (q *writeQueue) closeWith(reason, detail string) {
+	q.setCloseReason(reason, detail)
+	q.close()
+}
+
+// close 丢弃未写出的消息并关闭连接
+func (q *writeQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.frames = nil
+	q.bytes = 0
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	_ = q.conn.Close()
+}
+
+func (q *writeQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+func (q *writeQueue) Stats() WriteQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return WriteQueueStats{
+		Depth:     len(q.frames),
+		Bytes:     q.bytes,
+		HighWater: q.highWater,
+		Sent:      q.sent.Load(),
+		Dropped:   q.dropped.Load(),
+	}
+}
@@ -0,0 +1,373 @@
+// Package capture 录制 Router Center 收到的 RouteMessage 帧，供 cmd/router-replay 回放。
+//
+// 抓包文件为 JSON Lines（UTF-8，每行一个 JSON 对象）：
+//
+//	第 1 行为文件头:
+//	{"format":"virtual-router-capture","version":1,"startedAt":1700000000000,"filter":{...}}
+//	之后每行一帧:
+//	{"ts":1700000000123,"dir":"in","session":"game-1","from":"game-1","to":"chat-1",
+//	 "type":"RpcRequest","typeOrdinal":3,"packetId":1001,"data":"{...}"}
+//
+// ts 为 Unix 毫秒；dir 目前只有 "in"（节点发给 Router Center 的帧）；
+// data 为原始 Data 字段，nil 时省略；packetId 只在 RpcRequest/RpcResponse 上出现。
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+const (
+	FormatName = "virtual-router-capture"
+	Version    = 1
+
+	DirectionIn = "in"
+)
+
+// Filter 录制过滤条件，各字段为空表示不过滤；字段之间为「且」关系
+type Filter struct {
+	RouteIds     []string `json:"routeIds,omitempty"`
+	MessageTypes []string `json:"messageTypes,omitempty"`
+	PacketIds    []int    `json:"packetIds,omitempty"`
+}
+
+// 抓包默认上限，避免忘记停止的抓包写满磁盘
+const (
+	DefaultMaxBytes      = 256 * 1024 * 1024
+	DefaultMaxFrames     = 1_000_000
+	DefaultMaxDurationMs = int64(time.Hour / time.Millisecond)
+)
+
+// 达到上限自动停止的原因
+const (
+	StopReasonMaxBytes    = "maxBytes"
+	StopReasonMaxFrames   = "maxFrames"
+	StopReasonMaxDuration = "maxDuration"
+)
+
+// Limits 单次抓包的上限，任一项达到即停止；<=0 使用默认值
+type Limits struct {
+	MaxBytes      int64 `json:"maxBytes"`
+	MaxFrames     int64 `json:"maxFrames"`
+	MaxDurationMs int64 `json:"maxDurationMs"`
+}
+
+// WithDefaults 未设置的项填入默认值
+func (l Limits) WithDefaults() Limits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultMaxBytes
+	}
+	if l.MaxFrames <= 0 {
+		l.MaxFrames = DefaultMaxFrames
+	}
+	if l.MaxDurationMs <= 0 {
+		l.MaxDurationMs = DefaultMaxDurationMs
+	}
+	return l
+}
+
+// Header 抓包文件头
+type Header struct {
+	Format    string `json:"format"`
+	Version   int    `json:"version"`
+	StartedAt int64  `json:"startedAt"`
+	Filter    Filter `json:"filter"`
+}
+
+// Record 抓包文件中的一帧
+type Record struct {
+	Ts          int64   `json:"ts"`
+	Dir         string  `json:"dir"`
+	Session     string  `json:"session"`
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Type        string  `json:"type"`
+	TypeOrdinal int32   `json:"typeOrdinal"`
+	PacketId    int     `json:"packetId,omitempty"`
+	Data        *string `json:"data,omitempty"`
+}
+
+// Matches 判断某个会话上的消息是否满足过滤条件
+func (f Filter) Matches(sessionRouteId string, msg *core.RouteMessage) bool {
+	if msg == nil || msg.MessageType == nil {
+		return false
+	}
+	if len(f.RouteIds) > 0 && !slices.Contains(f.RouteIds, sessionRouteId) && !slices.Contains(f.RouteIds, msg.ToRouteId) {
+		return false
+	}
+	if len(f.MessageTypes) > 0 && !containsFold(f.MessageTypes, msg.MessageType.String()) {
+		return false
+	}
+	if len(f.PacketIds) > 0 {
+		packetId := PacketIdOf(msg)
+		if packetId == 0 || !slices.Contains(f.PacketIds, packetId) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate 检查过滤条件里的消息类型是否合法
+func (f Filter) Validate() error {
+	for _, name := range f.MessageTypes {
+		if _, ok := MessageTypeByName(name); !ok {
+			return fmt.Errorf("未知的消息类型: %s", name)
+		}
+	}
+	return nil
+}
+
+// PacketIdOf 取 RpcRequest/RpcResponse 的 packetId，其他消息返回 0
+func PacketIdOf(msg *core.RouteMessage) int {
+	if msg.MessageType == nil || msg.Data == nil {
+		return 0
+	}
+	if *msg.MessageType != core.RouteMessageTypeRpcRequest && *msg.MessageType != core.RouteMessageTypeRpcResponse {
+		return 0
+	}
+	var v struct {
+		PacketId int `json:"packetId"`
+	}
+	if err := json.Unmarshal([]byte(*msg.Data), &v); err != nil {
+		return 0
+	}
+	return v.PacketId
+}
+
+// MessageTypeByName 按名称（忽略大小写）查找消息类型
+func MessageTypeByName(name string) (core.RouteMessageType, bool) {
+	for t := core.RouteMessageTypeHeartBeat; t <= core.RouteMessageTypeSystemError; t++ {
+		if strings.EqualFold(t.String(), strings.TrimSpace(name)) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecord 把一帧消息转换为抓包记录
+func NewRecord(ts time.Time, dir, sessionRouteId string, msg *core.RouteMessage) Record {
+	rec := Record{
+		Ts:       ts.UnixMilli(),
+		Dir:      dir,
+		Session:  sessionRouteId,
+		From:     msg.FromRouteId,
+		To:       msg.ToRouteId,
+		PacketId: PacketIdOf(msg),
+		Data:     msg.Data,
+	}
+	if msg.MessageType != nil {
+		rec.Type = msg.MessageType.String()
+		rec.TypeOrdinal = int32(*msg.MessageType)
+	}
+	return rec
+}
+
+// RouteMessage 还原为可重新发送的 RouteMessage
+func (r Record) RouteMessage() (*core.RouteMessage, error) {
+	mt, ok := core.RouteMessageTypeFromOrdinal(r.TypeOrdinal)
+	if !ok {
+		return nil, fmt.Errorf("未知的消息类型序号: %d", r.TypeOrdinal)
+	}
+	return &core.RouteMessage{FromRouteId: r.From, ToRouteId: r.To, MessageType: mt, Data: r.Data}, nil
+}
+
+// Writer 线程安全地向抓包文件追加记录，写入的字节数或帧数达到上限时自行关闭
+type Writer struct {
+	mu     sync.Mutex
+	file   *os.File
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	filter Filter
+	limits Limits
+	path   string
+	count  int64
+	bytes  int64
+	closed bool
+	// stopReason 因达到上限而停止的原因，手动关闭时为空
+	stopReason string
+	done       chan struct{}
+}
+
+// countingWriter 统计写入文件的字节数
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// Create 新建抓包文件并写入文件头，已存在的同名文件会被覆盖；limits 未设置的项使用默认值
+func Create(path string, filter Filter, limits Limits) (*Writer, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	w := &Writer{file: f, buf: buf, filter: filter, limits: limits.WithDefaults(), path: path, done: make(chan struct{})}
+	w.enc = json.NewEncoder(countingWriter{w: buf, n: &w.bytes})
+	if err := w.enc.Encode(Header{Format: FormatName, Version: Version, StartedAt: time.Now().UnixMilli(), Filter: filter}); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Record 满足过滤条件时写入一帧，返回是否写入
+func (w *Writer) Record(dir, sessionRouteId string, msg *core.RouteMessage) bool {
+	if !w.filter.Matches(sessionRouteId, msg) {
+		return false
+	}
+	rec := NewRecord(time.Now(), dir, sessionRouteId, msg)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	if err := w.enc.Encode(rec); err != nil {
+		return false
+	}
+	w.count++
+	switch {
+	case w.bytes >= w.limits.MaxBytes:
+		_ = w.stopLocked(StopReasonMaxBytes)
+	case w.count >= w.limits.MaxFrames:
+		_ = w.stopLocked(StopReasonMaxFrames)
+	}
+	return true
+}
+
+func (w *Writer) Path() string {
+	return w.path
+}
+
+func (w *Writer) Filter() Filter {
+	return w.filter
+}
+
+func (w *Writer) Limits() Limits {
+	return w.limits
+}
+
+// Count 已写入的帧数
+func (w *Writer) Count() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Bytes 已写入的字节数（含文件头）
+func (w *Writer) Bytes() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.bytes
+}
+
+// StopReason 因达到上限停止的原因，未停止或手动关闭时为空
+func (w *Writer) StopReason() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopReason
+}
+
+// Done 文件关闭后关闭，无论是达到上限还是手动停止
+func (w *Writer) Done() <-chan struct{} {
+	return w.done
+}
+
+// Close 刷盘并关闭文件，可重复调用
+func (w *Writer) Close() error {
+	return w.Stop("")
+}
+
+// Stop 以 reason 停止抓包并关闭文件，已关闭时不做任何事
+func (w *Writer) Stop(reason string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopLocked(reason)
+}
+
+func (w *Writer) stopLocked(reason string) error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.stopReason = reason
+	defer close(w.done)
+	if err := w.buf.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// ReadAll 读取整个抓包文件
+func ReadAll(r io.Reader) (Header, []Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), core.MaxFrameSize*2)
+	var header Header
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return header, nil, err
+		}
+		return header, nil, errors.New("抓包文件为空")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, nil, fmt.Errorf("文件头解析失败: %w", err)
+	}
+	if header.Format != FormatName {
+		return header, nil, fmt.Errorf("不是抓包文件: format=%q", header.Format)
+	}
+	if header.Version > Version {
+		return header, nil, fmt.Errorf("不支持的抓包文件版本: %d", header.Version)
+	}
+	var records []Record
+	line := 1
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return header, records, fmt.Errorf("第 %d 行解析失败: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return header, records, scanner.Err()
+}
+
+// ReadFile 读取指定路径的抓包文件
+func ReadFile(path string) (Header, []Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	defer f.Close()
+	return ReadAll(f)
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// ReplayOptions 回放参数
+type ReplayOptions struct {
+	// Speed 回放倍速：1 为按原始间隔，2 为两倍速，<=0 表示不等待、尽快发送
+	Speed float64
+	// OnFrame 收到对端返回的帧时回调（可选），payload 为去掉长度前缀的内容
+	OnFrame func(session string, payload []byte)
+	// DialTimeout 建立连接的超时，默认 5 秒
+	DialTimeout time.Duration
+}
+
+// ReplayResult 回放统计
+type ReplayResult struct {
+	Sent     int `json:"sent"`
+	Skipped  int `json:"skipped"`
+	Sessions int `json:"sessions"`
+}
+
+// ReplayToCenter 把抓包记录按原始顺序发送到 Router Center，每个 session 使用独立的 TCP 连接，
+// 这样测试中心看到的节点身份与生产一致。
+func ReplayToCenter(ctx context.Context, addr string, records []Record, opts ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+	conns := map[string]net.Conn{}
+	var wg sync.WaitGroup
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		wg.Wait()
+	}()
+
+	err := replayTimed(ctx, records, opts.Speed, func(rec Record) error {
+		msg, err := rec.RouteMessage()
+		if err != nil {
+			result.Skipped++
+			return nil
+		}
+		session := rec.Session
+		if session == "" {
+			session = rec.From
+		}
+		conn, ok := conns[session]
+		if !ok {
+			conn, err = net.DialTimeout("tcp", addr, dialTimeout(opts))
+			if err != nil {
+				return err
+			}
+			conns[session] = conn
+			wg.Add(1)
+			go func(session string, conn net.Conn) {
+				defer wg.Done()
+				drainFrames(session, conn, opts.OnFrame)
+			}(session, conn)
+		}
+		payload, err := msg.EncodePayload()
+		if err != nil {
+			result.Skipped++
+			return nil
+		}
+		if err := core.WriteFrame(conn, payload); err != nil {
+			return err
+		}
+		result.Sent++
+		return nil
+	})
+	result.Sessions = len(conns)
+	return result, err
+}
+
+// ReplayToStub 只回放 RpcRequest，直接发给节点 direct 模式的 StubServer（不经过 Router Center）
+func ReplayToStub(ctx context.Context, addr string, records []Record, opts ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+	requests := make([]Record, 0, len(records))
+	for _, rec := range records {
+		if rec.TypeOrdinal == int32(core.RouteMessageTypeRpcRequest) && rec.Data != nil {
+			requests = append(requests, rec)
+		} else {
+			result.Skipped++
+		}
+	}
+	if len(requests) == 0 {
+		return result, errors.New("抓包文件中没有可回放的 RpcRequest")
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout(opts))
+	if err != nil {
+		return result, err
+	}
+	result.Sessions = 1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		drainFrames(addr, conn, opts.OnFrame)
+	}()
+
+	err = replayTimed(ctx, requests, opts.Speed, func(rec Record) error {
+		if err := core.WriteFrame(conn, []byte(*rec.Data)); err != nil {
+			return err
+		}
+		result.Sent++
+		return nil
+	})
+	// 给节点留一点时间返回最后几条响应
+	if err == nil && opts.OnFrame != nil {
+		waitQuiet(ctx, 500*time.Millisecond)
+	}
+	_ = conn.Close()
+	<-done
+	return result, err
+}
+
+// replayTimed 按记录的时间间隔（除以倍速）依次调用 send
+func replayTimed(ctx context.Context, records []Record, speed float64, send func(Record) error) error {
+	var prevTs int64
+	for i, rec := range records {
+		if i > 0 && speed > 0 && rec.Ts > prevTs {
+			delay := time.Duration(float64(rec.Ts-prevTs) * float64(time.Millisecond) / speed)
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		prevTs = rec.Ts
+		if err := send(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func drainFrames(session string, conn net.Conn, onFrame func(string, []byte)) {
+	for {
+		payload, err := core.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		if onFrame != nil {
+			onFrame(session, payload)
+		}
+	}
+}
+
+func waitQuiet(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func dialTimeout(opts ReplayOptions) time.Duration {
+	if opts.DialTimeout > 0 {
+		return opts.DialTimeout
+	}
+	return 5 * time.Second
+}
+
+// DescribeFrame 把回放时收到的帧转换为便于打印的文本：StubServer 返回 JSON，Router Center 返回 RouteMessage
+func DescribeFrame(payload []byte) string {
+	if json.Valid(payload) {
+		return string(payload)
+	}
+	if msg, err := core.DecodeRouteMessagePayload(payload); err == nil && msg.MessageType != nil {
+		data := ""
+		if msg.Data != nil {
+			data = *msg.Data
+		}
+		return msg.MessageType.String() + " " + msg.FromRouteId + " -> " + msg.ToRouteId + " " + data
+	}
+	return "<binary " + strconv.Itoa(len(payload)) + " bytes>"
+}
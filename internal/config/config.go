@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"strings"
 )
@@ -18,8 +19,184 @@ type RouterServerConfig struct {
 	RouterServerPort int `json:"routerServerPort"`
 	// HTTP 监控/管理端口，提供后台界面或 API
 	HTTPMonitorPort int `json:"httpMonitorPort"`
-	// 管理员密码，用于登录监控后台
+	// 管理员密码，用于登录监控后台；未配置 adminUsers 时作为内置 admin 账号的密码
 	AdminPassword string `json:"adminPassword"`
+	// 管理后台账号，配置后 adminPassword 不再用于登录
+	AdminUsers []AdminUser `json:"adminUsers,omitempty"`
+	// HTTP RPC 网关调用默认超时（毫秒），默认 10000
+	GatewayTimeoutMs int64 `json:"gatewayTimeoutMs,omitempty"`
+	// HTTP RPC 网关的 API Key，未配置时网关只接受管理员 Token
+	GatewayApiKeys []GatewayApiKey `json:"gatewayApiKeys,omitempty"`
+	// RPC 调试历史持久化文件，为空时只保存在内存
+	DebugHistoryFile string `json:"debugHistoryFile,omitempty"`
+	// RPC 调试历史保留条数，默认 500
+	DebugHistoryLimit int `json:"debugHistoryLimit,omitempty"`
+	// 管理后台审计日志文件（JSON 行，只追加），为空时只保存在内存
+	AuditLogFile string `json:"auditLogFile,omitempty"`
+	// 审计日志在内存中保留、可在后台查询的条数，默认 2000
+	AuditLogLimit int `json:"auditLogLimit,omitempty"`
+	// 流量抓包文件目录，默认 captures
+	CaptureDir string `json:"captureDir,omitempty"`
+	// 链路追踪导出配置，为空时不导出
+	Tracing *TracingConfig `json:"tracing,omitempty"`
+	// 进程日志配置，为空时使用默认值
+	Log *LogConfig `json:"log,omitempty"`
+	// 管理后台 Token 签名密钥配置，为空时使用默认值
+	JWT *JWTConfig `json:"jwt,omitempty"`
+	// 配置文件变更检查间隔（毫秒），默认 2000；小于 0 关闭自动重载，仍可通过 SIGHUP 或管理后台重载
+	ConfigReloadIntervalMs int64 `json:"configReloadIntervalMs,omitempty"`
+	// 节点未上报心跳间隔时的会话超时（毫秒），默认 30000
+	SessionTimeoutMs int64 `json:"sessionTimeoutMs,omitempty"`
+	// 会话心跳检查间隔（毫秒），默认 1000
+	HeartbeatSweepIntervalMs int64 `json:"heartbeatSweepIntervalMs,omitempty"`
+	// 连续错过多少次心跳后移除会话，默认 3；错过次数少一次时标记为疑似下线
+	HeartbeatMissLimit int `json:"heartbeatMissLimit,omitempty"`
+	// 每个节点连接发送队列的消息条数上限，默认 10000
+	SessionWriteQueueMaxMessages int `json:"sessionWriteQueueMaxMessages,omitempty"`
+	// 每个节点连接发送队列的字节数上限，默认 64MB
+	SessionWriteQueueMaxBytes int64 `json:"sessionWriteQueueMaxBytes,omitempty"`
+	// 向节点写出单条消息的超时（毫秒），超时后断开连接，默认 10000
+	SessionWriteTimeoutMs int64 `json:"sessionWriteTimeoutMs,omitempty"`
+	// 发送队列满时的处理：'drop' 丢弃新消息（默认）或 'disconnect' 断开节点连接
+	SessionWriteOverflowPolicy string `json:"sessionWriteOverflowPolicy,omitempty"`
+	// 节点限流配置，为空表示不限流
+	RateLimit *RateLimitConfig `json:"rateLimit,omitempty"`
+	// Router 端口同时保持的连接数上限，默认 10000，小于 0 不限制
+	MaxConnections int `json:"maxConnections,omitempty"`
+	// 同一来源 IP 的连接数上限，默认不限制
+	MaxConnectionsPerIp int `json:"maxConnectionsPerIp,omitempty"`
+	// 新连接完成注册（首次完整心跳）的时限（毫秒），默认 10000，小于 0 不限制
+	RegistrationTimeoutMs int64 `json:"registrationTimeoutMs,omitempty"`
+	// 已注册连接多久没有收到任何消息后断开（毫秒），默认 300000，应大于心跳超时；小于 0 不限制
+	ConnIdleTimeoutMs int64 `json:"connIdleTimeoutMs,omitempty"`
+}
+
+// RateLimitConfig 按 routeId 的令牌桶限流，只限制节点发出的消息与 RPC 请求，心跳与 RPC 响应不受限制
+type RateLimitConfig struct {
+	// 所有节点的默认限制
+	Default RateLimitRule `json:"default"`
+	// 按 routeId 覆盖默认限制，整条规则替换默认值
+	Nodes map[string]RateLimitRule `json:"nodes,omitempty"`
+}
+
+// RateLimitRule 每秒速率，0 表示不限制；令牌桶容量为速率 × burstSeconds
+type RateLimitRule struct {
+	// 消息数（MessageData 与 RpcRequest）
+	MessagesPerSecond float64 `json:"messagesPerSecond,omitempty"`
+	// 消息字节数
+	BytesPerSecond float64 `json:"bytesPerSecond,omitempty"`
+	// RPC 请求数
+	RpcPerSecond float64 `json:"rpcPerSecond,omitempty"`
+	// 按 packetId 限制 RPC 请求数
+	PacketRpcPerSecond map[int]float64 `json:"packetRpcPerSecond,omitempty"`
+	// 允许的突发时长（秒），默认 1
+	BurstSeconds float64 `json:"burstSeconds,omitempty"`
+}
+
+// RuleFor 返回节点生效的限流规则
+func (c *RateLimitConfig) RuleFor(routeId string) RateLimitRule {
+	if rule, ok := c.Nodes[routeId]; ok {
+		return rule
+	}
+	return c.Default
+}
+
+func (r RateLimitRule) check() error {
+	if r.MessagesPerSecond < 0 || r.BytesPerSecond < 0 || r.RpcPerSecond < 0 || r.BurstSeconds < 0 {
+		return errors.New("限流速率与 burstSeconds 不能为负数")
+	}
+	for packetId, rate := range r.PacketRpcPerSecond {
+		if rate < 0 {
+			return fmt.Errorf("packetId %d 的限流速率不能为负数", packetId)
+		}
+	}
+	return nil
+}
+
+// JWTConfig 管理后台 Token 签名密钥配置
+type JWTConfig struct {
+	// 固定签名密钥，多个 Router Center 共享 Token 时使用；配置后不读取 keyFile，也不支持轮换
+	Secret string `json:"secret,omitempty"`
+	// 密钥文件，首次启动自动生成随机密钥，默认 jwt-keys.json
+	KeyFile string `json:"keyFile,omitempty"`
+	// 自动轮换间隔（小时），0 表示只在管理后台手动轮换
+	RotateIntervalHours int `json:"rotateIntervalHours,omitempty"`
+	// 轮换后旧密钥继续用于校验的时长（小时），默认 24，与 Token 有效期一致
+	GracePeriodHours int `json:"gracePeriodHours,omitempty"`
+}
+
+// AdminUser 管理后台账号
+type AdminUser struct {
+	Username string `json:"username"`
+	// bcrypt 哈希
+	PasswordHash string `json:"passwordHash,omitempty"`
+	// 明文密码，仅便于手工编辑配置；启动时会被哈希，下次写回配置时替换为 passwordHash
+	Password string `json:"password,omitempty"`
+	// 角色：viewer 只读，operator 可发送调试 RPC 与抓包，admin 可修改设置与管理账号
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled,omitempty"`
+	// 两步验证（TOTP）密钥，Base32 编码；为空表示未启用，可在管理后台绑定
+	TotpSecret string `json:"totpSecret,omitempty"`
+	// 创建时间（Unix 毫秒）
+	CreatedAt int64 `json:"createdAt,omitempty"`
+}
+
+// LogConfig Router Center 进程日志配置
+type LogConfig struct {
+	// 输出格式：'text' 或 'json'，默认 text
+	Format string `json:"format,omitempty"`
+	// 默认级别：debug / info / warn / error，默认 info
+	Level string `json:"level,omitempty"`
+	// 按组件单独设置级别，例如 {"gateway": "debug"}，可在管理后台运行时修改
+	ComponentLevels map[string]string `json:"componentLevels,omitempty"`
+	// 日志文件目录，默认 logs
+	Dir string `json:"dir,omitempty"`
+	// 日志文件名，默认 router-center.log
+	FileName string `json:"fileName,omitempty"`
+	// 单个日志文件大小上限（MB），默认 20
+	MaxFileSizeMB int64 `json:"maxFileSizeMB,omitempty"`
+	// 保留的日志文件数（含当前文件），默认 5
+	MaxFiles int `json:"maxFiles,omitempty"`
+	// 轮转出去的日志文件是否 gzip 压缩（.gz），历史日志检索可直接读取
+	CompressRotated bool `json:"compressRotated,omitempty"`
+	// 管理后台可查看的内存日志条数，默认 800
+	BufferCapacity int `json:"bufferCapacity,omitempty"`
+}
+
+// TracingConfig 链路追踪导出配置
+type TracingConfig struct {
+	// 导出方式：'otlp' (OTLP/HTTP JSON) 或 'stdout'，为空表示不导出
+	Exporter string `json:"exporter"`
+	// OTLP Collector 地址，例如 http://127.0.0.1:4318
+	Endpoint string `json:"endpoint,omitempty"`
+	// 上报的 service.name，默认 Router Center 为 virtual-router-center，节点为 routeId
+	ServiceName string `json:"serviceName,omitempty"`
+	// 附加到 OTLP 请求上的 HTTP 头，例如鉴权
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// GatewayApiKey HTTP RPC 网关的访问凭证
+type GatewayApiKey struct {
+	// 名称，仅用于日志与审计
+	Name string `json:"name"`
+	// 请求头 X-Api-Key 携带的密钥
+	Key string `json:"key"`
+	// 允许调用的 routeId，支持 "*" 与前缀通配 "game-*"，为空表示不允许任何节点
+	AllowRoutes []string `json:"allowRoutes"`
+}
+
+// AllowRoute 判断该 Key 是否允许调用指定节点
+func (k GatewayApiKey) AllowRoute(routeId string) bool {
+	for _, pattern := range k.AllowRoutes {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" || pattern == routeId {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && prefix != "" && strings.HasPrefix(routeId, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // RouterClientConfig 路由客户端配置
@@ -40,6 +217,8 @@ type RouterClientConfig struct {
 	HeartBeatIntervalSecond int64 `json:"heartBeatIntervalSecond"`
 	// 断线重连尝试间隔（毫秒）
 	ReconnectIntervalMs int64 `json:"reconnectIntervalMs"`
+	// 链路追踪导出配置，为空时不导出
+	Tracing *TracingConfig `json:"tracing,omitempty"`
 }
 
 func ReadRouterServerConfig(fileName string) (*RouterServerConfig, error) {
@@ -47,20 +226,45 @@ func ReadRouterServerConfig(fileName string) (*RouterServerConfig, error) {
 		fileName = RouterServerConfigName
 	}
 	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
-		cfg := &RouterServerConfig{RouterServerPort: 9999, HTTPMonitorPort: 19999, AdminPassword: "root"}
+		cfg := &RouterServerConfig{RouterServerPort: 9999, HTTPMonitorPort: 19999, AdminPassword: "root", AuditLogFile: "logs/audit.log"}
 		_ = writeDefault(fileName, cfg)
 		return nil, errors.New("没有在当前路径找到配置文件, 自动给你生成了一个 " + fileName + ", 配置好后再启动项目!")
 	}
+	return LoadRouterServerConfig(fileName)
+}
+
+// LoadRouterServerConfig 读取已存在的配置文件，文件不存在时不会自动生成；用于启动与热更新
+func LoadRouterServerConfig(fileName string) (*RouterServerConfig, error) {
+	if fileName == "" {
+		fileName = RouterServerConfigName
+	}
 	data, err := os.ReadFile(fileName)
 	if err != nil {
 		return nil, err
 	}
+	cfg, err := ParseRouterServerConfig(data)
+	if err != nil {
+		return nil, errors.New("请再检查一下 " + fileName + " 的配置, " + err.Error())
+	}
+	return cfg, nil
+}
+
+// ParseRouterServerConfig 解析配置内容，补齐默认值并校验
+func ParseRouterServerConfig(data []byte) (*RouterServerConfig, error) {
 	cfg := &RouterServerConfig{}
 	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.Normalize(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Normalize 补齐默认值并校验
+func (cfg *RouterServerConfig) Normalize() error {
 	if cfg.RouterServerPort == 0 {
-		return nil, errors.New("请再检查一下 " + fileName + " 的配置, 不允许 routerServerPort = 0")
+		return errors.New("不允许 routerServerPort = 0")
 	}
 	if cfg.HTTPMonitorPort == 0 {
 		cfg.HTTPMonitorPort = 19999
@@ -68,7 +272,86 @@ func ReadRouterServerConfig(fileName string) (*RouterServerConfig, error) {
 	if cfg.AdminPassword == "" {
 		cfg.AdminPassword = "neko233"
 	}
-	return cfg, nil
+	if cfg.GatewayTimeoutMs <= 0 {
+		cfg.GatewayTimeoutMs = 10000
+	}
+	return cfg.Check()
+}
+
+// Check 校验配置的取值范围与账号、API Key 的一致性
+func (cfg *RouterServerConfig) Check() error {
+	for _, port := range []int{cfg.RouterServerPort, cfg.HTTPMonitorPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("端口超出范围: %d", port)
+		}
+	}
+	if cfg.GatewayTimeoutMs < 0 {
+		return errors.New("gatewayTimeoutMs 不能为负数")
+	}
+	if cfg.SessionTimeoutMs < 0 || cfg.HeartbeatSweepIntervalMs < 0 || cfg.HeartbeatMissLimit < 0 {
+		return errors.New("sessionTimeoutMs / heartbeatSweepIntervalMs / heartbeatMissLimit 不能为负数")
+	}
+	if cfg.SessionWriteQueueMaxMessages < 0 || cfg.SessionWriteQueueMaxBytes < 0 || cfg.SessionWriteTimeoutMs < 0 {
+		return errors.New("sessionWriteQueueMaxMessages / sessionWriteQueueMaxBytes / sessionWriteTimeoutMs 不能为负数")
+	}
+	switch cfg.SessionWriteOverflowPolicy {
+	case "", "drop", "disconnect":
+	default:
+		return errors.New("sessionWriteOverflowPolicy 只能是 drop 或 disconnect: " + cfg.SessionWriteOverflowPolicy)
+	}
+	if cfg.MaxConnectionsPerIp < 0 {
+		return errors.New("maxConnectionsPerIp 不能为负数")
+	}
+	if cfg.RateLimit != nil {
+		if err := cfg.RateLimit.Default.check(); err != nil {
+			return fmt.Errorf("rateLimit.default: %w", err)
+		}
+		for routeId, rule := range cfg.RateLimit.Nodes {
+			if err := rule.check(); err != nil {
+				return fmt.Errorf("rateLimit.nodes[%s]: %w", routeId, err)
+			}
+		}
+	}
+	usernames := make(map[string]bool, len(cfg.AdminUsers))
+	hasAdmin := false
+	for _, u := range cfg.AdminUsers {
+		name := strings.TrimSpace(u.Username)
+		if name == "" {
+			return errors.New("adminUsers 中存在空的 username")
+		}
+		if usernames[name] {
+			return errors.New("adminUsers 中账号重复: " + name)
+		}
+		usernames[name] = true
+		switch u.Role {
+		case "", "viewer", "operator", "admin":
+		default:
+			return errors.New("adminUsers 中账号 " + name + " 的角色未知: " + u.Role)
+		}
+		if u.PasswordHash == "" && u.Password == "" {
+			return errors.New("adminUsers 中账号 " + name + " 没有配置密码")
+		}
+		if u.Role == "admin" && !u.Disabled {
+			hasAdmin = true
+		}
+	}
+	if len(cfg.AdminUsers) > 0 && !hasAdmin {
+		return errors.New("adminUsers 中至少需要一个启用的 admin 账号")
+	}
+	keys := make(map[string]bool, len(cfg.GatewayApiKeys))
+	for _, k := range cfg.GatewayApiKeys {
+		if strings.TrimSpace(k.Key) == "" {
+			return errors.New("gatewayApiKeys 中 " + k.Name + " 的 key 为空")
+		}
+		if keys[k.Key] {
+			return errors.New("gatewayApiKeys 中存在重复的 key: " + k.Name)
+		}
+		keys[k.Key] = true
+	}
+	if cfg.JWT != nil && (cfg.JWT.GracePeriodHours < 0 || cfg.JWT.RotateIntervalHours < 0) {
+		return errors.New("jwt.gracePeriodHours 与 jwt.rotateIntervalHours 不能为负数")
+	}
+	return nil
 }
 
 func NewDefaultRouterClientConfig() *RouterClientConfig {
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// RouteTableDelta 路由表增量：FromVersion 之后到 Version 的变更；
+// Snapshot 为 true 时 Upserts 是完整路由表，不在其中的节点应删除
+type RouteTableDelta struct {
+	FromVersion int64       `json:"fromVersion"`
+	Version     int64       `json:"version"`
+	Snapshot    bool        `json:"snapshot,omitempty"`
+	Upserts     []RouteNode `json:"upserts,omitempty"`
+	Removes     []string    `json:"removes,omitempty"`
+}
+
+// Empty 没有任何变更
+func (d *RouteTableDelta) Empty() bool {
+	return !d.Snapshot && len(d.Upserts) == 0 && len(d.Removes) == 0
+}
+
+// HeartbeatAck 支持轻量心跳的节点收到的心跳响应（HeartBeat 消息的 JSON 对象）；
+// 旧版本节点仍然收到完整的 []RouteNode
+type HeartbeatAck struct {
+	// ResyncStubs Router Center 没有与 StubsHash 一致的 Stub 列表，节点需要立即发送完整心跳
+	ResyncStubs bool             `json:"resyncStubs,omitempty"`
+	Routes      *RouteTableDelta `json:"routes,omitempty"`
+}
+
+// StubsHash Stub 列表的摘要，与顺序无关
+func StubsHash(stubs []RpcStubMetadata) string {
+	sorted := make([]RpcStubMetadata, len(stubs))
+	copy(sorted, stubs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PacketId < sorted[j].PacketId })
+	data, _ := json.Marshal(sorted)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
@@ -12,6 +12,9 @@ type RouteMessage struct {
 	ToRouteId   string
 	MessageType *RouteMessageType
 	Data        *string
+	// Traceparent W3C trace-context，非空时以可选尾部字段编码在 Data 之后；
+	// Router Center 转发时只替换该字段，不需要解析或改写 Data
+	Traceparent string
 }
 
 // EncodePayload 编码内部负载（不含长度前缀）
@@ -27,6 +30,9 @@ func (m *RouteMessage) EncodePayload() ([]byte, error) {
 	}
 
 	payloadLen := 4 + len(fromBytes) + 4 + len(toBytes) + 4 + 4 + len(dataBytes)
+	if m.Traceparent != "" {
+		payloadLen += 4 + len(m.Traceparent)
+	}
 	buf := bytes.NewBuffer(make([]byte, 0, payloadLen))
 
 	if err := writeInt32(buf, int32(len(fromBytes))); err != nil {
@@ -66,6 +72,16 @@ func (m *RouteMessage) EncodePayload() ([]byte, error) {
 		}
 	}
 
+	// 没有链路时不写尾部字段，与旧版本的编码完全一致
+	if m.Traceparent != "" {
+		if err := writeInt32(buf, int32(len(m.Traceparent))); err != nil {
+			return nil, err
+		}
+		if _, err := buf.WriteString(m.Traceparent); err != nil {
+			return nil, err
+		}
+	}
+
 	return buf.Bytes(), nil
 }
 
@@ -134,11 +150,21 @@ func DecodeRouteMessagePayload(payload []byte) (*RouteMessage, error) {
 		data = &dStr
 	}
 
+	// 可选的 traceparent 尾部字段，旧版本节点不会写入；格式不对时忽略，不影响消息本身
+	var traceparent string
+	if tpLen, err := readInt32(reader); err == nil && tpLen > 0 && int(tpLen) == reader.Len() {
+		tpBytes := make([]byte, tpLen)
+		if _, err := io.ReadFull(reader, tpBytes); err == nil {
+			traceparent = string(tpBytes)
+		}
+	}
+
 	return &RouteMessage{
 		FromRouteId: string(fromBytes),
 		ToRouteId:   string(toBytes),
 		MessageType: msgType,
 		Data:        data,
+		Traceparent: traceparent,
 	}, nil
 }
 
@@ -58,10 +58,22 @@ type RpcStubMetadata struct {
 	ParameterNames        []string `json:"parameterNames"`
 	ParameterDescriptions []string `json:"parameterDescriptions"`
 	ParameterExampleJson  []string `json:"parameterExampleJson"`
+	// 以下字段由 Go 节点根据函数签名生成，其他语言的节点可能不提供
+	ParameterSchemaJson []string `json:"parameterSchemaJson,omitempty"`
+	ReturnType          string   `json:"returnType,omitempty"`
+	ReturnSchemaJson    string   `json:"returnSchemaJson,omitempty"`
 }
 
 type RpcServerInfo struct {
 	Host  string            `json:"host"`
 	Port  int               `json:"port"`
 	Stubs []RpcStubMetadata `json:"stubs"`
+	// 节点的心跳间隔（秒），Router Center 据此计算会话超时；旧版本节点不上报
+	HeartBeatIntervalSecond int64 `json:"heartBeatIntervalSecond,omitempty"`
+	// Stub 列表摘要（见 StubsHash），上报该字段表示节点支持轻量心跳与增量路由表
+	StubsHash string `json:"stubsHash,omitempty"`
+	// Lite 轻量心跳：不携带 stubs，由 Router Center 比对 StubsHash
+	Lite bool `json:"lite,omitempty"`
+	// RouteVersion 节点当前持有的路由表版本，0 表示需要完整路由表
+	RouteVersion int64 `json:"routeVersion,omitempty"`
 }
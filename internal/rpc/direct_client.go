@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -8,6 +9,8 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 type DirectClient struct {
@@ -68,6 +71,20 @@ func (c *DirectClient) SendRpcMessage(request *RpcRequest) (bool, error) {
 }
 
 func (c *DirectClient) GetOrCreateProxy(packetId int, timeout time.Duration, args []json.RawMessage) (string, error) {
+	return c.CallContext(context.Background(), packetId, timeout, args)
+}
+
+// CallContext 直连调用目标节点，ctx 中的链路会通过 traceparent 传给目标节点
+func (c *DirectClient) CallContext(ctx context.Context, packetId int, timeout time.Duration, args []json.RawMessage) (result string, err error) {
+	_, span := tracing.Start(ctx, "DirectClient.Call", tracing.SpanKindClient,
+		tracing.String("route.from", c.localRouteId),
+		tracing.String("route.to", c.routeId),
+		tracing.Int("rpc.packet_id", packetId))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	req := &RpcRequest{
 		FromRouteId:        c.localRouteId,
 		ToRouteId:          c.routeId,
@@ -75,7 +92,9 @@ func (c *DirectClient) GetOrCreateProxy(packetId int, timeout time.Duration, arg
 		StartTimeMs:        time.Now().UnixMilli(),
 		PacketId:           packetId,
 		MethodArgsJsonList: rawToStringList(args),
+		Traceparent:        span.Traceparent(),
 	}
+	span.SetAttributes(tracing.String("rpc.uid", req.RpcUid))
 	ok, err := c.SendRpcMessage(req)
 	if !ok || err != nil {
 		return "", err
@@ -1,10 +1,11 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 )
 
 func (c *DirectClient) Call(packetId int, timeout time.Duration, args []json.RawMessage) (string, error) {
-	return c.GetOrCreateProxy(packetId, timeout, args)
+	return c.CallContext(context.Background(), packetId, timeout, args)
 }
@@ -7,6 +7,9 @@ type RpcRequest struct {
 	StartTimeMs        int64    `json:"startTimeMs"`
 	PacketId           int      `json:"packetId"`
 	MethodArgsJsonList []string `json:"methodArgsJsonList"`
+	// Traceparent W3C trace-context，用于直连 RPC；经 Router Center 转发时放在 RouteMessage.Traceparent，
+	// 这里只兼容不支持该字段的发送方，调用方没有开启链路时为空
+	Traceparent string `json:"traceparent,omitempty"`
 }
 
 type RpcResponse struct {
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultInvokeTimeout Invoke 未显式指定超时时使用的默认超时
+const DefaultInvokeTimeout = 10 * time.Second
+
+var ErrServiceProviderRequired = errors.New("ServiceProvider 不能为空")
+
+// Invoke 以单个请求对象调用远端 RPC，并把返回值反序列化为 Resp
+func Invoke[Req, Resp any](provider ServiceProvider, packetId int, req Req) (Resp, error) {
+	return InvokeTimeout[Req, Resp](provider, packetId, DefaultInvokeTimeout, req)
+}
+
+// InvokeTimeout 同 Invoke，可指定超时
+func InvokeTimeout[Req, Resp any](provider ServiceProvider, packetId int, timeout time.Duration, req Req) (Resp, error) {
+	return InvokeArgs[Resp](provider, packetId, timeout, req)
+}
+
+// InvokeArgs 以任意数量的参数调用远端 RPC，参数顺序需与服务端函数签名一致
+func InvokeArgs[Resp any](provider ServiceProvider, packetId int, timeout time.Duration, args ...any) (Resp, error) {
+	var zero Resp
+	if provider == nil {
+		return zero, ErrServiceProviderRequired
+	}
+	rawArgs, err := MarshalArgs(args...)
+	if err != nil {
+		return zero, err
+	}
+	result, err := provider.Call(packetId, timeout, rawArgs)
+	if err != nil {
+		return zero, err
+	}
+	return DecodeResult[Resp](result)
+}
+
+// ContextServiceProvider 支持传递 ctx（链路追踪）的 ServiceProvider，RelayClient / DirectClient 均已实现
+type ContextServiceProvider interface {
+	CallContext(ctx context.Context, packetId int, timeout time.Duration, args []json.RawMessage) (string, error)
+}
+
+// InvokeArgsContext 同 InvokeArgs，provider 实现 ContextServiceProvider 时把 ctx 中的链路传给目标节点
+func InvokeArgsContext[Resp any](ctx context.Context, provider ServiceProvider, packetId int, timeout time.Duration, args ...any) (Resp, error) {
+	var zero Resp
+	if provider == nil {
+		return zero, ErrServiceProviderRequired
+	}
+	rawArgs, err := MarshalArgs(args...)
+	if err != nil {
+		return zero, err
+	}
+	var result string
+	if p, ok := provider.(ContextServiceProvider); ok {
+		result, err = p.CallContext(ctx, packetId, timeout, rawArgs)
+	} else {
+		result, err = provider.Call(packetId, timeout, rawArgs)
+	}
+	if err != nil {
+		return zero, err
+	}
+	return DecodeResult[Resp](result)
+}
+
+// MarshalArgs 把参数列表序列化为 ServiceProvider.Call 所需的 []json.RawMessage
+func MarshalArgs(args ...any) ([]json.RawMessage, error) {
+	list := make([]json.RawMessage, 0, len(args))
+	for i, a := range args {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return nil, fmt.Errorf("参数序列化失败: index=%d err=%w", i, err)
+		}
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+// DecodeResult 把 ServiceProvider.Call 返回的字符串反序列化为 T。
+// 服务端对 string 返回值不做 JSON 编码，因此 T 为 string 时直接返回原文。
+func DecodeResult[T any](result string) (T, error) {
+	var v T
+	if p, ok := any(&v).(*string); ok {
+		*p = result
+		return v, nil
+	}
+	if result == "" {
+		return v, nil
+	}
+	if err := json.Unmarshal([]byte(result), &v); err != nil {
+		return v, fmt.Errorf("RPC 返回值反序列化失败: %w", err)
+	}
+	return v, nil
+}
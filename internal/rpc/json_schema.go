@@ -0,0 +1,147 @@
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// JsonSchemaForType 根据 Go 类型生成 JSON Schema（draft 2020-12 子集），
+// 结构体字段遵循 encoding/json 的 tag 规则。
+func JsonSchemaForType(t reflect.Type) map[string]any {
+	return jsonSchemaForType(t, map[reflect.Type]bool{})
+}
+
+func jsonSchemaForType(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		inner := jsonSchemaForType(t.Elem(), visiting)
+		return map[string]any{"anyOf": []any{inner, map[string]any{"type": "null"}}}
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// 自定义序列化无法推断结构
+		return map[string]any{"description": typeName(t)}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer", "format": intFormat(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0, "format": intFormat(t)}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchemaForType(t.Elem(), visiting)}
+	case reflect.Array:
+		return map[string]any{
+			"type":     "array",
+			"items":    jsonSchemaForType(t.Elem(), visiting),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		return structSchema(t, visiting)
+	default:
+		// interface{} 等任意类型
+		return map[string]any{}
+	}
+}
+
+func intFormat(t reflect.Type) string {
+	if t.Bits() == 64 {
+		return "int64"
+	}
+	return "int32"
+}
+
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	if visiting[t] {
+		// 递归类型不再展开，避免死循环
+		return map[string]any{"type": "object", "title": t.Name()}
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := map[string]any{}
+	required := make([]string, 0)
+	collectStructFields(t, visiting, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if t.Name() != "" {
+		schema["title"] = t.Name()
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func collectStructFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				// 匿名嵌入结构体的字段会被 encoding/json 提升到外层
+				collectStructFields(ft, visiting, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fieldSchema := jsonSchemaForType(f.Type, visiting)
+		if strings.Contains(opts, "string") {
+			fieldSchema = map[string]any{"type": "string"}
+		}
+		properties[name] = fieldSchema
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func schemaJsonForType(t reflect.Type) string {
+	b, err := json.Marshal(JsonSchemaForType(t))
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
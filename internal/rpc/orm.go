@@ -25,16 +25,24 @@ type RpcFuncMeta struct {
 }
 
 func RegisterRpcFunc(meta RpcFuncMeta, fn any) error {
-	if meta.PacketId <= 0 {
-		return errors.New("packetId must be greater than 0")
-	}
 	if fn == nil {
 		return errors.New("fn is nil")
 	}
-	fnValue := reflect.ValueOf(fn)
+	metaData, handler, err := buildRpcStub(meta, reflect.ValueOf(fn))
+	if err != nil {
+		return err
+	}
+	return ServerStubManagerInstance().RegisterStub(metaData, handler)
+}
+
+// buildRpcStub 根据函数签名生成 Stub 元数据与参数自动反序列化的 handler
+func buildRpcStub(meta RpcFuncMeta, fnValue reflect.Value) (core.RpcStubMetadata, RpcHandler, error) {
+	if meta.PacketId <= 0 {
+		return core.RpcStubMetadata{}, nil, errors.New("packetId must be greater than 0")
+	}
 	fnType := fnValue.Type()
 	if fnType.Kind() != reflect.Func {
-		return errors.New("fn must be a function")
+		return core.RpcStubMetadata{}, nil, errors.New("fn must be a function")
 	}
 
 	paramTypes := make([]reflect.Type, fnType.NumIn())
@@ -70,9 +78,17 @@ func RegisterRpcFunc(meta RpcFuncMeta, fn any) error {
 
 	parameterTypes := make([]string, len(paramTypes))
 	parameterExampleJson := make([]string, len(paramTypes))
+	parameterSchemaJson := make([]string, len(paramTypes))
 	for i, t := range paramTypes {
 		parameterTypes[i] = typeName(t)
 		parameterExampleJson[i] = exampleForType(t)
+		parameterSchemaJson[i] = schemaJsonForType(t)
+	}
+
+	returnType, returnSchemaJson := "", ""
+	if rt, ok := resultType(fnType); ok {
+		returnType = typeName(rt)
+		returnSchemaJson = schemaJsonForType(rt)
 	}
 
 	metaData := core.RpcStubMetadata{
@@ -84,6 +100,9 @@ func RegisterRpcFunc(meta RpcFuncMeta, fn any) error {
 		ParameterNames:        paramNames,
 		ParameterDescriptions: paramDescriptions,
 		ParameterExampleJson:  parameterExampleJson,
+		ParameterSchemaJson:   parameterSchemaJson,
+		ReturnType:            returnType,
+		ReturnSchemaJson:      returnSchemaJson,
 	}
 
 	handler := func(args []json.RawMessage) (any, error) {
@@ -103,8 +122,7 @@ func RegisterRpcFunc(meta RpcFuncMeta, fn any) error {
 		return normalizeFuncResult(out)
 	}
 
-	ServerStubManagerInstance().RegisterStub(metaData, handler)
-	return nil
+	return metaData, handler, nil
 }
 
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -156,33 +174,102 @@ func typeName(t reflect.Type) string {
 	return t.String()
 }
 
+// resultType 返回函数的业务返回值类型（忽略末尾的 error）
+func resultType(fnType reflect.Type) (reflect.Type, bool) {
+	switch fnType.NumOut() {
+	case 1:
+		if fnType.Out(0).Implements(errorType) {
+			return nil, false
+		}
+		return fnType.Out(0), true
+	case 2:
+		return fnType.Out(0), true
+	default:
+		return nil, false
+	}
+}
+
 func exampleForType(t reflect.Type) string {
+	b, err := json.Marshal(exampleValueForType(t, map[reflect.Type]bool{}))
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// exampleValueForType 递归构造示例值，嵌套结构体/切片也会填充，便于调试页直接修改
+func exampleValueForType(t reflect.Type, visiting map[reflect.Type]bool) any {
 	if t.Kind() == reflect.Pointer {
-		return exampleForType(t.Elem())
+		return exampleValueForType(t.Elem(), visiting)
+	}
+	switch {
+	case t == timeType:
+		return "2006-01-02T15:04:05Z"
+	case t == rawMessageType:
+		return map[string]any{}
 	}
 	switch t.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return "0"
+		return 0
 	case reflect.Float32, reflect.Float64:
-		return "0.0"
+		return 0.0
 	case reflect.Bool:
-		return "false"
+		return false
 	case reflect.String:
-		return "\"string_value\""
+		return "string_value"
 	case reflect.Slice, reflect.Array:
-		return "[]"
+		if t.Elem().Kind() == reflect.Uint8 {
+			return ""
+		}
+		return []any{exampleValueForType(t.Elem(), visiting)}
 	case reflect.Map:
-		return "{}"
+		return map[string]any{}
 	case reflect.Struct:
-		b, err := json.Marshal(reflect.New(t).Elem().Interface())
-		if err == nil {
-			return string(b)
+		if visiting[t] || t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			b, err := json.Marshal(reflect.New(t).Elem().Interface())
+			if err == nil {
+				return json.RawMessage(b)
+			}
+			return map[string]any{}
 		}
-		return "{}"
+		visiting[t] = true
+		defer delete(visiting, t)
+		obj := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			collectExampleField(t.Field(i), visiting, obj)
+		}
+		return obj
 	default:
-		return "{}"
+		return map[string]any{}
+	}
+}
+
+func collectExampleField(f reflect.StructField, visiting map[reflect.Type]bool, obj map[string]any) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if f.Anonymous && name == "" {
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			for i := 0; i < ft.NumField(); i++ {
+				collectExampleField(ft.Field(i), visiting, obj)
+			}
+			return
+		}
+	}
+	if !f.IsExported() {
+		return
+	}
+	if name == "" {
+		name = f.Name
 	}
+	obj[name] = exampleValueForType(f.Type, visiting)
 }
 
 func fallback(s, def string) string {
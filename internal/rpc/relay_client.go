@@ -1,12 +1,14 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"sync/atomic"
 	"time"
 
 	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 type RelayClient struct {
@@ -19,6 +21,20 @@ func NewRelayClient(targetRouteId string, client RouterClientSender) *RelayClien
 }
 
 func (c *RelayClient) Call(packetId int, timeout time.Duration, args []json.RawMessage) (string, error) {
+	return c.CallContext(context.Background(), packetId, timeout, args)
+}
+
+// CallContext 同 Call，ctx 中的链路会通过 traceparent 传给目标节点
+func (c *RelayClient) CallContext(ctx context.Context, packetId int, timeout time.Duration, args []json.RawMessage) (result string, err error) {
+	ctx, span := tracing.Start(ctx, "RelayClient.Call", tracing.SpanKindClient,
+		tracing.String("route.from", c.routerClient.RouteId()),
+		tracing.String("route.to", c.targetRouteId),
+		tracing.Int("rpc.packet_id", packetId))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	start := time.Now()
 	if !c.routerClient.IsConnected() {
 		if err := c.routerClient.AwaitConnected(timeout); err != nil {
@@ -28,7 +44,7 @@ func (c *RelayClient) Call(packetId int, timeout time.Duration, args []json.RawM
 
 	// 本地调用优化
 	if c.targetRouteId == c.routerClient.RouteId() {
-		return invokeLocal(packetId, args)
+		return invokeLocal(ctx, packetId, args)
 	}
 
 	req := &RpcRequest{
@@ -39,10 +55,11 @@ func (c *RelayClient) Call(packetId int, timeout time.Duration, args []json.RawM
 		PacketId:           packetId,
 		MethodArgsJsonList: rawToStringList(args),
 	}
+	span.SetAttributes(tracing.String("rpc.uid", req.RpcUid))
 
 	future := NewFuture(req.RpcUid)
 	RelayFutureManagerInstance().Register(future)
-	if err := c.routerClient.Send(c.targetRouteId, core.RouteMessageTypeRpcRequest, req); err != nil {
+	if err := c.sendRequest(req, span.Traceparent()); err != nil {
 		remaining := timeout - time.Since(start)
 		if remaining <= 0 {
 			return "", err
@@ -50,7 +67,7 @@ func (c *RelayClient) Call(packetId int, timeout time.Duration, args []json.RawM
 		if waitErr := c.routerClient.AwaitConnected(remaining); waitErr != nil {
 			return "", err
 		}
-		if err := c.routerClient.Send(c.targetRouteId, core.RouteMessageTypeRpcRequest, req); err != nil {
+		if err := c.sendRequest(req, span.Traceparent()); err != nil {
 			return "", err
 		}
 	}
@@ -62,8 +79,17 @@ func (c *RelayClient) Call(packetId int, timeout time.Duration, args []json.RawM
 	return future.Await(remaining)
 }
 
-func invokeLocal(packetId int, args []json.RawMessage) (string, error) {
-	result, err := ServerStubManagerInstance().Invoke(packetId, args)
+// sendRequest 发送 RPC 请求，traceparent 优先放在 RouteMessage 上
+func (c *RelayClient) sendRequest(req *RpcRequest, traceparent string) error {
+	if sender, ok := c.routerClient.(TraceparentSender); ok {
+		return sender.SendWithTraceparent(c.targetRouteId, core.RouteMessageTypeRpcRequest, req, traceparent)
+	}
+	req.Traceparent = traceparent
+	return c.routerClient.Send(c.targetRouteId, core.RouteMessageTypeRpcRequest, req)
+}
+
+func invokeLocal(ctx context.Context, packetId int, args []json.RawMessage) (string, error) {
+	result, err := ServerStubManagerInstance().InvokeContext(ctx, packetId, args)
 	if err != nil {
 		return "", err
 	}
@@ -1,10 +1,12 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 
 	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 var relayFutureManager = NewFutureManager()
@@ -35,7 +37,13 @@ func HandleRelayRpcRequest(msg *core.RouteMessage, client RouterClientSender) {
 	}
 
 	resp := RpcResponse{RpcUid: req.RpcUid, StartTimeMs: req.StartTimeMs, PacketId: req.PacketId}
-	result, err := ServerStubManagerInstance().Invoke(req.PacketId, rawToJsonArgs(req.MethodArgsJsonList))
+	traceparent := msg.Traceparent
+	if traceparent == "" {
+		// 兼容把 traceparent 放在请求体中的发送方
+		traceparent = req.Traceparent
+	}
+	ctx := tracing.ContextWithTraceparent(context.Background(), traceparent)
+	result, err := ServerStubManagerInstance().InvokeContext(ctx, req.PacketId, rawToJsonArgs(req.MethodArgsJsonList))
 	if err != nil {
 		slog.Warn("Relay RPC 执行失败", "packetId", req.PacketId, "rpcUid", req.RpcUid, "error", err)
 		resp.ErrorFlag = true
@@ -0,0 +1,182 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// RpcService 可选接口：结构体自行声明「方法名 -> packetId」映射，
+// 这样 RegisterService 时可以不传 packetIds。
+type RpcService interface {
+	RpcPacketIds() map[string]int
+}
+
+// packetIdTag 在结构体字段上声明「方法名=packetId」，多个用逗号分隔，通常写在 _ 字段上：
+//
+//	type LoginService struct {
+//		_ struct{} `packetId:"Login=1001,Logout=1002"`
+//	}
+const packetIdTag = "packetId"
+
+// RpcServiceDescriber 可选接口：为方法补充描述与参数元数据，key 为方法名
+type RpcServiceDescriber interface {
+	RpcMethodMeta() map[string]RpcFuncMeta
+}
+
+// RegisterService 把结构体的导出方法注册为 RPC Stub，ClassName 为结构体名。
+// packetIds 为空时依次使用实例实现的 RpcService.RpcPacketIds() 与字段上的 packetId 标签。
+// 任一方法注册失败（包括 packetId 重复）时整个服务都不会注册。
+func (m *StubManager) RegisterService(instance any, packetIds map[string]int) error {
+	if instance == nil {
+		return errors.New("service instance is nil")
+	}
+	value := reflect.ValueOf(instance)
+	structType := value.Type()
+	for structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	serviceName := structType.Name()
+	if serviceName == "" {
+		return fmt.Errorf("无法识别服务名: %T", instance)
+	}
+
+	if len(packetIds) == 0 {
+		if provider, ok := instance.(RpcService); ok {
+			packetIds = provider.RpcPacketIds()
+		}
+	}
+	if len(packetIds) == 0 && structType.Kind() == reflect.Struct {
+		tagged, err := packetIdsFromTags(structType)
+		if err != nil {
+			return fmt.Errorf("服务 %s %w", serviceName, err)
+		}
+		packetIds = tagged
+	}
+	if len(packetIds) == 0 {
+		return fmt.Errorf("服务 %s 没有声明任何 packetId", serviceName)
+	}
+	var methodMeta map[string]RpcFuncMeta
+	if describer, ok := instance.(RpcServiceDescriber); ok {
+		methodMeta = describer.RpcMethodMeta()
+	}
+
+	methodNames := make([]string, 0, len(packetIds))
+	for name := range packetIds {
+		methodNames = append(methodNames, name)
+	}
+	sort.Strings(methodNames)
+
+	metas := make([]core.RpcStubMetadata, 0, len(methodNames))
+	handlers := make([]RpcHandler, 0, len(methodNames))
+	usedBy := map[int]string{}
+	for _, methodName := range methodNames {
+		packetId := packetIds[methodName]
+		if other, dup := usedBy[packetId]; dup {
+			return fmt.Errorf("%w: 服务 %s 内 packetId=%d 重复: %s 与 %s", ErrDuplicatePacketId, serviceName, packetId, other, methodName)
+		}
+		usedBy[packetId] = methodName
+
+		method := value.MethodByName(methodName)
+		if !method.IsValid() {
+			return fmt.Errorf("服务 %s 没有导出方法 %s", serviceName, methodName)
+		}
+		meta := methodMeta[methodName]
+		meta.PacketId = packetId
+		meta.ClassName = serviceName
+		meta.MethodName = methodName
+		stubMeta, handler, err := buildRpcStub(meta, method)
+		if err != nil {
+			return fmt.Errorf("服务 %s 方法 %s 注册失败: %w", serviceName, methodName, err)
+		}
+		metas = append(metas, stubMeta)
+		handlers = append(handlers, handler)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.interfaceIndex[serviceName]; exists {
+		return fmt.Errorf("服务 %s 已经注册", serviceName)
+	}
+	for _, meta := range metas {
+		if old, exists := m.metadata[meta.PacketId]; exists {
+			return fmt.Errorf("%w: packetId=%d 已被 %s.%s 占用，无法注册 %s.%s", ErrDuplicatePacketId, meta.PacketId, old.ClassName, old.MethodName, meta.ClassName, meta.MethodName)
+		}
+	}
+	registered := make([]int, 0, len(metas))
+	for i, meta := range metas {
+		m.handlers[meta.PacketId] = handlers[i]
+		m.metadata[meta.PacketId] = meta
+		registered = append(registered, meta.PacketId)
+	}
+	m.interfaceIndex[serviceName] = instance
+	m.servicePacketIds[serviceName] = registered
+	m.initialized.Store(true)
+	return nil
+}
+
+// packetIdsFromTags 读取结构体字段上的 packetId 标签
+func packetIdsFromTags(structType reflect.Type) (map[string]int, error) {
+	packetIds := map[string]int{}
+	for i := 0; i < structType.NumField(); i++ {
+		tag, ok := structType.Field(i).Tag.Lookup(packetIdTag)
+		if !ok {
+			continue
+		}
+		for _, item := range strings.Split(tag, ",") {
+			name, id, found := strings.Cut(strings.TrimSpace(item), "=")
+			packetId, err := strconv.Atoi(strings.TrimSpace(id))
+			name = strings.TrimSpace(name)
+			if !found || name == "" || err != nil || packetId <= 0 {
+				return nil, fmt.Errorf("packetId 标签格式应为 方法名=packetId: %q", item)
+			}
+			if other, dup := packetIds[name]; dup {
+				return nil, fmt.Errorf("方法 %s 重复声明 packetId: %d 与 %d", name, other, packetId)
+			}
+			packetIds[name] = packetId
+		}
+	}
+	return packetIds, nil
+}
+
+// UnregisterService 运行时注销服务，下一次心跳会向 Router Center 上报新的 Stub 列表
+func (m *StubManager) UnregisterService(serviceName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	packetIds, ok := m.servicePacketIds[serviceName]
+	if !ok {
+		return fmt.Errorf("服务 %s 未注册", serviceName)
+	}
+	for _, packetId := range packetIds {
+		delete(m.handlers, packetId)
+		delete(m.metadata, packetId)
+	}
+	delete(m.servicePacketIds, serviceName)
+	delete(m.interfaceIndex, serviceName)
+	return nil
+}
+
+// GetService 获取已注册的服务实例
+func (m *StubManager) GetService(serviceName string) (any, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.interfaceIndex[serviceName]
+	return v, ok
+}
+
+// GetServiceNames 返回已注册的服务名（按字母序）
+func (m *StubManager) GetServiceNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.servicePacketIds))
+	for name := range m.servicePacketIds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
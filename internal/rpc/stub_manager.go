@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"sync/atomic"
 
 	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 type RpcHandler func(args []json.RawMessage) (any, error)
@@ -20,12 +22,15 @@ type StubManager struct {
 	handlers       map[int]RpcHandler
 	metadata       map[int]core.RpcStubMetadata
 	interfaceIndex map[string]any
+	// 服务名 -> 该服务注册的 packetId，用于 UnregisterService
+	servicePacketIds map[string][]int
 }
 
 var stubManagerInstance = &StubManager{
-	handlers:       map[int]RpcHandler{},
-	metadata:       map[int]core.RpcStubMetadata{},
-	interfaceIndex: map[string]any{},
+	handlers:         map[int]RpcHandler{},
+	metadata:         map[int]core.RpcStubMetadata{},
+	interfaceIndex:   map[string]any{},
+	servicePacketIds: map[string][]int{},
 }
 
 func ServerStubManagerInstance() *StubManager {
@@ -45,12 +50,40 @@ func (m *StubManager) CheckInitialized() error {
 	return nil
 }
 
-func (m *StubManager) RegisterStub(meta core.RpcStubMetadata, handler RpcHandler) {
+// RegisterStub 注册单个 Stub，packetId 已被占用时返回错误而不是覆盖
+func (m *StubManager) RegisterStub(meta core.RpcStubMetadata, handler RpcHandler) error {
+	if handler == nil {
+		return errors.New("handler is nil")
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if old, exists := m.metadata[meta.PacketId]; exists {
+		return fmt.Errorf("%w: packetId=%d 已被 %s.%s 占用，无法注册 %s.%s", ErrDuplicatePacketId, meta.PacketId, old.ClassName, old.MethodName, meta.ClassName, meta.MethodName)
+	}
 	m.handlers[meta.PacketId] = handler
 	m.metadata[meta.PacketId] = meta
 	m.initialized.Store(true)
+	return nil
+}
+
+// UnregisterStub 注销单个 packetId
+func (m *StubManager) UnregisterStub(packetId int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.handlers[packetId]; !ok {
+		return false
+	}
+	delete(m.handlers, packetId)
+	delete(m.metadata, packetId)
+	for name, ids := range m.servicePacketIds {
+		for i, id := range ids {
+			if id == packetId {
+				m.servicePacketIds[name] = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+	}
+	return true
 }
 
 func (m *StubManager) RegisterInterface(name string, instance any) {
@@ -65,6 +98,7 @@ func (m *StubManager) Reset() {
 	m.handlers = map[int]RpcHandler{}
 	m.metadata = map[int]core.RpcStubMetadata{}
 	m.interfaceIndex = map[string]any{}
+	m.servicePacketIds = map[string][]int{}
 	m.initialized.Store(false)
 }
 
@@ -75,6 +109,13 @@ func (m *StubManager) GetHandler(packetId int) (RpcHandler, bool) {
 	return h, ok
 }
 
+func (m *StubManager) getMetadata(packetId int) (core.RpcStubMetadata, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	meta, ok := m.metadata[packetId]
+	return meta, ok
+}
+
 func (m *StubManager) GetAllStubsMetadata() []core.RpcStubMetadata {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -86,10 +127,23 @@ func (m *StubManager) GetAllStubsMetadata() []core.RpcStubMetadata {
 }
 
 func (m *StubManager) Invoke(packetId int, args []json.RawMessage) (result any, err error) {
+	return m.InvokeContext(context.Background(), packetId, args)
+}
+
+// InvokeContext 执行本地 Stub，ctx 中带有上游链路时本次执行记为其子 Span
+func (m *StubManager) InvokeContext(ctx context.Context, packetId int, args []json.RawMessage) (result any, err error) {
+	_, span := tracing.Start(ctx, "StubManager.Invoke", tracing.SpanKindServer, tracing.Int("rpc.packet_id", packetId))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 	h, ok := m.GetHandler(packetId)
 	if !ok {
 		return nil, errors.New("方法未注册: packetId=" + intToString(packetId))
 	}
+	if meta, ok := m.getMetadata(packetId); ok {
+		span.SetAttributes(tracing.String("rpc.method", meta.ClassName+"."+meta.MethodName))
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error("RPC 执行发生 panic，已恢复避免进程崩溃", "packetId", packetId, "panic", r)
@@ -1,12 +1,15 @@
 package rpc
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net"
 	"sync"
+
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
 )
 
 type StubServer struct {
@@ -57,7 +60,8 @@ func (s *StubServer) handleConn(conn net.Conn) {
 		}
 		response := RpcResponse{RpcUid: req.RpcUid, StartTimeMs: req.StartTimeMs, PacketId: req.PacketId}
 
-		result, err := ServerStubManagerInstance().Invoke(req.PacketId, rawToJsonArgs(req.MethodArgsJsonList))
+		ctx := tracing.ContextWithTraceparent(context.Background(), req.Traceparent)
+		result, err := ServerStubManagerInstance().InvokeContext(ctx, req.PacketId, rawToJsonArgs(req.MethodArgsJsonList))
 		if err != nil {
 			slog.Warn("Direct RPC 执行失败", "packetId", req.PacketId, "rpcUid", req.RpcUid, "error", err)
 			response.ErrorFlag = true
@@ -11,6 +11,7 @@ var (
 	ErrRouteNotFound        = errors.New("路由节点未注册到路由中心")
 	ErrRouterClientRequired = errors.New("Relay 模式需要 VirtualRouterClient")
 	ErrFrameTooLarge        = errors.New("frame length out of range")
+	ErrDuplicatePacketId    = errors.New("packetId 重复注册")
 )
 
 type RouterClientSender interface {
@@ -19,3 +20,9 @@ type RouterClientSender interface {
 	RouteId() string
 	AwaitConnected(timeout time.Duration) error
 }
+
+// TraceparentSender 能把 traceparent 放在 RouteMessage 上发送的 RouterClientSender，
+// Router Center 转发时不必解析请求体；未实现时 traceparent 放在 RpcRequest 中
+type TraceparentSender interface {
+	SendWithTraceparent(toRouteId string, msgType core.RouteMessageType, obj any, traceparent string) error
+}
@@ -0,0 +1,346 @@
+package rpcgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FacadeImportPath 生成代码依赖的公开包
+const FacadeImportPath = "github.com/neko233-com/virtual-router-go/VirtualRouterClient"
+
+var (
+	packetIdRegex = regexp.MustCompile(`^rpc:packetId\s*=\s*(\d+)\s*$`)
+	paramRegex    = regexp.MustCompile(`^rpc:param\s+(\w+)\s*(.*)$`)
+)
+
+// ServiceSpec 从接口声明中解析出的 RPC 服务
+type ServiceSpec struct {
+	Name    string
+	Methods []MethodSpec
+}
+
+// MethodSpec 接口中带 packetId 注解的方法
+type MethodSpec struct {
+	Name        string
+	PacketId    int
+	Description string
+	Params      []ParamSpec
+	ResultType  string
+	HasResult   bool
+}
+
+type ParamSpec struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// Generate 解析 Go 源码中的接口，生成服务端注册函数与强类型客户端桩。
+// typeNames 为空时处理所有包含 packetId 注解的接口。
+func Generate(fileName string, src []byte, typeNames []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, fileName, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range typeNames {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[name] = true
+		}
+	}
+
+	var services []ServiceSpec
+	usedPkgs := map[string]bool{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			iface, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			if len(wanted) > 0 && !wanted[ts.Name.Name] {
+				continue
+			}
+			svc, err := parseService(fset, ts.Name.Name, iface, usedPkgs)
+			if err != nil {
+				return nil, err
+			}
+			if len(svc.Methods) == 0 {
+				if len(wanted) > 0 {
+					return nil, fmt.Errorf("接口 %s 没有任何带 rpc:packetId 注解的方法", ts.Name.Name)
+				}
+				continue
+			}
+			services = append(services, svc)
+			delete(wanted, ts.Name.Name)
+		}
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("在 %s 中没有找到接口 %s", fileName, name)
+	}
+	if len(services) == 0 {
+		return nil, errors.New("没有找到任何带 rpc:packetId 注解的接口")
+	}
+
+	imports, err := collectImports(file, usedPkgs)
+	if err != nil {
+		return nil, err
+	}
+	return render(file.Name.Name, imports, services)
+}
+
+func parseService(fset *token.FileSet, name string, iface *ast.InterfaceType, usedPkgs map[string]bool) (ServiceSpec, error) {
+	svc := ServiceSpec{Name: name}
+	seen := map[int]string{}
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			continue
+		}
+		methodName := field.Names[0].Name
+		packetId, description, paramDescs := parseDoc(field.Doc)
+		if packetId <= 0 {
+			continue
+		}
+		if other, dup := seen[packetId]; dup {
+			return svc, fmt.Errorf("%s: packetId=%d 重复, 方法 %s 与 %s", name, packetId, other, methodName)
+		}
+		seen[packetId] = methodName
+		// Go 惯例的注释以方法名开头，描述中去掉
+		description = strings.TrimSpace(strings.TrimPrefix(description, methodName+" "))
+
+		method := MethodSpec{Name: methodName, PacketId: packetId, Description: description}
+		index := 0
+		for _, p := range fn.Params.List {
+			if _, variadic := p.Type.(*ast.Ellipsis); variadic {
+				return svc, fmt.Errorf("%s.%s: 不支持可变参数", name, methodName)
+			}
+			collectPkgs(p.Type, usedPkgs)
+			typeStr := exprString(fset, p.Type)
+			names := p.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{ast.NewIdent("arg" + strconv.Itoa(index))}
+			}
+			for _, n := range names {
+				paramName := n.Name
+				if paramName == "_" {
+					paramName = "arg" + strconv.Itoa(index)
+				}
+				method.Params = append(method.Params, ParamSpec{Name: paramName, Type: typeStr, Description: paramDescs[paramName]})
+				index++
+			}
+		}
+
+		results := flattenResults(fn.Results)
+		switch len(results) {
+		case 0:
+		case 1:
+			if !isErrorType(results[0]) {
+				method.HasResult = true
+				method.ResultType = exprString(fset, results[0])
+				collectPkgs(results[0], usedPkgs)
+			}
+		case 2:
+			if !isErrorType(results[1]) {
+				return svc, fmt.Errorf("%s.%s: 第二个返回值必须是 error", name, methodName)
+			}
+			method.HasResult = true
+			method.ResultType = exprString(fset, results[0])
+			collectPkgs(results[0], usedPkgs)
+		default:
+			return svc, fmt.Errorf("%s.%s: 返回值只能是 0、1 或 2 个", name, methodName)
+		}
+		svc.Methods = append(svc.Methods, method)
+	}
+	return svc, nil
+}
+
+func parseDoc(doc *ast.CommentGroup) (int, string, map[string]string) {
+	packetId := 0
+	paramDescs := map[string]string{}
+	if doc == nil {
+		return packetId, "", paramDescs
+	}
+	var lines []string
+	// 不使用 doc.Text()：它会吞掉 "//rpc:packetId=1" 这种无空格的指令行
+	for _, c := range doc.List {
+		line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if line == "" {
+			continue
+		}
+		if m := packetIdRegex.FindStringSubmatch(line); m != nil {
+			packetId, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if m := paramRegex.FindStringSubmatch(line); m != nil {
+			paramDescs[m[1]] = strings.TrimSpace(m[2])
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return packetId, strings.Join(lines, " "), paramDescs
+}
+
+func flattenResults(list *ast.FieldList) []ast.Expr {
+	if list == nil {
+		return nil
+	}
+	var out []ast.Expr
+	for _, f := range list.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, f.Type)
+		}
+	}
+	return out
+}
+
+func isErrorType(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "error"
+}
+
+func collectPkgs(expr ast.Expr, usedPkgs map[string]bool) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				usedPkgs[ident.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
+
+type importSpec struct {
+	Name string
+	Path string
+}
+
+func collectImports(file *ast.File, usedPkgs map[string]bool) ([]importSpec, error) {
+	var imports []importSpec
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		localName := path[strings.LastIndex(path, "/")+1:]
+		explicit := ""
+		if imp.Name != nil {
+			localName = imp.Name.Name
+			explicit = imp.Name.Name
+		}
+		if path == "time" || path == FacadeImportPath {
+			continue
+		}
+		if usedPkgs[localName] {
+			imports = append(imports, importSpec{Name: explicit, Path: path})
+		}
+	}
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path < imports[j].Path })
+	return imports, nil
+}
+
+func render(pkgName string, imports []importSpec, services []ServiceSpec) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by rpc-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import (\n\t\"time\"\n\n")
+	for _, imp := range imports {
+		if imp.Name != "" {
+			fmt.Fprintf(&b, "\t%s %q\n", imp.Name, imp.Path)
+		} else {
+			fmt.Fprintf(&b, "\t%q\n", imp.Path)
+		}
+	}
+	fmt.Fprintf(&b, "\t%q\n)\n", FacadeImportPath)
+
+	for _, svc := range services {
+		renderRegister(&b, svc)
+		renderClient(&b, svc)
+	}
+
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("格式化生成代码失败: %w\n%s", err, b.String())
+	}
+	return out, nil
+}
+
+func renderRegister(b *bytes.Buffer, svc ServiceSpec) {
+	fmt.Fprintf(b, "\n// Register%s 把 %s 的实现按 packetId 注册为 RPC Stub\n", svc.Name, svc.Name)
+	fmt.Fprintf(b, "func Register%s(impl %s) error {\n", svc.Name, svc.Name)
+	for _, m := range svc.Methods {
+		b.WriteString("\tif err := VirtualRouterClient.RegisterRpcFunc(VirtualRouterClient.RpcFuncMeta{\n")
+		fmt.Fprintf(b, "\t\tPacketId: %d,\n", m.PacketId)
+		fmt.Fprintf(b, "\t\tDescription: %q,\n", m.Description)
+		fmt.Fprintf(b, "\t\tClassName: %q,\n", svc.Name)
+		fmt.Fprintf(b, "\t\tMethodName: %q,\n", m.Name)
+		b.WriteString("\t\tParamMeta: []VirtualRouterClient.RpcParamMeta{\n")
+		for _, p := range m.Params {
+			fmt.Fprintf(b, "\t\t\t{Name: %q, Description: %q},\n", p.Name, p.Description)
+		}
+		b.WriteString("\t\t},\n")
+		fmt.Fprintf(b, "\t}, impl.%s); err != nil {\n\t\treturn err\n\t}\n", m.Name)
+	}
+	b.WriteString("\treturn nil\n}\n")
+}
+
+func renderClient(b *bytes.Buffer, svc ServiceSpec) {
+	clientName := svc.Name + "Client"
+	fmt.Fprintf(b, "\n// %s %s 的强类型客户端桩\n", clientName, svc.Name)
+	fmt.Fprintf(b, "type %s struct {\n\tprovider VirtualRouterClient.ServiceProvider\n\ttimeout time.Duration\n}\n", clientName)
+	fmt.Fprintf(b, "\n// New%s timeout <= 0 时使用 VirtualRouterClient.DefaultInvokeTimeout\n", clientName)
+	fmt.Fprintf(b, "func New%s(provider VirtualRouterClient.ServiceProvider, timeout time.Duration) *%s {\n", clientName, clientName)
+	b.WriteString("\tif timeout <= 0 {\n\t\ttimeout = VirtualRouterClient.DefaultInvokeTimeout\n\t}\n")
+	fmt.Fprintf(b, "\treturn &%s{provider: provider, timeout: timeout}\n}\n", clientName)
+
+	for _, m := range svc.Methods {
+		params := make([]string, 0, len(m.Params))
+		args := make([]string, 0, len(m.Params))
+		for _, p := range m.Params {
+			params = append(params, p.Name+" "+p.Type)
+			args = append(args, p.Name)
+		}
+		callArgs := ""
+		if len(args) > 0 {
+			callArgs = ", " + strings.Join(args, ", ")
+		}
+		if m.Description != "" {
+			fmt.Fprintf(b, "\n// %s %s\n", m.Name, m.Description)
+		} else {
+			b.WriteString("\n")
+		}
+		if m.HasResult {
+			fmt.Fprintf(b, "func (c *%s) %s(%s) (%s, error) {\n", clientName, m.Name, strings.Join(params, ", "), m.ResultType)
+			fmt.Fprintf(b, "\treturn VirtualRouterClient.InvokeArgs[%s](c.provider, %d, c.timeout%s)\n}\n", m.ResultType, m.PacketId, callArgs)
+		} else {
+			fmt.Fprintf(b, "func (c *%s) %s(%s) error {\n", clientName, m.Name, strings.Join(params, ", "))
+			fmt.Fprintf(b, "\t_, err := VirtualRouterClient.InvokeArgs[string](c.provider, %d, c.timeout%s)\n\treturn err\n}\n", m.PacketId, callArgs)
+		}
+	}
+}
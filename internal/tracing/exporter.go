@@ -0,0 +1,400 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+const (
+	exportBatchSize     = 256
+	exportMaxQueue      = 4096
+	exportFlushInterval = 2 * time.Second
+	exportTimeout       = 5 * time.Second
+)
+
+// Exporter 把结束的 Span 发送到后端，实现需要并发安全
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+var (
+	processorMu sync.Mutex
+	processor   atomic.Pointer[batchProcessor]
+	droppedSpan atomic.Uint64
+)
+
+// SetExporter 安装全局 Exporter，替换时会先把旧 Exporter 中缓冲的 Span 发完；传 nil 关闭导出
+func SetExporter(exp Exporter) {
+	processorMu.Lock()
+	defer processorMu.Unlock()
+	var next *batchProcessor
+	if exp != nil {
+		next = newBatchProcessor(exp)
+	}
+	old := processor.Swap(next)
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		_ = old.shutdown(ctx)
+	}
+}
+
+// Flush 立即导出所有缓冲的 Span
+func Flush(ctx context.Context) error {
+	if p := processor.Load(); p != nil {
+		return p.flush(ctx)
+	}
+	return nil
+}
+
+// Shutdown 导出剩余 Span 并卸载 Exporter
+func Shutdown(ctx context.Context) error {
+	processorMu.Lock()
+	defer processorMu.Unlock()
+	if p := processor.Swap(nil); p != nil {
+		return p.shutdown(ctx)
+	}
+	return nil
+}
+
+// DroppedSpans 因导出队列已满而丢弃的 Span 数
+func DroppedSpans() uint64 {
+	return droppedSpan.Load()
+}
+
+func exporterInstalled() bool {
+	return processor.Load() != nil
+}
+
+// Enabled 是否已安装 Exporter，未开启时调用方可以跳过链路相关的解析
+func Enabled() bool {
+	return exporterInstalled()
+}
+
+func enqueue(data SpanData) {
+	if p := processor.Load(); p != nil {
+		p.add(data)
+	}
+}
+
+// Install 按配置安装 Exporter；cfg 为空或未指定 exporter 时不开启导出
+func Install(cfg *config.TracingConfig, defaultServiceName string) error {
+	if cfg == nil || strings.TrimSpace(cfg.Exporter) == "" {
+		return nil
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Exporter)) {
+	case "stdout":
+		SetExporter(NewStdoutExporter(os.Stdout, serviceName))
+	case "otlp":
+		if strings.TrimSpace(cfg.Endpoint) == "" {
+			return errors.New("tracing.exporter=otlp 时必须配置 tracing.endpoint")
+		}
+		SetExporter(NewOTLPHTTPExporter(cfg.Endpoint, serviceName, cfg.Headers))
+	default:
+		return errors.New("未知的 tracing.exporter: " + cfg.Exporter)
+	}
+	slog.Info("链路追踪已开启", "exporter", cfg.Exporter, "service", serviceName)
+	return nil
+}
+
+// batchProcessor 缓冲 Span，攒够一批或定时交给 Exporter，导出不阻塞业务调用
+type batchProcessor struct {
+	exporter Exporter
+	mu       sync.Mutex
+	buf      []SpanData
+	exportMu sync.Mutex
+	kick     chan struct{}
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+func newBatchProcessor(exp Exporter) *batchProcessor {
+	p := &batchProcessor{
+		exporter: exp,
+		kick:     make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+func (p *batchProcessor) add(data SpanData) {
+	p.mu.Lock()
+	if len(p.buf) >= exportMaxQueue {
+		p.mu.Unlock()
+		droppedSpan.Add(1)
+		return
+	}
+	p.buf = append(p.buf, data)
+	full := len(p.buf) >= exportBatchSize
+	p.mu.Unlock()
+	if full {
+		select {
+		case p.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *batchProcessor) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(exportFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+		case <-p.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		if err := p.flush(ctx); err != nil {
+			slog.Warn("导出链路追踪数据失败", "error", err)
+		}
+		cancel()
+	}
+}
+
+func (p *batchProcessor) flush(ctx context.Context) error {
+	p.exportMu.Lock()
+	defer p.exportMu.Unlock()
+	for {
+		p.mu.Lock()
+		n := min(len(p.buf), exportBatchSize)
+		batch := append([]SpanData(nil), p.buf[:n]...)
+		p.buf = p.buf[n:]
+		p.mu.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := p.exporter.ExportSpans(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *batchProcessor) shutdown(ctx context.Context) error {
+	close(p.stopCh)
+	<-p.done
+	err := p.flush(ctx)
+	if shutdownErr := p.exporter.Shutdown(ctx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// StdoutExporter 每个 Span 输出一行 JSON，用于本地调试与测试
+type StdoutExporter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	serviceName string
+}
+
+func NewStdoutExporter(w io.Writer, serviceName string) *StdoutExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &StdoutExporter{w: w, serviceName: serviceName}
+}
+
+// StdoutSpan StdoutExporter 输出的一行
+type StdoutSpan struct {
+	Service      string      `json:"service,omitempty"`
+	Name         string      `json:"name"`
+	Kind         string      `json:"kind"`
+	TraceId      string      `json:"traceId"`
+	SpanId       string      `json:"spanId"`
+	ParentSpanId string      `json:"parentSpanId,omitempty"`
+	StartTime    time.Time   `json:"startTime"`
+	DurationUs   int64       `json:"durationUs"`
+	Attributes   []Attribute `json:"attributes,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+func (e *StdoutExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		line := StdoutSpan{
+			Service:    e.serviceName,
+			Name:       s.Name,
+			Kind:       s.Kind.String(),
+			TraceId:    s.TraceId.String(),
+			SpanId:     s.SpanId.String(),
+			StartTime:  s.StartTime,
+			DurationUs: s.EndTime.Sub(s.StartTime).Microseconds(),
+			Attributes: s.Attributes,
+			Error:      s.ErrorMsg,
+		}
+		if s.ParentSpanId.IsValid() {
+			line.ParentSpanId = s.ParentSpanId.String()
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StdoutExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// OTLPHTTPExporter 以 OTLP/HTTP JSON 编码把 Span 推送到 Collector（POST {endpoint}/v1/traces）
+type OTLPHTTPExporter struct {
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+}
+
+// NewOTLPHTTPExporter endpoint 可以是 Collector 根地址或完整的 /v1/traces 地址
+func NewOTLPHTTPExporter(endpoint, serviceName string, headers map[string]string) *OTLPHTTPExporter {
+	url := strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPHTTPExporter{
+		url:         url,
+		serviceName: serviceName,
+		headers:     headers,
+		client:      &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (e *OTLPHTTPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP Collector 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (e *OTLPHTTPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+// encode 按 OTLP JSON 映射组装请求体：id 为十六进制字符串，64 位整数为十进制字符串
+func (e *OTLPHTTPExporter) encode(spans []SpanData) map[string]any {
+	list := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		item := otlpSpan{
+			TraceId:           s.TraceId.String(),
+			SpanId:            s.SpanId.String(),
+			Name:              s.Name,
+			Kind:              int(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.ParentSpanId.IsValid() {
+			item.ParentSpanId = s.ParentSpanId.String()
+		}
+		if s.ErrorMsg != "" {
+			item.Status = otlpStatus{Code: 2, Message: s.ErrorMsg}
+		}
+		list = append(list, item)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes([]Attribute{String("service.name", e.serviceName)}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/neko233-com/virtual-router-go"},
+				"spans": list,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	list := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]any
+		switch v := a.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		list = append(list, otlpKeyValue{Key: a.Key, Value: value})
+	}
+	return list
+}
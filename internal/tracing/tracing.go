@@ -0,0 +1,302 @@
+// Package tracing 提供轻量的分布式链路追踪：W3C trace-context（traceparent）的解析与传播、
+// Span 的创建，以及可插拔的 Exporter（OTLP/HTTP 与 stdout）。
+//
+// traceparent 随 RpcRequest 的 JSON 字段传递，不改变 RouteMessage 的二进制帧格式：
+//
+//	00-<32 位十六进制 traceId>-<16 位十六进制 spanId>-<2 位十六进制 flags>
+//
+// 没有安装 Exporter 时 Span 仍会生成 id 以保证链路向下游传播，但结束时不做任何导出。
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader HTTP 请求头与 RpcRequest 字段使用的名称
+const TraceparentHeader = "traceparent"
+
+const flagSampled byte = 0x01
+
+// SpanKind 与 OTLP 的 SpanKind 取值一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+type TraceId [16]byte
+
+type SpanId [8]byte
+
+func (t TraceId) String() string { return hex.EncodeToString(t[:]) }
+
+func (t TraceId) IsValid() bool { return t != TraceId{} }
+
+func (s SpanId) String() string { return hex.EncodeToString(s[:]) }
+
+func (s SpanId) IsValid() bool { return s != SpanId{} }
+
+// SpanContext 跨进程传播的链路标识
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Flags   byte
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent 格式化为 W3C traceparent，无效时返回空串
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return "00-" + sc.TraceId.String() + "-" + sc.SpanId.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent 解析 W3C traceparent，只接受 version 00 及兼容的更高版本
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.New("traceparent 格式错误: " + value)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, errors.New("traceparent 版本不支持: " + value)
+	}
+	if _, err := hex.Decode(sc.TraceId[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, errors.New("traceparent traceId 非法: " + value)
+	}
+	if _, err := hex.Decode(sc.SpanId[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, errors.New("traceparent spanId 非法: " + value)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, errors.New("traceparent flags 非法: " + value)
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, errors.New("traceparent 不允许全 0 的 traceId/spanId: " + value)
+	}
+	return sc, nil
+}
+
+// Attribute Span 上的键值属性，Value 支持 string / bool / 整数 / 浮点
+type Attribute struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData 已结束 Span 的快照，交给 Exporter 导出
+type SpanData struct {
+	Name         string      `json:"name"`
+	Kind         SpanKind    `json:"kind"`
+	TraceId      TraceId     `json:"-"`
+	SpanId       SpanId      `json:"-"`
+	ParentSpanId SpanId      `json:"-"`
+	StartTime    time.Time   `json:"-"`
+	EndTime      time.Time   `json:"-"`
+	Attributes   []Attribute `json:"attributes,omitempty"`
+	// ErrorMsg 非空表示 Span 以错误结束
+	ErrorMsg string `json:"error,omitempty"`
+}
+
+// Span 一次操作的计时与属性，方法并发安全，End 可重复调用
+type Span struct {
+	mu     sync.Mutex
+	sc     SpanContext
+	data   SpanData
+	ended  bool
+	export bool
+}
+
+// SpanContext 当前 Span 的传播标识
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// Traceparent 供下游继续链路的 traceparent
+func (s *Span) Traceparent() string {
+	return s.SpanContext().Traceparent()
+}
+
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+// RecordError 把 Span 标记为失败，err 为 nil 时忽略
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.ErrorMsg = err.Error()
+	}
+}
+
+// End 结束 Span 并交给已安装的 Exporter
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.export {
+		enqueue(data)
+	}
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// Start 创建 Span：ctx 中有本地 Span 或远端 SpanContext 时作为其子 Span，否则开启新链路。
+// 未安装 Exporter 时不生成 Span，只把上游链路原样传给下游（没有上游时返回 nil Span）。
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	if !exporterInstalled() {
+		if !parent.IsValid() {
+			return ctx, nil
+		}
+		return ctx, &Span{sc: parent, ended: true}
+	}
+	sc := SpanContext{Flags: flagSampled}
+	if parent.IsValid() {
+		sc.TraceId = parent.TraceId
+		sc.Flags = parent.Flags
+	} else {
+		sc.TraceId = newTraceId()
+	}
+	sc.SpanId = newSpanId()
+	span := &Span{
+		sc:     sc,
+		export: sc.IsSampled(),
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			TraceId:      sc.TraceId,
+			SpanId:       sc.SpanId,
+			ParentSpanId: parent.SpanId,
+			StartTime:    time.Now(),
+			Attributes:   attrs,
+		},
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext 取 ctx 中的本地 Span，没有时返回 nil（nil Span 的方法均可安全调用）
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext 取 ctx 中的父链路标识，本地 Span 优先于远端
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemote 把上游传来的 SpanContext 放入 ctx，之后 Start 的 Span 会接在它下面
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// ContextWithTraceparent 解析 traceparent 并放入 ctx，为空或非法时原样返回 ctx
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemote(ctx, sc)
+}
+
+// TraceparentFromContext 当前 ctx 的 traceparent，用于向下游注入
+func TraceparentFromContext(ctx context.Context) string {
+	return SpanContextFromContext(ctx).Traceparent()
+}
+
+func newTraceId() TraceId {
+	var id TraceId
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanId() SpanId {
+	var id SpanId
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
@@ -89,6 +89,15 @@ if ($LASTEXITCODE -ne 0) {
     exit 1
 }
 
+# Build release packages with the same version ldflags as the packaging script
+Write-Host "Building release packages for $Version..." -ForegroundColor Yellow
+& (Join-Path $scriptDir "一键打包全平台.ps1")
+if (-not $? -or $LASTEXITCODE -ne 0) {
+    Write-Error "Failed to build release packages"
+    exit 1
+}
+Set-Location $scriptDir
+
 # Push tag
 Write-Host "Pushing tag to remote..." -ForegroundColor Yellow
 git push origin $Version
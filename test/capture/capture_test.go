@@ -0,0 +1,238 @@
+package capture_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/capture"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func rpcRequestMsg(from, to string, packetId int) *core.RouteMessage {
+	data, _ := json.Marshal(map[string]any{"rpcUid": from + "-" + to, "packetId": packetId, "methodArgsJsonList": []string{"1"}})
+	dataStr := string(data)
+	mt := core.RouteMessageTypeRpcRequest
+	return &core.RouteMessage{FromRouteId: from, ToRouteId: to, MessageType: &mt, Data: &dataStr}
+}
+
+func messageDataMsg(from, to, data string) *core.RouteMessage {
+	mt := core.RouteMessageTypeMessageData
+	return &core.RouteMessage{FromRouteId: from, ToRouteId: to, MessageType: &mt, Data: &data}
+}
+
+func TestServerCapture_FilterAndReadBack(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, CaptureDir: dir}
+	srv := server.NewServer(cfg)
+
+	filter := capture.Filter{RouteIds: []string{"game-1"}, MessageTypes: []string{"rpcrequest"}, PacketIds: []int{100}}
+	status, err := srv.StartCapture("../escape", filter, capture.Limits{})
+	if err != nil {
+		t.Fatalf("StartCapture error: %v", err)
+	}
+	if filepath.Dir(status.File) != dir {
+		t.Fatalf("capture file should stay inside captureDir, got %s", status.File)
+	}
+	if _, err := srv.StartCapture("other", capture.Filter{}, capture.Limits{}); err == nil {
+		t.Fatalf("second capture should be rejected")
+	}
+
+	srv.HandleRouteMessageForTest(rpcRequestMsg("game-1", "chat-1", 100))
+	srv.HandleRouteMessageForTest(rpcRequestMsg("game-1", "chat-1", 200))
+	srv.HandleRouteMessageForTest(messageDataMsg("game-1", "chat-1", "hello"))
+	srv.HandleRouteMessageForTest(rpcRequestMsg("game-2", "chat-1", 100))
+
+	final, err := srv.StopCapture()
+	if err != nil {
+		t.Fatalf("StopCapture error: %v", err)
+	}
+	if final.Frames != 1 || srv.CaptureStatus().Running {
+		t.Fatalf("unexpected final status: %#v", final)
+	}
+
+	header, records, err := capture.ReadFile(status.File)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if header.Format != capture.FormatName || len(header.Filter.PacketIds) != 1 {
+		t.Fatalf("unexpected header: %#v", header)
+	}
+	if len(records) != 1 || records[0].Session != "game-1" || records[0].PacketId != 100 || records[0].Type != "RpcRequest" {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+}
+
+func TestServerCapture_StopsAtLimits(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, CaptureDir: t.TempDir()}
+	srv := server.NewServer(cfg)
+
+	status, err := srv.StartCapture("frames", capture.Filter{}, capture.Limits{MaxFrames: 2})
+	if err != nil {
+		t.Fatalf("StartCapture error: %v", err)
+	}
+	if status.Limits.MaxFrames != 2 || status.Limits.MaxBytes != capture.DefaultMaxBytes || status.Limits.MaxDurationMs != capture.DefaultMaxDurationMs {
+		t.Fatalf("unset limits should use defaults: %#v", status.Limits)
+	}
+	for i := 0; i < 5; i++ {
+		srv.HandleRouteMessageForTest(messageDataMsg("game-1", "chat-1", "hello"))
+	}
+	waitForCapture(t, srv)
+	final := srv.CaptureStatus()
+	if final.Frames != 2 || final.StopReason != capture.StopReasonMaxFrames || final.Bytes <= 0 {
+		t.Fatalf("unexpected status after frame limit: %#v", final)
+	}
+	if _, records, err := capture.ReadFile(status.File); err != nil || len(records) != 2 {
+		t.Fatalf("file should hold 2 records, got %d (%v)", len(records), err)
+	}
+
+	if _, err := srv.StartCapture("duration", capture.Filter{}, capture.Limits{MaxDurationMs: 50}); err != nil {
+		t.Fatalf("StartCapture error: %v", err)
+	}
+	if srv.CaptureStatus().StopReason != "" {
+		t.Fatalf("new capture should clear the previous stop reason")
+	}
+	waitForCapture(t, srv)
+	if reason := srv.CaptureStatus().StopReason; reason != capture.StopReasonMaxDuration {
+		t.Fatalf("unexpected stop reason: %q", reason)
+	}
+}
+
+// waitForCapture 等待抓包自动停止
+func waitForCapture(t *testing.T, srv *server.Server) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.CaptureStatus().Running {
+		if time.Now().After(deadline) {
+			t.Fatalf("capture did not stop at its limit")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCapture_RejectsUnknownMessageType(t *testing.T) {
+	if _, err := capture.Create(filepath.Join(t.TempDir(), "x.jsonl"), capture.Filter{MessageTypes: []string{"Bogus"}}, capture.Limits{}); err == nil {
+		t.Fatalf("unknown message type should be rejected")
+	}
+}
+
+func TestReplayToCenter_PreservesOrderPerSession(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer ln.Close()
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer wg.Done()
+				defer conn.Close()
+				for {
+					payload, err := core.ReadFrame(conn)
+					if err != nil {
+						return
+					}
+					msg, _ := core.DecodeRouteMessagePayload(payload)
+					mu.Lock()
+					received[msg.FromRouteId] = append(received[msg.FromRouteId], *msg.Data)
+					mu.Unlock()
+				}
+			}(conn)
+		}
+	}()
+
+	base := time.Now()
+	records := []capture.Record{
+		capture.NewRecord(base, capture.DirectionIn, "a", messageDataMsg("a", "b", "1")),
+		capture.NewRecord(base.Add(20*time.Millisecond), capture.DirectionIn, "b", messageDataMsg("b", "a", "x")),
+		capture.NewRecord(base.Add(40*time.Millisecond), capture.DirectionIn, "a", messageDataMsg("a", "b", "2")),
+	}
+	start := time.Now()
+	result, err := capture.ReplayToCenter(context.Background(), ln.Addr().String(), records, capture.ReplayOptions{Speed: 2})
+	if err != nil {
+		t.Fatalf("ReplayToCenter error: %v", err)
+	}
+	// 40ms 的原始跨度按 2 倍速至少需要 20ms
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("timing not preserved, elapsed %v", elapsed)
+	}
+	if result.Sent != 3 || result.Sessions != 2 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	wg.Wait()
+	if got := received["a"]; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatalf("unexpected frames for session a: %v", got)
+	}
+	if got := received["b"]; len(got) != 1 || got[0] != "x" {
+		t.Fatalf("unexpected frames for session b: %v", got)
+	}
+}
+
+func TestReplayToStub_SendsOnlyRpcRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			payload, err := core.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			var req struct {
+				RpcUid   string `json:"rpcUid"`
+				PacketId int    `json:"packetId"`
+			}
+			_ = json.Unmarshal(payload, &req)
+			resp, _ := json.Marshal(map[string]any{"rpcUid": req.RpcUid, "packetId": req.PacketId, "resultValueStr": "ok"})
+			_ = core.WriteFrame(conn, resp)
+		}
+	}()
+
+	now := time.Now()
+	records := []capture.Record{
+		capture.NewRecord(now, capture.DirectionIn, "a", rpcRequestMsg("a", "b", 7)),
+		capture.NewRecord(now, capture.DirectionIn, "a", messageDataMsg("a", "b", "skip")),
+	}
+	var mu sync.Mutex
+	var responses []string
+	result, err := capture.ReplayToStub(context.Background(), ln.Addr().String(), records, capture.ReplayOptions{
+		OnFrame: func(_ string, payload []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			responses = append(responses, capture.DescribeFrame(payload))
+		},
+	})
+	if err != nil {
+		t.Fatalf("ReplayToStub error: %v", err)
+	}
+	if result.Sent != 1 || result.Skipped != 1 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(responses) != 1 {
+		t.Fatalf("expected 1 response, got %v", responses)
+	}
+}
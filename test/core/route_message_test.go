@@ -59,3 +59,33 @@ func TestRouteMessageEncodeDecodeNullData(t *testing.T) {
 		t.Fatalf("expected nil data, got %v", decoded.Data)
 	}
 }
+
+func TestRouteMessageEncodeDecodeTraceparent(t *testing.T) {
+	mt := core.RouteMessageTypeRpcRequest
+	data := "{\"rpcUid\":\"relay-1\"}"
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	msg := &core.RouteMessage{FromRouteId: "node-1", ToRouteId: "node-2", MessageType: &mt, Data: &data, Traceparent: traceparent}
+
+	payload, err := msg.EncodePayload()
+	if err != nil {
+		t.Fatalf("EncodePayload error: %v", err)
+	}
+	decoded, err := core.DecodeRouteMessagePayload(payload)
+	if err != nil {
+		t.Fatalf("DecodeRouteMessagePayload error: %v", err)
+	}
+	if decoded.Traceparent != traceparent || decoded.Data == nil || *decoded.Data != data {
+		t.Fatalf("traceparent roundtrip mismatch: %+v", decoded)
+	}
+
+	// 没有链路时编码与旧格式一致
+	msg.Traceparent = ""
+	plain, _ := msg.EncodePayload()
+	if len(plain) != len(payload)-4-len(traceparent) {
+		t.Fatalf("message without traceparent should not carry the trailer: %d vs %d", len(plain), len(payload))
+	}
+	decoded, _ = core.DecodeRouteMessagePayload(plain)
+	if decoded.Traceparent != "" {
+		t.Fatalf("unexpected traceparent: %q", decoded.Traceparent)
+	}
+}
@@ -0,0 +1,83 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+// localProvider 直接调用本进程的 StubManager，模拟 Relay/Direct 返回值的序列化规则。
+type localProvider struct{}
+
+func (p *localProvider) Call(packetId int, timeout time.Duration, args []json.RawMessage) (string, error) {
+	result, err := rpc.ServerStubManagerInstance().Invoke(packetId, args)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := result.(string); ok {
+		return s, nil
+	}
+	if result == nil {
+		return "", nil
+	}
+	b, _ := json.Marshal(result)
+	return string(b), nil
+}
+
+type addReq struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type addResp struct {
+	Sum int `json:"sum"`
+}
+
+func TestInvoke_TypedRequestResponse(t *testing.T) {
+	rpc.ServerStubManagerInstance().Reset()
+	defer rpc.ServerStubManagerInstance().Reset()
+
+	if err := rpc.RegisterRpcFunc(rpc.RpcFuncMeta{PacketId: 3001}, func(req addReq) (addResp, error) {
+		return addResp{Sum: req.A + req.B}, nil
+	}); err != nil {
+		t.Fatalf("RegisterRpcFunc error: %v", err)
+	}
+
+	resp, err := rpc.Invoke[addReq, addResp](&localProvider{}, 3001, addReq{A: 2, B: 5})
+	if err != nil {
+		t.Fatalf("Invoke error: %v", err)
+	}
+	if resp.Sum != 7 {
+		t.Fatalf("unexpected sum: %d", resp.Sum)
+	}
+}
+
+func TestInvokeArgs_StringResultAndNilProvider(t *testing.T) {
+	rpc.ServerStubManagerInstance().Reset()
+	defer rpc.ServerStubManagerInstance().Reset()
+
+	if err := rpc.RegisterRpcFunc(rpc.RpcFuncMeta{PacketId: 3002}, func(name string, times int) (string, error) {
+		out := ""
+		for i := 0; i < times; i++ {
+			out += name
+		}
+		return out, nil
+	}); err != nil {
+		t.Fatalf("RegisterRpcFunc error: %v", err)
+	}
+
+	// string 返回值服务端不做 JSON 编码，客户端应直接拿到原文。
+	out, err := rpc.InvokeArgs[string](&localProvider{}, 3002, time.Second, "ab", 3)
+	if err != nil {
+		t.Fatalf("InvokeArgs error: %v", err)
+	}
+	if out != "ababab" {
+		t.Fatalf("unexpected result: %q", out)
+	}
+
+	if _, err := rpc.InvokeArgs[string](nil, 3002, time.Second); err == nil {
+		t.Fatal("expected error for nil provider")
+	}
+}
@@ -0,0 +1,87 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+type schemaAddress struct {
+	City string `json:"city"`
+}
+
+type schemaPlayer struct {
+	Name    string            `json:"name"`
+	Level   int               `json:"level,omitempty"`
+	Tags    []string          `json:"tags"`
+	Address *schemaAddress    `json:"address"`
+	Extra   map[string]int    `json:"extra,omitempty"`
+	Ignored string            `json:"-"`
+	Attrs   map[string]string `json:"attrs"`
+}
+
+func TestJsonSchemaForType_Struct(t *testing.T) {
+	schema := rpc.JsonSchemaForType(reflect.TypeOf(schemaPlayer{}))
+	if schema["type"] != "object" {
+		t.Fatalf("expected object schema, got %#v", schema)
+	}
+	props := schema["properties"].(map[string]any)
+	if _, ok := props["Ignored"]; ok {
+		t.Fatalf("json:\"-\" field should be skipped")
+	}
+	if props["name"].(map[string]any)["type"] != "string" {
+		t.Fatalf("unexpected name schema: %#v", props["name"])
+	}
+	if props["tags"].(map[string]any)["type"] != "array" {
+		t.Fatalf("unexpected tags schema: %#v", props["tags"])
+	}
+	if _, ok := props["address"].(map[string]any)["anyOf"]; !ok {
+		t.Fatalf("pointer field should be nullable: %#v", props["address"])
+	}
+	required := schema["required"].([]string)
+	want := map[string]bool{"name": true, "tags": true, "attrs": true}
+	if len(required) != len(want) {
+		t.Fatalf("unexpected required: %v", required)
+	}
+	for _, name := range required {
+		if !want[name] {
+			t.Fatalf("unexpected required field %s", name)
+		}
+	}
+}
+
+func TestRegisterRpcFunc_ReportsSchemas(t *testing.T) {
+	rpc.ServerStubManagerInstance().Reset()
+	defer rpc.ServerStubManagerInstance().Reset()
+
+	err := rpc.RegisterRpcFunc(rpc.RpcFuncMeta{PacketId: 7, MethodName: "GetPlayer"}, func(id int64) (*schemaPlayer, error) {
+		return &schemaPlayer{}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterRpcFunc error: %v", err)
+	}
+	stubs := rpc.ServerStubManagerInstance().GetAllStubsMetadata()
+	if len(stubs) != 1 {
+		t.Fatalf("expected 1 stub, got %d", len(stubs))
+	}
+	meta := stubs[0]
+	if len(meta.ParameterSchemaJson) != 1 {
+		t.Fatalf("expected 1 parameter schema, got %v", meta.ParameterSchemaJson)
+	}
+	var param map[string]any
+	if err := json.Unmarshal([]byte(meta.ParameterSchemaJson[0]), &param); err != nil || param["type"] != "integer" {
+		t.Fatalf("unexpected parameter schema: %s", meta.ParameterSchemaJson[0])
+	}
+	if meta.ReturnType == "" || meta.ReturnSchemaJson == "" {
+		t.Fatalf("return type/schema should be reported: %#v", meta)
+	}
+	var result map[string]any
+	if err := json.Unmarshal([]byte(meta.ReturnSchemaJson), &result); err != nil {
+		t.Fatalf("invalid return schema: %v", err)
+	}
+	if _, ok := result["anyOf"]; !ok {
+		t.Fatalf("pointer return should be nullable: %s", meta.ReturnSchemaJson)
+	}
+}
@@ -0,0 +1,142 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+type BattleService struct {
+	prefix string
+}
+
+func (s *BattleService) Attack(target string, damage int) (string, error) {
+	return s.prefix + target + ":" + strconv.Itoa(damage), nil
+}
+
+func (s *BattleService) Ping() string {
+	return "pong"
+}
+
+func (s *BattleService) NotExposed() string {
+	return "hidden"
+}
+
+func (s *BattleService) RpcPacketIds() map[string]int {
+	return map[string]int{"Attack": 5101, "Ping": 5102}
+}
+
+func TestRegisterService_UsesStructNameAndPacketIds(t *testing.T) {
+	mgr := rpc.ServerStubManagerInstance()
+	mgr.Reset()
+	defer mgr.Reset()
+
+	if err := mgr.RegisterService(&BattleService{prefix: "hit-"}, nil); err != nil {
+		t.Fatalf("RegisterService error: %v", err)
+	}
+
+	stubs := mgr.GetAllStubsMetadata()
+	if len(stubs) != 2 {
+		t.Fatalf("expected 2 stubs, got %d", len(stubs))
+	}
+	for _, s := range stubs {
+		if s.ClassName != "BattleService" {
+			t.Fatalf("expected className BattleService, got %q", s.ClassName)
+		}
+	}
+
+	out, err := mgr.Invoke(5101, []json.RawMessage{json.RawMessage(`"boss"`), json.RawMessage("2")})
+	if err != nil {
+		t.Fatalf("Invoke error: %v", err)
+	}
+	if out != "hit-boss:2" {
+		t.Fatalf("unexpected result: %#v", out)
+	}
+}
+
+func TestRegisterService_DuplicatePacketIdRejectedAtomically(t *testing.T) {
+	mgr := rpc.ServerStubManagerInstance()
+	mgr.Reset()
+	defer mgr.Reset()
+
+	if err := rpc.RegisterRpcFunc(rpc.RpcFuncMeta{PacketId: 5102}, func() string { return "occupied" }); err != nil {
+		t.Fatalf("RegisterRpcFunc error: %v", err)
+	}
+
+	// 5102 已被占用：整个服务都不应注册，5101 也不能残留。
+	err := mgr.RegisterService(&BattleService{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "5102") {
+		t.Fatalf("expected duplicate packetId error, got %v", err)
+	}
+	if _, ok := mgr.GetHandler(5101); ok {
+		t.Fatal("service should not be partially registered")
+	}
+
+	err = mgr.RegisterService(&BattleService{}, map[string]int{"Attack": 1, "Ping": 1})
+	if err == nil || !strings.Contains(err.Error(), "重复") {
+		t.Fatalf("expected duplicate packetId inside service, got %v", err)
+	}
+}
+
+func TestUnregisterService_RemovesStubs(t *testing.T) {
+	mgr := rpc.ServerStubManagerInstance()
+	mgr.Reset()
+	defer mgr.Reset()
+
+	if err := mgr.RegisterService(&BattleService{}, nil); err != nil {
+		t.Fatalf("RegisterService error: %v", err)
+	}
+	if err := mgr.UnregisterService("BattleService"); err != nil {
+		t.Fatalf("UnregisterService error: %v", err)
+	}
+	if len(mgr.GetAllStubsMetadata()) != 0 {
+		t.Fatalf("expected no stubs after unregister, got %d", len(mgr.GetAllStubsMetadata()))
+	}
+	if err := mgr.UnregisterService("BattleService"); err == nil {
+		t.Fatal("expected error when unregistering twice")
+	}
+}
+
+type TaggedShopService struct {
+	_ struct{} `packetId:"Buy=5301, Price=5302"`
+}
+
+func (s *TaggedShopService) Buy(item string, count int) string {
+	return item + "x" + strconv.Itoa(count)
+}
+
+func (s *TaggedShopService) Price(item string) int {
+	return len(item)
+}
+
+type BadTagService struct {
+	_ struct{} `packetId:"Buy"`
+}
+
+func (s *BadTagService) Buy() {}
+
+func TestRegisterService_PacketIdsFromStructTag(t *testing.T) {
+	mgr := rpc.ServerStubManagerInstance()
+	mgr.Reset()
+	defer mgr.Reset()
+
+	if err := mgr.RegisterService(&TaggedShopService{}, nil); err != nil {
+		t.Fatalf("RegisterService error: %v", err)
+	}
+	stubs := mgr.GetAllStubsMetadata()
+	if len(stubs) != 2 {
+		t.Fatalf("expected 2 stubs from tag, got %#v", stubs)
+	}
+	out, err := mgr.Invoke(5301, []json.RawMessage{json.RawMessage(`"sword"`), json.RawMessage("3")})
+	if err != nil || out != "swordx3" {
+		t.Fatalf("unexpected invoke result: %#v, %v", out, err)
+	}
+
+	err = mgr.RegisterService(&BadTagService{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "packetId 标签") {
+		t.Fatalf("malformed tag should be rejected, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package rpc_test
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/neko233-com/virtual-router-go/internal/core"
@@ -65,3 +66,20 @@ func TestStubManagerCheckInitialized_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestStubManagerRegisterStub_DuplicatePacketIdRejected(t *testing.T) {
+	mgr := rpc.ServerStubManagerInstance()
+	mgr.Reset()
+	defer mgr.Reset()
+
+	handler := func(args []json.RawMessage) (any, error) { return nil, nil }
+	if err := mgr.RegisterStub(core.RpcStubMetadata{PacketId: 3, ClassName: "A", MethodName: "First"}, handler); err != nil {
+		t.Fatalf("first RegisterStub error: %v", err)
+	}
+	err := mgr.RegisterStub(core.RpcStubMetadata{PacketId: 3, ClassName: "B", MethodName: "Second"}, handler)
+	if !errors.Is(err, rpc.ErrDuplicatePacketId) {
+		t.Fatalf("expected ErrDuplicatePacketId, got %v", err)
+	}
+	if stubs := mgr.GetAllStubsMetadata(); len(stubs) != 1 || stubs[0].MethodName != "First" {
+		t.Fatalf("duplicate registration should not overwrite: %#v", stubs)
+	}
+}
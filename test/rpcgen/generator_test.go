@@ -0,0 +1,72 @@
+package rpcgen_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/neko233-com/virtual-router-go/internal/rpcgen"
+)
+
+const battleSource = `package battle
+
+import "github.com/example/proto"
+
+type AttackReq struct{ Target string }
+
+type BattleService interface {
+	// Attack 发起攻击
+	// rpc:packetId=1001
+	// rpc:param req 攻击参数
+	Attack(req AttackReq) (*proto.AttackResp, error)
+	//rpc:packetId=1002
+	Ping() error
+	// 未注解的方法不会生成
+	Internal()
+}
+`
+
+func TestGenerate_RegisterAndClientStubs(t *testing.T) {
+	out, err := rpcgen.Generate("battle.go", []byte(battleSource), []string{"BattleService"})
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	code := string(out)
+
+	expects := []string{
+		"// Code generated by rpc-gen. DO NOT EDIT.",
+		"package battle",
+		`"github.com/example/proto"`,
+		"func RegisterBattleService(impl BattleService) error {",
+		"PacketId:    1001,",
+		`ClassName:   "BattleService",`,
+		`MethodName:  "Attack",`,
+		`{Name: "req", Description: "攻击参数"},`,
+		"impl.Attack); err != nil",
+		"func (c *BattleServiceClient) Attack(req AttackReq) (*proto.AttackResp, error) {",
+		"VirtualRouterClient.InvokeArgs[*proto.AttackResp](c.provider, 1001, c.timeout, req)",
+		"func (c *BattleServiceClient) Ping() error {",
+	}
+	for _, want := range expects {
+		if !strings.Contains(code, want) {
+			t.Fatalf("generated code missing %q\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "Internal") {
+		t.Fatalf("unannotated method should not be generated\n%s", code)
+	}
+}
+
+func TestGenerate_DuplicatePacketIdRejected(t *testing.T) {
+	src := `package dup
+
+type S interface {
+	// rpc:packetId=1
+	A() error
+	// rpc:packetId=1
+	B() error
+}
+`
+	if _, err := rpcgen.Generate("dup.go", []byte(src), nil); err == nil || !strings.Contains(err.Error(), "重复") {
+		t.Fatalf("expected duplicate packetId error, got %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+	"github.com/neko233-com/virtual-router-go/internal/tracing"
+)
+
+const upstreamTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// memoryExporter 收集导出的 Span，验证 Exporter 可插拔
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *memoryExporter) ExportSpans(_ context.Context, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error { return nil }
+
+func (e *memoryExporter) byName(t *testing.T, name string) tracing.SpanData {
+	t.Helper()
+	if err := tracing.Flush(context.Background()); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("span %s not exported, got %d spans", name, len(e.spans))
+	return tracing.SpanData{}
+}
+
+func installMemoryExporter(t *testing.T) *memoryExporter {
+	t.Helper()
+	exp := &memoryExporter{}
+	tracing.SetExporter(exp)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+	return exp
+}
+
+func registerEchoStub(t *testing.T, packetId int) {
+	t.Helper()
+	mgr := rpc.ServerStubManagerInstance()
+	err := mgr.RegisterStub(core.RpcStubMetadata{PacketId: packetId, ClassName: "Battle", MethodName: "Echo"}, func(args []json.RawMessage) (any, error) {
+		return string(args[0]), nil
+	})
+	if err != nil {
+		t.Fatalf("register stub error: %v", err)
+	}
+	t.Cleanup(func() { mgr.UnregisterStub(packetId) })
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	sc, err := tracing.ParseTraceparent(upstreamTraceparent)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if sc.TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanId.String() != "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Fatalf("unexpected span context: %+v", sc)
+	}
+	if sc.Traceparent() != upstreamTraceparent {
+		t.Fatalf("format mismatch: %s", sc.Traceparent())
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	} {
+		if _, err := tracing.ParseTraceparent(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestStartContinuesRemoteParent(t *testing.T) {
+	installMemoryExporter(t)
+	ctx := tracing.ContextWithTraceparent(context.Background(), upstreamTraceparent)
+	ctx, parent := tracing.Start(ctx, "parent", tracing.SpanKindServer)
+	_, child := tracing.Start(ctx, "child", tracing.SpanKindClient)
+
+	if parent.SpanContext().TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("parent should join upstream trace: %s", parent.Traceparent())
+	}
+	if child.SpanContext().TraceId != parent.SpanContext().TraceId || child.SpanContext().SpanId == parent.SpanContext().SpanId {
+		t.Fatalf("child should share trace with new span id: parent=%s child=%s", parent.Traceparent(), child.Traceparent())
+	}
+
+	_, root := tracing.Start(context.Background(), "root", tracing.SpanKindInternal)
+	if !root.SpanContext().IsValid() || root.SpanContext().TraceId == parent.SpanContext().TraceId {
+		t.Fatalf("root span should start a new trace: %s", root.Traceparent())
+	}
+}
+
+func TestStartWithoutExporterOnlyPropagates(t *testing.T) {
+	tracing.SetExporter(nil)
+	if _, root := tracing.Start(context.Background(), "root", tracing.SpanKindInternal); root != nil || root.Traceparent() != "" {
+		t.Fatalf("no span should be created without exporter: %s", root.Traceparent())
+	}
+	ctx := tracing.ContextWithTraceparent(context.Background(), upstreamTraceparent)
+	_, span := tracing.Start(ctx, "work", tracing.SpanKindServer)
+	if span.Traceparent() != upstreamTraceparent {
+		t.Fatalf("upstream trace should pass through unchanged: %s", span.Traceparent())
+	}
+	span.SetAttributes(tracing.String("k", "v"))
+	span.End()
+}
+
+// loopbackSender 把 RelayClient 发出的请求直接交给本进程的 HandleRelayRpcRequest，模拟经过 Router Center 转发
+type loopbackSender struct {
+	routeId      string
+	requests     []rpc.RpcRequest
+	traceparents []string
+}
+
+func (s *loopbackSender) Send(toRouteId string, msgType core.RouteMessageType, obj any) error {
+	return s.SendWithTraceparent(toRouteId, msgType, obj, "")
+}
+
+func (s *loopbackSender) SendWithTraceparent(toRouteId string, msgType core.RouteMessageType, obj any, traceparent string) error {
+	data, _ := json.Marshal(obj)
+	dataStr := string(data)
+	mt := msgType
+	msg := &core.RouteMessage{FromRouteId: s.routeId, ToRouteId: toRouteId, MessageType: &mt, Data: &dataStr, Traceparent: traceparent}
+	switch msgType {
+	case core.RouteMessageTypeRpcRequest:
+		var req rpc.RpcRequest
+		_ = json.Unmarshal(data, &req)
+		s.requests = append(s.requests, req)
+		s.traceparents = append(s.traceparents, traceparent)
+		rpc.HandleRelayRpcRequest(msg, s)
+	case core.RouteMessageTypeRpcResponse:
+		rpc.HandleRelayRpcResponse(msg)
+	}
+	return nil
+}
+
+func (s *loopbackSender) IsConnected() bool                  { return true }
+func (s *loopbackSender) RouteId() string                    { return s.routeId }
+func (s *loopbackSender) AwaitConnected(time.Duration) error { return nil }
+
+func TestRelayClientPropagatesToStubInvoke(t *testing.T) {
+	exp := installMemoryExporter(t)
+	registerEchoStub(t, 9301)
+
+	sender := &loopbackSender{routeId: "game-1"}
+	client := rpc.NewRelayClient("battle-1", sender)
+	ctx := tracing.ContextWithTraceparent(context.Background(), upstreamTraceparent)
+	out, err := client.CallContext(ctx, 9301, time.Second, []json.RawMessage{json.RawMessage(`"hi"`)})
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+	if out != `"hi"` {
+		t.Fatalf("unexpected result: %s", out)
+	}
+
+	// traceparent 放在 RouteMessage 上，请求体不再携带
+	if len(sender.traceparents) != 1 || sender.traceparents[0] == "" || sender.requests[0].Traceparent != "" {
+		t.Fatalf("route message should carry traceparent: %v %+v", sender.traceparents, sender.requests)
+	}
+	call := exp.byName(t, "RelayClient.Call")
+	invoke := exp.byName(t, "StubManager.Invoke")
+	if call.TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || call.ParentSpanId.String() != "00f067aa0ba902b7" {
+		t.Fatalf("client span should continue upstream: trace=%s parent=%s", call.TraceId, call.ParentSpanId)
+	}
+	if invoke.TraceId != call.TraceId || invoke.ParentSpanId != call.SpanId {
+		t.Fatalf("stub span should be child of client span: invoke=%+v call=%+v", invoke, call)
+	}
+	if call.Kind != tracing.SpanKindClient || invoke.Kind != tracing.SpanKindServer {
+		t.Fatalf("unexpected kinds: call=%v invoke=%v", call.Kind, invoke.Kind)
+	}
+}
+
+func TestForwardReplacesTraceparentWithCenterSpan(t *testing.T) {
+	exp := installMemoryExporter(t)
+	srv := server.NewServer(&config.RouterServerConfig{})
+
+	conn, peer := net.Pipe()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = peer.Close()
+	})
+	session := server.NewRouterSession("battle-1", conn, core.RpcServerInfo{}, &sync.Mutex{})
+	if _, err := srv.SessionManager().UpsertSession("battle-1", session); err != nil {
+		t.Fatalf("upsert error: %v", err)
+	}
+	received := make(chan *core.RouteMessage, 1)
+	go func() {
+		payload, err := core.ReadFrame(peer)
+		if err != nil {
+			return
+		}
+		msg, _ := core.DecodeRouteMessagePayload(payload)
+		received <- msg
+	}()
+
+	req := rpc.RpcRequest{FromRouteId: "game-1", ToRouteId: "battle-1", RpcUid: "relay-1", PacketId: 9302, MethodArgsJsonList: []string{}}
+	data, _ := json.Marshal(req)
+	dataStr := string(data)
+	mt := core.RouteMessageTypeRpcRequest
+	srv.HandleRouteMessageForTest(&core.RouteMessage{FromRouteId: "game-1", ToRouteId: "battle-1", MessageType: &mt, Data: &dataStr, Traceparent: upstreamTraceparent})
+
+	var msg *core.RouteMessage
+	select {
+	case msg = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("target did not receive forwarded request")
+	}
+	if msg.Data == nil || *msg.Data != dataStr {
+		t.Fatalf("forwarded data should be untouched: %v", msg.Data)
+	}
+
+	span := exp.byName(t, "Server.forwardToTarget")
+	if span.ParentSpanId.String() != "00f067aa0ba902b7" {
+		t.Fatalf("forward span parent mismatch: %s", span.ParentSpanId)
+	}
+	want := tracing.SpanContext{TraceId: span.TraceId, SpanId: span.SpanId, Flags: 0x01}.Traceparent()
+	if msg.Traceparent != want {
+		t.Fatalf("traceparent should point to center span: got %s want %s", msg.Traceparent, want)
+	}
+}
+
+func TestGatewayJoinsHttpTraceparent(t *testing.T) {
+	exp := installMemoryExporter(t)
+	registerEchoStub(t, 9303)
+	srv := server.NewServer(&config.RouterServerConfig{})
+	h := server.NewHttpServer(&config.RouterServerConfig{AdminPassword: "pwd"}, srv)
+
+	// 模拟节点：收到 Router Center 的请求后走真实的 HandleRelayRpcRequest
+	conn, peer := net.Pipe()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = peer.Close()
+	})
+	node := &centerSender{srv: srv, routeId: "battle-1"}
+	go func() {
+		for {
+			payload, err := core.ReadFrame(peer)
+			if err != nil {
+				return
+			}
+			msg, err := core.DecodeRouteMessagePayload(payload)
+			if err == nil {
+				rpc.HandleRelayRpcRequest(msg, node)
+			}
+		}
+	}()
+	stubs := []core.RpcStubMetadata{{PacketId: 9303, ClassName: "Battle", MethodName: "Echo"}}
+	session := server.NewRouterSession("battle-1", conn, core.RpcServerInfo{Stubs: stubs}, &sync.Mutex{})
+	if _, err := srv.SessionManager().UpsertSession("battle-1", session); err != nil {
+		t.Fatalf("upsert error: %v", err)
+	}
+
+	token, err := server.GenerateToken("admin")
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/rpc/battle-1/9303", strings.NewReader(`["hi"]`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", upstreamTraceparent)
+	rec := httptest.NewRecorder()
+	h.HandleRpcGatewayForTest(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("gateway status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Data struct {
+			TraceId string `json:"traceId"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.Data.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("result should expose upstream trace id: %s", rec.Body.String())
+	}
+
+	gw := exp.byName(t, "Server.InvokeRpc")
+	invoke := exp.byName(t, "StubManager.Invoke")
+	if gw.ParentSpanId.String() != "00f067aa0ba902b7" {
+		t.Fatalf("gateway span should join http trace: parent=%s", gw.ParentSpanId)
+	}
+	if invoke.TraceId != gw.TraceId || invoke.ParentSpanId != gw.SpanId {
+		t.Fatalf("stub span should be child of gateway span: invoke=%+v gw=%+v", invoke, gw)
+	}
+}
+
+// centerSender 节点把响应交还给 Router Center
+type centerSender struct {
+	srv     *server.Server
+	routeId string
+}
+
+func (s *centerSender) Send(toRouteId string, msgType core.RouteMessageType, obj any) error {
+	data, _ := json.Marshal(obj)
+	dataStr := string(data)
+	mt := msgType
+	s.srv.HandleRpcResponseForTest(&core.RouteMessage{FromRouteId: s.routeId, ToRouteId: toRouteId, MessageType: &mt, Data: &dataStr})
+	return nil
+}
+
+func (s *centerSender) IsConnected() bool                  { return true }
+func (s *centerSender) RouteId() string                    { return s.routeId }
+func (s *centerSender) AwaitConnected(time.Duration) error { return nil }
+
+func TestStdoutExporterWritesJsonLines(t *testing.T) {
+	var buf bytes.Buffer
+	tracing.SetExporter(tracing.NewStdoutExporter(&buf, "game-1"))
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	ctx := tracing.ContextWithTraceparent(context.Background(), upstreamTraceparent)
+	_, span := tracing.Start(ctx, "work", tracing.SpanKindInternal, tracing.Int("rpc.packet_id", 7))
+	span.RecordError(io.EOF)
+	span.End()
+	span.End()
+	if err := tracing.Flush(context.Background()); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one line, got %q", buf.String())
+	}
+	var line tracing.StdoutSpan
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatalf("line is not json: %v", err)
+	}
+	if line.Service != "game-1" || line.Name != "work" || line.ParentSpanId != "00f067aa0ba902b7" || line.Error != "EOF" {
+		t.Fatalf("unexpected line: %+v", line)
+	}
+}
+
+func TestOTLPHTTPExporterPostsTraces(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		path   string
+		header string
+		body   map[string]any
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		header = r.Header.Get("X-Token")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	tracing.SetExporter(tracing.NewOTLPHTTPExporter(collector.URL, "virtual-router-center", map[string]string{"X-Token": "abc"}))
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+	ctx := tracing.ContextWithTraceparent(context.Background(), upstreamTraceparent)
+	_, span := tracing.Start(ctx, "Server.InvokeRpc", tracing.SpanKindServer, tracing.Int("rpc.packet_id", 1001))
+	span.RecordError(io.ErrUnexpectedEOF)
+	span.End()
+	if err := tracing.Flush(context.Background()); err != nil {
+		t.Fatalf("export error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path != "/v1/traces" || header != "abc" {
+		t.Fatalf("unexpected request: path=%s header=%s", path, header)
+	}
+	raw, _ := json.Marshal(body)
+	for _, want := range []string{
+		`"service.name"`, `"stringValue":"virtual-router-center"`,
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`, `"parentSpanId":"00f067aa0ba902b7"`,
+		`"kind":2`, `"intValue":"1001"`, `"code":2`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Fatalf("otlp body missing %s: %s", want, raw)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package virtual_router_client_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/neko233-com/virtual-router-go/VirtualRouterClient"
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+func TestRegisterRpcStub_ConflictKeepsFirstAndTryReturnsError(t *testing.T) {
+	mgr := rpc.ServerStubManagerInstance()
+	mgr.Reset()
+	defer mgr.Reset()
+
+	handler := func(args []json.RawMessage) (any, error) { return nil, nil }
+	VirtualRouterClient.RegisterRpcStub(VirtualRouterClient.RpcStubMetadata{PacketId: 11, ClassName: "A", MethodName: "First"}, handler)
+	// 旧接口遇到冲突只记录日志，不覆盖已注册的 Stub
+	VirtualRouterClient.RegisterRpcStub(VirtualRouterClient.RpcStubMetadata{PacketId: 11, ClassName: "B", MethodName: "Second"}, handler)
+
+	err := VirtualRouterClient.TryRegisterRpcStub(VirtualRouterClient.RpcStubMetadata{PacketId: 11, ClassName: "C", MethodName: "Third"}, handler)
+	if !errors.Is(err, VirtualRouterClient.ErrDuplicatePacketId) {
+		t.Fatalf("expected ErrDuplicatePacketId, got %v", err)
+	}
+	if stubs := mgr.GetAllStubsMetadata(); len(stubs) != 1 || stubs[0].MethodName != "First" {
+		t.Fatalf("conflicting registrations should not overwrite: %#v", stubs)
+	}
+}
@@ -0,0 +1,64 @@
+package virtual_router_client_test
+
+import (
+	"testing"
+
+	clientpkg "github.com/neko233-com/virtual-router-go/internal/VirtualRouterClient"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func TestRouteTable_ApplyDeltaAndSnapshot(t *testing.T) {
+	clientpkg.ResetRouteTableForTest()
+	t.Cleanup(clientpkg.ResetRouteTableForTest)
+	table := clientpkg.RouteTableInstance()
+
+	table.ApplyRouteDelta(core.RouteTableDelta{Version: 3, Snapshot: true, Upserts: []core.RouteNode{{RouterId: "a"}, {RouterId: "b"}}})
+	table.ApplyRouteDelta(core.RouteTableDelta{FromVersion: 3, Version: 5, Upserts: []core.RouteNode{{RouterId: "c"}}, Removes: []string{"a"}})
+	if table.RouteVersion() != 5 {
+		t.Fatalf("expected version 5, got %d", table.RouteVersion())
+	}
+	if _, err := table.GetOrCreateRpcClient("a"); err == nil {
+		t.Fatal("removed node should be gone")
+	}
+
+	// 完整路由表会删除其中不存在的节点
+	table.ApplyRouteDelta(core.RouteTableDelta{Version: 9, Snapshot: true, Upserts: []core.RouteNode{{RouterId: "c"}}})
+	if _, err := table.GetOrCreateRpcClient("b"); err == nil {
+		t.Fatal("node missing from snapshot should be removed")
+	}
+	table.ResetRouteVersion()
+	if table.RouteVersion() != 0 || !table.HasAnyRouteNode() {
+		t.Fatal("reset should only clear the version")
+	}
+}
+
+func TestRouteTable_DeltaGapAndStalePush(t *testing.T) {
+	clientpkg.ResetRouteTableForTest()
+	t.Cleanup(clientpkg.ResetRouteTableForTest)
+	table := clientpkg.RouteTableInstance()
+
+	// 尚未拿到完整路由表时收到的推送直接忽略
+	if !table.ApplyRouteDelta(core.RouteTableDelta{FromVersion: 4, Version: 5, Upserts: []core.RouteNode{{RouterId: "x"}}}) || table.HasAnyRouteNode() {
+		t.Fatal("push before snapshot should be ignored")
+	}
+	table.ApplyRouteDelta(core.RouteTableDelta{Version: 5, Snapshot: true, Upserts: []core.RouteNode{{RouterId: "a"}}})
+
+	// 过期的推送不会回退版本号
+	if !table.ApplyRouteDelta(core.RouteTableDelta{FromVersion: 3, Version: 4, Removes: []string{"a"}}) || table.RouteVersion() != 5 {
+		t.Fatal("stale delta should be ignored")
+	}
+	if _, err := table.GetOrCreateRpcClient("a"); err != nil {
+		t.Fatal("stale removal must not apply")
+	}
+
+	// 漏收 6 之后的增量需要重新拉取完整路由表
+	if table.ApplyRouteDelta(core.RouteTableDelta{FromVersion: 6, Version: 7, Upserts: []core.RouteNode{{RouterId: "b"}}}) {
+		t.Fatal("gap should be reported")
+	}
+	if table.RouteVersion() != 5 {
+		t.Fatalf("gap must not change version, got %d", table.RouteVersion())
+	}
+	if !table.ApplyRouteDelta(core.RouteTableDelta{FromVersion: 5, Version: 6, Upserts: []core.RouteNode{{RouterId: "b"}}}) || table.RouteVersion() != 6 {
+		t.Fatal("contiguous delta should apply")
+	}
+}
@@ -0,0 +1,279 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func loginFrom(handler http.Handler, ip, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	req.RemoteAddr = ip + ":40000"
+	req.Header.Set("User-Agent", "session-test/1.0")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLoginGuard_LocksAccountAndIPWithBackoff(t *testing.T) {
+	chdirTemp(t)
+	cfg := &config.RouterServerConfig{AdminUsers: []config.AdminUser{
+		{Username: "root", Password: "root-pass", Role: server.RoleAdmin},
+		{Username: "ops", Password: "ops-pass", Role: server.RoleOperator},
+	}}
+	h := server.NewHttpServer(cfg, nil)
+	clock := &fakeClock{now: time.Now()}
+	h.SetLoginClockForTest(clock.Now)
+	handler := h.HandlerForTest()
+
+	for i := 0; i < 5; i++ {
+		if rec := loginFrom(handler, "10.0.0.1", `{"username":"root","password":"bad"}`); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, rec.Code)
+		}
+	}
+	// 第 5 次失败后该 IP 上的账号被锁定，即使密码正确也不行
+	rec := loginFrom(handler, "10.0.0.1", `{"username":"root","password":"root-pass"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Fatalf("expected locked account, got %d retry=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// 其他 IP 的失败不会锁住正常用户
+	if rec := loginFrom(handler, "10.0.0.2", `{"username":"root","password":"root-pass"}`); rec.Code != http.StatusOK {
+		t.Fatalf("lock should not follow the account to other ips, got %d", rec.Code)
+	}
+	// 其他账号不受影响
+	if rec := loginFrom(handler, "10.0.0.1", `{"username":"ops","password":"ops-pass"}`); rec.Code != http.StatusOK {
+		t.Fatalf("other account should still login, got %d", rec.Code)
+	}
+
+	// 锁定到期后再失败一次，锁定时长翻倍
+	clock.Advance(31 * time.Second)
+	loginFrom(handler, "10.0.0.1", `{"username":"root","password":"bad"}`)
+	rec = loginFrom(handler, "10.0.0.1", `{"username":"root","password":"root-pass"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected doubled lock, got %d retry=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	clock.Advance(61 * time.Second)
+	if rec := loginFrom(handler, "10.0.0.1", `{"username":"root","password":"root-pass"}`); rec.Code != http.StatusOK {
+		t.Fatalf("login after lock expires failed: %d", rec.Code)
+	}
+
+	// 同一 IP 对不同账号的大量尝试按 IP 锁定
+	for i := 0; i < 20; i++ {
+		loginFrom(handler, "10.9.9.9", `{"username":"user-`+string(rune('a'+i))+`","password":"bad"}`)
+	}
+	if rec := loginFrom(handler, "10.9.9.9", `{"username":"ops","password":"ops-pass"}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected ip lock, got %d", rec.Code)
+	}
+}
+
+func TestLoginGuard_EntriesAreCapped(t *testing.T) {
+	chdirTemp(t)
+	cfg := &config.RouterServerConfig{AdminUsers: []config.AdminUser{{Username: "root", Password: "root-pass", Role: server.RoleAdmin}}}
+	h := server.NewHttpServer(cfg, nil)
+	handler := h.HandlerForTest()
+
+	for i := 0; i < 5; i++ {
+		loginFrom(handler, "10.0.0.1", `{"username":"root","password":"bad"}`)
+	}
+	// 随意的账号名与 IP 不能让记录无限增长，最久未失败的条目被淘汰
+	for i := 0; i < 6000; i++ {
+		h.LoginFailForTest("10.1."+strconv.Itoa(i/250)+"."+strconv.Itoa(i%250), "user-"+strconv.Itoa(i))
+	}
+	if n := h.LoginGuardEntriesForTest(); n != 10000 {
+		t.Fatalf("expected login guard to be capped at 10000 entries, got %d", n)
+	}
+	if rec := loginFrom(handler, "10.0.0.1", `{"username":"root","password":"root-pass"}`); rec.Code != http.StatusOK {
+		t.Fatalf("evicted lock should be released, got %d", rec.Code)
+	}
+}
+
+func TestAdminSessions_ListAndForceLogout(t *testing.T) {
+	chdirTemp(t)
+	cfg := &config.RouterServerConfig{AdminUsers: []config.AdminUser{
+		{Username: "root", Password: "root-pass", Role: server.RoleAdmin},
+		{Username: "ops", Password: "ops-pass", Role: server.RoleOperator},
+	}}
+	h := server.NewHttpServer(cfg, nil)
+	handler := h.HandlerForTest()
+
+	rootToken, _, _ := loginAs(t, handler, "root", "root-pass")
+	rec := loginFrom(handler, "10.0.0.8", `{"username":"ops","password":"ops-pass"}`)
+	var loginResp struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &loginResp)
+	opsToken := loginResp.Data.Token
+
+	// 刷新 Token 仍属于同一会话
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+opsToken)
+	req.RemoteAddr = "10.0.0.8:40001"
+	refreshRec := httptest.NewRecorder()
+	handler.ServeHTTP(refreshRec, req)
+	_ = json.Unmarshal(refreshRec.Body.Bytes(), &loginResp)
+	refreshedToken := loginResp.Data.Token
+	if refreshedToken == "" || refreshedToken == opsToken {
+		t.Fatalf("refresh failed: %s", refreshRec.Body.String())
+	}
+
+	rec = serveAdmin(t, handler, http.MethodGet, "/api/auth/sessions", rootToken, "")
+	var listResp struct {
+		Data []server.AdminSession `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listResp); err != nil || len(listResp.Data) != 2 {
+		t.Fatalf("expected 2 sessions: %s", rec.Body.String())
+	}
+	var opsSession server.AdminSession
+	for _, s := range listResp.Data {
+		if s.Username == "ops" {
+			opsSession = s
+		} else if !s.Current {
+			t.Fatalf("root session should be marked current: %+v", s)
+		}
+	}
+	if opsSession.ClientIP != "10.0.0.8" || opsSession.UserAgent != "session-test/1.0" || opsSession.CreatedAt == 0 || opsSession.LastSeenAt == 0 {
+		t.Fatalf("unexpected ops session: %+v", opsSession)
+	}
+
+	if rec := serveAdmin(t, handler, http.MethodGet, "/api/auth/sessions", refreshedToken, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("operator should not list sessions, got %d", rec.Code)
+	}
+	if rec := serveAdmin(t, handler, http.MethodDelete, "/api/auth/sessions?id="+opsSession.Id, rootToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("force logout failed: %d %s", rec.Code, rec.Body.String())
+	}
+	// 会话内的新旧 Token 都失效
+	for _, token := range []string{opsToken, refreshedToken} {
+		if rec := serveAdmin(t, handler, http.MethodGet, "/api/logs/levels", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("revoked session token should be rejected, got %d", rec.Code)
+		}
+	}
+	if sessions := h.ListAdminSessions(); len(sessions) != 1 || sessions[0].Username != "root" {
+		t.Fatalf("unexpected sessions after revoke: %+v", sessions)
+	}
+}
+
+func TestTotp_EnrollAndRequireOnLogin(t *testing.T) {
+	chdirTemp(t)
+	cfg := &config.RouterServerConfig{AdminUsers: []config.AdminUser{
+		{Username: "root", Password: "root-pass", Role: server.RoleAdmin},
+		{Username: "ops", Password: "ops-pass", Role: server.RoleOperator},
+	}}
+	h := server.NewHttpServer(cfg, nil)
+	handler := h.HandlerForTest()
+	token, _, _ := loginAs(t, handler, "root", "root-pass")
+
+	rec := serveAdmin(t, handler, http.MethodPost, "/api/auth/totp", token, `{"action":"setup"}`)
+	var setup struct {
+		Data struct {
+			Secret     string `json:"secret"`
+			OtpauthUrl string `json:"otpauthUrl"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &setup); err != nil || setup.Data.Secret == "" {
+		t.Fatalf("setup failed: %s", rec.Body.String())
+	}
+	if !strings.HasPrefix(setup.Data.OtpauthUrl, "otpauth://totp/") || !strings.Contains(setup.Data.OtpauthUrl, "secret="+setup.Data.Secret) {
+		t.Fatalf("unexpected otpauth url: %s", setup.Data.OtpauthUrl)
+	}
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/auth/totp", token, `{"action":"enable","code":"000000"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("wrong code should not enable totp, got %d", rec.Code)
+	}
+	// 用上一个步长的验证码确认，登录时当前步长的验证码仍可使用
+	prevCode := server.TotpCodeForTest(setup.Data.Secret, time.Now().Add(-30*time.Second))
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/auth/totp", token, `{"action":"enable","code":"`+prevCode+`"}`); rec.Code != http.StatusOK {
+		t.Fatalf("enable totp failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if users := h.ListAdminUsers(); !users[1].TotpEnabled || users[0].TotpEnabled {
+		t.Fatalf("totp flag not reflected: %+v", users)
+	}
+
+	rec = serveAdmin(t, handler, http.MethodPost, "/api/auth/login", "", `{"username":"root","password":"root-pass"}`)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"totpRequired":true`) {
+		t.Fatalf("login without code should ask for totp: %d %s", rec.Code, rec.Body.String())
+	}
+	code := server.TotpCodeForTest(setup.Data.Secret, time.Now())
+	body := `{"username":"root","password":"root-pass","totpCode":"` + code + `"}`
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/auth/login", "", body); rec.Code != http.StatusOK {
+		t.Fatalf("login with totp failed: %d %s", rec.Code, rec.Body.String())
+	}
+	// 同一验证码不能重复使用
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/auth/login", "", body); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed totp code should be rejected, got %d", rec.Code)
+	}
+
+	// admin 可为丢失验证器的账号重置
+	if rec := serveAdmin(t, handler, http.MethodPut, "/api/users?username=root", token, `{"resetTotp":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("reset totp failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, _, code := loginAs(t, handler, "root", "root-pass"); code != http.StatusOK {
+		t.Fatalf("login after reset should not need totp, got %d", code)
+	}
+}
+
+func TestAdminSessions_PasswordChangeRevokesSessions(t *testing.T) {
+	chdirTemp(t)
+	cfg := &config.RouterServerConfig{AdminUsers: []config.AdminUser{
+		{Username: "root", Password: "root-pass", Role: server.RoleAdmin},
+		{Username: "ops", Password: "ops-pass", Role: server.RoleOperator},
+	}}
+	h := server.NewHttpServer(cfg, nil)
+	handler := h.HandlerForTest()
+	authorized := func(token string) bool {
+		return serveAdmin(t, handler, http.MethodGet, "/api/system/settings", token, "").Code == http.StatusOK
+	}
+
+	rootToken, _, _ := loginAs(t, handler, "root", "root-pass")
+	otherRootToken, _, _ := loginAs(t, handler, "root", "root-pass")
+	opsToken, _, _ := loginAs(t, handler, "ops", "ops-pass")
+	otherOpsToken, _, _ := loginAs(t, handler, "ops", "ops-pass")
+
+	// 管理员重置密码后，该账号已有的 Token 全部失效
+	if rec := serveAdmin(t, handler, http.MethodPut, "/api/users?username=ops", rootToken, `{"password":"ops-new-pass"}`); rec.Code != http.StatusOK {
+		t.Fatalf("reset password failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if authorized(opsToken) || authorized(otherOpsToken) {
+		t.Fatal("tokens issued before a password reset should be revoked")
+	}
+	if !authorized(rootToken) || !authorized(otherRootToken) {
+		t.Fatal("other accounts should keep their sessions")
+	}
+
+	// 修改自己的密码保留当前会话，其他会话下线
+	rec := serveAdmin(t, handler, http.MethodPost, "/api/system/admin-password", rootToken, `{"oldPassword":"root-pass","newPassword":"root-new-pass"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change password failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if !authorized(rootToken) {
+		t.Fatal("the session that changed the password should stay signed in")
+	}
+	if authorized(otherRootToken) {
+		t.Fatal("other sessions of the account should be revoked")
+	}
+}
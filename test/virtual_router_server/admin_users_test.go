@@ -0,0 +1,172 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+// chdirTemp 账号修改会写回当前目录下的配置文件
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd error: %v", err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir temp dir error: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+	return tmp
+}
+
+func loginAs(t *testing.T, handler http.Handler, username, password string) (string, string, int) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	rec := serveAdmin(t, handler, http.MethodPost, "/api/auth/login", "", string(body))
+	var resp struct {
+		Data struct {
+			Token string `json:"token"`
+			User  struct {
+				Role string `json:"role"`
+			} `json:"user"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data.Token, resp.Data.User.Role, rec.Code
+}
+
+func TestAdminUsers_MigrateLegacyAndEnforceRoles(t *testing.T) {
+	tmp := chdirTemp(t)
+	cfg := &config.RouterServerConfig{RouterServerPort: 9999, HTTPMonitorPort: 19999, AdminPassword: "legacy-pass"}
+	h := server.NewHttpServer(cfg, nil)
+	handler := h.HandlerForTest()
+
+	// 兼容旧客户端：不传 username 时按内置 admin 登录
+	adminToken, role, code := loginAs(t, handler, "", "legacy-pass")
+	if code != http.StatusOK || role != server.RoleAdmin {
+		t.Fatalf("legacy login failed: code=%d role=%s", code, role)
+	}
+
+	for _, u := range []struct{ name, role string }{{"support", server.RoleViewer}, {"dev", server.RoleOperator}} {
+		body, _ := json.Marshal(map[string]string{"username": u.name, "password": "pass-" + u.name, "role": u.role})
+		if rec := serveAdmin(t, handler, http.MethodPost, "/api/users", adminToken, string(body)); rec.Code != http.StatusOK {
+			t.Fatalf("create user %s failed: %d %s", u.name, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 第一次建账号时 adminPassword 迁移为哈希存储的 admin 账号，配置中不再保留明文
+	data, err := os.ReadFile(filepath.Join(tmp, config.RouterServerConfigName))
+	if err != nil {
+		t.Fatalf("read config error: %v", err)
+	}
+	var persisted config.RouterServerConfig
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		t.Fatalf("decode config error: %v", err)
+	}
+	if persisted.AdminPassword != "" || len(persisted.AdminUsers) != 3 {
+		t.Fatalf("unexpected persisted accounts: password=%q users=%+v", persisted.AdminPassword, persisted.AdminUsers)
+	}
+	for _, u := range persisted.AdminUsers {
+		if !strings.HasPrefix(u.PasswordHash, "$2") || u.Password != "" || strings.Contains(string(data), "pass-"+u.Username) {
+			t.Fatalf("password should be stored as bcrypt hash: %+v", u)
+		}
+	}
+	if _, _, code := loginAs(t, handler, "admin", "legacy-pass"); code != http.StatusOK {
+		t.Fatalf("migrated admin login failed: %d", code)
+	}
+
+	viewerToken, role, code := loginAs(t, handler, "support", "pass-support")
+	if code != http.StatusOK || role != server.RoleViewer {
+		t.Fatalf("viewer login failed: code=%d role=%s", code, role)
+	}
+	operatorToken, _, _ := loginAs(t, handler, "dev", "pass-dev")
+	if _, _, code := loginAs(t, handler, "support", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password should fail, got %d", code)
+	}
+
+	cases := []struct {
+		name, method, path, token string
+		want                      int
+	}{
+		{"viewer reads log levels", http.MethodGet, "/api/logs/levels", viewerToken, http.StatusOK},
+		{"viewer cannot change log levels", http.MethodPost, "/api/logs/levels", viewerToken, http.StatusForbidden},
+		{"operator cannot change log levels", http.MethodPost, "/api/logs/levels", operatorToken, http.StatusForbidden},
+		{"viewer cannot send debug rpc", http.MethodPost, "/api/debug/send-rpc", viewerToken, http.StatusForbidden},
+		{"viewer cannot read audit", http.MethodGet, "/api/audit", viewerToken, http.StatusForbidden},
+		{"operator cannot manage users", http.MethodGet, "/api/users", operatorToken, http.StatusForbidden},
+		{"admin manages users", http.MethodGet, "/api/users", adminToken, http.StatusOK},
+	}
+	for _, c := range cases {
+		rec := serveAdmin(t, handler, c.method, c.path, c.token, `{"component":"gateway","level":"info"}`)
+		if rec.Code != c.want {
+			t.Fatalf("%s: expected %d, got %d %s", c.name, c.want, rec.Code, rec.Body.String())
+		}
+	}
+	t.Cleanup(func() { _ = server.SetLogLevel("gateway", "") })
+}
+
+func TestAdminUsers_DisableDeleteAndPlaintextConfig(t *testing.T) {
+	chdirTemp(t)
+	cfg := &config.RouterServerConfig{
+		RouterServerPort: 9999,
+		HTTPMonitorPort:  19999,
+		AdminUsers: []config.AdminUser{
+			{Username: "root", Password: "root-pass", Role: server.RoleAdmin},
+			{Username: "ops", Password: "ops-pass", Role: server.RoleOperator},
+		},
+	}
+	h := server.NewHttpServer(cfg, nil)
+	handler := h.HandlerForTest()
+
+	// 明文密码在启动时被哈希
+	if cfg.AdminUsers[0].Password != "" || !strings.HasPrefix(cfg.AdminUsers[0].PasswordHash, "$2") {
+		t.Fatalf("plaintext password should be hashed in memory: %+v", cfg.AdminUsers[0])
+	}
+	rootToken, _, code := loginAs(t, handler, "root", "root-pass")
+	if code != http.StatusOK {
+		t.Fatalf("root login failed: %d", code)
+	}
+	opsToken, _, _ := loginAs(t, handler, "ops", "ops-pass")
+
+	if rec := serveAdmin(t, handler, http.MethodPut, "/api/users?username=ops", rootToken, `{"disabled":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("disable user failed: %d %s", rec.Code, rec.Body.String())
+	}
+	// 已签发的 Token 立即失效，也不能再登录
+	if rec := serveAdmin(t, handler, http.MethodGet, "/api/logs/levels", opsToken, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("disabled user's token should be rejected, got %d", rec.Code)
+	}
+	if _, _, code := loginAs(t, handler, "ops", "ops-pass"); code != http.StatusUnauthorized {
+		t.Fatalf("disabled user should not login, got %d", code)
+	}
+
+	if rec := serveAdmin(t, handler, http.MethodPut, "/api/users?username=root", rootToken, `{"role":"viewer"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("demoting the last admin should fail, got %d", rec.Code)
+	}
+	if rec := serveAdmin(t, handler, http.MethodDelete, "/api/users?username=root", rootToken, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("deleting self should fail, got %d", rec.Code)
+	}
+	if rec := serveAdmin(t, handler, http.MethodDelete, "/api/users?username=ops", rootToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete user failed: %d %s", rec.Code, rec.Body.String())
+	}
+	users := h.ListAdminUsers()
+	if len(users) != 1 || users[0].Username != "root" {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+
+	// 修改自己的密码
+	body := `{"oldPassword":"root-pass","newPassword":"root-pass-2"}`
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/system/admin-password", rootToken, body); rec.Code != http.StatusOK {
+		t.Fatalf("change own password failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, _, code := loginAs(t, handler, "root", "root-pass-2"); code != http.StatusOK {
+		t.Fatalf("login with new password failed: %d", code)
+	}
+}
@@ -0,0 +1,166 @@
+package virtual_router_server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+func serveAdmin(t *testing.T, handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = "10.1.2.3:52311"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuditLog_RecordsMutatingAdminRequests(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+	h := server.NewHttpServer(&config.RouterServerConfig{AdminPassword: "pw-1234", AuditLogFile: file}, nil)
+	t.Cleanup(func() { _ = h.AuditLogForTest().Close() })
+	t.Cleanup(func() { _ = server.SetLogLevel("gateway", "") })
+	handler := h.HandlerForTest()
+
+	serveAdmin(t, handler, http.MethodPost, "/api/auth/login", "", `{"password":"wrong"}`)
+	serveAdmin(t, handler, http.MethodPost, "/api/auth/login", "", `{"password":"pw-1234"}`)
+	serveAdmin(t, handler, http.MethodPost, "/api/logs/levels", "", `{"component":"gateway","level":"debug"}`)
+	token, err := server.GenerateToken("admin")
+	if err != nil {
+		t.Fatalf("generate token error: %v", err)
+	}
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/logs/levels", token, `{"component":"gateway","level":"debug"}`); rec.Code != http.StatusOK {
+		t.Fatalf("set log level failed: %d %s", rec.Code, rec.Body.String())
+	}
+	// 只读请求不记录
+	serveAdmin(t, handler, http.MethodGet, "/api/logs/levels", token, "")
+
+	total, items := h.AuditLogForTest().Search(server.AuditQuery{})
+	if total != 4 {
+		t.Fatalf("expected 4 audit entries, got %d: %+v", total, items)
+	}
+	// 最新在前
+	setLevel, denied, login, failedLogin := items[0], items[1], items[2], items[3]
+
+	if failedLogin.Action != server.AuditActionLogin || failedLogin.Success || failedLogin.Status != http.StatusUnauthorized || failedLogin.Operator != "" {
+		t.Fatalf("unexpected failed login entry: %+v", failedLogin)
+	}
+	// 未通过鉴权的请求不记录请求体
+	if len(failedLogin.Params) != 0 {
+		t.Fatalf("failed login should not persist params: %s", failedLogin.Params)
+	}
+	if failedLogin.ClientIP != "10.1.2.3" || failedLogin.Message != "账号或密码错误" {
+		t.Fatalf("unexpected client ip or message: %+v", failedLogin)
+	}
+	if !login.Success || login.Operator != "admin" {
+		t.Fatalf("unexpected login entry: %+v", login)
+	}
+	if strings.Contains(string(login.Params), "pw-1234") || !strings.Contains(string(login.Params), `"***"`) {
+		t.Fatalf("password should be redacted: %s", login.Params)
+	}
+	if denied.Action != server.AuditActionLogLevelUpdate || denied.Success || denied.Status != http.StatusUnauthorized || len(denied.Params) != 0 {
+		t.Fatalf("unauthorized attempt should be recorded without params: %+v", denied)
+	}
+	if !setLevel.Success || setLevel.Operator != "admin" || !strings.Contains(string(setLevel.Params), `"gateway"`) {
+		t.Fatalf("unexpected log level entry: %+v", setLevel)
+	}
+
+	// 文件中按 JSON 行追加，重新打开后可以继续查询
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("open audit file error: %v", err)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry server.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit line is not json: %v", err)
+		}
+		lines++
+	}
+	_ = f.Close()
+	if lines != 4 {
+		t.Fatalf("expected 4 lines in audit file, got %d", lines)
+	}
+	reopened := server.NewAuditLog(file, 10)
+	defer reopened.Close()
+	if total, _ := reopened.Search(server.AuditQuery{Operator: "admin"}); total != 2 {
+		t.Fatalf("expected 2 entries by admin after reload, got %d", total)
+	}
+}
+
+func TestAuditLog_LargeBodyIsBoundedAndNotStored(t *testing.T) {
+	h := server.NewHttpServer(&config.RouterServerConfig{AdminPassword: "pw-1234"}, nil)
+	handler := h.HandlerForTest()
+	token, err := server.GenerateToken("admin")
+	if err != nil {
+		t.Fatalf("generate token error: %v", err)
+	}
+
+	// 超过解析上限的请求体只记录占位，不保存原文
+	large := `{"component":"gateway","level":"debug","pad":"` + strings.Repeat("x", 100*1024) + `"}`
+	serveAdmin(t, handler, http.MethodPost, "/api/logs/levels", token, large)
+	// 超过请求体上限时处理函数读取失败
+	huge := `{"component":"gateway","level":"debug","pad":"` + strings.Repeat("x", 2<<20) + `"}`
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/logs/levels", token, huge); rec.Code == http.StatusOK {
+		t.Fatalf("oversized body should be rejected")
+	}
+	t.Cleanup(func() { _ = server.SetLogLevel("gateway", "") })
+
+	_, items := h.AuditLogForTest().Search(server.AuditQuery{})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(items))
+	}
+	for _, item := range items {
+		if len(item.Params) > 1024 || strings.Contains(string(item.Params), "xxxx") {
+			t.Fatalf("large body should not be stored: %d bytes", len(item.Params))
+		}
+	}
+}
+
+func TestAuditLog_HttpQuery(t *testing.T) {
+	h := server.NewHttpServer(&config.RouterServerConfig{AdminPassword: "pw-1234"}, nil)
+	handler := h.HandlerForTest()
+	audit := h.AuditLogForTest()
+	audit.Record(server.AuditEntry{Operator: "admin", Action: server.AuditActionDebugSendRpc, Params: json.RawMessage(`{"targetRouteId":"game-1"}`), Success: true})
+	audit.Record(server.AuditEntry{Operator: "ops", Action: server.AuditActionCaptureControl, Success: true})
+	audit.Record(server.AuditEntry{Operator: "admin", Action: server.AuditActionLogin, Success: true})
+
+	token, _ := server.GenerateToken("admin")
+	if rec := serveAdmin(t, handler, http.MethodGet, "/api/audit", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("audit query should require auth, got %d", rec.Code)
+	}
+	rec := serveAdmin(t, handler, http.MethodGet, "/api/audit?action=debug.&keyword=game-1", token, "")
+	var resp struct {
+		Data struct {
+			Total int                 `json:"total"`
+			Items []server.AuditEntry `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if resp.Data.Total != 1 || resp.Data.Items[0].Action != server.AuditActionDebugSendRpc {
+		t.Fatalf("unexpected audit query result: %+v", resp.Data)
+	}
+
+	rec = serveAdmin(t, handler, http.MethodGet, "/api/audit?operator=admin&limit=1", token, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if resp.Data.Total != 2 || len(resp.Data.Items) != 1 || resp.Data.Items[0].Action != server.AuditActionLogin {
+		t.Fatalf("unexpected paged result: %+v", resp.Data)
+	}
+}
@@ -0,0 +1,223 @@
+package virtual_router_server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+// startReloadableCenter 在临时目录写入配置文件并按 main 的方式加载启动
+func startReloadableCenter(t *testing.T, cfg *config.RouterServerConfig) (*server.Server, *server.HttpServer) {
+	t.Helper()
+	chdirTemp(t)
+	restore, err := server.UseProcessLogConfigForTest(&config.LogConfig{})
+	if err != nil {
+		t.Fatalf("configure logs error: %v", err)
+	}
+	t.Cleanup(restore)
+	if err := config.WriteRouterServerConfig("", cfg); err != nil {
+		t.Fatalf("write config error: %v", err)
+	}
+	loaded, err := config.ReadRouterServerConfig("")
+	if err != nil {
+		t.Fatalf("read config error: %v", err)
+	}
+	srv := server.NewServer(loaded)
+	return srv, server.NewHttpServer(loaded, srv)
+}
+
+func reloadBaseConfig() *config.RouterServerConfig {
+	return &config.RouterServerConfig{
+		RouterServerPort: 9001,
+		HTTPMonitorPort:  9002,
+		GatewayTimeoutMs: 5000,
+		AdminUsers:       []config.AdminUser{{Username: "root", Password: "root-pass", Role: server.RoleAdmin}},
+		GatewayApiKeys:   []config.GatewayApiKey{{Name: "ops", Key: "old-key", AllowRoutes: []string{"game-*"}}},
+	}
+}
+
+func TestConfigReload_AppliesReloadableSettingsWithoutDroppingSessions(t *testing.T) {
+	srv, h := startReloadableCenter(t, reloadBaseConfig())
+	upsertEchoSession(t, srv, "game-1")
+	handler := h.HandlerForTest()
+	token, _, _ := loginAs(t, handler, "root", "root-pass")
+
+	next := reloadBaseConfig()
+	next.GatewayTimeoutMs = 50
+	next.AdminUsers = append(next.AdminUsers, config.AdminUser{Username: "ops", Password: "ops-pass", Role: server.RoleOperator})
+	next.GatewayApiKeys[0].Key = "new-key"
+	next.Log = &config.LogConfig{ComponentLevels: map[string]string{"gateway": "debug"}}
+	if err := config.WriteRouterServerConfig("", next); err != nil {
+		t.Fatalf("write config error: %v", err)
+	}
+	result, err := h.ReloadConfigFile("")
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	paths := map[string]server.ConfigChange{}
+	for _, c := range result.Changes {
+		paths[c.Path] = c
+	}
+	if c, ok := paths["gatewayTimeoutMs"]; !ok || !c.Reloadable || c.Old != "5000" || c.New != "50" {
+		t.Fatalf("timeout change not reported: %+v", result.Changes)
+	}
+	if c := paths["gatewayApiKeys[ops].key"]; c.Old != "***" || c.New != "***" {
+		t.Fatalf("gateway key change should be redacted: %+v", c)
+	}
+	if len(result.PendingRestart) != 0 {
+		t.Fatalf("nothing should wait for restart: %+v", result.PendingRestart)
+	}
+
+	// 已登录的 Token 与节点连接都不受影响
+	if rec := serveAdmin(t, handler, http.MethodGet, "/api/logs/levels", token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("existing token should stay valid, got %d", rec.Code)
+	}
+	if _, _, code := loginAs(t, handler, "ops", "ops-pass"); code != http.StatusOK {
+		t.Fatalf("new account should login, got %d", code)
+	}
+	if server.LogLevels().Components["gateway"] != "DEBUG" {
+		t.Fatalf("component level not applied: %+v", server.LogLevels())
+	}
+	if code, _ := callGateway(t, h, "/rpc/game-1/1", "old-key", `[1,2]`); code != http.StatusUnauthorized {
+		t.Fatalf("old gateway key should be rejected, got %d", code)
+	}
+	if code, resp := callGateway(t, h, "/rpc/game-1/1", "new-key", `[1,2]`); code != http.StatusOK || resp.Data.Result["sum"] != 3 {
+		t.Fatalf("new gateway key should work: %d %#v", code, resp)
+	}
+	start := time.Now()
+	if code, _ := callGateway(t, h, "/rpc/game-1/3", "new-key", `[]`); code != http.StatusGatewayTimeout || time.Since(start) > 2*time.Second {
+		t.Fatalf("new gateway timeout not applied: %d after %v", code, time.Since(start))
+	}
+}
+
+func TestConfigReload_InvalidConfigKeepsCurrent(t *testing.T) {
+	_, h := startReloadableCenter(t, reloadBaseConfig())
+	handler := h.HandlerForTest()
+
+	bad := reloadBaseConfig()
+	bad.AdminUsers = append(bad.AdminUsers, config.AdminUser{Username: "root", Password: "other", Role: server.RoleViewer})
+	bad.GatewayApiKeys[0].Key = "new-key"
+	if err := config.WriteRouterServerConfig("", bad); err != nil {
+		t.Fatalf("write config error: %v", err)
+	}
+	if _, err := h.ReloadConfigFile(""); err == nil {
+		t.Fatal("duplicate usernames should be rejected")
+	}
+
+	badLog := reloadBaseConfig()
+	badLog.Log = &config.LogConfig{Level: "loud"}
+	if _, err := h.ApplyConfig(badLog); err == nil {
+		t.Fatal("unknown log level should be rejected")
+	}
+
+	if _, _, code := loginAs(t, handler, "root", "root-pass"); code != http.StatusOK {
+		t.Fatalf("current account should still login, got %d", code)
+	}
+	if code, _ := callGateway(t, h, "/rpc/game-1/1", "new-key", `[]`); code != http.StatusUnauthorized {
+		t.Fatalf("rejected gateway key must not be applied, got %d", code)
+	}
+}
+
+func TestConfigEndpoint_RedactsAndKeepsRestartItemsPending(t *testing.T) {
+	_, h := startReloadableCenter(t, reloadBaseConfig())
+	handler := h.HandlerForTest()
+	token, _, _ := loginAs(t, handler, "root", "root-pass")
+
+	rec := serveAdmin(t, handler, http.MethodGet, "/api/system/config", token, "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "old-key") || strings.Contains(rec.Body.String(), "$2a$") {
+		t.Fatalf("config view must be redacted: %d %s", rec.Code, rec.Body.String())
+	}
+	var view struct {
+		Data struct {
+			Config config.RouterServerConfig `json:"config"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatalf("decode view error: %v", err)
+	}
+
+	// 原样提交占位符并修改端口与超时
+	edited := view.Data.Config
+	edited.RouterServerPort = 9101
+	edited.GatewayTimeoutMs = 3000
+	body, _ := json.Marshal(edited)
+	rec = serveAdmin(t, handler, http.MethodPut, "/api/system/config", token, string(body))
+	var resp struct {
+		Data server.ConfigReloadResult `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("put config failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(resp.Data.PendingRestart) != 1 || resp.Data.PendingRestart[0].Path != "routerServerPort" {
+		t.Fatalf("port change should wait for restart: %+v", resp.Data.PendingRestart)
+	}
+	if code, _ := callGateway(t, h, "/rpc/game-9/1", "old-key", `[]`); code == http.StatusUnauthorized {
+		t.Fatal("redacted gateway key should be restored")
+	}
+	if _, _, code := loginAs(t, handler, "root", "root-pass"); code != http.StatusOK {
+		t.Fatalf("redacted password hash should be restored, got %d", code)
+	}
+
+	// 之后修改账号写回配置文件时，待重启的修改不会丢失
+	if rec := serveAdmin(t, handler, http.MethodPost, "/api/users", token, `{"username":"ops","password":"ops-pass","role":"operator"}`); rec.Code != http.StatusOK {
+		t.Fatalf("create user failed: %d %s", rec.Code, rec.Body.String())
+	}
+	saved, err := config.LoadRouterServerConfig("")
+	if err != nil {
+		t.Fatalf("load saved config error: %v", err)
+	}
+	if saved.RouterServerPort != 9101 || saved.GatewayTimeoutMs != 3000 || len(saved.AdminUsers) != 2 || saved.GatewayApiKeys[0].Key != "old-key" {
+		t.Fatalf("unexpected saved config: %+v", saved)
+	}
+	rec = serveAdmin(t, handler, http.MethodGet, "/api/system/config", token, "")
+	if !strings.Contains(rec.Body.String(), `"path":"routerServerPort"`) {
+		t.Fatalf("pending restart should be listed: %s", rec.Body.String())
+	}
+
+	// 新增的 Key 不能使用占位符
+	edited.GatewayApiKeys = append(edited.GatewayApiKeys, config.GatewayApiKey{Name: "new", Key: "***"})
+	body, _ = json.Marshal(edited)
+	if rec := serveAdmin(t, handler, http.MethodPut, "/api/system/config", token, string(body)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("placeholder for new key should be rejected, got %d", rec.Code)
+	}
+	opsToken, _, _ := loginAs(t, handler, "ops", "ops-pass")
+	if rec := serveAdmin(t, handler, http.MethodGet, "/api/system/config", opsToken, ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("operator should not read config, got %d", rec.Code)
+	}
+}
+
+func TestConfigReload_WatchPicksUpFileChanges(t *testing.T) {
+	base := reloadBaseConfig()
+	base.ConfigReloadIntervalMs = 20
+	_, h := startReloadableCenter(t, base)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.WatchConfigFile(ctx, "")
+
+	next := reloadBaseConfig()
+	next.ConfigReloadIntervalMs = 20
+	next.AdminUsers = append(next.AdminUsers, config.AdminUser{Username: "watcher", Password: "watch-pass"})
+	data, _ := json.MarshalIndent(next, "", "  ")
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(config.RouterServerConfigName, data, 0644); err != nil {
+		t.Fatalf("write config error: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if users := h.ListAdminUsers(); len(users) == 2 {
+			if users[1].Username != "watcher" && users[0].Username != "watcher" {
+				t.Fatalf("unexpected users: %+v", users)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("config change was not picked up: %+v", h.ListAdminUsers())
+}
@@ -0,0 +1,128 @@
+package virtual_router_server_test
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func latestConnRecord(srv *server.Server) server.ConnectionRecord {
+	if list := srv.ConnectionHistory(1); len(list) == 1 {
+		return list[0]
+	}
+	return server.ConnectionRecord{}
+}
+
+func TestConnGuard_RegistrationAndIdleDeadlines(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2,
+		RegistrationTimeoutMs: 50, ConnIdleTimeoutMs: 150})
+
+	// 从不发送心跳的连接在注册时限后被关闭
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	srv.ServeConnForTest(peer)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := core.ReadFrame(conn); err == nil {
+		t.Fatal("unregistered connection should be closed")
+	}
+	waitFor(t, func() bool { return latestConnRecord(srv).Reason == server.ConnCloseRegisterTimeout })
+
+	// 注册后超过空闲时限没有任何消息同样断开，并移除会话
+	n := registeredNode(t, srv, "idle-node")
+	time.Sleep(100 * time.Millisecond)
+	n.heartbeat(core.RpcServerInfo{})
+	if srv.SessionManager().GetSession("idle-node") == nil {
+		t.Fatal("traffic within the idle timeout should keep the session")
+	}
+	waitFor(t, func() bool { return srv.SessionManager().GetSession("idle-node") == nil })
+	waitFor(t, func() bool {
+		r := latestConnRecord(srv)
+		return r.Reason == server.ConnCloseIdleTimeout && r.RouteId == "idle-node" && r.ClosedAt >= r.OpenedAt
+	})
+	waitFor(t, func() bool {
+		_, current, _, _, _ := srv.Stats()
+		return current == 0
+	})
+}
+
+func TestConnGuard_ConnectionLimitsRejectWithReason(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, MaxConnectionsPerIp: 1})
+	registeredNode(t, srv, "first")
+
+	// net.Pipe 的来源地址相同，第二条连接超出单 IP 上限
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	srv.ServeConnForTest(peer)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	payload, err := core.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("rejected connection should receive a reason: %v", err)
+	}
+	msg, _ := core.DecodeRouteMessagePayload(payload)
+	if msg == nil || *msg.MessageType != core.RouteMessageTypeSystemError || !strings.Contains(*msg.Data, server.ConnCloseMaxPerIp) {
+		t.Fatalf("unexpected rejection message: %+v", msg)
+	}
+	if r := latestConnRecord(srv); !r.Rejected || r.Reason != server.ConnCloseMaxPerIp {
+		t.Fatalf("rejection should be recorded: %+v", r)
+	}
+	if _, current, _, _, _ := srv.Stats(); current != 1 {
+		t.Fatalf("rejected connection must not be counted, got %d", current)
+	}
+
+	srv = server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, MaxConnections: 1})
+	registeredNode(t, srv, "only")
+	conn2, peer2 := net.Pipe()
+	defer conn2.Close()
+	srv.ServeConnForTest(peer2)
+	waitFor(t, func() bool { return latestConnRecord(srv).Reason == server.ConnCloseMaxConnections })
+}
+
+func TestConnGuard_RouteIdConflictKeepsExistingSession(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	first := connectFakeNodeFrom(t, srv, "dup", "10.0.0.1:5000")
+	first.heartbeat(core.RpcServerInfo{})
+	waitFor(t, func() bool { return srv.SessionManager().GetSession("dup") != nil })
+	original := srv.SessionManager().GetSession("dup")
+
+	second := connectFakeNodeFrom(t, srv, "dup", "10.0.0.2:5000")
+	second.heartbeat(core.RpcServerInfo{})
+	if msg := second.next(core.RouteMessageTypeSystemError, time.Second); msg == nil {
+		t.Fatal("duplicate route id should be rejected")
+	}
+	waitFor(t, func() bool { return latestConnRecord(srv).Reason == server.ConnCloseRouteIdRejected })
+	if srv.SessionManager().GetSession("dup") != original {
+		t.Fatal("closing the rejected connection must not remove the original session")
+	}
+}
+
+func TestConnGuard_ConcurrentAcceptsNeverExceedLimit(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, MaxConnections: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		conn, peer := net.Pipe()
+		t.Cleanup(func() { _ = conn.Close() })
+		go func() { _, _ = io.Copy(io.Discard, conn) }()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.ServeConnForTest(peer)
+		}()
+	}
+	wg.Wait()
+
+	_, current, _, _, _ := srv.Stats()
+	if current > 10 {
+		t.Fatalf("concurrent accepts exceeded the limit: %d", current)
+	}
+	if rejected := srv.RejectedConnections(); int64(rejected)+current != 50 {
+		t.Fatalf("every connection should be admitted or rejected: current=%d rejected=%d", current, rejected)
+	}
+}
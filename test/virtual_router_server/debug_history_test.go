@@ -0,0 +1,163 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+type debugSendResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		RequestId string `json:"requestId"`
+		ReplayOf  string `json:"replayOf"`
+	} `json:"data"`
+}
+
+func postDebugJSON(t *testing.T, handler func(http.ResponseWriter, *http.Request), path, body string, out any) int {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+		t.Fatalf("decode %s response error: %v, body=%s", path, err, rr.Body.String())
+	}
+	return rr.Code
+}
+
+func waitDebugCallDone(t *testing.T, srv *server.Server, rpcUid string) server.DebugCall {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if call, ok := srv.DebugHistory().Get(rpcUid); ok && call.Status != server.DebugCallPending {
+			return call
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("debug call %s not completed", rpcUid)
+	return server.DebugCall{}
+}
+
+func TestDebugHistory_RecordReplayAndTemplates(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "debug-history.json")
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, DebugHistoryFile: historyFile}
+	srv := server.NewServer(cfg)
+	upsertEchoSession(t, srv, "game-1")
+	h := server.NewHttpServer(cfg, srv)
+
+	var sent debugSendResponse
+	if code := postDebugJSON(t, h.HandleDebugSendRpcForTest, "/api/debug/send-rpc", `{"targetRouteId":"game-1","packetId":1,"params":[4,5]}`, &sent); code != http.StatusOK || !sent.Success {
+		t.Fatalf("send-rpc failed: code=%d resp=%#v", code, sent)
+	}
+	call := waitDebugCallDone(t, srv, sent.Data.RequestId)
+	if call.Status != server.DebugCallSuccess || call.Method != "Calc.Add" || !strings.Contains(string(call.Response), "sum") {
+		t.Fatalf("unexpected debug call: %#v", call)
+	}
+	if result, ok := srv.GetDebugResult(sent.Data.RequestId); !ok || !strings.Contains(result, `\"sum\":9`) {
+		t.Fatalf("rpc-result should expose raw response, got %q", result)
+	}
+
+	var replayed debugSendResponse
+	if code := postDebugJSON(t, h.HandleDebugReplayForTest, "/api/debug/history/replay", `{"requestId":"`+sent.Data.RequestId+`"}`, &replayed); code != http.StatusOK {
+		t.Fatalf("replay failed: code=%d resp=%#v", code, replayed)
+	}
+	if replayed.Data.RequestId == sent.Data.RequestId || replayed.Data.ReplayOf != sent.Data.RequestId {
+		t.Fatalf("replay should create a new call linked to the original: %#v", replayed)
+	}
+	waitDebugCallDone(t, srv, replayed.Data.RequestId)
+
+	// 业务错误也要进入历史
+	var failed debugSendResponse
+	postDebugJSON(t, h.HandleDebugSendRpcForTest, "/api/debug/send-rpc", `{"targetRouteId":"game-1","packetId":2,"params":[]}`, &failed)
+	if call := waitDebugCallDone(t, srv, failed.Data.RequestId); call.Status != server.DebugCallError || call.ErrorMsg != "余额不足" {
+		t.Fatalf("unexpected failed call: %#v", call)
+	}
+
+	rr := httptest.NewRecorder()
+	h.HandleDebugHistoryForTest(rr, httptest.NewRequest(http.MethodGet, "/api/debug/history?packetId=1&limit=1", nil))
+	var history struct {
+		Data struct {
+			Total int                `json:"total"`
+			Items []server.DebugCall `json:"items"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &history)
+	if history.Data.Total != 2 || len(history.Data.Items) != 1 || history.Data.Items[0].RpcUid != replayed.Data.RequestId {
+		t.Fatalf("history should list newest first with pagination: %#v", history.Data)
+	}
+
+	var saved struct {
+		Success bool                 `json:"success"`
+		Data    server.DebugTemplate `json:"data"`
+	}
+	if code := postDebugJSON(t, h.HandleDebugTemplatesForTest, "/api/debug/templates", `{"name":"加法","targetRouteId":"game-1","packetId":1,"params":[1,1]}`, &saved); code != http.StatusOK || saved.Data.Id == "" {
+		t.Fatalf("save template failed: code=%d resp=%#v", code, saved)
+	}
+	var fromTemplate debugSendResponse
+	postDebugJSON(t, h.HandleDebugReplayForTest, "/api/debug/history/replay", `{"templateId":"`+saved.Data.Id+`"}`, &fromTemplate)
+	if call := waitDebugCallDone(t, srv, fromTemplate.Data.RequestId); !strings.Contains(string(call.Response), `\"sum\":2`) {
+		t.Fatalf("template replay should use template params: %#v", call)
+	}
+
+	// 重启后从文件恢复
+	srv.DebugHistory().Flush()
+	reloaded := server.NewDebugHistoryStore(historyFile, 0)
+	if total, _ := reloaded.Search(server.DebugHistoryQuery{}); total != 4 {
+		t.Fatalf("expected 4 persisted calls, got %d", total)
+	}
+	if tpls := reloaded.Templates("game-1", 1); len(tpls) != 1 || tpls[0].Name != "加法" {
+		t.Fatalf("expected persisted template, got %#v", tpls)
+	}
+}
+
+func TestDebugHistoryStore_Bounded(t *testing.T) {
+	store := server.NewDebugHistoryStore("", 3)
+	for i := 0; i < 5; i++ {
+		store.Add(&server.DebugCall{RpcUid: "uid-" + string(rune('a'+i)), TargetRouteId: "n", PacketId: 1, Status: server.DebugCallPending, CreatedAt: time.Now().UnixMilli()})
+	}
+	total, items := store.Search(server.DebugHistoryQuery{})
+	if total != 3 || items[0].RpcUid != "uid-e" {
+		t.Fatalf("expected newest 3 calls, got %d %#v", total, items)
+	}
+	if _, ok := store.Get("uid-a"); ok {
+		t.Fatalf("oldest call should be evicted")
+	}
+}
+
+func TestDebugHistoryStore_PersistsInBackground(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "debug_history.json")
+	store := server.NewDebugHistoryStore(historyFile, 0)
+	store.Add(&server.DebugCall{RpcUid: "uid-1", TargetRouteId: "n", PacketId: 1, Status: server.DebugCallPending, CreatedAt: time.Now().UnixMilli()})
+	if !store.Complete("uid-1", `{"rpcUid":"uid-1","resultValueStr":"ok"}`) {
+		t.Fatalf("complete should find the call")
+	}
+	// 记录与响应不同步写文件，多次变更合并为一次落盘
+	if _, err := os.Stat(historyFile); !os.IsNotExist(err) {
+		t.Fatalf("history should be written in background, stat err=%v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		reloaded := server.NewDebugHistoryStore(historyFile, 0)
+		if call, ok := reloaded.Get("uid-1"); ok && call.Status == server.DebugCallSuccess {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("history was not persisted in background")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	store.Fail("uid-1", "late")
+	store.Flush()
+	if call, ok := server.NewDebugHistoryStore(historyFile, 0).Get("uid-1"); !ok || call.ErrorMsg != "late" {
+		t.Fatalf("flush should write pending changes immediately: %#v", call)
+	}
+}
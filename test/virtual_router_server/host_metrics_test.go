@@ -0,0 +1,103 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+func TestBuildInfo_VersionFileFallback(t *testing.T) {
+	dir := t.TempDir()
+	// 与仓库中 version.txt 一致，带 UTF-8 BOM 与换行
+	if err := os.WriteFile(filepath.Join(dir, "version.txt"), []byte("\ufeffv1.2.3\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.ReadVersionFile(filepath.Join(dir, "version.txt")); got != "v1.2.3" {
+		t.Fatalf("unexpected version: %q", got)
+	}
+	if got := server.ReadVersionFile(filepath.Join(dir, "missing.txt")); got != "" {
+		t.Fatalf("missing file should yield empty version, got %q", got)
+	}
+
+	info := server.ResolveBuildInfoForTest([]string{filepath.Join(dir, "none"), dir})
+	if info.GoVersion != runtime.Version() {
+		t.Fatalf("unexpected go version: %+v", info)
+	}
+	// 测试二进制没有模块版本时回退到 version.txt
+	if info.Source != "buildinfo" && (info.Source != "version.txt" || info.Version != "v1.2.3") {
+		t.Fatalf("unexpected build info: %+v", info)
+	}
+	if info := server.ResolveBuildInfoForTest(nil); info.Version == "" || info.Source == "" {
+		t.Fatalf("version should never be empty: %+v", info)
+	}
+}
+
+func TestHostMetrics_StatusAndMetricsEndpoints(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	h := server.NewHttpServer(cfg, server.NewServer(cfg))
+
+	rr := httptest.NewRecorder()
+	h.HandleStatusForTest(rr, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var status struct {
+		Data struct {
+			ServerInfo map[string]any `json:"serverInfo"`
+			System     map[string]any `json:"system"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Data.ServerInfo["version"] != server.CurrentBuildInfo().Version || status.Data.System["goVersion"] != runtime.Version() {
+		t.Fatalf("unexpected status: %s", rr.Body.String())
+	}
+	if _, ok := status.Data.System["javaVersion"]; ok {
+		t.Fatalf("java leftovers should be gone: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.HandleMetricsForTest(rr, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+	var metrics struct {
+		Data struct {
+			Available bool `json:"available"`
+			Cpu       struct {
+				Usage        float64    `json:"usage"`
+				LoadAverages [3]float64 `json:"loadAverages"`
+				Cores        int        `json:"cores"`
+			} `json:"cpu"`
+			Host struct {
+				MemTotal     int64 `json:"memTotal"`
+				MemAvailable int64 `json:"memAvailable"`
+			} `json:"host"`
+			Fd struct {
+				Open int    `json:"open"`
+				Max  uint64 `json:"max"`
+			} `json:"fd"`
+			Memory struct {
+				Rss int64 `json:"rss"`
+			} `json:"memory"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("decode metrics: %v", err)
+	}
+	d := metrics.Data
+	if d.Cpu.Cores != runtime.NumCPU() || d.Cpu.Usage < 0 || d.Cpu.Usage > 100 {
+		t.Fatalf("unexpected cpu metrics: %s", rr.Body.String())
+	}
+	if runtime.GOOS != "linux" {
+		if d.Available {
+			t.Fatal("host metrics are only read from /proc on linux")
+		}
+		return
+	}
+	if !d.Available || d.Host.MemTotal <= 0 || d.Host.MemAvailable > d.Host.MemTotal || d.Fd.Open <= 0 || d.Fd.Max == 0 || d.Memory.Rss <= 0 {
+		t.Fatalf("unexpected linux host metrics: %s", rr.Body.String())
+	}
+}
@@ -114,6 +114,8 @@ func TestHandleLogsExport_ReturnsTextAttachment(t *testing.T) {
 
 func TestHandleRouterRPCRanking_ReturnsList(t *testing.T) {
 	s := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	upsertDrainedSession(t, s.SessionManager(), "router-a", 0)
+	upsertDrainedSession(t, s.SessionManager(), "router-b", 0)
 	s.RecordRouterRPCForTest("router-a", "router-b")
 	s.RecordRouterRPCForTest("router-a", "router-b")
 
@@ -160,4 +162,16 @@ func TestMatchLogLevel(t *testing.T) {
 	if !server.MatchLogLevelForTest("2026 warn timeout", "warn") {
 		t.Fatalf("warn should match")
 	}
+	if server.MatchLogLevelForTest("2026 debug details", "info") {
+		t.Fatalf("debug should not match info")
+	}
+}
+
+func TestMatchRecordLevel_InfoExcludesDebug(t *testing.T) {
+	cases := map[string]bool{"DEBUG": false, "INFO": true, "WARN": false, "ERROR": false}
+	for level, want := range cases {
+		if got := server.MatchRecordLevelForTest(server.LogRecord{Level: level, Line: "x"}, "info"); got != want {
+			t.Fatalf("%s record matched info = %v, want %v", level, got, want)
+		}
+	}
 }
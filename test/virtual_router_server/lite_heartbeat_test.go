@@ -0,0 +1,196 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// fakeNode 通过 net.Pipe 接入 Router Center 的模拟节点
+type fakeNode struct {
+	t       *testing.T
+	routeId string
+	conn    net.Conn
+	inbox   chan *core.RouteMessage
+}
+
+func connectFakeNode(t *testing.T, srv *server.Server, routeId string) *fakeNode {
+	t.Helper()
+	return connectFakeNodeFrom(t, srv, routeId, "")
+}
+
+// remoteAddrConn 为 net.Pipe 指定来源地址，区分不同的节点连接
+type remoteAddrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c remoteAddrConn) RemoteAddr() net.Addr { return c.addr }
+
+// connectFakeNodeFrom 以指定来源地址接入，remote 为空时使用 net.Pipe 默认地址
+func connectFakeNodeFrom(t *testing.T, srv *server.Server, routeId, remote string) *fakeNode {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { _ = conn.Close() })
+	n := &fakeNode{t: t, routeId: routeId, conn: conn, inbox: make(chan *core.RouteMessage, 64)}
+	go func() {
+		for {
+			payload, err := core.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			if msg, err := core.DecodeRouteMessagePayload(payload); err == nil {
+				n.inbox <- msg
+			}
+		}
+	}()
+	if remote != "" {
+		addr, err := net.ResolveTCPAddr("tcp", remote)
+		if err != nil {
+			t.Fatalf("bad remote %s: %v", remote, err)
+		}
+		peer = remoteAddrConn{Conn: peer, addr: addr}
+	}
+	srv.ServeConnForTest(peer)
+	return n
+}
+
+func (n *fakeNode) heartbeat(info core.RpcServerInfo) {
+	n.t.Helper()
+	data, _ := json.Marshal(info)
+	dataStr := string(data)
+	mt := core.RouteMessageTypeHeartBeat
+	payload, _ := (&core.RouteMessage{FromRouteId: n.routeId, MessageType: &mt, Data: &dataStr}).EncodePayload()
+	if _, err := n.conn.Write(core.EncodeFrame(payload)); err != nil {
+		n.t.Fatalf("%s heartbeat write error: %v", n.routeId, err)
+	}
+}
+
+// next 等待下一条指定类型的消息，超时返回 nil
+func (n *fakeNode) next(mt core.RouteMessageType, timeout time.Duration) *core.RouteMessage {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-n.inbox:
+			if *msg.MessageType == mt {
+				return msg
+			}
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+func (n *fakeNode) ack() core.HeartbeatAck {
+	n.t.Helper()
+	msg := n.next(core.RouteMessageTypeHeartBeat, time.Second)
+	if msg == nil || msg.Data == nil {
+		n.t.Fatalf("%s: expected heartbeat ack", n.routeId)
+	}
+	var ack core.HeartbeatAck
+	if err := json.Unmarshal([]byte(*msg.Data), &ack); err != nil {
+		n.t.Fatalf("%s: ack should be an object: %s", n.routeId, *msg.Data)
+	}
+	return ack
+}
+
+func TestLiteHeartbeat_HashOnlyAndRouteDeltas(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	stubs := []core.RpcStubMetadata{{PacketId: 2, MethodName: "B"}, {PacketId: 1, MethodName: "A"}}
+	hash := core.StubsHash(stubs)
+	if hash != core.StubsHash([]core.RpcStubMetadata{stubs[1], stubs[0]}) {
+		t.Fatal("stubs hash should not depend on order")
+	}
+
+	a := connectFakeNode(t, srv, "game-a")
+	a.heartbeat(core.RpcServerInfo{Stubs: stubs, StubsHash: hash})
+	ack := a.ack()
+	if ack.Routes == nil || !ack.Routes.Snapshot || len(ack.Routes.Upserts) != 1 || ack.ResyncStubs {
+		t.Fatalf("registration should return a snapshot: %+v", ack)
+	}
+	version := ack.Routes.Version
+
+	// 摘要一致、路由表无变化的轻量心跳不需要响应，登记的 Stub 列表保持不变
+	a.heartbeat(core.RpcServerInfo{StubsHash: hash, Lite: true, RouteVersion: version})
+	if msg := a.next(core.RouteMessageTypeHeartBeat, 100*time.Millisecond); msg != nil {
+		t.Fatalf("unchanged lite heartbeat should not be answered: %s", *msg.Data)
+	}
+	if n := len(srv.SessionManager().GetSession("game-a").GetRpcServerInfo().Stubs); n != 2 {
+		t.Fatalf("lite heartbeat must keep registered stubs, got %d", n)
+	}
+
+	// 旧版本节点仍然收到完整路由表，之后的加入以 []RouteNode、移除以 RemoveRouteNode 推送
+	b := connectFakeNode(t, srv, "chat-b")
+	b.heartbeat(core.RpcServerInfo{Host: "10.0.0.2", Port: 7000})
+	msg := b.next(core.RouteMessageTypeHeartBeat, time.Second)
+	var nodes []core.RouteNode
+	if msg == nil || json.Unmarshal([]byte(*msg.Data), &nodes) != nil || len(nodes) != 2 {
+		t.Fatalf("legacy node should receive the full route list")
+	}
+
+	// 新节点加入时立即向已有节点推送带版本号的增量
+	ack = a.ack()
+	if ack.Routes == nil || ack.Routes.Snapshot || ack.Routes.FromVersion != version || ack.Routes.Version != version+1 ||
+		len(ack.Routes.Upserts) != 1 || ack.Routes.Upserts[0].RouterId != "chat-b" {
+		t.Fatalf("expected pushed delta with chat-b: %+v", ack.Routes)
+	}
+
+	d := connectFakeNode(t, srv, "chat-d")
+	d.heartbeat(core.RpcServerInfo{StubsHash: hash, Stubs: stubs})
+	msg = b.next(core.RouteMessageTypeHeartBeat, time.Second)
+	if msg == nil || json.Unmarshal([]byte(*msg.Data), &nodes) != nil || len(nodes) != 1 || nodes[0].RouterId != "chat-d" {
+		t.Fatalf("legacy node should receive the joined node as a list")
+	}
+	_ = d.conn.Close()
+	if msg = b.next(core.RouteMessageTypeRemoveRouteNode, time.Second); msg == nil || *msg.Data != `["chat-d"]` {
+		t.Fatal("legacy node should receive RemoveRouteNode")
+	}
+	if ack = a.ack(); ack.Routes == nil || ack.Routes.Version != version+2 || len(ack.Routes.Upserts) != 1 {
+		t.Fatalf("expected pushed delta with chat-d: %+v", ack.Routes)
+	}
+	if ack = a.ack(); ack.Routes == nil || ack.Routes.Version != version+3 || len(ack.Routes.Removes) != 1 {
+		t.Fatalf("expected pushed removal of chat-d: %+v", ack.Routes)
+	}
+
+	// 仍停留在旧版本的轻量心跳带回合并后的增量
+	a.heartbeat(core.RpcServerInfo{StubsHash: hash, Lite: true, RouteVersion: version})
+	ack = a.ack()
+	if ack.Routes == nil || ack.Routes.FromVersion != version || len(ack.Routes.Upserts) != 1 || ack.Routes.Upserts[0].RouterId != "chat-b" ||
+		len(ack.Routes.Removes) != 1 || ack.Routes.Removes[0] != "chat-d" {
+		t.Fatalf("expected collapsed delta: %+v", ack.Routes)
+	}
+	version = ack.Routes.Version
+
+	_ = b.conn.Close()
+	ack = a.ack()
+	if ack.Routes == nil || ack.Routes.FromVersion != version || len(ack.Routes.Removes) != 1 || ack.Routes.Removes[0] != "chat-b" {
+		t.Fatalf("expected pushed removal delta: %+v", ack.Routes)
+	}
+	version = ack.Routes.Version
+
+	// 摘要不一致时要求重新上报，完整心跳后恢复轻量模式
+	newStubs := append(stubs, core.RpcStubMetadata{PacketId: 3, MethodName: "C"})
+	a.heartbeat(core.RpcServerInfo{StubsHash: core.StubsHash(newStubs), Lite: true, RouteVersion: version})
+	if ack = a.ack(); !ack.ResyncStubs {
+		t.Fatalf("hash mismatch should request resync: %+v", ack)
+	}
+	a.heartbeat(core.RpcServerInfo{Stubs: newStubs, StubsHash: core.StubsHash(newStubs), RouteVersion: version})
+	if ack = a.ack(); ack.ResyncStubs || ack.Routes == nil || !ack.Routes.Empty() {
+		t.Fatalf("full heartbeat at current version should get an empty delta: %+v", ack)
+	}
+	if n := len(srv.SessionManager().GetSession("game-a").GetRpcServerInfo().Stubs); n != 3 {
+		t.Fatalf("full heartbeat should update stubs, got %d", n)
+	}
+
+	// 未登记的连接发送轻量心跳同样要求完整上报
+	c := connectFakeNode(t, srv, "late-c")
+	c.heartbeat(core.RpcServerInfo{StubsHash: hash, Lite: true})
+	if ack := c.ack(); !ack.ResyncStubs || srv.SessionManager().GetSession("late-c") != nil {
+		t.Fatalf("unknown session lite heartbeat should request resync: %+v", ack)
+	}
+}
@@ -0,0 +1,223 @@
+package virtual_router_server_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+var logSearchBase = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func textLogLine(minute int, level, component, msg string) string {
+	ts := logSearchBase.Add(time.Duration(minute) * time.Minute).Format(time.RFC3339Nano)
+	return fmt.Sprintf("time=%s level=%s component=%s msg=%q", ts, level, component, msg)
+}
+
+func writeGzipLog(t *testing.T, path string, lines []string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create gz error: %v", err)
+	}
+	zw := gzip.NewWriter(f)
+	_, _ = io.WriteString(zw, strings.Join(lines, "\n")+"\n")
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close gz error: %v", err)
+	}
+	_ = f.Close()
+}
+
+// prepareRotatedLogs .2.gz 为 0~9 分钟，.1 为 10~19 分钟，当前文件由 slog 写入
+func prepareRotatedLogs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	var older, newer []string
+	for i := 0; i < 10; i++ {
+		older = append(older, textLogLine(i, "INFO", "router", fmt.Sprintf("older-%d", i)))
+		level := "INFO"
+		if i%2 == 0 {
+			level = "ERROR"
+		}
+		newer = append(newer, textLogLine(10+i, level, "session", fmt.Sprintf("newer-%d", i)))
+	}
+	writeGzipLog(t, filepath.Join(dir, "center.log.2.gz"), older)
+	if err := os.WriteFile(filepath.Join(dir, "center.log.1"), []byte(strings.Join(newer, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("write rotated log error: %v", err)
+	}
+	useLogConfig(t, &config.LogConfig{Dir: dir, FileName: "center.log", MaxFileSizeMB: 1, MaxFiles: 5, CompressRotated: true})
+	return dir
+}
+
+func TestSearchLogFiles_CoversGzipAndPaginates(t *testing.T) {
+	prepareRotatedLogs(t)
+
+	to := logSearchBase.Add(time.Hour)
+	first, err := server.SearchLogFiles(server.LogSearchQuery{To: to, Limit: 5})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if first.Total != 20 || len(first.Items) != 5 {
+		t.Fatalf("unexpected result: total=%d items=%d", first.Total, len(first.Items))
+	}
+	if !strings.Contains(first.Items[0].Line, "newer-9") || first.Items[0].File != "center.log.1" {
+		t.Fatalf("newest item should come first: %+v", first.Items[0])
+	}
+	if len(first.Files) != 3 || first.Files[0] != "center.log.2.gz" {
+		t.Fatalf("unexpected files: %v", first.Files)
+	}
+
+	// 第 4 页全部来自压缩文件
+	last, err := server.SearchLogFiles(server.LogSearchQuery{To: to, Offset: 15, Limit: 5})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if len(last.Items) != 5 || !strings.Contains(last.Items[4].Line, "older-0") || last.Items[4].File != "center.log.2.gz" {
+		t.Fatalf("unexpected last page: %+v", last.Items)
+	}
+}
+
+func TestSearchLogFiles_TimeRangeKeywordAndLevel(t *testing.T) {
+	prepareRotatedLogs(t)
+
+	result, err := server.SearchLogFiles(server.LogSearchQuery{
+		From: logSearchBase.Add(5 * time.Minute),
+		To:   logSearchBase.Add(14 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if result.Total != 10 {
+		t.Fatalf("expected 10 lines in range, got %d", result.Total)
+	}
+
+	result, err = server.SearchLogFiles(server.LogSearchQuery{To: logSearchBase.Add(time.Hour), Level: "error", Component: "session", Keyword: "NEWER"})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if result.Total != 5 {
+		t.Fatalf("expected 5 error lines, got %d", result.Total)
+	}
+	for _, item := range result.Items {
+		if item.Level != "ERROR" || item.Component != "session" {
+			t.Fatalf("unexpected item: %+v", item)
+		}
+	}
+}
+
+func TestHandleLogSearch_HttpAndStreamingExport(t *testing.T) {
+	prepareRotatedLogs(t)
+	h := server.NewHttpServer(&config.RouterServerConfig{}, nil)
+
+	from := logSearchBase.Add(8 * time.Minute).Format(time.RFC3339)
+	to := logSearchBase.Add(11 * time.Minute).Format(time.RFC3339)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/logs/search?from="+from+"&to="+to+"&limit=2", nil)
+	rec := httptest.NewRecorder()
+	h.HandleLogSearchForTest(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data server.LogSearchResult `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if resp.Data.Total != 4 || len(resp.Data.Items) != 2 {
+		t.Fatalf("unexpected result: %+v", resp.Data)
+	}
+
+	// 导出不分页，按从旧到新输出整个范围
+	req = httptest.NewRequest(http.MethodGet, "/api/logs/search/export?from="+from+"&to="+to, nil)
+	rec = httptest.NewRecorder()
+	h.HandleLogSearchExportForTest(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("unexpected export response: %d %v", rec.Code, rec.Header())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], "older-8") || !strings.Contains(lines[3], "newer-1") {
+		t.Fatalf("unexpected export lines: %v", lines)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/logs/search?from=bad-time", nil)
+	rec = httptest.NewRecorder()
+	h.HandleLogSearchForTest(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad time, got %d", rec.Code)
+	}
+}
+
+func TestRotatingFileWriter_CompressesRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	w, err := server.NewCompressedRotatingFileWriterForTest(dir, "center.log", 200, 3)
+	if err != nil {
+		t.Fatalf("create writer error: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := fmt.Fprintf(w, "line-%02d %s\n", i, strings.Repeat("x", 40)); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "center.log.1")); !os.IsNotExist(err) {
+		t.Fatalf("uncompressed rotated file should be removed, err=%v", err)
+	}
+	f, err := os.Open(filepath.Join(dir, "center.log.1.gz"))
+	if err != nil {
+		t.Fatalf("open gz error: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader error: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read gz error: %v", err)
+	}
+	if !strings.Contains(string(data), "line-") {
+		t.Fatalf("unexpected gz content: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "center.log.3.gz")); !os.IsNotExist(err) {
+		t.Fatalf("files beyond maxFiles should be removed, err=%v", err)
+	}
+}
+
+func TestSearchLogFiles_TruncatesOverlongLines(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{
+		textLogLine(0, "INFO", "router", "before"),
+		textLogLine(1, "INFO", "router", strings.Repeat("x", 2*1024*1024)),
+		textLogLine(2, "INFO", "router", "after"),
+	}
+	if err := os.WriteFile(filepath.Join(dir, "center.log.1"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("write rotated log error: %v", err)
+	}
+	useLogConfig(t, &config.LogConfig{Dir: dir, FileName: "center.log", MaxFileSizeMB: 1, MaxFiles: 5})
+
+	res, err := server.SearchLogFiles(server.LogSearchQuery{From: logSearchBase, To: logSearchBase.Add(time.Hour), Component: "router"})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if res.Total != 3 || !strings.Contains(res.Items[0].Line, "after") || !strings.Contains(res.Items[2].Line, "before") {
+		t.Fatalf("overlong line must not stop the scan: %+v", res.Total)
+	}
+	long := res.Items[1]
+	if !long.Truncated || len(long.Line) != 1024*1024 || long.Time != logSearchBase.Add(time.Minute).UnixMilli() {
+		t.Fatalf("overlong line should be truncated, got truncated=%v len=%d", long.Truncated, len(long.Line))
+	}
+}
@@ -0,0 +1,234 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+)
+
+func useLogConfig(t *testing.T, cfg *config.LogConfig) {
+	t.Helper()
+	restore, err := server.UseProcessLogConfigForTest(cfg)
+	if err != nil {
+		t.Fatalf("configure logs error: %v", err)
+	}
+	t.Cleanup(restore)
+	t.Cleanup(server.SetProcessLogRecordsForTest(nil, 100))
+}
+
+func lastRecord(t *testing.T) server.LogRecord {
+	t.Helper()
+	records := server.GetRecentProcessLogRecords(1)
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got %d", len(records))
+	}
+	return records[0]
+}
+
+func TestConfigureProcessLogs_JsonFileAndStructuredRecords(t *testing.T) {
+	dir := t.TempDir()
+	useLogConfig(t, &config.LogConfig{Format: "json", Dir: dir, FileName: "center.log", MaxFileSizeMB: 1, MaxFiles: 3})
+
+	slog.Default().With("component", "gateway").Info("网关调用完成", "routeId", "game-1", "packetId", 1001)
+
+	// 内存缓冲中保留结构化字段，component 单独提取
+	rec := lastRecord(t)
+	if rec.Component != "gateway" || rec.Level != "INFO" || rec.Msg != "网关调用完成" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if rec.Attrs["routeId"] != "game-1" || rec.Attrs["packetId"] != "1001" {
+		t.Fatalf("unexpected attrs: %+v", rec.Attrs)
+	}
+	if !strings.Contains(rec.Line, "[gateway]") || !strings.Contains(rec.Line, "routeId=game-1") {
+		t.Fatalf("unexpected line: %s", rec.Line)
+	}
+
+	// 日志文件按配置的文件名输出 JSON
+	data, err := os.ReadFile(filepath.Join(dir, "center.log"))
+	if err != nil {
+		t.Fatalf("read log file error: %v", err)
+	}
+	var found bool
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("log line is not json: %q", line)
+		}
+		if obj["msg"] == "网关调用完成" && obj["component"] == "gateway" && obj["routeId"] == "game-1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("json log line not found in file: %s", data)
+	}
+}
+
+func TestConfigureProcessLogs_ComponentLevels(t *testing.T) {
+	useLogConfig(t, &config.LogConfig{Dir: t.TempDir(), ComponentLevels: map[string]string{"gateway": "warn"}})
+
+	gateway := slog.Default().With("component", "gateway")
+	gateway.Info("gateway-info-dropped")
+	slog.Default().With("component", "router").Info("router-info-kept")
+	if rec := lastRecord(t); rec.Msg != "router-info-kept" {
+		t.Fatalf("gateway info should be filtered by component level, last=%+v", rec)
+	}
+
+	// 运行时调低 gateway 级别后立即生效
+	if err := server.SetLogLevel("gateway", "debug"); err != nil {
+		t.Fatalf("set level error: %v", err)
+	}
+	slog.Default().With("component", "gateway").Debug("gateway-debug-kept")
+	if rec := lastRecord(t); rec.Msg != "gateway-debug-kept" || rec.Level != "DEBUG" {
+		t.Fatalf("gateway debug should pass after SetLogLevel, last=%+v", rec)
+	}
+
+	// 删除单独设置后回到默认 info
+	if err := server.SetLogLevel("gateway", ""); err != nil {
+		t.Fatalf("reset level error: %v", err)
+	}
+	slog.Default().With("component", "gateway").Debug("gateway-debug-dropped")
+	if rec := lastRecord(t); rec.Msg != "gateway-debug-kept" {
+		t.Fatalf("gateway debug should be dropped after reset, last=%+v", rec)
+	}
+
+	if _, err := server.UseProcessLogConfigForTest(&config.LogConfig{Format: "xml"}); err == nil {
+		t.Fatal("unknown format should be rejected")
+	}
+}
+
+func TestConfigureProcessLogs_ReloadKeepsRuntimeLevels(t *testing.T) {
+	cfg := &config.LogConfig{Dir: t.TempDir(), ComponentLevels: map[string]string{"gateway": "warn"}, BufferCapacity: 50}
+	// 不用 useLogConfig：它会把缓冲容量改成 100
+	t.Cleanup(server.SetProcessLogRecordsForTest(nil, 0))
+	restore, err := server.UseProcessLogConfigForTest(cfg)
+	if err != nil {
+		t.Fatalf("configure logs error: %v", err)
+	}
+	t.Cleanup(restore)
+	if got := server.ProcessLogCapacityForTest(); got != 50 {
+		t.Fatalf("buffer capacity should follow config, got %d", got)
+	}
+	_ = server.SetLogLevel("gateway", "debug")
+	_ = server.SetLogLevel("router", "debug")
+
+	// 无关的日志配置变更不覆盖运行时调整，去掉 bufferCapacity 恢复默认容量
+	next := *cfg
+	next.MaxFiles = 3
+	next.BufferCapacity = 0
+	if err := server.ConfigureProcessLogs(&next); err != nil {
+		t.Fatalf("reconfigure error: %v", err)
+	}
+	levels := server.LogLevels()
+	if levels.Components["gateway"] != "DEBUG" || levels.Components["router"] != "DEBUG" {
+		t.Fatalf("unchanged config levels must keep runtime overrides: %+v", levels.Components)
+	}
+	if got := server.ProcessLogCapacityForTest(); got != 800 {
+		t.Fatalf("unset buffer capacity should reset to default, got %d", got)
+	}
+
+	// 只有配置中变化的组件级别才重新应用
+	next.ComponentLevels = map[string]string{"gateway": "error"}
+	if err := server.ConfigureProcessLogs(&next); err != nil {
+		t.Fatalf("reconfigure error: %v", err)
+	}
+	levels = server.LogLevels()
+	if levels.Components["gateway"] != "ERROR" || levels.Components["router"] != "DEBUG" {
+		t.Fatalf("changed config level should be applied: %+v", levels.Components)
+	}
+	next.ComponentLevels = nil
+	if err := server.ConfigureProcessLogs(&next); err != nil {
+		t.Fatalf("reconfigure error: %v", err)
+	}
+	if _, ok := server.LogLevels().Components["gateway"]; ok {
+		t.Fatalf("level removed from config should be cleared")
+	}
+}
+
+func TestHandleLogs_FilterByComponentAndAttrs(t *testing.T) {
+	restore := server.SetProcessLogRecordsForTest([]server.LogRecord{
+		{Level: "INFO", Component: "gateway", Msg: "a", Attrs: map[string]string{"routeId": "game-1"}, Line: "INFO [gateway] a routeId=game-1"},
+		{Level: "WARN", Component: "gateway", Msg: "b", Attrs: map[string]string{"routeId": "game-2"}, Line: "WARN [gateway] b routeId=game-2"},
+		{Level: "WARN", Component: "session", Msg: "c", Attrs: map[string]string{"routeId": "game-1"}, Line: "WARN [session] c routeId=game-1"},
+		{Line: "raw warn line without structure"},
+	}, 10)
+	t.Cleanup(restore)
+
+	h := server.NewHttpServer(&config.RouterServerConfig{}, nil)
+	query := func(q string) []server.LogRecord {
+		rr := httptest.NewRecorder()
+		h.HandleLogsForTest(rr, httptest.NewRequest(http.MethodGet, "/api/logs?"+q, nil))
+		var resp struct {
+			Data struct {
+				Records []server.LogRecord `json:"records"`
+				Lines   []string           `json:"lines"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if len(resp.Data.Records) != len(resp.Data.Lines) {
+			t.Fatalf("records and lines should align: %s", rr.Body.String())
+		}
+		return resp.Data.Records
+	}
+
+	if got := query("routeId=game-1"); len(got) != 2 {
+		t.Fatalf("routeId filter: expected 2, got %+v", got)
+	}
+	if got := query("component=gateway&attr=routeId:game-2"); len(got) != 1 || got[0].Msg != "b" {
+		t.Fatalf("component+attr filter: got %+v", got)
+	}
+	// 结构化记录按真实级别过滤，原始文本退回关键字匹配
+	if got := query("level=warn"); len(got) != 3 {
+		t.Fatalf("level filter: expected 3, got %+v", got)
+	}
+}
+
+func TestHandleLogLevels_GetAndUpdate(t *testing.T) {
+	useLogConfig(t, &config.LogConfig{Dir: t.TempDir()})
+	h := server.NewHttpServer(&config.RouterServerConfig{}, nil)
+
+	rr := httptest.NewRecorder()
+	h.HandleLogLevelsForTest(rr, httptest.NewRequest(http.MethodPost, "/api/logs/levels", strings.NewReader(`{"component":"capture","level":"error"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Data server.LogLevelSnapshot `json:"data"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Data.Components["capture"] != "ERROR" || resp.Data.Default != "INFO" || len(resp.Data.Known) == 0 {
+		t.Fatalf("unexpected snapshot: %+v", resp.Data)
+	}
+
+	rr = httptest.NewRecorder()
+	h.HandleLogLevelsForTest(rr, httptest.NewRequest(http.MethodPost, "/api/logs/levels", strings.NewReader(`{"component":"capture","level":"loud"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid level should be rejected, got %d", rr.Code)
+	}
+}
+
+func TestComponentLog_CachedPerComponentUntilDefaultChanges(t *testing.T) {
+	first := server.ComponentLogForTest(server.LogComponentGateway)
+	if server.ComponentLogForTest(server.LogComponentGateway) != first {
+		t.Fatal("component logger should be cached")
+	}
+	if server.ComponentLogForTest(server.LogComponentSession) == first {
+		t.Fatal("each component should have its own logger")
+	}
+
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if server.ComponentLogForTest(server.LogComponentGateway) == first {
+		t.Fatal("replacing slog.Default should rebuild component loggers")
+	}
+}
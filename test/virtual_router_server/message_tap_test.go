@@ -0,0 +1,106 @@
+package virtual_router_server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/capture"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func tapRpcRequest(from, to, rpcUid string, packetId int) *core.RouteMessage {
+	data, _ := json.Marshal(map[string]any{"rpcUid": rpcUid, "packetId": packetId})
+	dataStr := string(data)
+	mt := core.RouteMessageTypeRpcRequest
+	return &core.RouteMessage{FromRouteId: from, ToRouteId: to, MessageType: &mt, Data: &dataStr}
+}
+
+func TestMessageTap_FilterAndSample(t *testing.T) {
+	tap := server.NewMessageTap()
+	sub := tap.Subscribe(capture.Filter{MessageTypes: []string{"RpcRequest"}, PacketIds: []int{7}}, 2)
+	defer tap.Unsubscribe(sub)
+
+	for i := 0; i < 4; i++ {
+		tap.Publish(tapRpcRequest("game-1", "chat-1", "uid-"+string(rune('a'+i)), 7))
+	}
+	tap.Publish(tapRpcRequest("game-1", "chat-1", "other", 8))
+	mt := core.RouteMessageTypeMessageData
+	tap.Publish(&core.RouteMessage{FromRouteId: "game-1", ToRouteId: "chat-1", MessageType: &mt})
+
+	var got []server.TapEvent
+	for len(sub.C) > 0 {
+		got = append(got, <-sub.C)
+	}
+	if len(got) != 2 || got[0].RpcUid != "uid-a" || got[1].RpcUid != "uid-c" {
+		t.Fatalf("expected sampled events uid-a/uid-c, got %#v", got)
+	}
+	if got[0].PacketId != 7 || got[0].Type != "RpcRequest" || got[0].Size == 0 {
+		t.Fatalf("unexpected event summary: %#v", got[0])
+	}
+
+	tap.Unsubscribe(sub)
+	if tap.Subscribers() != 0 {
+		t.Fatalf("expected no subscribers after unsubscribe")
+	}
+}
+
+func TestTapStream_SSE(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+	h := server.NewHttpServer(cfg, srv)
+	ts := httptest.NewServer(http.HandlerFunc(h.HandleTapStreamForTest))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/tap/stream?routeId=game-1&type=RpcRequest")
+	if err != nil {
+		t.Fatalf("GET stream error: %v", err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.MessageTap().Subscribers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	srv.HandleRouteMessageForTest(tapRpcRequest("game-9", "chat-1", "ignored", 1))
+	srv.HandleRouteMessageForTest(tapRpcRequest("game-1", "chat-1", "wanted", 1))
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream error: %v", err)
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			continue
+		}
+		var event server.TapEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("decode event error: %v", err)
+		}
+		if event.RpcUid != "wanted" || event.From != "game-1" {
+			t.Fatalf("unexpected event: %#v", event)
+		}
+		return
+	}
+}
+
+func TestTapStream_RejectsUnknownType(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	h := server.NewHttpServer(cfg, server.NewServer(cfg))
+	rr := httptest.NewRecorder()
+	h.HandleTapStreamForTest(rr, httptest.NewRequest(http.MethodGet, "/api/tap/stream?type=Nope", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func sumPoints(points []server.MetricPoint) int64 {
+	var total int64
+	for _, p := range points {
+		total += p.V
+	}
+	return total
+}
+
+func queryMetricsHistory(t *testing.T, h *server.HttpServer, query string) (int, server.MetricHistory) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.HandleMetricsHistoryForTest(rr, httptest.NewRequest(http.MethodGet, "/api/metrics/history?"+query, nil))
+	var resp struct {
+		Data server.MetricHistory `json:"data"`
+	}
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode history: %v", err)
+		}
+	}
+	return rr.Code, resp.Data
+}
+
+func TestMetricsHistory_CountersGaugesAndRouterSeries(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+	h := server.NewHttpServer(cfg, srv)
+
+	a := registeredNode(t, srv, "hist-a")
+	b := registeredNode(t, srv, "hist-b")
+	a.rpcRequest("hist-b", "uid-1", 1)
+	if b.next(core.RouteMessageTypeRpcRequest, time.Second) == nil {
+		t.Fatal("rpc request should be forwarded")
+	}
+	a.send("ghost", 1)
+	waitFor(t, func() bool { return srv.RequestsPerMinute() == 4 })
+	srv.SampleGaugesForTest()
+
+	code, hist := queryMetricsHistory(t, h, "window=60")
+	if code != http.StatusOK || hist.Resolution != server.MetricResolutionSecond || hist.StepMs != 1000 {
+		t.Fatalf("unexpected history: %d %+v", code, hist)
+	}
+	if n := len(hist.Series[server.MetricRequests]); n < 59 || n > 61 {
+		t.Fatalf("60 second window should return one point per second, got %d", n)
+	}
+	// 两次心跳 + 一次 RPC + 一条发往离线节点的消息
+	want := map[string]int64{server.MetricRequests: 4, server.MetricRpc: 1, server.MetricErrors: 1}
+	for name, v := range want {
+		if got := sumPoints(hist.Series[name]); got != v {
+			t.Fatalf("%s: want %d, got %d", name, v, got)
+		}
+	}
+	if sumPoints(hist.Series[server.MetricBytes]) <= 0 {
+		t.Fatal("bytes should be recorded")
+	}
+	last := func(name string) int64 { s := hist.Series[name]; return s[len(s)-1].V }
+	if last(server.MetricConnections) != 2 || last(server.MetricRouters) != 2 {
+		t.Fatalf("unexpected gauges: connections=%d routers=%d", last(server.MetricConnections), last(server.MetricRouters))
+	}
+
+	_, routerHist := queryMetricsHistory(t, h, "routeId=hist-b&window=60")
+	if len(routerHist.Series) != 1 || sumPoints(routerHist.Series[server.MetricRpc]) != 1 {
+		t.Fatalf("unexpected router series: %+v", routerHist.Series)
+	}
+	if stats := srv.RouterRPCStats("hist-b", 10); len(stats) != 1 || stats[0].PerMinute != 1 {
+		t.Fatalf("per minute ranking should come from the router series: %+v", stats)
+	}
+}
+
+func TestMetricsHistory_MinuteRetentionAndValidation(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+	h := server.NewHttpServer(cfg, srv)
+
+	now := time.Now()
+	srv.AddMetricAtForTest(server.MetricRequests, now.Add(-25*time.Hour), 100)
+	srv.AddMetricAtForTest(server.MetricRequests, now.Add(-2*time.Hour), 7)
+	srv.AddMetricAtForTest(server.MetricRequests, now, 3)
+
+	// 秒级只保留 1 小时，超过 24 小时的数据已被覆盖
+	_, seconds := queryMetricsHistory(t, h, "metrics=requests&window=7200")
+	if len(seconds.Series[server.MetricRequests]) != 3600 || sumPoints(seconds.Series[server.MetricRequests]) != 3 {
+		t.Fatalf("second series should be capped at one hour, got %d points sum %d",
+			len(seconds.Series[server.MetricRequests]), sumPoints(seconds.Series[server.MetricRequests]))
+	}
+	if len(seconds.Series) != 1 {
+		t.Fatalf("only the requested metrics should be returned: %v", seconds.Series)
+	}
+	_, minutes := queryMetricsHistory(t, h, "metrics=requests&resolution=minute")
+	if len(minutes.Series[server.MetricRequests]) != 1440 || sumPoints(minutes.Series[server.MetricRequests]) != 10 {
+		t.Fatalf("minute series should keep 24 hours, got %d points sum %d",
+			len(minutes.Series[server.MetricRequests]), sumPoints(minutes.Series[server.MetricRequests]))
+	}
+
+	if code, _ := queryMetricsHistory(t, h, "resolution=hour"); code != http.StatusBadRequest {
+		t.Fatalf("unknown resolution should be rejected, got %d", code)
+	}
+	if code, _ := queryMetricsHistory(t, h, "metrics=requests,cpu"); code != http.StatusBadRequest {
+		t.Fatalf("unknown metric should be rejected, got %d", code)
+	}
+}
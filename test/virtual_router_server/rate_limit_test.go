@@ -0,0 +1,191 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+func (n *fakeNode) rpcRequest(to, rpcUid string, packetId int) {
+	n.t.Helper()
+	data, _ := json.Marshal(rpc.RpcRequest{FromRouteId: n.routeId, ToRouteId: to, RpcUid: rpcUid, PacketId: packetId})
+	dataStr := string(data)
+	mt := core.RouteMessageTypeRpcRequest
+	payload, _ := (&core.RouteMessage{FromRouteId: n.routeId, ToRouteId: to, MessageType: &mt, Data: &dataStr}).EncodePayload()
+	if _, err := n.conn.Write(core.EncodeFrame(payload)); err != nil {
+		n.t.Fatalf("%s rpc write error: %v", n.routeId, err)
+	}
+}
+
+// countMessages 在 wait 内统计收到的指定类型消息
+func (n *fakeNode) countMessages(mt core.RouteMessageType, wait time.Duration) []*core.RouteMessage {
+	var out []*core.RouteMessage
+	for {
+		msg := n.next(mt, wait)
+		if msg == nil {
+			return out
+		}
+		out = append(out, msg)
+	}
+}
+
+func registeredNode(t *testing.T, srv *server.Server, routeId string) *fakeNode {
+	t.Helper()
+	n := connectFakeNode(t, srv, routeId)
+	n.heartbeat(core.RpcServerInfo{})
+	waitFor(t, func() bool { return srv.SessionManager().GetSession(routeId) != nil })
+	return n
+}
+
+func TestRateLimit_RejectsRpcWithTypedResponseAndCountsHits(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, RateLimit: &config.RateLimitConfig{
+		// 长突发时长让容量分别为 2 与 3，测试期间补充的令牌可以忽略
+		Default: config.RateLimitRule{RpcPerSecond: 0.02, MessagesPerSecond: 0.03, BurstSeconds: 100},
+		Nodes: map[string]config.RateLimitRule{
+			"vip":    {},
+			"packet": {PacketRpcPerSecond: map[int]float64{7: 1}},
+		},
+	}})
+	target := registeredNode(t, srv, "target")
+	a := registeredNode(t, srv, "game-a")
+	for i := 0; i < 5; i++ {
+		a.rpcRequest("target", "uid-"+string(rune('a'+i)), 1)
+	}
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 2 {
+		t.Fatalf("target should receive 2 requests within the burst, got %d", got)
+	}
+	rejected := a.countMessages(core.RouteMessageTypeRpcResponse, 200*time.Millisecond)
+	if len(rejected) != 3 {
+		t.Fatalf("caller should get 3 rate limited responses, got %d", len(rejected))
+	}
+	var resp rpc.RpcResponse
+	if err := json.Unmarshal([]byte(*rejected[0].Data), &resp); err != nil || !resp.ErrorFlag ||
+		!strings.HasPrefix(resp.ErrorMsg, server.RateLimitedErrorCode) || resp.RpcUid != "uid-c" || rejected[0].FromRouteId != "target" {
+		t.Fatalf("unexpected rejection: %s", *rejected[0].Data)
+	}
+
+	// 普通消息超出限制只按间隔回复一次 SystemError
+	a.send("target", 5)
+	if got := len(target.countMessages(core.RouteMessageTypeMessageData, 200*time.Millisecond)); got != 1 {
+		t.Fatalf("only the remaining message token should pass, got %d", got)
+	}
+	if got := len(a.countMessages(core.RouteMessageTypeSystemError, 200*time.Millisecond)); got != 1 {
+		t.Fatalf("system error notice should be throttled, got %d", got)
+	}
+
+	stats := srv.RouterRPCStats("game-a", 10)
+	if len(stats) != 1 || stats[0].RateLimited != 7 || stats[0].RateLimitedByKind[server.RateLimitRpc] != 3 ||
+		stats[0].RateLimitedByKind[server.RateLimitMessages] != 4 {
+		t.Fatalf("unexpected limit hit counters: %+v", stats)
+	}
+
+	// 覆盖规则：vip 不限流，packet 只限制 packetId 7
+	vip := registeredNode(t, srv, "vip")
+	for i := 0; i < 5; i++ {
+		vip.rpcRequest("target", "vip", 1)
+	}
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 5 {
+		t.Fatalf("override without limits should pass everything, got %d", got)
+	}
+	p := registeredNode(t, srv, "packet")
+	p.rpcRequest("target", "p1", 7)
+	p.rpcRequest("target", "p2", 7)
+	p.rpcRequest("target", "p3", 8)
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 2 {
+		t.Fatalf("packet limit should reject only the second packet 7 call, got %d", got)
+	}
+	if hits := srv.RateLimitHits("packet"); hits[server.RateLimitPacket] != 1 {
+		t.Fatalf("unexpected packet hits: %+v", hits)
+	}
+}
+
+func TestRateLimit_ConfigValidation(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, RateLimit: &config.RateLimitConfig{
+		Nodes: map[string]config.RateLimitRule{"bad": {PacketRpcPerSecond: map[int]float64{1: -1}}},
+	}}
+	if err := cfg.Check(); err == nil {
+		t.Fatal("negative packet rate should be rejected")
+	}
+}
+
+func TestRateLimit_KeyedByConnectionAndKeptAcrossUnrelatedReloads(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, RateLimit: &config.RateLimitConfig{
+		Default: config.RateLimitRule{RpcPerSecond: 0.02, BurstSeconds: 100},
+		Nodes:   map[string]config.RateLimitRule{"vip": {}},
+	}}
+	srv := server.NewServer(cfg)
+	h := server.NewHttpServer(cfg, srv)
+	target := registeredNode(t, srv, "target")
+
+	// 同一连接不断更换 FromRouteId（包括冒用不限流的 vip）仍共用一个令牌桶
+	rotating := connectFakeNode(t, srv, "rot-0")
+	for i, id := range []string{"rot-1", "rot-2", "vip", "rot-3", "rot-4"} {
+		rotating.routeId = id
+		rotating.rpcRequest("target", "r"+string(rune('a'+i)), 1)
+	}
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 2 {
+		t.Fatalf("rotating route ids must not bypass the limit, got %d", got)
+	}
+
+	a := registeredNode(t, srv, "game-a")
+	a.rpcRequest("target", "a1", 1)
+	a.rpcRequest("target", "a2", 1)
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 2 {
+		t.Fatalf("burst should pass, got %d", got)
+	}
+
+	// 修改无关配置不会重置令牌桶
+	next := *cfg
+	next.GatewayTimeoutMs = 1234
+	if _, err := h.ApplyConfig(&next); err != nil {
+		t.Fatalf("apply config: %v", err)
+	}
+	a.rpcRequest("target", "a3", 1)
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 0 {
+		t.Fatalf("unrelated reload must keep the exhausted bucket, got %d", got)
+	}
+
+	// 修改限流配置后按新规则重建
+	next.RateLimit = &config.RateLimitConfig{Default: config.RateLimitRule{RpcPerSecond: 0.03, BurstSeconds: 100}}
+	if _, err := h.ApplyConfig(&next); err != nil {
+		t.Fatalf("apply config: %v", err)
+	}
+	a.rpcRequest("target", "a4", 1)
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 1 {
+		t.Fatalf("rate limit change should rebuild buckets, got %d", got)
+	}
+}
+
+func TestRateLimit_UnruledPacketIdsAreNotCached(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, RateLimit: &config.RateLimitConfig{
+		Default: config.RateLimitRule{PacketRpcPerSecond: map[int]float64{7: 1}, BurstSeconds: 100},
+	}})
+	target := registeredNode(t, srv, "target")
+	a := registeredNode(t, srv, "game-a")
+
+	// 没有规则的 packetId 不限流，也不在连接上留下令牌桶
+	for i := 0; i < 200; i++ {
+		a.rpcRequest("target", "u"+strconv.Itoa(i), 1000+i)
+	}
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 200 {
+		t.Fatalf("unruled packetIds should pass, got %d", got)
+	}
+	if n := srv.RateLimitPacketBucketsForTest(); n != 0 {
+		t.Fatalf("unruled packetIds must not be cached, got %d buckets", n)
+	}
+
+	a.rpcRequest("target", "ruled", 7)
+	if got := len(target.countMessages(core.RouteMessageTypeRpcRequest, 200*time.Millisecond)); got != 1 {
+		t.Fatalf("ruled packetId within burst should pass, got %d", got)
+	}
+	if n := srv.RateLimitPacketBucketsForTest(); n != 1 {
+		t.Fatalf("ruled packetId should get a bucket, got %d", n)
+	}
+}
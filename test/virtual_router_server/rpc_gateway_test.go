@@ -0,0 +1,168 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+	"github.com/neko233-com/virtual-router-go/internal/rpc"
+)
+
+// upsertEchoSession 模拟一个节点：packetId=1 把参数相加返回，packetId=2 返回业务错误，packetId=3 不响应
+func upsertEchoSession(t *testing.T, srv *server.Server, routeId string) {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = peer.Close()
+	})
+	go func() {
+		for {
+			payload, err := core.ReadFrame(peer)
+			if err != nil {
+				return
+			}
+			msg, err := core.DecodeRouteMessagePayload(payload)
+			if err != nil || msg.Data == nil || *msg.MessageType != core.RouteMessageTypeRpcRequest {
+				continue
+			}
+			var req rpc.RpcRequest
+			_ = json.Unmarshal([]byte(*msg.Data), &req)
+			resp := rpc.RpcResponse{RpcUid: req.RpcUid, PacketId: req.PacketId}
+			switch req.PacketId {
+			case 1:
+				var a, b int
+				_ = json.Unmarshal([]byte(req.MethodArgsJsonList[0]), &a)
+				_ = json.Unmarshal([]byte(req.MethodArgsJsonList[1]), &b)
+				out, _ := json.Marshal(map[string]int{"sum": a + b})
+				resp.ResultValueStr = string(out)
+			case 2:
+				resp.ErrorFlag = true
+				resp.ErrorMsg = "余额不足"
+			default:
+				continue
+			}
+			data, _ := json.Marshal(resp)
+			dataStr := string(data)
+			mt := core.RouteMessageTypeRpcResponse
+			srv.HandleRpcResponseForTest(&core.RouteMessage{FromRouteId: routeId, ToRouteId: msg.FromRouteId, MessageType: &mt, Data: &dataStr})
+		}
+	}()
+	stubs := []core.RpcStubMetadata{
+		{PacketId: 1, ClassName: "Calc", MethodName: "Add"},
+		{PacketId: 2, ClassName: "Wallet", MethodName: "Pay"},
+		{PacketId: 3, ClassName: "Slow", MethodName: "Hang"},
+	}
+	session := server.NewRouterSession(routeId, conn, core.RpcServerInfo{Stubs: stubs}, &sync.Mutex{})
+	if _, err := srv.SessionManager().UpsertSession(routeId, session); err != nil {
+		t.Fatalf("upsert %s error: %v", routeId, err)
+	}
+}
+
+type gatewayResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		RpcUid string         `json:"rpcUid"`
+		Result map[string]int `json:"result"`
+	} `json:"data"`
+	Error struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+func callGateway(t *testing.T, h *server.HttpServer, path, apiKey, body string) (int, gatewayResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	rr := httptest.NewRecorder()
+	h.HandleRpcGatewayForTest(rr, req)
+	var resp gatewayResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response error: %v, body=%s", err, rr.Body.String())
+	}
+	return rr.Code, resp
+}
+
+func TestRpcGateway_InvokeAndErrors(t *testing.T) {
+	cfg := &config.RouterServerConfig{
+		RouterServerPort: 1,
+		HTTPMonitorPort:  2,
+		GatewayApiKeys: []config.GatewayApiKey{
+			{Name: "ops", Key: "ops-key", AllowRoutes: []string{"game-*"}},
+		},
+	}
+	srv := server.NewServer(cfg)
+	upsertEchoSession(t, srv, "game-1")
+	upsertEchoSession(t, srv, "chat-1")
+	h := server.NewHttpServer(cfg, srv)
+
+	code, resp := callGateway(t, h, "/rpc/game-1/1", "ops-key", `{"params":[2,3]}`)
+	if code != http.StatusOK || !resp.Success || resp.Data.Result["sum"] != 5 {
+		t.Fatalf("unexpected result: code=%d resp=%#v", code, resp)
+	}
+
+	// 参数数组形式
+	code, resp = callGateway(t, h, "/rpc/game-1/1", "ops-key", `[10,20]`)
+	if code != http.StatusOK || resp.Data.Result["sum"] != 30 {
+		t.Fatalf("unexpected array body result: code=%d resp=%#v", code, resp)
+	}
+
+	cases := []struct {
+		name, path, key, body string
+		status                int
+		code                  string
+	}{
+		{"bad key", "/rpc/game-1/1", "wrong", `[]`, http.StatusUnauthorized, server.GatewayErrUnauthorized},
+		{"no credentials", "/rpc/game-1/1", "", `[]`, http.StatusUnauthorized, server.GatewayErrUnauthorized},
+		{"acl", "/rpc/chat-1/1", "ops-key", `[1,2]`, http.StatusForbidden, server.GatewayErrForbidden},
+		{"offline", "/rpc/game-9/1", "ops-key", `[]`, http.StatusNotFound, server.GatewayErrRouteOffline},
+		{"no stub", "/rpc/game-1/99", "ops-key", `[]`, http.StatusNotFound, server.GatewayErrNoStub},
+		{"bad packet", "/rpc/game-1/abc", "ops-key", `[]`, http.StatusBadRequest, server.GatewayErrBadRequest},
+		{"remote error", "/rpc/game-1/2", "ops-key", `[]`, http.StatusBadGateway, server.GatewayErrRemote},
+		{"timeout", "/rpc/game-1/3", "ops-key", `{"params":[],"timeoutMs":50}`, http.StatusGatewayTimeout, server.GatewayErrTimeout},
+		{"too large", "/rpc/game-1/1", "ops-key", `["` + strings.Repeat("x", core.MaxFrameSize) + `"]`, http.StatusRequestEntityTooLarge, server.GatewayErrTooLarge},
+	}
+	for _, c := range cases {
+		code, resp := callGateway(t, h, c.path, c.key, c.body)
+		if code != c.status || resp.Success || resp.Error.Code != c.code {
+			t.Fatalf("%s: expected %d/%s, got %d/%#v", c.name, c.status, c.code, code, resp)
+		}
+	}
+}
+
+func TestRpcGateway_ConcurrentCallsUseUniqueRpcUid(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+	upsertEchoSession(t, srv, "game-1")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := srv.InvokeRpc(t.Context(), "game-1", 1, []string{"1", "2"}, 0)
+			if err != nil {
+				t.Errorf("InvokeRpc error: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[result.RpcUid] {
+				t.Errorf("duplicate rpcUid %s", result.RpcUid)
+			}
+			seen[result.RpcUid] = true
+		}(i)
+	}
+	wg.Wait()
+}
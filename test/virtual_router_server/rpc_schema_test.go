@@ -0,0 +1,96 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+type schemaResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Documents []struct {
+			Name     string         `json:"name"`
+			Document map[string]any `json:"document"`
+		} `json:"documents"`
+	} `json:"data"`
+}
+
+func fetchRpcSchemaForTest(t *testing.T, h *server.HttpServer, query string) schemaResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.HandleRpcSchemaForTest(rr, httptest.NewRequest(http.MethodGet, "/api/rpc/schema?"+query, nil))
+	var resp schemaResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response error: %v, body=%s", err, rr.Body.String())
+	}
+	return resp
+}
+
+func TestRpcSchema_OpenAPIMergedAndNode(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+
+	login := core.RpcStubMetadata{
+		PacketId:            100,
+		ClassName:           "LoginService",
+		MethodName:          "Login",
+		ParameterNames:      []string{"account"},
+		ParameterTypes:      []string{"string"},
+		ParameterSchemaJson: []string{`{"type":"string"}`},
+		ReturnType:          "bool",
+		ReturnSchemaJson:    `{"type":"boolean"}`,
+	}
+	// Kotlin 节点不会上报 Schema，按类型名推断。
+	ping := core.RpcStubMetadata{PacketId: 200, ClassName: "com.demo.PingService", MethodName: "ping", ParameterTypes: []string{"Long"}}
+
+	upsertStubSession(t, srv, "gate-1", login)
+	upsertStubSession(t, srv, "gate-2", login, ping)
+
+	h := server.NewHttpServer(cfg, srv)
+	merged := fetchRpcSchemaForTest(t, h, "format=openapi&mode=merged")
+	if !merged.Success || len(merged.Data.Documents) != 1 {
+		t.Fatalf("unexpected merged response: %#v", merged)
+	}
+	doc := merged.Data.Documents[0].Document
+	if doc["openapi"] != "3.1.0" {
+		t.Fatalf("unexpected openapi version: %v", doc["openapi"])
+	}
+	paths := doc["paths"].(map[string]any)
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths, got %v", paths)
+	}
+	op := paths["/rpc/{routeId}/100"].(map[string]any)["post"].(map[string]any)
+	routeEnum := op["parameters"].([]any)[0].(map[string]any)["schema"].(map[string]any)["enum"].([]any)
+	if len(routeEnum) != 2 {
+		t.Fatalf("packetId 100 should list both nodes, got %v", routeEnum)
+	}
+	pingOp := paths["/rpc/{routeId}/200"].(map[string]any)["post"].(map[string]any)
+	body := pingOp["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	param := body["properties"].(map[string]any)["params"].(map[string]any)["prefixItems"].([]any)[0].(map[string]any)
+	if param["type"] != "integer" {
+		t.Fatalf("Long should map to integer, got %v", param)
+	}
+
+	perNode := fetchRpcSchemaForTest(t, h, "format=jsonschema&mode=node")
+	if !perNode.Success || len(perNode.Data.Documents) != 2 {
+		t.Fatalf("unexpected node response: %#v", perNode)
+	}
+	if perNode.Data.Documents[0].Name != "gate-1" {
+		t.Fatalf("documents should be sorted by routeId, got %s", perNode.Data.Documents[0].Name)
+	}
+	defs := perNode.Data.Documents[1].Document["$defs"].(map[string]any)
+	if _, ok := defs["200"]; !ok {
+		t.Fatalf("gate-2 should include packetId 200: %v", defs)
+	}
+
+	bad := fetchRpcSchemaForTest(t, h, "format=yaml")
+	if bad.Success {
+		t.Fatalf("unsupported format should fail")
+	}
+}
@@ -1,6 +1,7 @@
 package virtual_router_server_test
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -10,6 +11,9 @@ import (
 
 func TestServerRouterRPCStats_RankingAndKeyword(t *testing.T) {
 	s := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	for _, routeId := range []string{"alpha", "beta", "gamma"} {
+		upsertDrainedSession(t, s.SessionManager(), routeId, 0)
+	}
 
 	for i := 0; i < 5; i++ {
 		s.RecordRouterRPCForTest("alpha", "beta")
@@ -42,3 +46,30 @@ func TestServerRouterRPCStats_RankingAndKeyword(t *testing.T) {
 		t.Fatalf("expected pruned perMinute=0, got %d", filtered[0].PerMinute)
 	}
 }
+
+func TestServerRouterRPCStats_OnlyRegisteredRoutersKeepSeries(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	s := server.NewServer(cfg)
+	h := server.NewHttpServer(cfg, s)
+	upsertDrainedSession(t, s.SessionManager(), "alpha", 0)
+
+	// ToRouteId 由节点随意填写，不存在的节点不产生统计
+	for i := 0; i < 100; i++ {
+		s.RecordRouterRPCForTest("alpha", "ghost-"+strconv.Itoa(i))
+	}
+	if list := s.RouterRPCStats("", 200); len(list) != 1 || list[0].RouterID != "alpha" || list[0].PerMinute != 100 {
+		t.Fatalf("only registered routers should be ranked: %+v", list)
+	}
+	if _, hist := queryMetricsHistory(t, h, "routeId=ghost-1&window=60"); sumPoints(hist.Series[server.MetricRpc]) != 0 {
+		t.Fatal("unregistered router should have no series")
+	}
+
+	// 会话移除后删除节点序列
+	s.SessionManager().RemoveSession("alpha")
+	if _, hist := queryMetricsHistory(t, h, "routeId=alpha&window=60"); sumPoints(hist.Series[server.MetricRpc]) != 0 {
+		t.Fatal("series should be removed with the session")
+	}
+	if _, hist := queryMetricsHistory(t, h, "window=60"); sumPoints(hist.Series[server.MetricRpc]) != 100 {
+		t.Fatal("global rpc series should keep every call")
+	}
+}
@@ -0,0 +1,170 @@
+package virtual_router_server_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// upsertDrainedSession 注册一个会话，对端持续读取，避免移除通知阻塞
+func upsertDrainedSession(t *testing.T, m *server.RouterSessionManager, routeId string, heartbeatSecond int64) *server.RouterSession {
+	t.Helper()
+	conn, peer := net.Pipe()
+	go func() { _, _ = io.Copy(io.Discard, peer) }()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = peer.Close()
+	})
+	session := server.NewRouterSession(routeId, conn, core.RpcServerInfo{HeartBeatIntervalSecond: heartbeatSecond}, &sync.Mutex{})
+	if _, err := m.UpsertSession(routeId, session); err != nil {
+		t.Fatalf("upsert %s error: %v", routeId, err)
+	}
+	return session
+}
+
+func TestHeartbeatSweep_SuspectThenRemoveByNegotiatedInterval(t *testing.T) {
+	m := server.NewRouterSessionManager()
+	defer m.Stop()
+	m.SetHeartbeatPolicy(server.HeartbeatPolicy{SessionTimeout: 30 * time.Second, SweepInterval: time.Second, MissLimit: 3})
+
+	fast := upsertDrainedSession(t, m, "fast", 1)
+	legacy := upsertDrainedSession(t, m, "legacy", 0)
+	now := time.Now()
+
+	// 上报 1 秒心跳的节点：错过 2 次疑似下线，错过 3 次移除
+	m.CheckHeartbeatForTest(now.Add(2500 * time.Millisecond))
+	if !fast.IsSuspect() || legacy.IsSuspect() {
+		t.Fatalf("unexpected suspect state: fast=%v legacy=%v", fast.IsSuspect(), legacy.IsSuspect())
+	}
+	m.CheckHeartbeatForTest(now.Add(3500 * time.Millisecond))
+	if m.GetSession("fast") != nil || fast.IsActive() {
+		t.Fatal("fast node should be removed after missing 3 heartbeats")
+	}
+
+	// 未上报间隔的旧节点按 sessionTimeout 计算
+	m.CheckHeartbeatForTest(now.Add(21 * time.Second))
+	if !legacy.IsSuspect() || m.GetSession("legacy") == nil {
+		t.Fatal("legacy node should be suspect but kept")
+	}
+	m.RefreshSession("legacy")
+	if legacy.IsSuspect() {
+		t.Fatal("heartbeat should clear suspect state")
+	}
+	m.CheckHeartbeatForTest(time.Now().Add(31 * time.Second))
+	if m.GetSession("legacy") != nil {
+		t.Fatal("legacy node should be removed after sessionTimeout")
+	}
+}
+
+func TestHeartbeatSweep_ReportedIntervalIsCappedBySessionTimeout(t *testing.T) {
+	m := server.NewRouterSessionManager()
+	defer m.Stop()
+	m.SetHeartbeatPolicy(server.HeartbeatPolicy{SessionTimeout: 30 * time.Second, SweepInterval: time.Second, MissLimit: 3})
+
+	// 节点声称 1 小时才发一次心跳，超时仍不超过 sessionTimeout
+	upsertDrainedSession(t, m, "lazy", 3600)
+	m.CheckHeartbeatForTest(time.Now().Add(31 * time.Second))
+	if m.GetSession("lazy") != nil {
+		t.Fatal("reported interval must not extend the timeout beyond sessionTimeout")
+	}
+}
+
+func TestHeartbeatSweep_SuspectNodesLeaveRouteTable(t *testing.T) {
+	m := server.NewRouterSessionManager()
+	defer m.Stop()
+	m.SetHeartbeatPolicy(server.HeartbeatPolicy{SessionTimeout: 30 * time.Second, SweepInterval: time.Second, MissLimit: 3})
+	upsertDrainedSession(t, m, "fast", 1)
+	upsertDrainedSession(t, m, "legacy", 0)
+	routeIds := func() map[string]bool {
+		out := map[string]bool{}
+		for _, n := range m.GetAllRouteNodeList() {
+			out[n.RouterId] = true
+		}
+		return out
+	}
+
+	version := m.RouteVersion()
+	m.CheckHeartbeatForTest(time.Now().Add(2500 * time.Millisecond))
+	if ids := routeIds(); ids["fast"] || !ids["legacy"] {
+		t.Fatalf("suspect node should be withdrawn from the route table: %v", ids)
+	}
+	delta := m.RouteDeltaSince(version)
+	if len(delta.Removes) != 1 || delta.Removes[0] != "fast" {
+		t.Fatalf("suspect node should be pushed as removed: %+v", delta)
+	}
+	if m.GetSession("fast") == nil {
+		t.Fatal("suspect session should be kept until timeout")
+	}
+
+	version = m.RouteVersion()
+	m.RefreshSession("fast")
+	if !routeIds()["fast"] {
+		t.Fatal("recovered node should rejoin the route table")
+	}
+	if delta := m.RouteDeltaSince(version); len(delta.Upserts) != 1 || delta.Upserts[0].RouterId != "fast" {
+		t.Fatalf("recovered node should be pushed as upsert: %+v", delta)
+	}
+}
+
+func TestHeartbeatSweep_MessagesAreNotForwardedToSuspectNodes(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	a := registeredNode(t, srv, "a")
+	b := registeredNode(t, srv, "b")
+	for b.next(core.RouteMessageTypeHeartBeat, 100*time.Millisecond) != nil {
+	}
+
+	srv.SessionManager().CheckHeartbeatForTest(time.Now().Add(25 * time.Second))
+	if !srv.SessionManager().GetSession("b").IsSuspect() {
+		t.Fatal("b should be suspect")
+	}
+	a.send("b", 1)
+	if got := len(b.countMessages(core.RouteMessageTypeMessageData, 200*time.Millisecond)); got != 0 {
+		t.Fatalf("suspect node should not receive messages, got %d", got)
+	}
+	if _, err := srv.SendDebugRpc("b", 1, nil, "admin", ""); err == nil || !strings.Contains(err.Error(), "疑似下线") {
+		t.Fatalf("debug rpc to a suspect node should fail: %v", err)
+	}
+
+	// 心跳恢复后重新转发
+	b.heartbeat(core.RpcServerInfo{})
+	waitFor(t, func() bool { return !srv.SessionManager().GetSession("b").IsSuspect() })
+	a.send("b", 1)
+	if got := len(b.countMessages(core.RouteMessageTypeMessageData, 200*time.Millisecond)); got != 1 {
+		t.Fatalf("recovered node should receive messages again, got %d", got)
+	}
+}
+
+func TestHeartbeatSweep_LoopIsStoppable(t *testing.T) {
+	m := server.NewRouterSessionManager()
+	m.SetHeartbeatPolicy(server.HeartbeatPolicy{SessionTimeout: 150 * time.Millisecond, SweepInterval: 10 * time.Millisecond, MissLimit: 3})
+	upsertDrainedSession(t, m, "dead", 0)
+
+	done := make(chan struct{})
+	go func() {
+		m.RunHeartbeatSweep(context.Background())
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for m.GetSession("dead") != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m.GetSession("dead") != nil {
+		t.Fatal("dead node should be removed by the sweep loop")
+	}
+
+	m.Stop()
+	m.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweep loop should exit after Stop")
+	}
+}
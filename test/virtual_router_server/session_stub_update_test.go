@@ -0,0 +1,37 @@
+package virtual_router_server_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func TestUpsertSession_HeartbeatRefreshesStubList(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	writeMu := &sync.Mutex{}
+	first := server.NewRouterSession("node", conn, core.RpcServerInfo{Stubs: []core.RpcStubMetadata{{PacketId: 1}, {PacketId: 2}}}, writeMu)
+	if _, err := srv.SessionManager().UpsertSession("node", first); err != nil {
+		t.Fatalf("upsert error: %v", err)
+	}
+
+	// 同一连接的下一次心跳：节点已注销一个服务，中心应以最新 Stub 列表为准。
+	next := server.NewRouterSession("node", conn, core.RpcServerInfo{Stubs: []core.RpcStubMetadata{{PacketId: 1}}}, writeMu)
+	session, err := srv.SessionManager().UpsertSession("node", next)
+	if err != nil {
+		t.Fatalf("upsert error: %v", err)
+	}
+	if session != first {
+		t.Fatal("expected existing session to be reused")
+	}
+	if n := len(session.GetRpcServerInfo().Stubs); n != 1 {
+		t.Fatalf("expected 1 stub after heartbeat, got %d", n)
+	}
+}
@@ -0,0 +1,150 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// timelineTypes 时间线事件类型，按发生顺序
+func timelineTypes(srv *server.Server, routeId string) []string {
+	events := srv.SessionManager().SessionEvents(routeId, 0)
+	out := make([]string, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		out = append(out, events[i].Type)
+	}
+	return out
+}
+
+func TestSessionTimeline_RecordsLifecycleAndReasons(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+
+	first := connectFakeNodeFrom(t, srv, "gate", "10.0.0.1:5000")
+	first.heartbeat(core.RpcServerInfo{Host: "10.0.0.1", Port: 9000})
+	waitFor(t, func() bool { return srv.SessionManager().GetSession("gate") != nil })
+
+	// 重复的 routeId 被拒绝，记录在已注册节点的时间线上
+	dup := connectFakeNodeFrom(t, srv, "gate", "10.0.0.2:5000")
+	dup.heartbeat(core.RpcServerInfo{})
+	if dup.next(core.RouteMessageTypeSystemError, time.Second) == nil {
+		t.Fatal("duplicate route id should be rejected")
+	}
+	waitFor(t, func() bool { return len(timelineTypes(srv, "gate")) == 3 })
+
+	first.heartbeat(core.RpcServerInfo{Host: "10.0.0.1", Port: 9001, Stubs: []core.RpcStubMetadata{{PacketId: 1, MethodName: "login"}}})
+	waitFor(t, func() bool { return len(timelineTypes(srv, "gate")) == 5 })
+	// 收完心跳回复再关闭，避免关闭原因变为发送失败
+	for first.next(core.RouteMessageTypeHeartBeat, 200*time.Millisecond) != nil {
+	}
+	_ = first.conn.Close()
+	waitFor(t, func() bool { return len(timelineTypes(srv, "gate")) == 6 })
+
+	want := []string{
+		server.SessionEventConnect, server.SessionEventRegister,
+		server.SessionEventRejected,
+		server.SessionEventStubsChanged, server.SessionEventEndpointChanged,
+		server.SessionEventRemoved,
+	}
+	if got := timelineTypes(srv, "gate"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected timeline:\n got %v\nwant %v", got, want)
+	}
+	events := srv.SessionManager().SessionEvents("gate", 0)
+	if events[0].Detail != server.ConnClosePeerClosed || events[0].RemoteAddr != "10.0.0.1:5000" {
+		t.Fatalf("removal should carry the close reason: %+v", events[0])
+	}
+	if events[1].Detail != "10.0.0.1:9000 -> 10.0.0.1:9001" {
+		t.Fatalf("unexpected endpoint change detail: %+v", events[1])
+	}
+	if events[3].Type != server.SessionEventRejected || events[3].RemoteAddr != "10.0.0.2:5000" {
+		t.Fatalf("unexpected rejected event: %+v", events[3])
+	}
+
+	// 心跳超时移除的会话记录超时原因，连接记录同样使用该原因
+	registeredNode(t, srv, "slow")
+	srv.SessionManager().CheckHeartbeatForTest(time.Now().Add(time.Hour))
+	waitFor(t, func() bool {
+		events := srv.SessionManager().SessionEvents("slow", 1)
+		return len(events) == 1 && events[0].Type == server.SessionEventRemoved && events[0].Detail == server.ConnCloseHeartbeatTimeout
+	})
+	waitFor(t, func() bool {
+		r := latestConnRecord(srv)
+		return r.RouteId == "slow" && r.Reason == server.ConnCloseHeartbeatTimeout
+	})
+
+	h := server.NewHttpServer(cfg, srv)
+	rr := httptest.NewRecorder()
+	h.HandleConnectionsForTest(rr, httptest.NewRequest(http.MethodGet, "/api/connections?routeId=gate&limit=2", nil))
+	var resp struct {
+		Data struct {
+			RouteId       string                       `json:"routeId"`
+			Timeline      []server.SessionEvent        `json:"timeline"`
+			TimelineNodes []server.SessionEventSummary `json:"timelineNodes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Data.RouteId != "gate" || len(resp.Data.Timeline) != 2 || resp.Data.Timeline[0].Type != server.SessionEventRemoved {
+		t.Fatalf("unexpected timeline response: %s", rr.Body.String())
+	}
+	if len(resp.Data.TimelineNodes) != 2 {
+		t.Fatalf("unexpected timeline nodes: %+v", resp.Data.TimelineNodes)
+	}
+	for _, n := range resp.Data.TimelineNodes {
+		if n.IsOnline || n.Last.Type != server.SessionEventRemoved {
+			t.Fatalf("removed nodes should be listed offline: %+v", n)
+		}
+	}
+}
+
+func TestSessionTimeline_UnregisteredRouteIdsDoNotCreateTimelines(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	registeredNode(t, srv, "real")
+
+	// 未注册的连接不断更换 FromRouteId，不会产生时间线，也就挤不掉已注册节点的记录
+	rotating := connectFakeNode(t, srv, "fake-0")
+	for i := 0; i < 50; i++ {
+		rotating.routeId = "fake-" + strconv.Itoa(i)
+		rotating.send("real", 1)
+	}
+	_ = rotating.conn.Close()
+	waitFor(t, func() bool { return latestConnRecord(srv).RouteId == "fake-49" })
+
+	summaries := srv.SessionManager().SessionEventSummaries()
+	if len(summaries) != 1 || summaries[0].RouteId != "real" {
+		t.Fatalf("only registered nodes should have timelines: %+v", summaries)
+	}
+	if got := timelineTypes(srv, "real"); strings.Join(got, ",") != server.SessionEventConnect+","+server.SessionEventRegister {
+		t.Fatalf("connect should be recorded once at registration: %v", got)
+	}
+}
+
+func TestSessionTimeline_EvictsLeastRecentlyUpdatedRoute(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2})
+	m := srv.SessionManager()
+	for i := 0; i < 1000; i++ {
+		m.RecordSessionEvent(server.SessionEvent{RouteId: "node-" + strconv.Itoa(i), Type: server.SessionEventRegister})
+	}
+	// node-0 最近又有事件，淘汰的是 node-1
+	m.RecordSessionEvent(server.SessionEvent{RouteId: "node-0", Type: server.SessionEventRemoved})
+	m.RecordSessionEvent(server.SessionEvent{RouteId: "node-1000", Type: server.SessionEventRegister})
+
+	if len(m.SessionEvents("node-1", 0)) != 0 {
+		t.Fatal("least recently updated route should be evicted")
+	}
+	if len(m.SessionEvents("node-0", 0)) != 2 || len(m.SessionEvents("node-1000", 0)) != 1 {
+		t.Fatal("recently updated routes should be kept")
+	}
+	if n := len(m.SessionEventSummaries()); n != 1000 {
+		t.Fatalf("expected 1000 timelines, got %d", n)
+	}
+}
@@ -0,0 +1,74 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+func upsertStubSession(t *testing.T, srv *server.Server, routeId string, stubs ...core.RpcStubMetadata) {
+	t.Helper()
+	conn, peer := net.Pipe()
+	// 持续读取对端，避免下线广播写 net.Pipe 时阻塞。
+	go func() { _, _ = io.Copy(io.Discard, peer) }()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = peer.Close()
+	})
+	session := server.NewRouterSession(routeId, conn, core.RpcServerInfo{Stubs: stubs}, &sync.Mutex{})
+	if _, err := srv.SessionManager().UpsertSession(routeId, session); err != nil {
+		t.Fatalf("upsert %s error: %v", routeId, err)
+	}
+}
+
+func TestStubConflicts_DetectsMismatchedSignatures(t *testing.T) {
+	cfg := &config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2}
+	srv := server.NewServer(cfg)
+
+	login := core.RpcStubMetadata{PacketId: 100, ClassName: "Login", MethodName: "Login", ParameterTypes: []string{"string"}}
+	loginV2 := core.RpcStubMetadata{PacketId: 100, ClassName: "Login", MethodName: "Login", ParameterTypes: []string{"string", "int"}}
+	ping := core.RpcStubMetadata{PacketId: 200, ClassName: "A", MethodName: "Ping"}
+	pingOtherClass := core.RpcStubMetadata{PacketId: 200, ClassName: "B", MethodName: "Ping"}
+
+	upsertStubSession(t, srv, "gate-1", login, ping)
+	upsertStubSession(t, srv, "gate-2", loginV2, pingOtherClass)
+
+	// 参数类型不同视为冲突；仅 ClassName 不同不算冲突。
+	conflicts := srv.SessionManager().StubConflicts()
+	if len(conflicts) != 1 || conflicts[0].PacketId != 100 {
+		t.Fatalf("expected only packetId 100 conflict, got %#v", conflicts)
+	}
+	if len(conflicts[0].Signatures) != 2 {
+		t.Fatalf("expected 2 signatures, got %#v", conflicts[0].Signatures)
+	}
+
+	h := server.NewHttpServer(cfg, srv)
+	rr := httptest.NewRecorder()
+	h.HandleRpcConflictsForTest(rr, httptest.NewRequest(http.MethodGet, "/api/rpc/conflicts", nil))
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Total int `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response error: %v", err)
+	}
+	if !resp.Success || resp.Data.Total != 1 {
+		t.Fatalf("unexpected conflict report: %s", rr.Body.String())
+	}
+
+	// 冲突节点下线后冲突消失。
+	srv.SessionManager().RemoveSession("gate-2")
+	if n := len(srv.SessionManager().StubConflicts()); n != 0 {
+		t.Fatalf("expected no conflicts after removal, got %d", n)
+	}
+}
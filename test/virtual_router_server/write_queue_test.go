@@ -0,0 +1,161 @@
+package virtual_router_server_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	server "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+	"github.com/neko233-com/virtual-router-go/internal/config"
+	"github.com/neko233-com/virtual-router-go/internal/core"
+)
+
+// connectSilentNode 登记一个从不读取的节点，模拟跟不上的慢消费者
+func connectSilentNode(t *testing.T, srv *server.Server, routeId string) net.Conn {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { _ = conn.Close() })
+	srv.ServeConnForTest(peer)
+	data, _ := json.Marshal(core.RpcServerInfo{})
+	dataStr := string(data)
+	mt := core.RouteMessageTypeHeartBeat
+	payload, _ := (&core.RouteMessage{FromRouteId: routeId, MessageType: &mt, Data: &dataStr}).EncodePayload()
+	if _, err := conn.Write(core.EncodeFrame(payload)); err != nil {
+		t.Fatalf("%s heartbeat write error: %v", routeId, err)
+	}
+	waitFor(t, func() bool { return srv.SessionManager().GetSession(routeId) != nil })
+	return conn
+}
+
+func (n *fakeNode) send(to string, count int) {
+	n.t.Helper()
+	mt := core.RouteMessageTypeMessageData
+	data := "payload"
+	payload, _ := (&core.RouteMessage{FromRouteId: n.routeId, ToRouteId: to, MessageType: &mt, Data: &data}).EncodePayload()
+	frame := core.EncodeFrame(payload)
+	for i := 0; i < count; i++ {
+		if _, err := n.conn.Write(frame); err != nil {
+			n.t.Fatalf("%s send error: %v", n.routeId, err)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func writeQueueOf(srv *server.Server, routeId string) server.WriteQueueStats {
+	for _, snap := range srv.SessionManager().GetAllSessionSnapshots() {
+		if snap.RouterId == routeId {
+			return snap.WriteQueue
+		}
+	}
+	return server.WriteQueueStats{}
+}
+
+func TestWriteQueue_SlowConsumerDropsWithoutBlockingSenders(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2,
+		SessionWriteQueueMaxMessages: 4, SessionWriteTimeoutMs: 60000})
+	connectSilentNode(t, srv, "slow")
+	a := connectFakeNode(t, srv, "game-a")
+	a.heartbeat(core.RpcServerInfo{})
+
+	// 发送方的读取 goroutine 不会被慢节点卡住
+	done := make(chan struct{})
+	go func() {
+		a.send("slow", 20)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("sender should not block on a slow consumer")
+	}
+	waitFor(t, func() bool { return writeQueueOf(srv, "slow").Dropped > 0 })
+	waitFor(t, func() bool {
+		stats := writeQueueOf(srv, "slow")
+		// 登记响应、game-a 加入的推送与 20 条消息中只有 4 条留在队列
+		return stats.Depth == 4 && stats.Dropped == 18
+	})
+	stats := writeQueueOf(srv, "slow")
+	if stats.HighWater != 4 || stats.Bytes <= 0 {
+		t.Fatalf("unexpected queue stats: %+v", stats)
+	}
+	if srv.SessionManager().GetSession("slow") == nil {
+		t.Fatal("drop policy should keep the session")
+	}
+	if got := writeQueueOf(srv, "game-a"); got.Dropped != 0 || got.Sent == 0 {
+		t.Fatalf("healthy node queue should drain: %+v", got)
+	}
+}
+
+func TestWriteQueue_DisconnectPolicyAndWriteTimeout(t *testing.T) {
+	srv := server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2,
+		SessionWriteQueueMaxMessages: 2, SessionWriteTimeoutMs: 60000, SessionWriteOverflowPolicy: server.WriteOverflowDisconnect})
+	connectSilentNode(t, srv, "slow")
+	a := connectFakeNode(t, srv, "game-a")
+	a.heartbeat(core.RpcServerInfo{})
+	a.send("slow", 5)
+	waitFor(t, func() bool { return srv.SessionManager().GetSession("slow") == nil })
+	if msg := a.next(core.RouteMessageTypeRemoveRouteNode, time.Second); msg == nil || *msg.Data != `["slow"]` {
+		t.Fatal("disconnected node should be removed from the route table")
+	}
+
+	// 写超时同样断开连接
+	srv = server.NewServer(&config.RouterServerConfig{RouterServerPort: 1, HTTPMonitorPort: 2, SessionWriteTimeoutMs: 50})
+	connectSilentNode(t, srv, "stuck")
+	waitFor(t, func() bool { return srv.SessionManager().GetSession("stuck") == nil })
+}
+
+// blockingConn 的 Write 阻塞到 release 后才成功返回，Close 不会打断正在进行的写出
+type blockingConn struct {
+	net.Conn
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingConn) Write(p []byte) (int, error) {
+	c.writing <- struct{}{}
+	<-c.release
+	return len(p), nil
+}
+
+func (c *blockingConn) Close() error                     { return nil }
+func (c *blockingConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *blockingConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+
+func TestWriteQueue_CloseDuringInFlightWrite(t *testing.T) {
+	_, peer := net.Pipe()
+	conn := &blockingConn{Conn: peer, writing: make(chan struct{}), release: make(chan struct{})}
+	q := server.NewWriteQueueForTest(conn)
+	if err := q.Enqueue([]byte("frame-1")); err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Enqueue([]byte("frame-2"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run()
+	}()
+	<-conn.writing
+	q.Close()
+	close(conn.release)
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("writer should exit after the queue is closed")
+	}
+	if st := q.Stats(); st.Depth != 0 || st.Bytes != 0 {
+		t.Fatalf("closed queue should stay empty: %+v", st)
+	}
+}
@@ -30,6 +30,15 @@ if (-not (Test-Path $devopsScriptFile)) {
     throw "运维脚本不存在: $devopsScriptFile"
 }
 
+$version = (Get-Content (Join-Path $root "version.txt") -Raw).Trim()
+$commit = (git rev-parse --short HEAD 2>$null)
+if (-not $commit) {
+    $commit = "unknown"
+}
+$buildTime = (Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
+$pkg = "github.com/neko233-com/virtual-router-go/internal/VirtualRouterServer"
+$ldflags = "-s -w -X $pkg.buildVersion=$version -X $pkg.buildCommit=$commit -X $pkg.buildTime=$buildTime"
+
 $env:CGO_ENABLED = "0"
 
 foreach ($t in $targets) {
@@ -49,7 +58,7 @@ foreach ($t in $targets) {
     $env:GOARCH = $arch
 
     Write-Host "Building $($serverBuild.name) for $os/$arch ..."
-    go build -trimpath -ldflags "-s -w" -o $outFile $serverBuild.path
+    go build -trimpath -ldflags $ldflags -o $outFile $serverBuild.path
 
     Copy-Item -Path $serverConfigFile -Destination (Join-Path $outDir "neko233-router-server.json") -Force
     if ($os -eq "linux" -or $os -eq "darwin") {